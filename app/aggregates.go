@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"effective_mobile/internal/repository"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// effective-mobile aggregates rebuild [flags]
+// Пересчет monthly_spend по таблице подписок, например после ручной правки подписок в базе
+func runAggregates(args []string) {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		fmt.Fprintf(os.Stderr, "aggregates: command is required\n\n%s\n", usage)
+		os.Exit(2)
+	}
+	command, args := args[0], args[1:]
+	if command != "rebuild" {
+		fmt.Fprintf(os.Stderr, "aggregates: unknown command %q\n\n%s\n", command, usage)
+		os.Exit(2)
+	}
+
+	logger := newStartupLogger()
+	conf := loadConfig(logger, args).Current()
+	db, err := connectDB(logger, conf)
+	if err != nil {
+		logger.Fatal("Failed to connect to database", "error", err)
+	}
+
+	started := time.Now()
+	rows, err := repository.RebuildMonthlySpend(context.Background(), db)
+	if err != nil {
+		logger.Fatal("Failed to rebuild monthly spend", "error", err)
+	}
+	logger.Info("Monthly spend rebuilt", "rows", rows, "duration", time.Since(started))
+}
@@ -4,74 +4,383 @@ import (
 	"context"
 	_ "effective_mobile/docs"
 	"effective_mobile/internal/api"
+	"effective_mobile/internal/auth"
 	"effective_mobile/internal/config"
+	"effective_mobile/internal/events"
+	"effective_mobile/internal/health"
+	"effective_mobile/internal/metrics"
+	"effective_mobile/internal/notifications"
+	"effective_mobile/internal/ratelimit"
 	"effective_mobile/internal/repository"
+	"effective_mobile/internal/scheduler"
 	"effective_mobile/internal/service"
+	"effective_mobile/internal/stream"
+	"effective_mobile/internal/tenant"
+	"effective_mobile/internal/tracing"
+	"effective_mobile/internal/webhooks"
 	"effective_mobile/pkg/logger_module"
+	"errors"
+	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/gorilla/mux"
-	"github.com/pressly/goose"
+	"github.com/pressly/goose/v3"
+	"github.com/spf13/pflag"
 	httpSwagger "github.com/swaggo/http-swagger"
 	"gorm.io/gorm"
 )
 
+const usage = `Usage:
+  effective-mobile [serve] [flags]        запустить HTTP-сервер (по умолчанию)
+  effective-mobile migrate <command>      управлять миграциями базы:
+      up                                  применить все новые миграции
+      down                                откатить последнюю миграцию
+      redo                                откатить и заново применить последнюю миграцию
+      status                              показать примененные и ожидающие миграции
+      create <name> [--dir migrations]    создать файл новой миграции (для SQLite --dir migrations/sqlite)
+  effective-mobile aggregates rebuild     пересчитать агрегат monthly_spend по таблице подписок
+
+Флаги конфига (--config, --db.host и другие) принимают serve, migrate и aggregates, список: effective-mobile --help`
+
 func main() {
-	// 1. Инициализация логгера записывает в файл и в stdout
-	log_file, err := os.OpenFile("app.log", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
-	if err != nil {
-		log.Fatal("Failed open log file", err)
+	args := os.Args[1:]
+	command := "serve"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
 	}
-	defer log_file.Close()
 
-	multi_writer := io.MultiWriter(log_file, os.Stdout)
+	switch command {
+	case "serve":
+		serve(args)
+	case "migrate":
+		runMigrate(args)
+	case "aggregates":
+		runAggregates(args)
+	case "help":
+		fmt.Println(usage)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s\n", command, usage)
+		os.Exit(2)
+	}
+}
 
-	logger := logger_module.New(multi_writer, "[APP]", log.LstdFlags|log.Lshortfile)
+// Логгер до чтения конфига: только stdout с настройками по умолчанию
+func newStartupLogger() *logger_module.Logger {
+	logger, err := logger_module.New(os.Stdout, logger_module.Options{AddSource: true})
+	if err != nil {
+		log.Fatal("Failed to create logger", err)
+	}
+	logger_module.SetDefault(logger)
+	return logger
+}
 
-	logger.Info("Logger starter")
-	// 2. Загрузка конфигурации
-	conf, err := config.Load_Config_PG(logger)
+// Загрузка конфигурации: файл, переменные окружения и флаги. При ошибках проверки
+// выводим все проблемы и завершаемся, при --help — просто завершаемся
+func loadConfig(logger *logger_module.Logger, args []string) *config.Provider {
+	provider, err := config.NewProvider(logger, args)
+	if errors.Is(err, pflag.ErrHelp) {
+		os.Exit(0)
+	}
+	if validationErr, ok := config.IsValidationError(err); ok {
+		for _, problem := range validationErr.Problems {
+			logger.Error("Invalid config", "problem", problem)
+		}
+		logger.Fatal("Failed to load config", "problems", len(validationErr.Problems))
+	}
 	if err != nil {
 		logger.Fatal("Failed to load config", "error", err)
 	}
+	return provider
+}
+
+func serve(args []string) {
+	// 1. Пока конфиг не прочитан, пишем только в stdout с настройками по умолчанию
+	logger := newStartupLogger()
 
-	// 3. Подключение к PostgreSQL
-	db, err := repository.NewConnectPostgresDB(logger, conf)
+	// 2. Загрузка конфигурации: файл, переменные окружения и флаги
+	provider := loadConfig(logger, args)
+	// Конфиг на момент старта; ключи, которые меняются на лету, читаются через provider
+	conf := provider.Current()
+
+	// Логгер из конфига пишет в файл с ротацией и в stdout
+	log_file, err := logger_module.NewRotatingFile(conf.Logging.File, logger_module.RotateOptions{
+		MaxSize:    conf.Logging.MaxSizeMB * 1024 * 1024,
+		MaxAge:     conf.Logging.MaxAge,
+		MaxBackups: conf.Logging.MaxBackups,
+		Compress:   conf.Logging.Compress,
+	})
 	if err != nil {
-		logger.Fatal("Failed to connect to database", "error", err)
+		logger.Fatal("Failed to open log file", "error", err, "path", conf.Logging.File)
 	}
+	defer log_file.Close()
 
-	// 4. Применение миграций
-	if err := applyMigrations(db); err != nil {
-		logger.Fatal("Failed to apply migrations", "error", err)
+	logLevel, err := logger_module.ParseLevel(conf.Logging.Level)
+	if err != nil {
+		logger.Fatal("Failed to configure logger", "error", err)
+	}
+	fileLogger, err := logger_module.New(io.MultiWriter(log_file, os.Stdout), logger_module.Options{Format: conf.Logging.Format, Level: logLevel, AddSource: true})
+	if err != nil {
+		logger.Fatal("Failed to configure logger", "error", err)
+	}
+	logger = fileLogger
+	logger_module.SetDefault(logger)
+	logger.Info("Logger started", "level", logLevel.String(), "format", conf.Logging.Format, "file", conf.Logging.File)
+
+	// Уровень логов меняется при правке файла конфига без перезапуска
+	provider.Subscribe(func(previous, current *config.Config_PG) {
+		if previous.Logging.Level == current.Logging.Level {
+			return
+		}
+		level, err := logger_module.ParseLevel(current.Logging.Level)
+		if err != nil {
+			logger.Error("Failed to change log level", "error", err)
+			return
+		}
+		logger.SetLevel(level)
+	})
+	provider.Watch(logger)
+
+	// По SIGHUP переоткрываем файл лога: так работает внешний logrotate (copytruncate не нужен)
+	reopenLog := make(chan os.Signal, 1)
+	signal.Notify(reopenLog, syscall.SIGHUP)
+	go func() {
+		for range reopenLog {
+			if err := log_file.Reopen(); err != nil {
+				logger.Error("Failed to reopen log file", "error", err, "path", conf.Logging.File)
+				continue
+			}
+			logger.Info("Log file reopened", "path", conf.Logging.File)
+		}
+	}()
+
+	// Трейсинг настраиваем до подключения к базе, чтобы SQL-спаны шли в настроенный экспортер
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Config{
+		Exporter:    conf.Tracing.Exporter,
+		ServiceName: conf.Tracing.ServiceName,
+		SampleRatio: conf.Tracing.SampleRatio,
+	})
+	if err != nil {
+		logger.Fatal("Failed to configure tracing", "error", err)
 	}
 
-	// 5. Инициализация слоёв приложения
-	gorm_repo := repository.NewGormRepo(db, logger)
-	subService := service.NewSubciptionService(gorm_repo, logger)
-	subHandler := api.NewSubciptionHandler(subService, logger)
+	// Фоновые обработчики работают с данными всех организаций, tenant_id берут из самих записей
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+	backgroundCtx := tenant.WithAllTenants(jobsCtx)
+
+	// 3. Подключение к базе и применение миграций. С STORAGE=sqlite подписки хранятся в файле,
+	// с STORAGE=memory — в памяти процесса. Все, что работает только с Postgres (outbox с NOTIFY,
+	// advisory-блокировки), в этих режимах выключено
+	var db *gorm.DB
+	var healthChecks *health.Health
+	var subscription_repo repository.SubsctriptionRepository
+	postgres := conf.Storage.Driver == config.StoragePostgres
+	if !postgres {
+		logger.Warn("Storage is not PostgreSQL, only subscriptions API is available", "storage", conf.Storage.Driver,
+			"disabled", "webhooks,notifications,api_keys,event_stream,scheduler,quotas")
+	}
+	if conf.Storage.Driver == config.StorageMemory {
+		logger.Warn("Storage is in memory, data is lost on restart")
+		healthChecks = health.New(2 * time.Second)
+		subscription_repo = repository.NewMemoryRepo()
+	} else {
+		db, healthChecks = connectDatabase(jobsCtx, conf, logger)
+		subscription_repo = repository.NewGormRepo(db, logger)
+	}
+	// Подписки через pgxpool с подготовленными запросами; остальные таблицы по-прежнему через GORM
+	if postgres && conf.DB.Repository == config.RepositoryPgx {
+		pool, err := repository.NewPgxPool(logger, conf)
+		if err != nil {
+			logger.Fatal("Failed to connect pgx pool", "error", err)
+		}
+		defer pool.Close()
+		healthChecks.Add("database_pgx", pool.Ping)
+		subscription_repo = repository.NewPgxRepo(pool, logger)
+		logger.Info("Subscriptions repository uses pgx")
+	}
+
+	// 4. Инициализация слоёв приложения
+	gorm_repo := repository.NewInstrumentedRepo(subscription_repo)
+
+	// Рассылка событий по вебхукам: изменения подписок пишутся в outbox репозиторием,
+	// события планировщика попадают туда через шину
+	var webhook_repo *repository.WebhookRepo
+	var dispatcher *webhooks.Dispatcher
+	var notifier events.Notifier
+	var webhookPolicy *webhooks.AddressPolicy
+	if postgres {
+		var err error
+		webhookPolicy, err = webhooks.NewAddressPolicy(conf.Webhooks.AllowedNetworks)
+		if err != nil {
+			logger.Fatal("Failed to configure webhook networks", "error", err)
+		}
+		webhook_repo = repository.NewWebhookRepo(db, logger)
+		dispatcher = webhooks.NewDispatcher(webhook_repo, webhookPolicy, conf.Webhooks.MaxAttempts, conf.Webhooks.PollInterval, logger)
+		dispatcher.Start(backgroundCtx)
+		notifier = dispatcher
+	}
+
+	// Суммы для дашбордов: сбрасываются при изменениях через эту реплику, изменения с других реплик
+	// становятся видны по истечении cache.ttl
+	var totalCache service.Cache
+	if conf.Cache.Enabled {
+		totalCache = service.NewLRUCache(conf.Cache.Size, conf.Cache.TTL)
+	}
+	subService := service.NewSubciptionService(gorm_repo, notifier, totalCache, logger)
+	handlers := []routeRegistrar{api.NewSubciptionHandler(subService, logger)}
+	var apiKeys auth.APIKeyStore
+	var hub *stream.Hub
+	var jobScheduler *scheduler.Scheduler
+	var quotaStore ratelimit.QuotaStore
+
+	if postgres {
+		outbox := repository.NewOutboxRepo(db, logger)
+		bus := events.NewBus(logger)
+		bus.Subscribe(func(ctx context.Context, event events.Event) error {
+			if err := outbox.Publish(ctx, event); err != nil {
+				return err
+			}
+			dispatcher.Notify()
+			return nil
+		})
+
+		webhookHandler := api.NewWebhookHandler(service.NewWebhookService(webhook_repo, webhookPolicy, logger), logger)
+		notification_repo := repository.NewNotificationRepo(db, logger)
+		notificationHandler := api.NewNotificationHandler(service.NewNotificationService(notification_repo, logger), logger)
+		apikey_repo := repository.NewAPIKeyRepo(db, logger)
+		apiKeys = apikey_repo
+		apiKeyHandler := api.NewAPIKeyHandler(service.NewAPIKeyService(apikey_repo, logger), logger)
+
+		// Поток событий для SSE: слушаем NOTIFY из outbox, так видны изменения с любой реплики
+		hub = stream.NewHub(repository.DSN(conf), outbox, logger)
+		hub.Start(backgroundCtx)
+		streamHandler := api.NewEventStreamHandler(hub, outbox, logger)
+		handlers = append(handlers, webhookHandler, notificationHandler, apiKeyHandler, streamHandler)
+
+		// 5. Фоновые задачи по жизненному циклу подписок и рассылка писем
+		if conf.Scheduler.Enabled {
+			locker, err := repository.NewAdvisoryLocker(db, "effective_mobile.scheduler", logger)
+			if err != nil {
+				logger.Fatal("Failed to create scheduler lock", "error", err)
+			}
+			lifecycle := service.NewLifecycleJobs(gorm_repo, bus, logger)
+			sender, err := newSender(conf)
+			if err != nil {
+				logger.Fatal("Failed to create notification sender", "error", err)
+			}
+			digests := notifications.NewDigests(notification_repo, subService, sender, logger)
+
+			jobScheduler = scheduler.New(locker, repository.NewJobRunRepo(db, logger), conf.Scheduler.RunHour, logger)
+			jobScheduler.Add(string(events.SubscriptionExpired), lifecycle.EmitExpired)
+			jobScheduler.Add(string(events.SubscriptionRenewalDue), lifecycle.EmitRenewalDue)
+			jobScheduler.Add(string(events.TrialEnding), lifecycle.EmitTrialEnding)
+			jobScheduler.Add("notifications."+notifications.KindMonthlySummary, digests.SendMonthlySummaries)
+			jobScheduler.Add("notifications."+notifications.KindRenewalReminders, digests.SendRenewalReminders)
+			retention := webhooks.NewRetention(webhook_repo, conf.Webhooks.EventRetention, conf.Webhooks.DeliveryRetention, logger)
+			jobScheduler.Add("webhooks.retention", retention.Run)
+			// Задачи обращаются к сервисам без пользователя, от имени системы
+			jobScheduler.Start(auth.NewContext(backgroundCtx, auth.System))
+		}
+		quotaStore = repository.NewQuotaRepo(db, logger)
+	}
+
+	// Аутентификация запросов к /api/: API-ключи из базы и JWT
+	var authMiddleware mux.MiddlewareFunc
+	if conf.Auth.Enabled {
+		verifier, err := auth.NewJWTVerifier(auth.JWTConfig{
+			HS256Secret:  conf.Auth.JWTHS256Secret,
+			RSAPublicKey: conf.Auth.JWTPublicKeyFile,
+			JWKSFile:     conf.Auth.JWTJWKSFile,
+			Issuer:       conf.Auth.JWTIssuer,
+			Audience:     conf.Auth.JWTAudience,
+		})
+		if err != nil {
+			logger.Fatal("Failed to load JWT keys", "error", err)
+		}
+		if verifier == nil {
+			logger.Info("No JWT keys configured, only API keys are accepted")
+		}
+		// Токены фидов заменяют ключи и JWT в URL потока событий и календаря
+		if conf.Auth.FeedTokenSecret == "" {
+			logger.Warn("FEED_TOKEN_SECRET is not set, feed tokens are valid only on this replica")
+		}
+		feedTokens, err := auth.NewFeedTokens(conf.Auth.FeedTokenSecret, conf.Auth.FeedTokenTTL)
+		if err != nil {
+			logger.Fatal("Failed to create feed token key", "error", err)
+		}
+		handlers = append(handlers, api.NewFeedTokenHandler(feedTokens, logger))
+		authMiddleware = api.NewAuthMiddleware(auth.NewAuthenticator(apiKeys, verifier, feedTokens), logger)
+	} else {
+		logger.Info("Authentication is disabled, API is public")
+		authMiddleware = api.NewStaticPrincipalMiddleware(auth.Anonymous)
+	}
 
 	// 6. Настройка роутера
 	router := mux.NewRouter()
-	CreateRoutes(router, subHandler)
+	middlewares := []mux.MiddlewareFunc{authMiddleware, api.NewTenantMiddleware(conf.Auth.DefaultTenant, logger)}
+	var quotas *ratelimit.Quotas
+	if conf.RateLimit.Enabled {
+		var err error
+		quotas, err = newQuotas(conf, quotaStore, logger)
+		if err != nil {
+			logger.Fatal("Failed to configure rate limits", "error", err)
+		}
+		if quotas != nil {
+			// Накопленные запросы записываются в базу и при остановке сервера
+			quotas.Start(jobsCtx)
+		}
+		ipRateLimitMiddleware, rateLimitMiddleware, err := newRateLimitMiddlewares(provider, quotas, logger)
+		if err != nil {
+			logger.Fatal("Failed to configure rate limits", "error", err)
+		}
+		// Адрес ограничиваем до проверки учетных данных, клиента — после
+		middlewares = append([]mux.MiddlewareFunc{ipRateLimitMiddleware}, middlewares...)
+		middlewares = append(middlewares, rateLimitMiddleware)
+	}
+	adminHandlers := []routeRegistrar{api.NewConfigHandler(provider, logger)}
+	CreateRoutes(router, logger, middlewares, adminHandlers, handlers...)
+	// Трейсинг и метрики идут после id запроса и журнала доступа, но снаружи восстановления
+	// после паники в /api/, поэтому видят ответ 500
+	router.Use(api.NewTracingMiddleware())
+	if conf.Metrics.Enabled {
+		if err := registerMetrics(db, gorm_repo); err != nil {
+			logger.Fatal("Failed to register metrics", "error", err)
+		}
+		router.Use(api.NewMetricsMiddleware())
+		router.Handle("/metrics", metrics.Handler()).Methods("GET")
+	}
+	api.NewHealthHandler(healthChecks, logger).RegisterRouter(router)
 
 	// 7. Настройка HTTP-сервера
+	// CORS снаружи роутера, иначе preflight OPTIONS получит 405. id запроса и журнал доступа
+	// еще снаружи: gorilla/mux вызывает router.Use только для найденных роутов, а 404 и 405
+	// тоже должны получить X-Request-ID и запись в журнале
+	var handler http.Handler = api.NewCORSMiddleware(func() []string {
+		return provider.Current().CORS.AllowedOrigins
+	})(router)
+	handler = api.NewAccessLogMiddleware(logger)(handler)
+	handler = api.NewRequestLoggerMiddleware(logger)(handler)
 	server := &http.Server{
-		Addr:         ":" + conf.Http_Port,
-		Handler:      router,
+		Addr:         ":" + conf.HTTP.Port,
+		Handler:      handler,
 		ReadTimeout:  10 * time.Second,
 		WriteTimeout: 10 * time.Second,
 	}
+	// Открытые SSE-потоки иначе не дадут серверу завершиться
+	if hub != nil {
+		server.RegisterOnShutdown(hub.Close)
+	}
 
 	// 8. Запускаем сервер в отдельной горутине чтобы не заблочить основной поток
 	go func() {
-		logger.Info("starting server", "port", conf.Http_Port)
+		logger.Info("starting server", "port", conf.HTTP.Port)
 		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			logger.Fatal("Server failed", "error", err)
 		}
@@ -80,7 +389,15 @@ func main() {
 	// Ожидание сигналов завершения
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
+	received := <-quit
+
+	// Сначала /readyz начинает отвечать 503, и только после паузы сервер перестает принимать
+	// соединения: балансировщик успевает снять трафик. При Ctrl+C локально не ждем
+	healthChecks.SetShuttingDown()
+	if received == syscall.SIGTERM && conf.HTTP.ShutdownDrainDelay > 0 {
+		logger.Info("Draining before shutdown", "delay", conf.HTTP.ShutdownDrainDelay)
+		time.Sleep(conf.HTTP.ShutdownDrainDelay)
+	}
 
 	// Завершение работы с таймаутом
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -90,32 +407,222 @@ func main() {
 		logger.Fatal("Server shutdown error", "error", err)
 	}
 
+	// Останавливаем планировщик (отпуская лидерство) и рассылку вебхуков
+	stopJobs()
+	if jobScheduler != nil {
+		jobScheduler.Wait()
+	}
+	if dispatcher != nil {
+		dispatcher.Wait()
+		hub.Wait()
+	}
+	if quotas != nil {
+		quotas.Wait()
+	}
+
+	// Дописываем накопленные спаны
+	if err := shutdownTracing(ctx); err != nil {
+		logger.Error("Failed to flush traces", "error", err)
+	}
+
 	logger.Info("Server stopped gracefully")
 }
 
-// applyMigrations применяет миграции к БД
-func applyMigrations(db *gorm.DB) error {
-	logger := logger_module.Get()
+// Подключение к базе из конфига: PostgreSQL или файл SQLite
+func connectDB(logger *logger_module.Logger, conf *config.Config_PG) (*gorm.DB, error) {
+	switch conf.Storage.Driver {
+	case config.StoragePostgres:
+		return repository.NewConnectPostgresDB(logger, conf)
+	case config.StorageSQLite:
+		return repository.NewConnectSQLiteDB(logger, conf)
+	default:
+		return nil, fmt.Errorf("storage %q has no database", conf.Storage.Driver)
+	}
+}
+
+// Подключение к базе, применение миграций и проверки готовности, которые от нее зависят
+func connectDatabase(ctx context.Context, conf *config.Config_PG, logger *logger_module.Logger) (*gorm.DB, *health.Health) {
+	db, err := connectDB(logger, conf)
+	if err != nil {
+		logger.Fatal("Failed to connect to database", "error", err)
+	}
+
+	// С --no-migrate миграции применяет отдельная задача (migrate up),
+	// а /readyz не пропускает трафик, пока есть непримененные миграции
+	migrator, err := repository.NewMigrator(db)
+	if err != nil {
+		logger.Fatal("Failed to load migrations", "error", err)
+	}
+	if conf.DB.NoMigrate {
+		logger.Info("Migrations on start are disabled")
+	} else if err := applyMigrations(ctx, migrator, logger); err != nil {
+		logger.Fatal("Failed to apply migrations", "error", err)
+	}
+
+	// Проверки для /readyz и /health
+	healthChecks, err := newHealthChecks(db, migrator)
+	if err != nil {
+		logger.Fatal("Failed to configure health checks", "error", err)
+	}
+
+	// Потеря и восстановление соединения с базой видны в логах и метриках
+	if err := repository.MonitorConnection(ctx, db, 10*time.Second, logger); err != nil {
+		logger.Fatal("Failed to monitor database connection", "error", err)
+	}
+	return db, healthChecks
+}
+
+// Готовность: база отвечает и применены все встроенные миграции
+func newHealthChecks(db *gorm.DB, migrator *goose.Provider) (*health.Health, error) {
 	sqlDB, err := db.DB()
 	if err != nil {
-		logger.Fatal("failed to get sql.DB: %w", err)
+		return nil, err
+	}
+
+	healthChecks := health.New(2 * time.Second)
+	healthChecks.Add("database", sqlDB.PingContext)
+	healthChecks.Add("migrations", func(ctx context.Context) error {
+		current, target, err := migrator.GetVersions(ctx)
+		if err != nil {
+			return err
+		}
+		if current < target {
+			return fmt.Errorf("database is at version %d, expected %d", current, target)
+		}
+		return nil
+	})
+	return healthChecks, nil
+}
+
+// Способ отправки писем из конфига: SMTP в проде, файл или stdout для локальной разработки
+func newSender(conf *config.Config_PG) (notifications.Sender, error) {
+	switch conf.Notify.Sender {
+	case "smtp":
+		if conf.Notify.SMTPHost == "" {
+			return nil, fmt.Errorf("SMTP_HOST is required for NOTIFY_SENDER=smtp")
+		}
+		return notifications.NewSMTPSender(conf.Notify.SMTPHost, conf.Notify.SMTPPort, conf.Notify.SMTPUsername, conf.Notify.SMTPPassword, conf.Notify.From), nil
+	case "file":
+		return notifications.NewFileSender(conf.Notify.FilePath, conf.Notify.From)
+	case "stdout", "":
+		return notifications.NewWriterSender(os.Stdout, conf.Notify.From), nil
+	default:
+		return nil, fmt.Errorf("unknown NOTIFY_SENDER %q", conf.Notify.Sender)
+	}
+}
+
+// Метрики пула соединений и доменные показатели, которые считаются при каждом сборе.
+// Без базы (STORAGE=memory) метрик пула нет
+func registerMetrics(db *gorm.DB, subscriptions repository.SubsctriptionRepository) error {
+	if db != nil {
+		sqlDB, err := db.DB()
+		if err != nil {
+			return err
+		}
+		if err := metrics.RegisterDBStats(sqlDB, db.Dialector.Name()); err != nil {
+			return err
+		}
+	}
+	return metrics.RegisterGauge("active_subscriptions", "Subscriptions active right now across all tenants.",
+		func(ctx context.Context) (float64, error) {
+			count, err := subscriptions.CountActive(tenant.WithAllTenants(ctx), time.Now())
+			return float64(count), err
+		})
+}
+
+// Суточные квоты из конфига; квоты выключены (nil), пока не заданы тарифы,
+// и без базы, где хранится расход (store == nil)
+func newQuotas(conf *config.Config_PG, store ratelimit.QuotaStore, logger *logger_module.Logger) (*ratelimit.Quotas, error) {
+	tiers, err := ratelimit.ParseTiers(conf.RateLimit.QuotaTiers)
+	if err != nil {
+		return nil, err
 	}
-	// Указываем dialect и папку с миграциями
-	goose.SetDialect("postgres")
-	if err := goose.Up(sqlDB, "migrations"); err != nil {
-		logger.Fatal("failed to apply migrations: %w", err)
+	if len(tiers) == 0 || store == nil {
+		return nil, nil
 	}
+	if _, ok := tiers[conf.RateLimit.QuotaDefaultTier]; conf.RateLimit.QuotaDefaultTier != "" && !ok {
+		return nil, fmt.Errorf("RATE_QUOTA_DEFAULT_TIER %q is not listed in RATE_QUOTA_TIERS", conf.RateLimit.QuotaDefaultTier)
+	}
+	return ratelimit.NewQuotas(store, tiers, conf.RateLimit.QuotaDefaultTier, conf.RateLimit.QuotaFlushInterval, logger), nil
+}
 
-	return nil
+// Ограничения частоты запросов из конфига и суточные квоты (quotas == nil — квоты выключены)
+// Ограничения частоты меняются при правке файла конфига без перезапуска
+func newRateLimitMiddlewares(provider *config.Provider, quotas *ratelimit.Quotas, logger *logger_module.Logger) (mux.MiddlewareFunc, mux.MiddlewareFunc, error) {
+	conf := provider.Current()
+	defaultRule, routes, err := parseRateLimits(conf)
+	if err != nil {
+		return nil, nil, err
+	}
+	ipRule, err := ratelimit.ParseRule(conf.RateLimit.IP)
+	if err != nil {
+		return nil, nil, err
+	}
 
+	limiter := ratelimit.NewLimiter(defaultRule, routes)
+	ipLimiter := ratelimit.NewLimiter(ipRule, nil)
+	provider.Subscribe(func(previous, current *config.Config_PG) {
+		if previous.RateLimit.IP != current.RateLimit.IP {
+			ipRule, err := ratelimit.ParseRule(current.RateLimit.IP)
+			if err != nil {
+				logger.Error("Failed to change rate limits", "error", err)
+			} else {
+				ipLimiter.SetRules(ipRule, nil)
+			}
+		}
+		if previous.RateLimit.Default == current.RateLimit.Default && previous.RateLimit.Routes == current.RateLimit.Routes {
+			return
+		}
+		defaultRule, routes, err := parseRateLimits(current)
+		if err != nil {
+			logger.Error("Failed to change rate limits", "error", err)
+			return
+		}
+		limiter.SetRules(defaultRule, routes)
+	})
+	return api.NewIPRateLimitMiddleware(ipLimiter, conf.RateLimit.TrustForwarded, logger),
+		api.NewRateLimitMiddleware(limiter, quotas, conf.RateLimit.TrustForwarded, logger), nil
 }
 
-// Регистрируем все HTTP-роуты
-func CreateRoutes(router *mux.Router, handler *api.SubscriptionHandler) {
+func parseRateLimits(conf *config.Config_PG) (ratelimit.Rule, map[string]ratelimit.Rule, error) {
+	defaultRule, err := ratelimit.ParseRule(conf.RateLimit.Default)
+	if err != nil {
+		return ratelimit.Rule{}, nil, err
+	}
+	routes, err := ratelimit.ParseRouteRules(conf.RateLimit.Routes)
+	if err != nil {
+		return ratelimit.Rule{}, nil, err
+	}
+	return defaultRule, routes, nil
+}
+
+// Обработчик, который сам регистрирует свои роуты
+type routeRegistrar interface {
+	RegisterRouter(router *mux.Router)
+}
+
+// Регистрируем все HTTP-роуты. Ко всем запросам применяются id запроса и журнал доступа,
+// к /api/ и /admin/ — восстановление после паники и затем middlewares по порядку
+func CreateRoutes(router *mux.Router, logger *logger_module.Logger, middlewares []mux.MiddlewareFunc, adminHandlers []routeRegistrar, handlers ...routeRegistrar) {
+	// id запроса и журнал доступа оборачивают весь роутер (см. serve), здесь только шаблон роута для журнала
+	router.Use(api.NewRouteRecorderMiddleware())
+
 	// Добавляем Swagger UI к роутеру
 	router.PathPrefix("/swagger/").Handler(httpSwagger.WrapHandler)
 
 	// Добавляем префикс для работы с endpoints
-	api := router.PathPrefix("/api/").Subrouter()
-	handler.RegisterRouter(api)
+	apiRouter := router.PathPrefix("/api/").Subrouter()
+	apiRouter.Use(api.NewRecoveryMiddleware(logger))
+	apiRouter.Use(middlewares...)
+	for _, handler := range handlers {
+		handler.RegisterRouter(apiRouter)
+	}
+
+	// Служебные ручки: та же аутентификация, права администратора проверяют обработчики
+	adminRouter := router.PathPrefix("/admin/").Subrouter()
+	adminRouter.Use(api.NewRecoveryMiddleware(logger))
+	adminRouter.Use(middlewares...)
+	for _, handler := range adminHandlers {
+		handler.RegisterRouter(adminRouter)
+	}
 }
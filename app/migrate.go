@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"effective_mobile/internal/repository"
+	"effective_mobile/pkg/logger_module"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pressly/goose/v3"
+	"github.com/spf13/pflag"
+)
+
+// Применяем все новые миграции и пишем в лог каждую примененную
+func applyMigrations(ctx context.Context, migrator *goose.Provider, logger *logger_module.Logger) error {
+	results, err := migrator.Up(ctx)
+	for _, result := range results {
+		logMigrationResult(logger, result)
+	}
+	if err != nil {
+		return err
+	}
+	current, _, err := migrator.GetVersions(ctx)
+	if err != nil {
+		return err
+	}
+	logger.Info("Migrations applied", "applied", len(results), "version", current)
+	return nil
+}
+
+func logMigrationResult(logger *logger_module.Logger, result *goose.MigrationResult) {
+	if result == nil {
+		return
+	}
+	if result.Error != nil {
+		logger.Error("Migration failed", "version", result.Source.Version, "file", result.Source.Path, "direction", result.Direction, "error", result.Error)
+		return
+	}
+	logger.Info("Migration applied", "version", result.Source.Version, "file", result.Source.Path, "direction", result.Direction, "duration", result.Duration)
+}
+
+// effective-mobile migrate <up|down|redo|status|create> [flags]
+func runMigrate(args []string) {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		fmt.Fprintf(os.Stderr, "migrate: command is required\n\n%s\n", usage)
+		os.Exit(2)
+	}
+	command, args := args[0], args[1:]
+
+	// Для create база не нужна: только файл в папке исходников
+	if command == "create" {
+		path, err := createMigrationFromArgs(args)
+		if errors.Is(err, pflag.ErrHelp) {
+			return
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "migrate create: %v\n", err)
+			os.Exit(2)
+		}
+		fmt.Println("Created", path)
+		return
+	}
+
+	logger := newStartupLogger()
+	conf := loadConfig(logger, args).Current()
+	db, err := connectDB(logger, conf)
+	if err != nil {
+		logger.Fatal("Failed to connect to database", "error", err)
+	}
+	migrator, err := repository.NewMigrator(db)
+	if err != nil {
+		logger.Fatal("Failed to load migrations", "error", err)
+	}
+	defer migrator.Close()
+
+	ctx := context.Background()
+	switch command {
+	case "up":
+		err = applyMigrations(ctx, migrator, logger)
+	case "down":
+		err = rollbackMigration(ctx, migrator, logger)
+	case "redo":
+		if err = rollbackMigration(ctx, migrator, logger); err == nil {
+			var result *goose.MigrationResult
+			result, err = migrator.UpByOne(ctx)
+			logMigrationResult(logger, result)
+		}
+	case "status":
+		err = printMigrationStatus(ctx, migrator)
+	default:
+		fmt.Fprintf(os.Stderr, "migrate: unknown command %q\n\n%s\n", command, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		logger.Fatal("Migration command failed", "command", command, "error", err)
+	}
+}
+
+func rollbackMigration(ctx context.Context, migrator *goose.Provider, logger *logger_module.Logger) error {
+	result, err := migrator.Down(ctx)
+	logMigrationResult(logger, result)
+	return err
+}
+
+func printMigrationStatus(ctx context.Context, migrator *goose.Provider) error {
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		return err
+	}
+	for _, status := range statuses {
+		applied := "pending"
+		if status.State == goose.StateApplied {
+			applied = status.AppliedAt.UTC().Format("2006-01-02 15:04:05")
+		}
+		fmt.Printf("%-6d %-20s %s\n", status.Source.Version, applied, filepath.Base(status.Source.Path))
+	}
+	return nil
+}
+
+func createMigrationFromArgs(args []string) (string, error) {
+	flags := pflag.NewFlagSet("migrate create", pflag.ContinueOnError)
+	dir := flags.String("dir", "migrations", "папка с миграциями")
+	if err := flags.Parse(args); err != nil {
+		return "", err
+	}
+	if flags.NArg() != 1 {
+		return "", errors.New("usage: migrate create <name> [--dir migrations]")
+	}
+	return createMigration(*dir, flags.Arg(0))
+}
+
+var (
+	migrationName    = regexp.MustCompile(`^[a-z0-9_]+$`)
+	migrationVersion = regexp.MustCompile(`^(\d+)_.*\.sql$`)
+)
+
+// Создаем файл следующей по номеру миграции в формате остальных: 0010_name.up.sql
+func createMigration(dir, name string) (string, error) {
+	if !migrationName.MatchString(name) {
+		return "", fmt.Errorf("migration name must contain only a-z, 0-9 and _, got %q", name)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+	var last int64
+	for _, entry := range entries {
+		match := migrationVersion.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err == nil && version > last {
+			last = version
+		}
+	}
+
+	path := filepath.Join(dir, fmt.Sprintf("%04d_%s.up.sql", last+1, name))
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	if _, err := file.WriteString("-- +goose Up\n\n-- +goose Down\n"); err != nil {
+		return "", err
+	}
+	return path, nil
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateMigration_NextVersion(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"0001_init.up.sql", "0009_rate_limits.up.sql", "README.md"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), nil, 0o644))
+	}
+
+	path, err := createMigration(dir, "add_index")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "0010_add_index.up.sql"), path)
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "-- +goose Up\n\n-- +goose Down\n", string(content))
+}
+
+func TestCreateMigration_InvalidName(t *testing.T) {
+	_, err := createMigration(t.TempDir(), "Add Index")
+	assert.Error(t, err)
+}
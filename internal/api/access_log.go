@@ -0,0 +1,71 @@
+package api
+
+import (
+	"context"
+	"effective_mobile/pkg/logger_module"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+type routeKey struct{}
+
+// Одна запись на запрос: метод, шаблон роута, код ответа, размер тела и время обработки.
+// Пишется логгером запроса, поэтому содержит request_id. Оборачивает весь роутер, чтобы
+// в журнал попадали и 404/405; шаблон совпавшего роута сообщает NewRouteRecorderMiddleware,
+// для запросов без роута пишется путь
+func NewAccessLogMiddleware(logger *logger_module.Logger) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			started := time.Now()
+			recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+			var matched string
+			next.ServeHTTP(recorder, r.WithContext(context.WithValue(r.Context(), routeKey{}, &matched)))
+
+			route := r.URL.Path
+			if matched != "" {
+				route = matched
+			} else if template, ok := routeTemplate(r); ok {
+				route = template
+			}
+			args := []any{
+				"method", r.Method,
+				"route", route,
+				"status", recorder.status,
+				"bytes", recorder.bytes,
+				"latency", time.Since(started),
+			}
+			if recorder.status >= http.StatusInternalServerError {
+				requestLogger(r, logger).Error("HTTP request", args...)
+				return
+			}
+			requestLogger(r, logger).Info("HTTP request", args...)
+		})
+	}
+}
+
+// Передаем журналу доступа шаблон совпавшего роута: снаружи роутера он недоступен.
+// Подключается через router.Use
+func NewRouteRecorderMiddleware() mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if matched, ok := r.Context().Value(routeKey{}).(*string); ok {
+				if template, ok := routeTemplate(r); ok {
+					*matched = template
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Шаблон роута gorilla/mux, если запрос уже сопоставлен с роутом
+func routeTemplate(r *http.Request) (string, bool) {
+	current := mux.CurrentRoute(r)
+	if current == nil {
+		return "", false
+	}
+	template, err := current.GetPathTemplate()
+	return template, err == nil
+}
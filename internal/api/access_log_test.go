@@ -0,0 +1,79 @@
+package api
+
+import (
+	"bytes"
+	"effective_mobile/pkg/logger_module"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccessLogMiddleware(t *testing.T) {
+	var out bytes.Buffer
+	logger, err := logger_module.New(&out, logger_module.Options{Format: logger_module.FormatText, Level: slog.LevelInfo})
+	require.NoError(t, err)
+
+	router := mux.NewRouter()
+	router.Use(NewRequestLoggerMiddleware(logger), NewAccessLogMiddleware(logger))
+	router.HandleFunc("/api/subscriptions/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("hello"))
+	}).Methods("POST")
+	router.HandleFunc("/api/broken", func(w http.ResponseWriter, r *http.Request) {
+		sendError(w, http.StatusServiceUnavailable, "unavailable")
+	}).Methods("GET")
+
+	request_test := httptest.NewRequest("POST", "/api/subscriptions/42", nil)
+	request_test.Header.Set(RequestIDHeader, "access-1")
+	router.ServeHTTP(httptest.NewRecorder(), request_test)
+
+	// В записи шаблон роута, а не путь с id
+	assert.Contains(t, out.String(), `level=INFO msg="HTTP request" request_id=access-1 method=POST route=/api/subscriptions/{id} status=201 bytes=5 latency=`)
+
+	out.Reset()
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/broken", nil))
+	assert.Contains(t, out.String(), "level=ERROR")
+	assert.Contains(t, out.String(), "route=/api/broken status=503")
+}
+
+// Снаружи роутера журнал и id запроса есть и у ответов без роута: 404 и 405
+func TestAccessLogMiddleware_WrapsRouter(t *testing.T) {
+	var out bytes.Buffer
+	logger, err := logger_module.New(&out, logger_module.Options{Format: logger_module.FormatText, Level: slog.LevelInfo})
+	require.NoError(t, err)
+
+	router := mux.NewRouter()
+	router.Use(NewRouteRecorderMiddleware())
+	router.HandleFunc("/api/subscriptions/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}).Methods("DELETE")
+	handler := NewRequestLoggerMiddleware(logger)(NewAccessLogMiddleware(logger)(router))
+
+	cases := []struct {
+		method string
+		path   string
+		status int
+		route  string
+	}{
+		{"DELETE", "/api/subscriptions/42", http.StatusNoContent, "/api/subscriptions/{id}"},
+		{"GET", "/api/unknown", http.StatusNotFound, "/api/unknown"},
+		{"GET", "/api/subscriptions/42", http.StatusMethodNotAllowed, "/api/subscriptions/42"},
+	}
+	for _, tc := range cases {
+		out.Reset()
+		request_test := httptest.NewRequest(tc.method, tc.path, nil)
+		request_test.Header.Set(RequestIDHeader, "wrap-1")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, request_test)
+
+		assert.Equal(t, tc.status, w.Code)
+		assert.Equal(t, "wrap-1", w.Header().Get(RequestIDHeader))
+		assert.Contains(t, out.String(), fmt.Sprintf(`msg="HTTP request" request_id=wrap-1 method=%s route=%s status=%d`, tc.method, tc.route, tc.status))
+	}
+}
@@ -2,6 +2,7 @@ package api
 
 import (
 	"context"
+	"effective_mobile/internal/tracing"
 	"net/http"
 	"time"
 
@@ -10,7 +11,7 @@ import (
 
 // GetTotalCost возвращает суммарную стоимость подписок
 // @Summary Подсчет стоимости
-// @Description Подсчитываем суммарную стоимость всех подписок за выбранный период
+// @Description Подсчитываем суммарную стоимость всех подписок за выбранный период. Роли finance и admin могут считать по всем пользователям, остальные — только по своим подпискам (без user_id считается по своим). Ответ содержит ETag: повторный запрос с If-None-Match получает 304, если сумма не изменилась
 // @Tags subscriptions
 // @Accept json
 // @Produce json
@@ -18,51 +19,61 @@ import (
 // @Param service_name query string false "Название сервиса для фильтрации" example("Netflix")
 // @Param start query string true "Начало периода (формат MM-YYYY)" example("01-2025")
 // @Param end query string true "Конец периода (формат MM-YYYY)" example("10-2025")
+// @Param If-None-Match header string false "ETag ранее полученного ответа"
 // @Success 200 {object} TotalCostResponse
+// @Success 304 "Сумма не изменилась с ответа с этим ETag"
 // @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
 // @Router /api/subscriptions/total [get]
 func (handler *SubscriptionHandler) GetTotalCost(w http.ResponseWriter, r *http.Request) {
-	handler.logger.Info("GetTotalCost handler called", "method", r.Method, "path", r.URL.Path)
+	logger := requestLogger(r, handler.logger)
+	logger.Info("GetTotalCost handler called", "method", r.Method, "path", r.URL.Path)
 	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 	defer cancel()
+	ctx, span := tracing.Start(ctx, "SubscriptionHandler.GetTotalCost")
+	defer span.End()
 
-	handler.logger.Debug("Getting params from query")
+	logger.Debug("Getting params from query")
 	params := r.URL.Query()
 	userID, _ := uuid.Parse(params.Get("user_id"))
 
 	serviceName := params.Get("service_name")
 
-	handler.logger.Debug("Parsing param start date")
+	logger.Debug("Parsing param start date")
 	start, err := time.Parse("01-2006", params.Get("start"))
 	if err != nil {
-		handler.logger.Error("Failed parse start invalid date format",
+		logger.Error("Failed parse start invalid date format",
 			"error", err.Error(),
 			"status_code", http.StatusBadRequest)
 		sendError(w, http.StatusBadRequest, "invalid start date format")
 		return
 	}
-	handler.logger.Debug("Parsing param end date")
+	logger.Debug("Parsing param end date")
 	end, err := time.Parse("01-2006", params.Get("end"))
 	if err != nil {
-		handler.logger.Error("Failed parse end invalid date format",
+		logger.Error("Failed parse end invalid date format",
 			"error", err.Error(),
 			"status_code", http.StatusBadRequest)
 		sendError(w, http.StatusBadRequest, "invalid end date format")
 		return
 	}
-	handler.logger.Debug("Calling service to get total cost")
+	logger.Debug("Calling service to get total cost")
 
 	total, err := handler.service.GetTotalCost(ctx, userID, serviceName, start, end)
 	if err != nil {
-		handler.logger.Error("Failed get total cost for subscrioptions",
+		if sendAccessError(w, logger, err) {
+			return
+		}
+		logger.Error("Failed get total cost for subscrioptions",
 			"error", err.Error(),
 			"status_code", http.StatusInternalServerError)
 		sendError(w, http.StatusInternalServerError, "internal server error")
 		return
 	}
-	handler.logger.Info("Successfully get total cost")
-	renderJSON(w, http.StatusOK, map[string]int{"total": total})
+	logger.Info("Successfully get total cost")
+	renderConditionalJSON(w, r, http.StatusOK, TotalCostResponse{Total: total})
 }
 
 // TotalCostResponse структура ответа для суммы подписок
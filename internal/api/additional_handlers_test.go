@@ -1,6 +1,7 @@
 package api
 
 import (
+	"effective_mobile/internal/service"
 	"effective_mobile/pkg/logger_module"
 	"encoding/json"
 	"errors"
@@ -140,3 +141,77 @@ func TestGetTotalCost_ServiceError(t *testing.T) {
 	assert.Contains(t, w.Body.String(), "internal server error")
 	mockService.AssertExpectations(t)
 }
+
+func TestGetTotalCost_Forbidden(t *testing.T) {
+	mockService := new(MockSubscriptionService)
+	logger := logger_module.Get()
+
+	handler := &SubscriptionHandler{
+		service: mockService,
+		logger:  logger,
+	}
+
+	// Обычный пользователь запрашивает сумму по чужим подпискам
+	userID := uuid.New()
+	startDate := time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)
+	endDate := time.Date(2025, time.December, 1, 0, 0, 0, 0, time.UTC)
+	mockService.On("GetTotalCost", mock.Anything, userID, "", startDate, endDate).
+		Return(0, service.ErrForbidden)
+
+	request_test := httptest.NewRequest("GET",
+		"/api/subscriptions/total?user_id="+userID.String()+
+			"&start=01-2025"+
+			"&end=12-2025", nil)
+	w := httptest.NewRecorder()
+
+	handler.GetTotalCost(w, request_test)
+
+	// Проверка
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "forbidden")
+	mockService.AssertExpectations(t)
+}
+
+func TestGetTotalCost_ConditionalGet(t *testing.T) {
+	mockService := new(MockSubscriptionService)
+	handler := &SubscriptionHandler{
+		service: mockService,
+		logger:  logger_module.Get(),
+	}
+
+	startDate := time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)
+	endDate := time.Date(2025, time.December, 1, 0, 0, 0, 0, time.UTC)
+	mockService.On("GetTotalCost", mock.Anything, uuid.Nil, "", startDate, endDate).Return(12000, nil).Twice()
+	mockService.On("GetTotalCost", mock.Anything, uuid.Nil, "", startDate, endDate).Return(13000, nil).Once()
+
+	get := func(ifNoneMatch string) *httptest.ResponseRecorder {
+		request_test := httptest.NewRequest("GET", "/api/subscriptions/total?start=01-2025&end=12-2025", nil)
+		if ifNoneMatch != "" {
+			request_test.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		w := httptest.NewRecorder()
+		handler.GetTotalCost(w, request_test)
+		return w
+	}
+
+	first := get("")
+	assert.Equal(t, http.StatusOK, first.Code)
+	etag := first.Header().Get("ETag")
+	assert.NotEmpty(t, etag)
+	assert.Equal(t, "private, no-cache", first.Header().Get("Cache-Control"))
+
+	// Сумма не изменилась — тело не отправляем
+	notModified := get(`"other", W/` + etag)
+	assert.Equal(t, http.StatusNotModified, notModified.Code)
+	assert.Empty(t, notModified.Body.String())
+	assert.Equal(t, etag, notModified.Header().Get("ETag"))
+
+	// Сумма изменилась — новый ответ с новым ETag
+	changed := get(etag)
+	assert.Equal(t, http.StatusOK, changed.Code)
+	assert.NotEqual(t, etag, changed.Header().Get("ETag"))
+	var response map[string]int
+	assert.NoError(t, json.NewDecoder(changed.Body).Decode(&response))
+	assert.Equal(t, 13000, response["total"])
+	mockService.AssertExpectations(t)
+}
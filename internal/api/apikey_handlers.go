@@ -0,0 +1,142 @@
+package api
+
+import (
+	"context"
+	"effective_mobile/internal/objects"
+	"effective_mobile/internal/service"
+	"effective_mobile/pkg/logger_module"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+type APIKeyHandler struct {
+	service service.APIKeyServiceI
+	logger  *logger_module.Logger
+}
+
+func NewAPIKeyHandler(service service.APIKeyServiceI, logger *logger_module.Logger) *APIKeyHandler {
+	return &APIKeyHandler{service: service, logger: logger}
+}
+
+func (handler *APIKeyHandler) RegisterRouter(router *mux.Router) {
+	router.HandleFunc("/api-keys", handler.CreateAPIKey).Methods("POST")
+	router.HandleFunc("/api-keys", handler.GetListAPIKey).Methods("GET")
+	router.HandleFunc("/api-keys/{id:[0-9a-fA-F-]{36}}", handler.RevokeAPIKey).Methods("DELETE")
+}
+
+// Ошибки сервиса API-ключей в HTTP-коды
+func (handler *APIKeyHandler) sendServiceError(w http.ResponseWriter, r *http.Request, err error) {
+	logger := requestLogger(r, handler.logger)
+	if sendAccessError(w, logger, err) {
+		return
+	}
+	switch {
+	case errors.Is(err, service.ErrInvalidAPIKey):
+		logger.Error("Invalid api key request", "error", err.Error(), "status_code", http.StatusBadRequest)
+		sendError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, service.ErrAPIKeyNotFound):
+		logger.Error("API key not found", "error", err.Error(), "status_code", http.StatusNotFound)
+		sendError(w, http.StatusNotFound, "api key not found")
+	default:
+		logger.Error("API key service failed", "error", err.Error(), "status_code", http.StatusInternalServerError)
+		sendError(w, http.StatusInternalServerError, "internal server error")
+	}
+}
+
+// Данная ручка выпускает API-ключ
+// @Summary Выпустить API-ключ
+// @Description Выпускаем ключ для текущего пользователя. Ключ возвращается только в ответе на создание, дальше передается в заголовке X-API-Key или Authorization: Bearer
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param api_key body objects.APIKeyCreateRequest true "Данные ключа"
+// @Success 201 {object} objects.APIKey
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/api-keys [post]
+func (handler *APIKeyHandler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	logger := requestLogger(r, handler.logger)
+	logger.Info("CreateAPIKey handler called", "method", r.Method, "path", r.URL.Path)
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	var req objects.APIKeyCreateRequest
+	logger.Debug("Decode request body")
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.Error("failed to request body", "error", err.Error(), "status_code", http.StatusBadRequest)
+		sendError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	key, err := handler.service.Create(ctx, &req)
+	if err != nil {
+		handler.sendServiceError(w, r, err)
+		return
+	}
+	logger.Info("API key created successfully", "api_key_id", key.ID)
+	renderJSON(w, http.StatusCreated, key)
+}
+
+// Данная ручка возвращает API-ключи пользователя
+// @Summary Получаем API-ключи
+// @Description Ключи текущего пользователя вместе с отозванными (без самих ключей)
+// @Tags auth
+// @Produce json
+// @Success 200 {array} objects.APIKey
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/api-keys [get]
+func (handler *APIKeyHandler) GetListAPIKey(w http.ResponseWriter, r *http.Request) {
+	logger := requestLogger(r, handler.logger)
+	logger.Info("GetListAPIKey handler called", "method", r.Method, "path", r.URL.Path)
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	keys, err := handler.service.List(ctx)
+	if err != nil {
+		handler.sendServiceError(w, r, err)
+		return
+	}
+	renderJSON(w, http.StatusOK, keys)
+}
+
+// Данная ручка отзывает API-ключ
+// @Summary Отозвать API-ключ
+// @Description Отзываем ключ текущего пользователя, запросы с ним перестают приниматься сразу
+// @Tags auth
+// @Produce json
+// @Param id path string true "ID ключа" format(uuid) example("550e8400-e29b-41d4-a716-446655440000")
+// @Success 204 "Ключ отозван"
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/api-keys/{id} [delete]
+func (handler *APIKeyHandler) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	logger := requestLogger(r, handler.logger)
+	logger.Info("RevokeAPIKey handler called", "method", r.Method, "path", r.URL.Path)
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		logger.Error("Invalid api key ID format", "error", err.Error(), "status_code", http.StatusBadRequest)
+		sendError(w, http.StatusBadRequest, "invalid api key id")
+		return
+	}
+
+	if err := handler.service.Revoke(ctx, id); err != nil {
+		handler.sendServiceError(w, r, err)
+		return
+	}
+	logger.Info("Successfully revoke api key", "id", id)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,94 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"effective_mobile/internal/objects"
+	"effective_mobile/internal/service"
+	"effective_mobile/pkg/logger_module"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockAPIKeyService struct {
+	mock.Mock
+}
+
+func (m *MockAPIKeyService) Create(ctx context.Context, req *objects.APIKeyCreateRequest) (*objects.APIKey, error) {
+	args := m.Called(ctx, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*objects.APIKey), args.Error(1)
+}
+
+func (m *MockAPIKeyService) List(ctx context.Context) ([]*objects.APIKey, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*objects.APIKey), args.Error(1)
+}
+
+func (m *MockAPIKeyService) Revoke(ctx context.Context, id uuid.UUID) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func TestCreateAPIKey_Success(t *testing.T) {
+	mockService := new(MockAPIKeyService)
+	logger := logger_module.Get()
+
+	handler := &APIKeyHandler{
+		service: mockService,
+		logger:  logger,
+	}
+
+	test_req := &objects.APIKeyCreateRequest{Name: "billing-export"}
+	created := &objects.APIKey{ID: uuid.New(), UserID: uuid.New(), Name: "billing-export", Prefix: "em_3f9a1c", KeyHash: "hash", Key: "em_3f9a1c0d"}
+	mockService.On("Create", mock.Anything, test_req).Return(created, nil)
+
+	request_test := httptest.NewRequest("POST", "/api/api-keys", bytes.NewBufferString(`{"name": "billing-export"}`))
+	w := httptest.NewRecorder()
+
+	handler.CreateAPIKey(w, request_test)
+
+	// Проверка
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.NotContains(t, w.Body.String(), "hash")
+
+	var response objects.APIKey
+	err := json.NewDecoder(w.Body).Decode(&response)
+	assert.NoError(t, err)
+	assert.Equal(t, "em_3f9a1c0d", response.Key)
+	mockService.AssertExpectations(t)
+}
+
+func TestRevokeAPIKey_Unauthenticated(t *testing.T) {
+	mockService := new(MockAPIKeyService)
+	logger := logger_module.Get()
+
+	handler := &APIKeyHandler{
+		service: mockService,
+		logger:  logger,
+	}
+
+	testID := uuid.New()
+	mockService.On("Revoke", mock.Anything, testID).Return(service.ErrUnauthenticated)
+
+	request_test := httptest.NewRequest("DELETE", "/api/api-keys/"+testID.String(), nil)
+	request_test = mux.SetURLVars(request_test, map[string]string{"id": testID.String()})
+	w := httptest.NewRecorder()
+
+	handler.RevokeAPIKey(w, request_test)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	mockService.AssertExpectations(t)
+}
@@ -0,0 +1,77 @@
+package api
+
+import (
+	"effective_mobile/internal/auth"
+	"effective_mobile/pkg/logger_module"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// Проверяем учетные данные каждого запроса к /api/ и кладем Principal в контекст запроса
+func NewAuthMiddleware(authenticator *auth.Authenticator, logger *logger_module.Logger) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal, err := authenticateRequest(r, authenticator)
+			switch {
+			case err == nil:
+			case errors.Is(err, auth.ErrNoCredentials):
+				requestLogger(r, logger).Error("Request without credentials", "path", r.URL.Path, "status_code", http.StatusUnauthorized)
+				w.Header().Set("WWW-Authenticate", `Bearer realm="api"`)
+				sendError(w, http.StatusUnauthorized, "authentication required")
+				return
+			case errors.Is(err, auth.ErrInvalidCredentials):
+				requestLogger(r, logger).Error("Invalid credentials", "error", err.Error(), "path", r.URL.Path, "status_code", http.StatusUnauthorized)
+				w.Header().Set("WWW-Authenticate", `Bearer realm="api", error="invalid_token"`)
+				sendError(w, http.StatusUnauthorized, "invalid credentials")
+				return
+			default:
+				requestLogger(r, logger).Error("Failed to authenticate request", "error", err.Error(), "status_code", http.StatusInternalServerError)
+				sendError(w, http.StatusInternalServerError, "internal server error")
+				return
+			}
+
+			r = withLogAttrs(r, logger, "user_id", principal.UserID, "auth_method", principal.Method)
+			next.ServeHTTP(w, r.WithContext(auth.NewContext(r.Context(), principal)))
+		})
+	}
+}
+
+// EventSource в браузере и календари не умеют передавать заголовки, поэтому для потока событий
+// и .ics-фида принимается параметр access_token. URL оседает в журналах прокси и истории браузера,
+// поэтому в нем принимается только короткоживущий токен фида (POST /api/feed-tokens), но не API-ключ или JWT
+func authenticateRequest(r *http.Request, authenticator *auth.Authenticator) (*auth.Principal, error) {
+	if credential := requestCredential(r); credential != "" {
+		return authenticator.Authenticate(r.Context(), credential)
+	}
+	if r.Method == http.MethodGet && auth.IsFeedPath(r.URL.Path) {
+		return authenticator.AuthenticateFeed(r.URL.Query().Get("access_token"), r.URL.Path)
+	}
+	return nil, auth.ErrNoCredentials
+}
+
+// Учетные данные из заголовков: X-API-Key или Authorization: Bearer <API-ключ или JWT>
+func requestCredential(r *http.Request) string {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return key
+	}
+	if header := r.Header.Get("Authorization"); header != "" {
+		scheme, token, found := strings.Cut(header, " ")
+		if found && strings.EqualFold(scheme, "Bearer") {
+			return strings.TrimSpace(token)
+		}
+		return ""
+	}
+	return ""
+}
+
+// Аутентификация выключена: все запросы выполняются от имени одного Principal
+func NewStaticPrincipalMiddleware(principal *auth.Principal) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(auth.NewContext(r.Context(), principal)))
+		})
+	}
+}
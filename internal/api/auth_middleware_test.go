@@ -0,0 +1,150 @@
+package api
+
+import (
+	"context"
+	"effective_mobile/internal/auth"
+	"effective_mobile/internal/objects"
+	"effective_mobile/pkg/logger_module"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeAPIKeyStore struct {
+	hash string
+	key  *objects.APIKey
+}
+
+func (s *fakeAPIKeyStore) FindByHash(ctx context.Context, hash string) (*objects.APIKey, error) {
+	if hash != s.hash {
+		return nil, auth.ErrInvalidCredentials
+	}
+	return s.key, nil
+}
+
+func newAuthTestRouter(t *testing.T) (*mux.Router, string, uuid.UUID) {
+	router, raw, userID, _ := newFeedTestRouter(t)
+	return router, raw, userID
+}
+
+func newFeedTestRouter(t *testing.T) (*mux.Router, string, uuid.UUID, *auth.FeedTokens) {
+	raw, err := auth.GenerateAPIKey()
+	assert.NoError(t, err)
+	userID := uuid.New()
+	store := &fakeAPIKeyStore{hash: auth.HashAPIKey(raw), key: &objects.APIKey{ID: uuid.New(), UserID: userID}}
+	feeds, err := auth.NewFeedTokens("feed-secret", time.Hour)
+	assert.NoError(t, err)
+
+	router := mux.NewRouter()
+	api := router.PathPrefix("/api/").Subrouter()
+	api.Use(NewAuthMiddleware(auth.NewAuthenticator(store, nil, feeds), logger_module.Get()))
+	whoami := func(w http.ResponseWriter, r *http.Request) {
+		principal, _ := auth.FromContext(r.Context())
+		w.Write([]byte(principal.UserID.String()))
+	}
+	api.HandleFunc("/subscriptions", whoami).Methods("GET")
+	api.HandleFunc("/users/{user_id}/renewals.ics", whoami).Methods("GET")
+	NewFeedTokenHandler(feeds, logger_module.Get()).RegisterRouter(api)
+	return router, raw, userID, feeds
+}
+
+func TestAuthMiddleware_Credentials(t *testing.T) {
+	router, key, userID := newAuthTestRouter(t)
+
+	cases := []struct {
+		name   string
+		target string
+		header map[string]string
+		status int
+	}{
+		{"no credentials", "/api/subscriptions", nil, http.StatusUnauthorized},
+		{"x-api-key", "/api/subscriptions", map[string]string{"X-API-Key": key}, http.StatusOK},
+		{"bearer api key", "/api/subscriptions", map[string]string{"Authorization": "Bearer " + key}, http.StatusOK},
+		{"unknown key", "/api/subscriptions", map[string]string{"X-API-Key": auth.APIKeyPrefix + "nope"}, http.StatusUnauthorized},
+		{"basic scheme", "/api/subscriptions", map[string]string{"Authorization": "Basic " + key}, http.StatusUnauthorized},
+		// API-ключ в URL не принимается даже для фидов
+		{"query key ignored", "/api/subscriptions?access_token=" + key, nil, http.StatusUnauthorized},
+		{"query key for ics", "/api/users/" + userID.String() + "/renewals.ics?access_token=" + key, nil, http.StatusUnauthorized},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			request_test := httptest.NewRequest("GET", tc.target, nil)
+			for name, value := range tc.header {
+				request_test.Header.Set(name, value)
+			}
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, request_test)
+
+			assert.Equal(t, tc.status, w.Code)
+			if tc.status == http.StatusOK {
+				assert.Equal(t, userID.String(), w.Body.String())
+			} else {
+				assert.Contains(t, w.Header().Get("WWW-Authenticate"), "Bearer")
+			}
+		})
+	}
+}
+
+func TestAuthMiddleware_FeedToken(t *testing.T) {
+	router, key, userID, feeds := newFeedTestRouter(t)
+	icsPath := "/api/users/" + userID.String() + "/renewals.ics"
+
+	// Токен выпускается по основным учетным данным
+	request_test := httptest.NewRequest("POST", "/api/feed-tokens", strings.NewReader(`{"path": "`+icsPath+`"}`))
+	request_test.Header.Set("X-API-Key", key)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, request_test)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var issued objects.FeedToken
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&issued))
+	assert.Equal(t, icsPath, issued.Path)
+
+	expired, _, err := feeds.Issue(&auth.Principal{Subject: "key", UserID: userID}, icsPath, time.Now().Add(-2*time.Hour))
+	assert.NoError(t, err)
+
+	cases := []struct {
+		name   string
+		method string
+		target string
+		header map[string]string
+		status int
+	}{
+		{"feed token for its path", "GET", icsPath + "?access_token=" + issued.Token, nil, http.StatusOK},
+		{"feed token for other path", "GET", "/api/users/" + uuid.NewString() + "/renewals.ics?access_token=" + issued.Token, nil, http.StatusUnauthorized},
+		{"feed token outside feeds", "GET", "/api/subscriptions?access_token=" + issued.Token, nil, http.StatusUnauthorized},
+		{"feed token in header", "GET", icsPath, map[string]string{"Authorization": "Bearer " + issued.Token}, http.StatusUnauthorized},
+		{"feed token cannot issue tokens", "POST", "/api/feed-tokens?access_token=" + issued.Token, nil, http.StatusUnauthorized},
+		{"expired feed token", "GET", icsPath + "?access_token=" + expired, nil, http.StatusUnauthorized},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			request_test := httptest.NewRequest(tc.method, tc.target, strings.NewReader(`{"path": "`+icsPath+`"}`))
+			for name, value := range tc.header {
+				request_test.Header.Set(name, value)
+			}
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, request_test)
+
+			assert.Equal(t, tc.status, w.Code)
+			if tc.status == http.StatusOK {
+				assert.Equal(t, userID.String(), w.Body.String())
+			}
+		})
+	}
+
+	// Токен выпускается только для фидов
+	request_test = httptest.NewRequest("POST", "/api/feed-tokens", strings.NewReader(`{"path": "/api/subscriptions"}`))
+	request_test.Header.Set("X-API-Key", key)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, request_test)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
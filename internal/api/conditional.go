@@ -0,0 +1,51 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// Ответ зависит от того, кто спрашивает, поэтому в общие кэши (прокси, CDN) он не попадает,
+// а клиент перед каждым использованием сохраненного ответа переспрашивает сервер с If-None-Match
+const conditionalCacheControl = "private, no-cache"
+
+// ETag ответа — хэш тела: одинаковые данные дают одинаковый ETag на любой реплике
+func bodyETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// If-None-Match совпадает с etag: список через запятую, слабые W/"..." и "*"
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// JSON-ответ с ETag и Cache-Control для условных GET: если у клиента тот же ответ,
+// отправляем 304 без тела
+func renderConditionalJSON(w http.ResponseWriter, r *http.Request, code int, object interface{}) {
+	body, err := json.Marshal(object)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+	body = append(body, '\n') // как json.Encoder в renderJSON
+	etag := bodyETag(body)
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", conditionalCacheControl)
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" && etagMatches(ifNoneMatch, etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(body)
+}
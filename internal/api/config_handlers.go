@@ -0,0 +1,51 @@
+package api
+
+import (
+	"effective_mobile/internal/auth"
+	"effective_mobile/internal/config"
+	"effective_mobile/pkg/logger_module"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+type ConfigHandler struct {
+	provider *config.Provider
+	logger   *logger_module.Logger
+}
+
+func NewConfigHandler(provider *config.Provider, logger *logger_module.Logger) *ConfigHandler {
+	return &ConfigHandler{provider: provider, logger: logger}
+}
+
+// Роуты регистрируются на подроутере /admin/
+func (handler *ConfigHandler) RegisterRouter(router *mux.Router) {
+	router.HandleFunc("/config", handler.GetConfig).Methods("GET")
+}
+
+// Данная ручка возвращает действующий конфиг
+// @Summary Действующий конфиг
+// @Description Конфиг с учетом файла, переменных окружения, флагов и изменений, примененных на лету, плоским списком "раздел.ключ". Пароли и секреты заменены на "***". Только для администратора
+// @Tags admin
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Router /admin/config [get]
+func (handler *ConfigHandler) GetConfig(w http.ResponseWriter, r *http.Request) {
+	logger := requestLogger(r, handler.logger)
+	logger.Info("GetConfig handler called", "method", r.Method, "path", r.URL.Path)
+
+	principal, ok := auth.FromContext(r.Context())
+	if !ok {
+		logger.Error("Request is not authenticated", "status_code", http.StatusUnauthorized)
+		sendError(w, http.StatusUnauthorized, "authentication required")
+		return
+	}
+	if !principal.HasRole(auth.RoleAdmin) {
+		logger.Error("Access denied", "status_code", http.StatusForbidden)
+		sendError(w, http.StatusForbidden, "admin role required")
+		return
+	}
+	renderJSON(w, http.StatusOK, handler.provider.Current().Redacted())
+}
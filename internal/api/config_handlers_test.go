@@ -0,0 +1,47 @@
+package api
+
+import (
+	"effective_mobile/internal/auth"
+	"effective_mobile/internal/config"
+	"effective_mobile/pkg/logger_module"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigHandler_GetConfig(t *testing.T) {
+	t.Setenv("DB_HOST", "localhost")
+	t.Setenv("DB_USER", "postgres")
+	t.Setenv("DB_NAME", "subscriptions")
+	t.Setenv("DB_PASSWORD", "s3cret")
+	provider, err := config.NewProvider(logger_module.Get(), nil)
+	require.NoError(t, err)
+
+	serve := func(principal *auth.Principal) *httptest.ResponseRecorder {
+		router := mux.NewRouter()
+		admin := router.PathPrefix("/admin/").Subrouter()
+		admin.Use(NewStaticPrincipalMiddleware(principal))
+		NewConfigHandler(provider, logger_module.Get()).RegisterRouter(admin)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/admin/config", nil))
+		return w
+	}
+
+	w := serve(auth.System)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "***", response["db.password"])
+	assert.Equal(t, "localhost", response["db.host"])
+	assert.NotContains(t, w.Body.String(), "s3cret")
+
+	userID := uuid.New()
+	w = serve(&auth.Principal{Subject: userID.String(), UserID: userID, Method: auth.MethodJWT, Roles: []auth.Role{auth.RoleUser}})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
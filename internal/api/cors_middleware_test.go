@@ -0,0 +1,52 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCORSMiddleware(t *testing.T) {
+	origins := []string{"https://app.example.com"}
+	router := mux.NewRouter()
+	router.HandleFunc("/api/subscriptions", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}).Methods("GET")
+	handler := NewCORSMiddleware(func() []string { return origins })(router)
+
+	// Обычный запрос с разрешенного origin
+	request_test := httptest.NewRequest("GET", "/api/subscriptions", nil)
+	request_test.Header.Set("Origin", "https://app.example.com")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, request_test)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "https://app.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Contains(t, w.Header().Get("Access-Control-Expose-Headers"), RequestIDHeader)
+
+	// Preflight отвечает до роутера, у которого нет OPTIONS
+	request_test = httptest.NewRequest("OPTIONS", "/api/subscriptions", nil)
+	request_test.Header.Set("Origin", "https://app.example.com")
+	request_test.Header.Set("Access-Control-Request-Method", "POST")
+	request_test.Header.Set("Access-Control-Request-Headers", "Authorization, Content-Type")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, request_test)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "Authorization, Content-Type", w.Header().Get("Access-Control-Allow-Headers"))
+	assert.Contains(t, w.Header().Get("Access-Control-Allow-Methods"), "POST")
+
+	// Чужой origin не получает заголовков CORS
+	request_test = httptest.NewRequest("GET", "/api/subscriptions", nil)
+	request_test.Header.Set("Origin", "https://evil.example.com")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, request_test)
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+
+	// Список origin меняется на лету
+	origins = []string{"*"}
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, request_test)
+	assert.Equal(t, "https://evil.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+}
@@ -0,0 +1,72 @@
+package api
+
+import (
+	"effective_mobile/internal/auth"
+	"effective_mobile/internal/objects"
+	"effective_mobile/pkg/logger_module"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+type FeedTokenHandler struct {
+	tokens *auth.FeedTokens
+	logger *logger_module.Logger
+}
+
+func NewFeedTokenHandler(tokens *auth.FeedTokens, logger *logger_module.Logger) *FeedTokenHandler {
+	return &FeedTokenHandler{tokens: tokens, logger: logger}
+}
+
+func (handler *FeedTokenHandler) RegisterRouter(router *mux.Router) {
+	router.HandleFunc("/feed-tokens", handler.CreateFeedToken).Methods("POST")
+}
+
+// Данная ручка выпускает токен для чтения фида
+// @Summary Выпустить токен фида
+// @Description Короткоживущий токен для потока событий или .ics-календаря, которые не умеют передавать заголовки. Токен передается в параметре access_token, действует только для GET указанного пути и с правами текущего пользователя
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param feed_token body objects.FeedTokenRequest true "Путь фида"
+// @Success 201 {object} objects.FeedToken
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/feed-tokens [post]
+func (handler *FeedTokenHandler) CreateFeedToken(w http.ResponseWriter, r *http.Request) {
+	logger := requestLogger(r, handler.logger)
+	logger.Info("CreateFeedToken handler called", "method", r.Method, "path", r.URL.Path)
+
+	principal, ok := auth.FromContext(r.Context())
+	if !ok {
+		logger.Error("Request is not authenticated", "status_code", http.StatusUnauthorized)
+		sendError(w, http.StatusUnauthorized, "authentication required")
+		return
+	}
+
+	var req objects.FeedTokenRequest
+	logger.Debug("Decode request body")
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.Error("failed to request body", "error", err.Error(), "status_code", http.StatusBadRequest)
+		sendError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	token, expires, err := handler.tokens.Issue(principal, req.Path, time.Now())
+	if errors.Is(err, auth.ErrNotFeedPath) {
+		logger.Error("Invalid feed path", "error", err.Error(), "status_code", http.StatusBadRequest)
+		sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		logger.Error("Failed to issue feed token", "error", err.Error(), "status_code", http.StatusInternalServerError)
+		sendError(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+	logger.Info("Feed token issued", "feed_path", req.Path, "expires_at", expires)
+	renderJSON(w, http.StatusCreated, objects.FeedToken{Token: token, Path: req.Path, ExpiresAt: expires})
+}
@@ -4,9 +4,11 @@ import (
 	"bytes"
 	"context"
 	"effective_mobile/internal/objects"
+	"effective_mobile/internal/service"
 	"effective_mobile/pkg/logger_module"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -58,6 +60,14 @@ func (m *MockSubscriptionService) GetTotalCost(ctx context.Context, userID uuid.
 	return args.Int(0), args.Error(1)
 }
 
+func (m *MockSubscriptionService) GetRenewals(ctx context.Context, userID uuid.UUID, from, to time.Time) ([]objects.Renewal, error) {
+	args := m.Called(ctx, userID, from, to)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]objects.Renewal), args.Error(1)
+}
+
 func TestCreateSubscription_Success(t *testing.T) {
 	// Подготавливаем моки
 	mockService := new(MockSubscriptionService)
@@ -108,7 +118,7 @@ func TestCreateSubscription_Success(t *testing.T) {
 // Проверка невалидного json
 func TestCreateSubscription_InvalidDate(t *testing.T) {
 	mockService := new(MockSubscriptionService)
-	logger := logger_module.New(nil, "[TEST]", 0)
+	logger := logger_module.Get()
 
 	handler := &SubscriptionHandler{
 		service: mockService,
@@ -131,6 +141,30 @@ func TestCreateSubscription_InvalidDate(t *testing.T) {
 	assert.Contains(t, w.Body.String(), "invalid format start_data")
 }
 
+func TestCreateSubscription_InvalidPrice(t *testing.T) {
+	mockService := new(MockSubscriptionService)
+	handler := &SubscriptionHandler{
+		service: mockService,
+		logger:  logger_module.Get(),
+	}
+	mockService.On("Create", mock.Anything, mock.Anything).
+		Return(fmt.Errorf("%w: price must be positive", service.ErrInvalidSubscription))
+
+	test_body := `{
+		"service_name": "Netflix",
+		"price": 0,
+		"user_id": "550e8400-e29b-41d4-a716-446655440000",
+		"start_date": "11-2025"
+	}`
+	request_test := httptest.NewRequest("POST", "/api/subscriptions", bytes.NewBufferString(test_body))
+	w := httptest.NewRecorder()
+
+	handler.CreateSubscription(w, request_test)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "price must be positive")
+}
+
 func TestGetSubscription(t *testing.T) {
 	// Настройка моков
 	mock_service := new(MockSubscriptionService)
@@ -454,3 +488,53 @@ func TestDeleteSubscription_NotFound(t *testing.T) {
 	assert.Contains(t, w.Body.String(), "subscription not found")
 	mockService.AssertExpectations(t)
 }
+
+func TestGetSubscription_Forbidden(t *testing.T) {
+	mockService := new(MockSubscriptionService)
+	logger := logger_module.Get()
+
+	handler := &SubscriptionHandler{
+		service: mockService,
+		logger:  logger,
+	}
+
+	testID := uuid.New()
+
+	// Подписка принадлежит другому пользователю
+	mockService.On("GetByID", mock.Anything, testID).Return(nil, service.ErrForbidden)
+
+	request_test := httptest.NewRequest("GET", "/api/subscriptions/"+testID.String(), nil)
+	request_test = mux.SetURLVars(request_test, map[string]string{"id": testID.String()})
+	w := httptest.NewRecorder()
+
+	handler.GetSubscription(w, request_test)
+
+	// Проверка
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "forbidden")
+	mockService.AssertExpectations(t)
+}
+
+func TestDeleteSubscription_Unauthenticated(t *testing.T) {
+	mockService := new(MockSubscriptionService)
+	logger := logger_module.Get()
+
+	handler := &SubscriptionHandler{
+		service: mockService,
+		logger:  logger,
+	}
+
+	testID := uuid.New()
+
+	mockService.On("Delete", mock.Anything, testID).Return(service.ErrUnauthenticated)
+
+	request_test := httptest.NewRequest("DELETE", "/subscriptions/"+testID.String(), nil)
+	request_test = mux.SetURLVars(request_test, map[string]string{"id": testID.String()})
+	w := httptest.NewRecorder()
+
+	handler.DeleteSubscription(w, request_test)
+
+	// Проверка
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	mockService.AssertExpectations(t)
+}
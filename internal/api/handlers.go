@@ -4,8 +4,10 @@ import (
 	"context"
 	"effective_mobile/internal/objects"
 	"effective_mobile/internal/service"
+	"effective_mobile/internal/tracing"
 	"effective_mobile/pkg/logger_module"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 	"time"
@@ -30,7 +32,8 @@ type UpdateResponce struct {
 
 // Структура для ошибок в API
 type ErrorResponse struct {
-	Error string `json:"error"`
+	Error     string `json:"error"`
+	RequestID string `json:"request_id,omitempty"`
 }
 
 func renderJSON(w http.ResponseWriter, code int, object interface{}) {
@@ -44,6 +47,32 @@ func sendError(w http.ResponseWriter, code int, message string) {
 	renderJSON(w, code, ErrorResponse{Error: message})
 }
 
+// Ошибки доступа из сервисного слоя: 401 без аутентификации и 403 для чужих данных.
+// Возвращает true, если ответ уже отправлен
+func sendAccessError(w http.ResponseWriter, logger *logger_module.Logger, err error) bool {
+	switch {
+	case errors.Is(err, service.ErrUnauthenticated):
+		logger.Error("Request is not authenticated", "status_code", http.StatusUnauthorized)
+		sendError(w, http.StatusUnauthorized, "authentication required")
+	case errors.Is(err, service.ErrForbidden):
+		logger.Error("Access denied", "error", err.Error(), "status_code", http.StatusForbidden)
+		sendError(w, http.StatusForbidden, "forbidden")
+	default:
+		return false
+	}
+	return true
+}
+
+// Неверные данные подписки из сервисного слоя — 400. Возвращает true, если ответ уже отправлен
+func sendValidationError(w http.ResponseWriter, logger *logger_module.Logger, err error) bool {
+	if !errors.Is(err, service.ErrInvalidSubscription) {
+		return false
+	}
+	logger.Error("Invalid subscription", "error", err.Error(), "status_code", http.StatusBadRequest)
+	sendError(w, http.StatusBadRequest, err.Error())
+	return true
+}
+
 // Данная ручка создает новую подписку
 // @Summary Создать подписку
 // @Description Создать новую запись о подписке пользователя
@@ -53,31 +82,36 @@ func sendError(w http.ResponseWriter, code int, message string) {
 // @Param sub body objects.SubscriptionCreateRequest true "Данные подписки"
 // @Success 201 {object} objects.Subscription
 // @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
 // @Router /api/subscriptions [post]
 func (handler *SubscriptionHandler) CreateSubscription(w http.ResponseWriter, r *http.Request) {
+	logger := requestLogger(r, handler.logger)
 
 	ctx, cancel := context.WithTimeout(r.Context(), 7*time.Second)
 	defer cancel()
+	ctx, span := tracing.Start(ctx, "SubscriptionHandler.CreateSubscription")
+	defer span.End()
 
 	var req_sub objects.SubscriptionCreateRequest
 
-	handler.logger.Debug("Decode request body")
+	logger.Debug("Decode request body")
 	err := json.NewDecoder(r.Body).Decode(&req_sub)
 	if err != nil {
-		handler.logger.Error("failed to request body", "error", err.Error(), "status_code", http.StatusBadRequest)
+		logger.Error("failed to request body", "error", err.Error(), "status_code", http.StatusBadRequest)
 		sendError(w, http.StatusBadRequest, "invalid request body")
 		return
 	}
 
-	handler.logger.Debug("Request body decoded successfully",
+	logger.Debug("Request body decoded successfully",
 		"service_name", req_sub.ServiceName,
 		"user_id", req_sub.UserID)
 
-	handler.logger.Debug("Started parse start date", "start_date", req_sub.StartDate)
+	logger.Debug("Started parse start date", "start_date", req_sub.StartDate)
 	start_Date, err := time.Parse("01-2006", req_sub.StartDate)
 	if err != nil {
-		handler.logger.Error("Invalid start date format",
+		logger.Error("Invalid start date format",
 			"error", err.Error(),
 			"start_date", req_sub.StartDate,
 			"status_code", http.StatusBadRequest)
@@ -85,10 +119,10 @@ func (handler *SubscriptionHandler) CreateSubscription(w http.ResponseWriter, r
 		return
 	}
 
-	handler.logger.Debug("Started parse user ID", "user_id", req_sub.UserID)
+	logger.Debug("Started parse user ID", "user_id", req_sub.UserID)
 	user_ID, err := uuid.Parse(req_sub.UserID)
 	if err != nil {
-		handler.logger.Error("Invalid user ID format",
+		logger.Error("Invalid user ID format",
 			"error", err.Error(),
 			"user_id", req_sub.UserID,
 			"status_code", http.StatusBadRequest)
@@ -104,10 +138,10 @@ func (handler *SubscriptionHandler) CreateSubscription(w http.ResponseWriter, r
 	}
 
 	if req_sub.EndDate != nil {
-		handler.logger.Debug("Start parse end date", "end_date", *req_sub.EndDate)
+		logger.Debug("Start parse end date", "end_date", *req_sub.EndDate)
 		end_Date, err := time.Parse("01-2006", *req_sub.EndDate)
 		if err != nil {
-			handler.logger.Error("Invalid end date format",
+			logger.Error("Invalid end date format",
 				"error", err.Error(),
 				"end date", *req_sub.EndDate,
 				"status_code", http.StatusBadRequest)
@@ -117,22 +151,39 @@ func (handler *SubscriptionHandler) CreateSubscription(w http.ResponseWriter, r
 		sub.EndDate = &end_Date
 	}
 
-	handler.logger.Info("Creating subscription",
+	if req_sub.TrialEndDate != nil {
+		logger.Debug("Start parse trial end date", "trial_end_date", *req_sub.TrialEndDate)
+		trial_End_Date, err := time.Parse("01-2006", *req_sub.TrialEndDate)
+		if err != nil {
+			logger.Error("Invalid trial end date format",
+				"error", err.Error(),
+				"trial_end_date", *req_sub.TrialEndDate,
+				"status_code", http.StatusBadRequest)
+			sendError(w, http.StatusBadRequest, "invalid trial_end_date format")
+			return
+		}
+		sub.TrialEndDate = &trial_End_Date
+	}
+
+	logger.Info("Creating subscription",
 		"service_name", sub.ServiceName,
 		"user_id", sub.UserID,
 		"start_date", sub.StartDate.Format("01-2006"),
 		"price", sub.Price)
 
-	handler.logger.Debug("Calling service to create subscription")
+	logger.Debug("Calling service to create subscription")
 
 	if err := handler.service.Create(ctx, sub); err != nil {
-		handler.logger.Error("Failed to create subscription",
+		if sendAccessError(w, logger, err) || sendValidationError(w, logger, err) {
+			return
+		}
+		logger.Error("Failed to create subscription",
 			"error", err.Error(),
 			"status_code", http.StatusInternalServerError)
 		sendError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
-	handler.logger.Info("Subscription created successfully",
+	logger.Info("Subscription created successfully",
 		"service_name", sub.ServiceName)
 	renderJSON(w, http.StatusCreated, sub)
 }
@@ -146,31 +197,39 @@ func (handler *SubscriptionHandler) CreateSubscription(w http.ResponseWriter, r
 // @Param id path string true "ID подписки" format(uuid) example("550e8400-e29b-41d4-a716-446655440000")
 // @Success 200 {object} objects.Subscription
 // @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
 // @Router /api/subscriptions/{id} [get]
 func (handler *SubscriptionHandler) GetSubscription(w http.ResponseWriter, r *http.Request) {
-	handler.logger.Info("GetSubscription handler called", "method", r.Method,
+	logger := requestLogger(r, handler.logger)
+	logger.Info("GetSubscription handler called", "method", r.Method,
 		"path", r.URL.Path)
 
 	ctx, cancel := context.WithTimeout(r.Context(), 7*time.Second)
 	defer cancel()
+	ctx, span := tracing.Start(ctx, "SubscriptionHandler.GetSubscription")
+	defer span.End()
 
-	handler.logger.Debug("Start parse subscription id")
+	logger.Debug("Start parse subscription id")
 	id, err := uuid.Parse(mux.Vars(r)["id"])
 	if err != nil {
-		handler.logger.Error("Invalid subscription ID format",
+		logger.Error("Invalid subscription ID format",
 			"error", err.Error(),
 			"subscription_id", id,
 			"status_code", http.StatusBadRequest)
 		sendError(w, http.StatusBadRequest, "invalid subscription id")
 		return
 	}
-	handler.logger.Debug("Calling service to get subscription",
+	logger.Debug("Calling service to get subscription",
 		"subscription_id", id)
 
 	sub, err := handler.service.GetByID(ctx, id)
 	if err != nil {
-		handler.logger.Error("Failed to retrieve subscription",
+		if sendAccessError(w, logger, err) {
+			return
+		}
+		logger.Error("Failed to retrieve subscription",
 			"error", err.Error(),
 			"subscription_id", id,
 			"status_code", http.StatusNotFound)
@@ -178,7 +237,7 @@ func (handler *SubscriptionHandler) GetSubscription(w http.ResponseWriter, r *ht
 		return
 	}
 
-	handler.logger.Info("Subscription successfully get",
+	logger.Info("Subscription successfully get",
 		"subscription_id", id,
 		"service_name", sub.ServiceName,
 		"user_id", sub.UserID)
@@ -187,7 +246,7 @@ func (handler *SubscriptionHandler) GetSubscription(w http.ResponseWriter, r *ht
 
 // Данная ручка возвращает список подписок с пагинацией
 // @Summary Получаем подписки
-// @Description Получаем все подписки которые есть
+// @Description Получаем все подписки которые есть (администратор), остальные пользователи получают только свои подписки
 // @Tags subscriptions
 // @Accept json
 // @Produce json
@@ -195,30 +254,37 @@ func (handler *SubscriptionHandler) GetSubscription(w http.ResponseWriter, r *ht
 // @Param offset query integer false "Смещение (по умолчанию 0)"
 // @Success 200 {array} objects.Subscription
 // @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
 // @Router /api/subscriptions [get]
 func (handler *SubscriptionHandler) GetListSubscription(w http.ResponseWriter, r *http.Request) {
-	handler.logger.Info("GetListSubscription handler called", "method", r.Method,
+	logger := requestLogger(r, handler.logger)
+	logger.Info("GetListSubscription handler called", "method", r.Method,
 		"path", r.URL.Path)
 	ctx, cancel := context.WithTimeout(r.Context(), 7*time.Second)
 	defer cancel()
+	ctx, span := tracing.Start(ctx, "SubscriptionHandler.GetListSubscription")
+	defer span.End()
 
-	handler.logger.Debug("Parse params limit,offset in query")
+	logger.Debug("Parse params limit,offset in query")
 	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
 	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
 
-	handler.logger.Debug("Calling service to get list subscription",
-		"params", "limit", limit, "offset", offset)
+	logger.Debug("Calling service to get list subscription", "limit", limit, "offset", offset)
 
 	subscriptions, err := handler.service.Get_List(ctx, limit, offset)
 	if err != nil {
-		handler.logger.Error("Failed to get all subscriptions",
+		if sendAccessError(w, logger, err) {
+			return
+		}
+		logger.Error("Failed to get all subscriptions",
 			"error", err.Error(),
 			"status_code", http.StatusInternalServerError)
 		sendError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
-	handler.logger.Info("Successfully get list subscrition", "params", "limit", limit, "offset", offset)
+	logger.Info("Successfully get list subscrition", "limit", limit, "offset", offset)
 	renderJSON(w, http.StatusOK, subscriptions)
 }
 
@@ -233,20 +299,25 @@ func (handler *SubscriptionHandler) GetListSubscription(w http.ResponseWriter, r
 // @Success 200 {object} UpdateResponce
 // @Failure 400 {object} ErrorResponse
 // @Failure 404 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
 // @Router /api/subscriptions/{id} [patch]
 func (handler *SubscriptionHandler) UpdateSubscription(w http.ResponseWriter, r *http.Request) {
-	handler.logger.Info("UpdateSubscription handler called", "method", r.Method,
+	logger := requestLogger(r, handler.logger)
+	logger.Info("UpdateSubscription handler called", "method", r.Method,
 		"path", r.URL.Path)
 	ctx, cancel := context.WithTimeout(r.Context(), 7*time.Second)
 	defer cancel()
+	ctx, span := tracing.Start(ctx, "SubscriptionHandler.UpdateSubscription")
+	defer span.End()
 
 	// Получаем ID из пути
-	handler.logger.Info("Started parse id in path")
+	logger.Info("Started parse id in path")
 	variable := mux.Vars(r)
 	id, err := uuid.Parse(variable["id"])
 	if err != nil {
-		handler.logger.Error("Invalid format for subscription id",
+		logger.Error("Invalid format for subscription id",
 			"error", err.Error(),
 			"status_code", http.StatusBadRequest)
 		sendError(w, http.StatusBadRequest, "invalid subscription id")
@@ -254,50 +325,53 @@ func (handler *SubscriptionHandler) UpdateSubscription(w http.ResponseWriter, r
 	}
 
 	var updateStruct objects.SubscriptionUpdateRequest
-	handler.logger.Debug("Decode request body")
+	logger.Debug("Decode request body")
 	if err := json.NewDecoder(r.Body).Decode(&updateStruct); err != nil {
-		handler.logger.Error("failed to request body", "error", err, "status_code", http.StatusBadRequest)
+		logger.Error("failed to request body", "error", err, "status_code", http.StatusBadRequest)
 		sendError(w, http.StatusBadRequest, "invalid request fields")
 		return
 	}
 
-	handler.logger.Debug("Request body decoded successfully")
+	logger.Debug("Request body decoded successfully")
 
 	// Проверяем, что есть хотя бы одно поле для обновления
 	if updateStruct.ServiceName == nil && updateStruct.Price == nil && updateStruct.EndDate == nil {
-		handler.logger.Error("No fields for update", "error", err, http.StatusBadRequest)
+		logger.Error("No fields for update", "error", err, "status_code", http.StatusBadRequest)
 		sendError(w, http.StatusBadRequest, "no fields for update")
 		return
 	}
-	handler.logger.Debug("Start create map for fields from request body")
+	logger.Debug("Start create map for fields from request body")
 	// Преобразуем в map для GORM
 	fields := make(map[string]interface{})
 	if updateStruct.ServiceName != nil {
-		handler.logger.Debug("Create field service name")
+		logger.Debug("Create field service name")
 		fields["service_name"] = *updateStruct.ServiceName
 	}
 	if updateStruct.Price != nil {
-		handler.logger.Debug("Create field price")
+		logger.Debug("Create field price")
 		fields["price"] = *updateStruct.Price
 	}
 	if updateStruct.EndDate != nil {
 		// Преобразуем строку даты в time.Time
-		handler.logger.Debug("Parse and create field endDate")
+		logger.Debug("Parse and create field endDate")
 		if endDate, err := time.Parse("01-2006", *updateStruct.EndDate); err == nil {
 			fields["end_date"] = endDate
 		}
 	}
-	handler.logger.Debug("Calling service to delete subscription by id",
+	logger.Debug("Calling service to delete subscription by id",
 		"subscription_id", id, "fields", fields)
 
 	if err := handler.service.Update(ctx, id, fields); err != nil {
-		handler.logger.Error("Failed update subscription by fields",
+		if sendAccessError(w, logger, err) || sendValidationError(w, logger, err) {
+			return
+		}
+		logger.Error("Failed update subscription by fields",
 			"error", err.Error(),
 			"status_code", http.StatusNotFound)
 		sendError(w, http.StatusNotFound, "subscription not found")
 		return
 	}
-	handler.logger.Info("Successfully update subscription")
+	logger.Info("Successfully update subscription")
 	renderJSON(w, http.StatusOK, map[string]string{"status": "success"})
 
 }
@@ -311,35 +385,43 @@ func (handler *SubscriptionHandler) UpdateSubscription(w http.ResponseWriter, r
 // @Param id path string true "ID подписки в формате UUID" format(uuid) example("550e8400-e29b-41d4-a716-446655440000")
 // @Success 204 "Подписка успешно удалена"
 // @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
 // @Router /api/subscriptions/{id} [delete]
 func (handler *SubscriptionHandler) DeleteSubscription(w http.ResponseWriter, r *http.Request) {
-	handler.logger.Info("DeleteSubscription handler called", "method", r.Method,
+	logger := requestLogger(r, handler.logger)
+	logger.Info("DeleteSubscription handler called", "method", r.Method,
 		"path", r.URL.Path)
 	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
 	defer cancel()
+	ctx, span := tracing.Start(ctx, "SubscriptionHandler.DeleteSubscription")
+	defer span.End()
 
-	handler.logger.Debug("Start parse id")
+	logger.Debug("Start parse id")
 	vars := mux.Vars(r)
 	id, err := uuid.Parse(vars["id"])
 	if err != nil {
-		handler.logger.Error("Invalid subscription id format",
+		logger.Error("Invalid subscription id format",
 			"error", err.Error(),
 			"subscription_id", id,
 			"status_code", http.StatusBadRequest)
 		sendError(w, http.StatusBadRequest, "invalid subscription ID")
 		return
 	}
-	handler.logger.Debug("Calling service to delete subscription by id",
+	logger.Debug("Calling service to delete subscription by id",
 		"subscription_id", id)
 
 	if err := handler.service.Delete(ctx, id); err != nil {
-		handler.logger.Error("Failed delete subscription by id",
+		if sendAccessError(w, logger, err) {
+			return
+		}
+		logger.Error("Failed delete subscription by id",
 			"error", err.Error(),
 			"status_code", http.StatusNotFound)
 		sendError(w, http.StatusNotFound, "subscription not found")
 		return
 	}
-	handler.logger.Info("Successfully delete subscription by id", "id", id)
+	logger.Info("Successfully delete subscription by id", "id", id)
 	renderJSON(w, http.StatusNoContent, nil)
 }
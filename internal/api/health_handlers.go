@@ -0,0 +1,81 @@
+package api
+
+import (
+	"effective_mobile/internal/health"
+	"effective_mobile/pkg/logger_module"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+type HealthHandler struct {
+	health *health.Health
+	logger *logger_module.Logger
+}
+
+func NewHealthHandler(health *health.Health, logger *logger_module.Logger) *HealthHandler {
+	return &HealthHandler{health: health, logger: logger}
+}
+
+// Пробы регистрируются на корневом роутере: без аутентификации, тенанта и лимитов
+func (handler *HealthHandler) RegisterRouter(router *mux.Router) {
+	router.HandleFunc("/healthz", handler.Liveness).Methods("GET")
+	router.HandleFunc("/readyz", handler.Readiness).Methods("GET")
+	router.HandleFunc("/health", handler.Health).Methods("GET")
+}
+
+type HealthStatusResponse struct {
+	Status string `json:"status"`
+}
+
+// Данная ручка показывает, что процесс жив
+// @Summary Liveness-проба
+// @Description Всегда 200, пока процесс обрабатывает запросы. База и другие зависимости не проверяются
+// @Tags health
+// @Produce json
+// @Success 200 {object} HealthStatusResponse
+// @Router /healthz [get]
+func (handler *HealthHandler) Liveness(w http.ResponseWriter, r *http.Request) {
+	renderJSON(w, http.StatusOK, HealthStatusResponse{Status: health.StatusUp})
+}
+
+// Данная ручка показывает, готов ли сервис принимать запросы
+// @Summary Readiness-проба
+// @Description 200, если база отвечает, миграции применены и сервис не завершается. После SIGTERM сразу отвечает 503, чтобы балансировщик успел снять трафик
+// @Tags health
+// @Produce json
+// @Success 200 {object} HealthStatusResponse
+// @Failure 503 {object} HealthStatusResponse
+// @Router /readyz [get]
+func (handler *HealthHandler) Readiness(w http.ResponseWriter, r *http.Request) {
+	report := handler.check(r)
+	renderJSON(w, statusCode(report), HealthStatusResponse{Status: report.Status})
+}
+
+// Данная ручка возвращает состояние всех компонентов
+// @Summary Состояние сервиса
+// @Description Статус, ошибка и время проверки каждого компонента: database, migrations и shutdown после SIGTERM
+// @Tags health
+// @Produce json
+// @Success 200 {object} health.Report
+// @Failure 503 {object} health.Report
+// @Router /health [get]
+func (handler *HealthHandler) Health(w http.ResponseWriter, r *http.Request) {
+	report := handler.check(r)
+	renderJSON(w, statusCode(report), report)
+}
+
+func (handler *HealthHandler) check(r *http.Request) health.Report {
+	report := handler.health.Check(r.Context())
+	if report.Status != health.StatusUp {
+		requestLogger(r, handler.logger).Warn("Service is not ready", "components", report.Components)
+	}
+	return report
+}
+
+func statusCode(report health.Report) int {
+	if report.Status != health.StatusUp {
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusOK
+}
@@ -0,0 +1,55 @@
+package api
+
+import (
+	"context"
+	"effective_mobile/internal/health"
+	"effective_mobile/pkg/logger_module"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHealthHandler(t *testing.T) {
+	var dbErr error
+	healthChecks := health.New(time.Second)
+	healthChecks.Add("database", func(ctx context.Context) error { return dbErr })
+
+	router := mux.NewRouter()
+	NewHealthHandler(healthChecks, logger_module.Get()).RegisterRouter(router)
+	serve := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		return w
+	}
+
+	assert.Equal(t, http.StatusOK, serve("/healthz").Code)
+	assert.Equal(t, http.StatusOK, serve("/readyz").Code)
+
+	w := serve("/health")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var report health.Report
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	assert.Equal(t, health.StatusUp, report.Components["database"].Status)
+
+	// База недоступна: процесс жив, но не готов
+	dbErr = errors.New("connection refused")
+	assert.Equal(t, http.StatusOK, serve("/healthz").Code)
+	assert.Equal(t, http.StatusServiceUnavailable, serve("/readyz").Code)
+	w = serve("/health")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	assert.Equal(t, "connection refused", report.Components["database"].Error)
+
+	// После SIGTERM readiness падает даже со здоровой базой
+	dbErr = nil
+	healthChecks.SetShuttingDown()
+	assert.Equal(t, http.StatusServiceUnavailable, serve("/readyz").Code)
+	assert.Equal(t, http.StatusOK, serve("/healthz").Code)
+}
@@ -0,0 +1,56 @@
+package api
+
+import (
+	"effective_mobile/internal/metrics"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Считаем запросы и их длительность по шаблону роута. Запросы, для которых роут не нашелся,
+// до middleware не доходят, поэтому произвольные пути не раздувают число меток
+func NewMetricsMiddleware() mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			started := time.Now()
+			recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(recorder, r)
+
+			route := "unknown"
+			if current := mux.CurrentRoute(r); current != nil {
+				if template, err := current.GetPathTemplate(); err == nil {
+					route = template
+				}
+			}
+			metrics.ObserveHTTP(r.Method, route, recorder.status, time.Since(started))
+		})
+	}
+}
+
+// Запоминаем код ответа и размер тела. Unwrap нужен http.ResponseController, иначе поток событий не сможет делать Flush
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	bytes       int64
+	wroteHeader bool
+}
+
+func (recorder *statusRecorder) WriteHeader(status int) {
+	if !recorder.wroteHeader {
+		recorder.status = status
+		recorder.wroteHeader = true
+	}
+	recorder.ResponseWriter.WriteHeader(status)
+}
+
+func (recorder *statusRecorder) Write(b []byte) (int, error) {
+	recorder.wroteHeader = true
+	n, err := recorder.ResponseWriter.Write(b)
+	recorder.bytes += int64(n)
+	return n, err
+}
+
+func (recorder *statusRecorder) Unwrap() http.ResponseWriter {
+	return recorder.ResponseWriter
+}
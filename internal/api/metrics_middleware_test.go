@@ -0,0 +1,37 @@
+package api
+
+import (
+	"effective_mobile/internal/metrics"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetricsMiddleware_RouteTemplate(t *testing.T) {
+	router := mux.NewRouter()
+	router.Use(NewMetricsMiddleware())
+	router.Handle("/metrics", metrics.Handler()).Methods("GET")
+	api := router.PathPrefix("/api/").Subrouter()
+	api.HandleFunc("/metrics-test/{id}", func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	}).Methods("GET")
+
+	for i := 0; i < 3; i++ {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/metrics-test/"+uuid.NewString(), nil))
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	body, _ := io.ReadAll(w.Body)
+
+	// Все запросы попали в одну серию с шаблоном роута, а не с конкретным id
+	assert.Contains(t, string(body), `effective_mobile_http_requests_total{method="GET",route="/api/metrics-test/{id}",status="404"} 3`)
+	assert.Contains(t, string(body), `effective_mobile_http_request_duration_seconds_count{method="GET",route="/api/metrics-test/{id}"} 3`)
+	assert.NotContains(t, string(body), "/api/metrics-test/"+uuid.Nil.String())
+}
@@ -0,0 +1,126 @@
+package api
+
+import (
+	"context"
+	"effective_mobile/internal/objects"
+	"effective_mobile/internal/service"
+	"effective_mobile/pkg/logger_module"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+type NotificationHandler struct {
+	service service.NotificationServiceI
+	logger  *logger_module.Logger
+}
+
+func NewNotificationHandler(service service.NotificationServiceI, logger *logger_module.Logger) *NotificationHandler {
+	return &NotificationHandler{service: service, logger: logger}
+}
+
+func (handler *NotificationHandler) RegisterRouter(router *mux.Router) {
+	router.HandleFunc("/users/{user_id:[0-9a-fA-F-]{36}}/notifications", handler.GetNotificationPreferences).Methods("GET")
+	router.HandleFunc("/users/{user_id:[0-9a-fA-F-]{36}}/notifications", handler.UpdateNotificationPreferences).Methods("PUT")
+}
+
+// Ошибки сервиса уведомлений в HTTP-коды
+func (handler *NotificationHandler) sendServiceError(w http.ResponseWriter, r *http.Request, err error) {
+	logger := requestLogger(r, handler.logger)
+	if sendAccessError(w, logger, err) {
+		return
+	}
+	switch {
+	case errors.Is(err, service.ErrInvalidNotificationPreferences):
+		logger.Error("Invalid notification preferences", "error", err.Error(), "status_code", http.StatusBadRequest)
+		sendError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, service.ErrNotificationPreferencesNotFound):
+		logger.Error("Notification preferences not found", "error", err.Error(), "status_code", http.StatusNotFound)
+		sendError(w, http.StatusNotFound, "notification preferences not found")
+	default:
+		logger.Error("Notification service failed", "error", err.Error(), "status_code", http.StatusInternalServerError)
+		sendError(w, http.StatusInternalServerError, "internal server error")
+	}
+}
+
+// Данная ручка возвращает настройки уведомлений пользователя
+// @Summary Настройки уведомлений
+// @Description Получаем адрес и включенные письма: ежемесячная сводка расходов и напоминания за 3 дня до списания
+// @Tags notifications
+// @Produce json
+// @Param user_id path string true "ID пользователя" format(uuid) example("550e8400-e29b-41d4-a716-446655440000")
+// @Success 200 {object} objects.NotificationPreferences
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/users/{user_id}/notifications [get]
+func (handler *NotificationHandler) GetNotificationPreferences(w http.ResponseWriter, r *http.Request) {
+	logger := requestLogger(r, handler.logger)
+	logger.Info("GetNotificationPreferences handler called", "method", r.Method, "path", r.URL.Path)
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	userID, err := uuid.Parse(mux.Vars(r)["user_id"])
+	if err != nil {
+		logger.Error("Invalid user ID format", "error", err.Error(), "status_code", http.StatusBadRequest)
+		sendError(w, http.StatusBadRequest, "invalid user_id format")
+		return
+	}
+
+	prefs, err := handler.service.GetPreferences(ctx, userID)
+	if err != nil {
+		handler.sendServiceError(w, r, err)
+		return
+	}
+	renderJSON(w, http.StatusOK, prefs)
+}
+
+// Данная ручка сохраняет настройки уведомлений пользователя
+// @Summary Изменить настройки уведомлений
+// @Description Задаем адрес и включаем или отключаем письма. Не переданные флаги не меняются, для нового пользователя все письма включены
+// @Tags notifications
+// @Accept json
+// @Produce json
+// @Param user_id path string true "ID пользователя" format(uuid) example("550e8400-e29b-41d4-a716-446655440000")
+// @Param preferences body objects.NotificationPreferencesRequest true "Настройки уведомлений"
+// @Success 200 {object} objects.NotificationPreferences
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/users/{user_id}/notifications [put]
+func (handler *NotificationHandler) UpdateNotificationPreferences(w http.ResponseWriter, r *http.Request) {
+	logger := requestLogger(r, handler.logger)
+	logger.Info("UpdateNotificationPreferences handler called", "method", r.Method, "path", r.URL.Path)
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	userID, err := uuid.Parse(mux.Vars(r)["user_id"])
+	if err != nil {
+		logger.Error("Invalid user ID format", "error", err.Error(), "status_code", http.StatusBadRequest)
+		sendError(w, http.StatusBadRequest, "invalid user_id format")
+		return
+	}
+
+	var req objects.NotificationPreferencesRequest
+	logger.Debug("Decode request body")
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.Error("failed to request body", "error", err.Error(), "status_code", http.StatusBadRequest)
+		sendError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	prefs, err := handler.service.SavePreferences(ctx, userID, &req)
+	if err != nil {
+		handler.sendServiceError(w, r, err)
+		return
+	}
+	logger.Info("Notification preferences saved", "user_id", userID)
+	renderJSON(w, http.StatusOK, prefs)
+}
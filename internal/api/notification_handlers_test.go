@@ -0,0 +1,94 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"effective_mobile/internal/objects"
+	"effective_mobile/internal/service"
+	"effective_mobile/pkg/logger_module"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockNotificationService struct {
+	mock.Mock
+}
+
+func (m *MockNotificationService) GetPreferences(ctx context.Context, userID uuid.UUID) (*objects.NotificationPreferences, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*objects.NotificationPreferences), args.Error(1)
+}
+
+func (m *MockNotificationService) SavePreferences(ctx context.Context, userID uuid.UUID, req *objects.NotificationPreferencesRequest) (*objects.NotificationPreferences, error) {
+	args := m.Called(ctx, userID, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*objects.NotificationPreferences), args.Error(1)
+}
+
+func TestUpdateNotificationPreferences_OptOut(t *testing.T) {
+	mockService := new(MockNotificationService)
+	logger := logger_module.Get()
+
+	handler := &NotificationHandler{
+		service: mockService,
+		logger:  logger,
+	}
+
+	testUserID := uuid.New()
+	disabled := false
+	test_req := &objects.NotificationPreferencesRequest{Email: "user@example.com", MonthlySummary: &disabled}
+	saved := &objects.NotificationPreferences{UserID: testUserID, Email: "user@example.com", MonthlySummary: false, RenewalReminders: true}
+	mockService.On("SavePreferences", mock.Anything, testUserID, test_req).Return(saved, nil)
+
+	test_body := `{"email": "user@example.com", "monthly_summary": false}`
+	request_test := httptest.NewRequest("PUT", "/api/users/"+testUserID.String()+"/notifications", bytes.NewBufferString(test_body))
+	request_test = mux.SetURLVars(request_test, map[string]string{"user_id": testUserID.String()})
+	w := httptest.NewRecorder()
+
+	handler.UpdateNotificationPreferences(w, request_test)
+
+	// Проверка
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response objects.NotificationPreferences
+	err := json.NewDecoder(w.Body).Decode(&response)
+	assert.NoError(t, err)
+	assert.False(t, response.MonthlySummary)
+	assert.True(t, response.RenewalReminders)
+	mockService.AssertExpectations(t)
+}
+
+func TestGetNotificationPreferences_NotFound(t *testing.T) {
+	mockService := new(MockNotificationService)
+	logger := logger_module.Get()
+
+	handler := &NotificationHandler{
+		service: mockService,
+		logger:  logger,
+	}
+
+	testUserID := uuid.New()
+	mockService.On("GetPreferences", mock.Anything, testUserID).Return(nil, service.ErrNotificationPreferencesNotFound)
+
+	request_test := httptest.NewRequest("GET", "/api/users/"+testUserID.String()+"/notifications", nil)
+	request_test = mux.SetURLVars(request_test, map[string]string{"user_id": testUserID.String()})
+	w := httptest.NewRecorder()
+
+	handler.GetNotificationPreferences(w, request_test)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Body.String(), "notification preferences not found")
+	mockService.AssertExpectations(t)
+}
@@ -0,0 +1,120 @@
+package api
+
+import (
+	"effective_mobile/internal/auth"
+	"effective_mobile/internal/ratelimit"
+	"effective_mobile/internal/tenant"
+	"effective_mobile/pkg/logger_module"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Ограничиваем частоту запросов клиента (token bucket) и суточную квоту организации.
+// Стоит после аутентификации и выбора тенанта: клиент — API-ключ или sub из JWT,
+// без аутентификации — IP-адрес. quotas == nil — квоты выключены
+func NewRateLimitMiddleware(limiter *ratelimit.Limiter, quotas *ratelimit.Quotas, trustForwarded bool, logger *logger_module.Logger) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			now := time.Now()
+			client := rateLimitClient(r, trustForwarded)
+
+			// Шаблон роута, а не путь: иначе у каждого id была бы своя корзина
+			route := r.URL.Path
+			if current := mux.CurrentRoute(r); current != nil {
+				if template, err := current.GetPathTemplate(); err == nil {
+					route = template
+				}
+			}
+
+			if !allowRequest(w, r, limiter.Allow(client, r.Method, route, now), client, route, logger) {
+				return
+			}
+
+			if quotas != nil {
+				tenantID, _ := tenant.FromContext(r.Context())
+				quota, err := quotas.Check(r.Context(), tenantID, now)
+				switch {
+				case err != nil:
+					// Недоступность счетчиков не должна останавливать API, пропускаем запрос
+					requestLogger(r, logger).Error("Failed to check daily quota", "error", err, "tenant_id", tenantID)
+				case quota.Limited:
+					w.Header().Set("X-Quota-Limit", strconv.FormatInt(quota.Limit, 10))
+					w.Header().Set("X-Quota-Remaining", strconv.FormatInt(quota.Remaining, 10))
+					w.Header().Set("X-Quota-Reset", headerSeconds(quota.Reset))
+					if !quota.Allowed {
+						requestLogger(r, logger).Error("Daily quota exceeded", "tenant_id", tenantID, "limit", quota.Limit, "status_code", http.StatusTooManyRequests)
+						w.Header().Set("Retry-After", headerSeconds(quota.Reset))
+						sendError(w, http.StatusTooManyRequests, "daily quota exceeded")
+						return
+					}
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Ограничиваем частоту запросов с одного IP-адреса до проверки учетных данных: иначе запросы
+// с неверным или отозванным ключом получали бы 401 без ограничений, а каждая проверка
+// API-ключа — это запрос в базу. Все роуты делят одну корзину адреса; корзину клиента
+// после аутентификации списывает NewRateLimitMiddleware
+func NewIPRateLimitMiddleware(limiter *ratelimit.Limiter, trustForwarded bool, logger *logger_module.Logger) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			client := "ip:" + clientIP(r, trustForwarded)
+			if !allowRequest(w, r, limiter.Allow(client, "", "", time.Now()), client, "", logger) {
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Заголовки RateLimit-* по решению ограничителя; отклоненный запрос получает 429 с Retry-After
+func allowRequest(w http.ResponseWriter, r *http.Request, decision ratelimit.Decision, client, route string, logger *logger_module.Logger) bool {
+	w.Header().Set("RateLimit-Limit", strconv.Itoa(decision.Limit))
+	w.Header().Set("RateLimit-Remaining", strconv.Itoa(decision.Remaining))
+	w.Header().Set("RateLimit-Reset", headerSeconds(decision.Reset))
+	if decision.Allowed {
+		return true
+	}
+	requestLogger(r, logger).Error("Rate limit exceeded", "client", client, "route", route, "status_code", http.StatusTooManyRequests)
+	w.Header().Set("Retry-After", headerSeconds(decision.RetryAfter))
+	sendError(w, http.StatusTooManyRequests, "rate limit exceeded")
+	return false
+}
+
+// Ключ клиента для корзины
+func rateLimitClient(r *http.Request, trustForwarded bool) string {
+	if principal, ok := auth.FromContext(r.Context()); ok && principal.Method != auth.MethodSystem {
+		return principal.Method + ":" + principal.Subject
+	}
+	return "ip:" + clientIP(r, trustForwarded)
+}
+
+// X-Forwarded-For учитываем, только если сервис стоит за своим прокси: иначе клиент подставит любой адрес
+func clientIP(r *http.Request, trustForwarded bool) string {
+	if trustForwarded {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			first, _, _ := strings.Cut(forwarded, ",")
+			return strings.TrimSpace(first)
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// Секунды с округлением вверх: Retry-After: 0 клиенты понимают как "повторить сразу"
+func headerSeconds(d time.Duration) string {
+	return strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10)
+}
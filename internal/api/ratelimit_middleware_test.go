@@ -0,0 +1,156 @@
+package api
+
+import (
+	"context"
+	"effective_mobile/internal/auth"
+	"effective_mobile/internal/objects"
+	"effective_mobile/internal/ratelimit"
+	"effective_mobile/internal/tenant"
+	"effective_mobile/pkg/logger_module"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeQuotaStore struct {
+	tier  string
+	count int64
+}
+
+func (s *fakeQuotaStore) AddDaily(ctx context.Context, tenantID string, day time.Time, n int64) (int64, error) {
+	s.count += n
+	return s.count, nil
+}
+
+func (s *fakeQuotaStore) DailyUsage(ctx context.Context, tenantID string, day time.Time) (int64, error) {
+	return s.count, nil
+}
+
+func (s *fakeQuotaStore) TenantTier(ctx context.Context, tenantID string) (string, error) {
+	return s.tier, nil
+}
+
+func newRateLimitTestRouter(limiter *ratelimit.Limiter, quotas *ratelimit.Quotas, principal *auth.Principal) *mux.Router {
+	router := mux.NewRouter()
+	api := router.PathPrefix("/api/").Subrouter()
+	api.Use(NewStaticPrincipalMiddleware(principal), NewTenantMiddleware(tenant.Default, logger_module.Get()))
+	api.Use(NewRateLimitMiddleware(limiter, quotas, false, logger_module.Get()))
+	ok := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }
+	api.HandleFunc("/subscriptions/total", ok).Methods("GET")
+	api.HandleFunc("/subscriptions/{id}", ok).Methods("GET")
+	return router
+}
+
+func TestRateLimitMiddleware_PerRoute(t *testing.T) {
+	limiter := ratelimit.NewLimiter(
+		ratelimit.Rule{Rate: 10, Burst: 10},
+		map[string]ratelimit.Rule{"GET /api/subscriptions/total": {Rate: 1, Burst: 1}},
+	)
+	principal := &auth.Principal{Subject: uuid.NewString(), Method: auth.MethodJWT, Roles: []auth.Role{auth.RoleUser}}
+	router := newRateLimitTestRouter(limiter, nil, principal)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/subscriptions/total", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "1", w.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "0", w.Header().Get("RateLimit-Remaining"))
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/subscriptions/total", nil))
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+	assert.Contains(t, w.Body.String(), "rate limit exceeded")
+
+	// Остальные роуты ограничиваются общей корзиной, разные id делят ее
+	for _, id := range []string{uuid.NewString(), uuid.NewString()} {
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/api/subscriptions/"+id, nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+	assert.Equal(t, "10", w.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "8", w.Header().Get("RateLimit-Remaining"))
+}
+
+func TestRateLimitMiddleware_ByIPWithoutAuth(t *testing.T) {
+	limiter := ratelimit.NewLimiter(ratelimit.Rule{Rate: 1, Burst: 1}, nil)
+	router := newRateLimitTestRouter(limiter, nil, auth.Anonymous)
+
+	request := func(addr string) int {
+		request_test := httptest.NewRequest("GET", "/api/subscriptions/"+uuid.NewString(), nil)
+		request_test.RemoteAddr = addr
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, request_test)
+		return w.Code
+	}
+	assert.Equal(t, http.StatusOK, request("10.0.0.1:5000"))
+	assert.Equal(t, http.StatusTooManyRequests, request("10.0.0.1:5001"))
+	assert.Equal(t, http.StatusOK, request("10.0.0.2:5000"))
+}
+
+func TestRateLimitMiddleware_DailyQuota(t *testing.T) {
+	limiter := ratelimit.NewLimiter(ratelimit.Rule{Rate: 100, Burst: 100}, nil)
+	quotas := ratelimit.NewQuotas(&fakeQuotaStore{}, map[string]int64{"free": 1}, "free", time.Second, logger_module.Get())
+	router := newRateLimitTestRouter(limiter, quotas, auth.Anonymous)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/subscriptions/total", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "1", w.Header().Get("X-Quota-Limit"))
+	assert.Equal(t, "0", w.Header().Get("X-Quota-Remaining"))
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/subscriptions/total", nil))
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Contains(t, w.Body.String(), "daily quota exceeded")
+	assert.NotEmpty(t, w.Header().Get("Retry-After"))
+}
+
+// Неверный ключ получает 401, пока не кончится корзина адреса, затем 429 — и в базу больше не ходит
+func TestRateLimitMiddleware_IPLimitBeforeAuth(t *testing.T) {
+	raw, err := auth.GenerateAPIKey()
+	assert.NoError(t, err)
+	store := &countingAPIKeyStore{}
+	router := mux.NewRouter()
+	api := router.PathPrefix("/api/").Subrouter()
+	api.Use(NewIPRateLimitMiddleware(ratelimit.NewLimiter(ratelimit.Rule{Rate: 1, Burst: 2}, nil), false, logger_module.Get()))
+	api.Use(NewAuthMiddleware(auth.NewAuthenticator(store, nil, nil), logger_module.Get()))
+	api.Use(NewRateLimitMiddleware(ratelimit.NewLimiter(ratelimit.Rule{Rate: 10, Burst: 10}, nil), nil, false, logger_module.Get()))
+	api.HandleFunc("/subscriptions", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }).Methods("GET")
+
+	request := func(addr string) *httptest.ResponseRecorder {
+		request_test := httptest.NewRequest("GET", "/api/subscriptions", nil)
+		request_test.RemoteAddr = addr
+		request_test.Header.Set("X-API-Key", raw)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, request_test)
+		return w
+	}
+
+	for i := 0; i < 2; i++ {
+		assert.Equal(t, http.StatusUnauthorized, request("10.0.0.1:5000").Code)
+	}
+	for i := 0; i < 3; i++ {
+		w := request("10.0.0.1:5000")
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+		assert.Equal(t, "1", w.Header().Get("Retry-After"))
+	}
+	assert.Equal(t, 2, store.lookups)
+
+	// У другого адреса своя корзина
+	assert.Equal(t, http.StatusUnauthorized, request("10.0.0.2:5000").Code)
+	assert.Equal(t, 3, store.lookups)
+}
+
+type countingAPIKeyStore struct {
+	lookups int
+}
+
+func (s *countingAPIKeyStore) FindByHash(ctx context.Context, hash string) (*objects.APIKey, error) {
+	s.lookups++
+	return nil, auth.ErrInvalidCredentials
+}
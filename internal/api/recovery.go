@@ -0,0 +1,39 @@
+package api
+
+import (
+	"effective_mobile/pkg/logger_module"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/gorilla/mux"
+)
+
+// Паника в обработчике не должна рвать соединение без следа: логируем ее со стеком
+// и отвечаем 500 с id запроса, по которому запись можно найти в логах
+func NewRecoveryMiddleware(logger *logger_module.Logger) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+			defer func() {
+				recovered := recover()
+				if recovered == nil {
+					return
+				}
+				// ErrAbortHandler — штатный способ прервать ответ, его обрабатывает net/http
+				if recovered == http.ErrAbortHandler {
+					panic(recovered)
+				}
+				requestLogger(r, logger).Error("Panic in HTTP handler", "panic", recovered, "stack", string(debug.Stack()))
+				if recorder.wroteHeader {
+					// Заголовки уже ушли клиенту, изменить код ответа нельзя
+					return
+				}
+				renderJSON(recorder, http.StatusInternalServerError, ErrorResponse{
+					Error:     "internal server error",
+					RequestID: RequestIDFromContext(r.Context()),
+				})
+			}()
+			next.ServeHTTP(recorder, r)
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package api
+
+import (
+	"bytes"
+	"effective_mobile/pkg/logger_module"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecoveryMiddleware(t *testing.T) {
+	var out bytes.Buffer
+	logger, err := logger_module.New(&out, logger_module.Options{Format: logger_module.FormatText, Level: slog.LevelInfo})
+	require.NoError(t, err)
+
+	router := mux.NewRouter()
+	router.Use(NewRequestLoggerMiddleware(logger), NewAccessLogMiddleware(logger), NewRecoveryMiddleware(logger))
+	router.HandleFunc("/api/panic", func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}).Methods("GET")
+	router.HandleFunc("/api/partial", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("partial"))
+		panic("late boom")
+	}).Methods("GET")
+
+	request_test := httptest.NewRequest("GET", "/api/panic", nil)
+	request_test.Header.Set(RequestIDHeader, "panic-1")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, request_test)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	var response ErrorResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, ErrorResponse{Error: "internal server error", RequestID: "panic-1"}, response)
+	assert.Contains(t, out.String(), `msg="Panic in HTTP handler" request_id=panic-1 panic=boom stack=`)
+	assert.Contains(t, out.String(), "route=/api/panic status=500")
+
+	// Если ответ уже начат, код не меняется, но паника не уходит дальше
+	out.Reset()
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/partial", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "partial", w.Body.String())
+	assert.Contains(t, out.String(), "panic=\"late boom\"")
+
+	// ErrAbortHandler пробрасывается дальше для net/http
+	router.HandleFunc("/api/abort", func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}).Methods("GET")
+	assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/abort", nil))
+	})
+}
@@ -0,0 +1,195 @@
+package api
+
+import (
+	"context"
+	"effective_mobile/internal/objects"
+	"effective_mobile/internal/tracing"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// Сколько месяцев показываем в календаре, если параметр to не указан
+const defaultRenewalMonths = 12
+
+// GetRenewals возвращает предстоящие списания пользователя
+// @Summary Календарь списаний
+// @Description Рассчитываем даты продления подписок пользователя за период (границы включительно, по умолчанию 12 месяцев от текущего)
+// @Tags renewals
+// @Accept json
+// @Produce json
+// @Param user_id path string true "ID пользователя (UUID)" format(uuid) example("550e8400-e29b-41d4-a716-446655440000")
+// @Param from query string false "Начало периода (формат MM-YYYY)" example("01-2025")
+// @Param to query string false "Конец периода (формат MM-YYYY)" example("12-2025")
+// @Success 200 {array} objects.Renewal
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/users/{user_id}/renewals [get]
+func (handler *SubscriptionHandler) GetRenewals(w http.ResponseWriter, r *http.Request) {
+	logger := requestLogger(r, handler.logger)
+	logger.Info("GetRenewals handler called", "method", r.Method, "path", r.URL.Path)
+
+	renewals, ok := handler.loadRenewals(w, r)
+	if !ok {
+		return
+	}
+	logger.Info("Successfully get renewals", "count", len(renewals))
+	renderJSON(w, http.StatusOK, renewals)
+}
+
+// GetRenewalsICS отдает предстоящие списания пользователя в формате iCalendar
+// @Summary Календарь списаний (iCalendar)
+// @Description Фид RFC 5545 с отдельным событием на каждое списание, для подписки в календаре
+// @Tags renewals
+// @Produce text/calendar
+// @Param user_id path string true "ID пользователя (UUID)" format(uuid) example("550e8400-e29b-41d4-a716-446655440000")
+// @Param from query string false "Начало периода (формат MM-YYYY)" example("01-2025")
+// @Param to query string false "Конец периода (формат MM-YYYY)" example("12-2025")
+// @Success 200 {string} string "Календарь в формате text/calendar"
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/users/{user_id}/renewals.ics [get]
+func (handler *SubscriptionHandler) GetRenewalsICS(w http.ResponseWriter, r *http.Request) {
+	logger := requestLogger(r, handler.logger)
+	logger.Info("GetRenewalsICS handler called", "method", r.Method, "path", r.URL.Path)
+
+	renewals, ok := handler.loadRenewals(w, r)
+	if !ok {
+		return
+	}
+	logger.Info("Successfully get renewals calendar", "count", len(renewals))
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="renewals.ics"`)
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(renderICS(renewals, time.Now().UTC())))
+}
+
+// Общая часть обеих ручек: разбор параметров и запрос в сервис.
+// При ошибке сама отправляет ответ и возвращает false
+func (handler *SubscriptionHandler) loadRenewals(w http.ResponseWriter, r *http.Request) ([]objects.Renewal, bool) {
+	logger := requestLogger(r, handler.logger)
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	ctx, span := tracing.Start(ctx, "SubscriptionHandler.loadRenewals")
+	defer span.End()
+
+	logger.Debug("Start parse user id")
+	userID, err := uuid.Parse(mux.Vars(r)["user_id"])
+	if err != nil {
+		logger.Error("Invalid user ID format",
+			"error", err.Error(),
+			"status_code", http.StatusBadRequest)
+		sendError(w, http.StatusBadRequest, "invalid user_id format")
+		return nil, false
+	}
+
+	logger.Debug("Parsing params from,to")
+	params := r.URL.Query()
+	now := time.Now().UTC()
+	from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	if params.Get("from") != "" {
+		from, err = time.Parse("01-2006", params.Get("from"))
+		if err != nil {
+			logger.Error("Failed parse from invalid date format",
+				"error", err.Error(),
+				"status_code", http.StatusBadRequest)
+			sendError(w, http.StatusBadRequest, "invalid from date format")
+			return nil, false
+		}
+	}
+	to := from.AddDate(0, defaultRenewalMonths-1, 0)
+	if params.Get("to") != "" {
+		to, err = time.Parse("01-2006", params.Get("to"))
+		if err != nil {
+			logger.Error("Failed parse to invalid date format",
+				"error", err.Error(),
+				"status_code", http.StatusBadRequest)
+			sendError(w, http.StatusBadRequest, "invalid to date format")
+			return nil, false
+		}
+	}
+	if to.Before(from) {
+		logger.Error("Invalid renewals period", "from", from, "to", to, "status_code", http.StatusBadRequest)
+		sendError(w, http.StatusBadRequest, "to must not be before from")
+		return nil, false
+	}
+
+	// Месяц to включаем целиком, поэтому в сервис передаем начало следующего месяца
+	logger.Debug("Calling service to get renewals", "user_id", userID)
+	renewals, err := handler.service.GetRenewals(ctx, userID, from, to.AddDate(0, 1, 0))
+	if err != nil {
+		if sendAccessError(w, logger, err) {
+			return nil, false
+		}
+		logger.Error("Failed get renewals",
+			"error", err.Error(),
+			"status_code", http.StatusInternalServerError)
+		sendError(w, http.StatusInternalServerError, "internal server error")
+		return nil, false
+	}
+	return renewals, true
+}
+
+// Собираем календарь по RFC 5545: одно событие на весь день на каждое списание
+func renderICS(renewals []objects.Renewal, stamp time.Time) string {
+	var b strings.Builder
+	writeICSLine(&b, "BEGIN:VCALENDAR")
+	writeICSLine(&b, "VERSION:2.0")
+	writeICSLine(&b, "PRODID:-//effective_mobile//subscription renewals//RU")
+	writeICSLine(&b, "CALSCALE:GREGORIAN")
+	writeICSLine(&b, "METHOD:PUBLISH")
+	writeICSLine(&b, "X-WR-CALNAME:"+escapeICSText("Списания по подпискам"))
+	for _, renewal := range renewals {
+		day := renewal.Date.Format("20060102")
+		writeICSLine(&b, "BEGIN:VEVENT")
+		// UID стабилен между выгрузками, чтобы календарь обновлял события, а не дублировал их
+		writeICSLine(&b, fmt.Sprintf("UID:%s-%s@effective_mobile", renewal.SubscriptionID, day))
+		writeICSLine(&b, "DTSTAMP:"+stamp.Format("20060102T150405Z"))
+		writeICSLine(&b, "DTSTART;VALUE=DATE:"+day)
+		writeICSLine(&b, "DTEND;VALUE=DATE:"+renewal.Date.AddDate(0, 0, 1).Format("20060102"))
+		writeICSLine(&b, "SUMMARY:"+escapeICSText(fmt.Sprintf("%s — %d руб.", renewal.ServiceName, renewal.Price)))
+		writeICSLine(&b, "DESCRIPTION:"+escapeICSText(fmt.Sprintf("Списание по подписке %s: %d руб.", renewal.ServiceName, renewal.Price)))
+		writeICSLine(&b, "TRANSP:TRANSPARENT")
+		writeICSLine(&b, "END:VEVENT")
+	}
+	writeICSLine(&b, "END:VCALENDAR")
+	return b.String()
+}
+
+// Экранируем спецсимволы в текстовых значениях (RFC 5545, 3.3.11)
+func escapeICSText(text string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+	).Replace(text)
+}
+
+// Пишем строку контента с переносом длинных строк: не больше 75 октетов в строке,
+// продолжение начинается с пробела (RFC 5545, 3.1). UTF-8 символы не разрываем
+func writeICSLine(b *strings.Builder, line string) {
+	const maxOctets = 75
+	width := 0
+	for _, char := range line {
+		size := utf8.RuneLen(char)
+		if width+size > maxOctets {
+			b.WriteString("\r\n ")
+			width = 1
+		}
+		b.WriteRune(char)
+		width += size
+	}
+	b.WriteString("\r\n")
+}
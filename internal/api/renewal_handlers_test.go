@@ -0,0 +1,122 @@
+package api
+
+import (
+	"effective_mobile/internal/objects"
+	"effective_mobile/pkg/logger_module"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestGetRenewals_Success(t *testing.T) {
+	mockService := new(MockSubscriptionService)
+	logger := logger_module.Get()
+
+	handler := &SubscriptionHandler{
+		service: mockService,
+		logger:  logger,
+	}
+
+	// Тестовые данные
+	userID := uuid.MustParse("550e8400-e29b-41d4-a716-446655440000")
+	from := time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)
+	// Месяц to включается целиком, поэтому в сервис уходит начало следующего
+	to := time.Date(2025, time.April, 1, 0, 0, 0, 0, time.UTC)
+	renewals := []objects.Renewal{
+		{SubscriptionID: uuid.New(), ServiceName: "Netflix", Price: 599, Date: from},
+		{SubscriptionID: uuid.New(), ServiceName: "Yandex", Price: 300, Date: from.AddDate(0, 1, 0)},
+	}
+
+	mockService.On("GetRenewals", mock.Anything, userID, from, to).Return(renewals, nil)
+
+	request_test := httptest.NewRequest("GET", "/api/users/"+userID.String()+"/renewals?from=01-2025&to=03-2025", nil)
+	request_test = mux.SetURLVars(request_test, map[string]string{"user_id": userID.String()})
+	w := httptest.NewRecorder()
+
+	handler.GetRenewals(w, request_test)
+
+	// Проверка
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response []objects.Renewal
+	err := json.NewDecoder(w.Body).Decode(&response)
+	assert.NoError(t, err)
+	assert.Len(t, response, 2)
+	assert.Equal(t, "Netflix", response[0].ServiceName)
+	assert.Equal(t, 300, response[1].Price)
+	mockService.AssertExpectations(t)
+}
+
+func TestGetRenewals_InvalidPeriod(t *testing.T) {
+	mockService := new(MockSubscriptionService)
+	logger := logger_module.Get()
+
+	handler := &SubscriptionHandler{
+		service: mockService,
+		logger:  logger,
+	}
+
+	userID := uuid.New()
+
+	// Конец периода раньше начала
+	request_test := httptest.NewRequest("GET", "/api/users/"+userID.String()+"/renewals?from=05-2025&to=03-2025", nil)
+	request_test = mux.SetURLVars(request_test, map[string]string{"user_id": userID.String()})
+	w := httptest.NewRecorder()
+
+	handler.GetRenewals(w, request_test)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "to must not be before from")
+	mockService.AssertNotCalled(t, "GetRenewals")
+}
+
+func TestGetRenewalsICS_Success(t *testing.T) {
+	mockService := new(MockSubscriptionService)
+	logger := logger_module.Get()
+
+	handler := &SubscriptionHandler{
+		service: mockService,
+		logger:  logger,
+	}
+
+	userID := uuid.New()
+	subID := uuid.MustParse("550e8400-e29b-41d4-a716-446655440000")
+	renewals := []objects.Renewal{
+		{SubscriptionID: subID, ServiceName: "Netflix, Premium", Price: 599, Date: time.Date(2025, time.September, 1, 0, 0, 0, 0, time.UTC)},
+	}
+
+	mockService.On("GetRenewals", mock.Anything, userID, mock.Anything, mock.Anything).Return(renewals, nil)
+
+	request_test := httptest.NewRequest("GET", "/api/users/"+userID.String()+"/renewals.ics", nil)
+	request_test = mux.SetURLVars(request_test, map[string]string{"user_id": userID.String()})
+	w := httptest.NewRecorder()
+
+	handler.GetRenewalsICS(w, request_test)
+
+	// Проверка
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "text/calendar")
+
+	body := w.Body.String()
+	assert.True(t, strings.HasPrefix(body, "BEGIN:VCALENDAR\r\n"))
+	assert.True(t, strings.HasSuffix(body, "END:VCALENDAR\r\n"))
+	assert.Equal(t, 1, strings.Count(body, "BEGIN:VEVENT"))
+	assert.Contains(t, body, "UID:"+subID.String()+"-20250901@effective_mobile")
+	assert.Contains(t, body, "DTSTART;VALUE=DATE:20250901")
+	// Запятая в названии сервиса должна быть экранирована
+	assert.Contains(t, body, `Netflix\, Premium`)
+
+	// Ни одна строка не длиннее 75 октетов
+	for _, line := range strings.Split(strings.TrimSuffix(body, "\r\n"), "\r\n") {
+		assert.LessOrEqual(t, len(line), 75)
+	}
+	mockService.AssertExpectations(t)
+}
@@ -0,0 +1,54 @@
+package api
+
+import (
+	"context"
+	"effective_mobile/pkg/logger_module"
+	"net/http"
+	"regexp"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// Заголовок с id запроса: принимаем от клиента или прокси и возвращаем в ответе
+const RequestIDHeader = "X-Request-ID"
+
+// Чужой id попадает в логи, поэтому принимаем только короткие строки без пробелов и управляющих символов
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+type requestIDKey struct{}
+
+// id текущего запроса или пустая строка вне NewRequestLoggerMiddleware
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
+
+// Кладем в контекст логгер запроса с request_id. Middleware аутентификации и тенанта
+// дополняют его user_id и tenant_id, поэтому все записи обработчика можно связать с запросом
+func NewRequestLoggerMiddleware(logger *logger_module.Logger) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requestID := r.Header.Get(RequestIDHeader)
+			if !requestIDPattern.MatchString(requestID) {
+				requestID = uuid.NewString()
+			}
+			w.Header().Set(RequestIDHeader, requestID)
+
+			ctx := context.WithValue(r.Context(), requestIDKey{}, requestID)
+			ctx = logger_module.NewContext(ctx, logger.With("request_id", requestID))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// Логгер запроса; без middleware (например, в тестах) — логгер обработчика
+func requestLogger(r *http.Request, fallback *logger_module.Logger) *logger_module.Logger {
+	return logger_module.FromContext(r.Context(), fallback)
+}
+
+// Добавляем атрибуты к логгеру запроса
+func withLogAttrs(r *http.Request, fallback *logger_module.Logger, args ...any) *http.Request {
+	logger := requestLogger(r, fallback).With(args...)
+	return r.WithContext(logger_module.NewContext(r.Context(), logger))
+}
@@ -0,0 +1,55 @@
+package api
+
+import (
+	"bytes"
+	"effective_mobile/internal/auth"
+	"effective_mobile/internal/tenant"
+	"effective_mobile/pkg/logger_module"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestLoggerMiddleware(t *testing.T) {
+	var out bytes.Buffer
+	logger, err := logger_module.New(&out, logger_module.Options{Format: logger_module.FormatText, Level: slog.LevelInfo})
+	require.NoError(t, err)
+
+	userID := uuid.New()
+	principal := &auth.Principal{Subject: userID.String(), UserID: userID, Method: auth.MethodJWT, Roles: []auth.Role{auth.RoleUser}}
+
+	router := mux.NewRouter()
+	router.Use(NewRequestLoggerMiddleware(logger))
+	api := router.PathPrefix("/api/").Subrouter()
+	api.Use(NewStaticPrincipalMiddleware(principal), func(next http.Handler) http.Handler {
+		// Как NewAuthMiddleware: добавляем пользователя в логгер запроса
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, withLogAttrs(r, logger, "user_id", principal.UserID))
+		})
+	}, NewTenantMiddleware(tenant.Default, logger))
+	api.HandleFunc("/ping", func(w http.ResponseWriter, r *http.Request) {
+		requestLogger(r, logger).Info("Ping handler called")
+	}).Methods("GET")
+
+	// id клиента сохраняется
+	request_test := httptest.NewRequest("GET", "/api/ping", nil)
+	request_test.Header.Set(RequestIDHeader, "client-id-1")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, request_test)
+	assert.Equal(t, "client-id-1", w.Header().Get(RequestIDHeader))
+	assert.Contains(t, out.String(), "msg=\"Ping handler called\" request_id=client-id-1 user_id="+userID.String()+" tenant_id=default")
+
+	// Недопустимый id заменяется сгенерированным
+	request_test = httptest.NewRequest("GET", "/api/ping", nil)
+	request_test.Header.Set(RequestIDHeader, "bad id\nwith newline")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, request_test)
+	_, err = uuid.Parse(w.Header().Get(RequestIDHeader))
+	assert.NoError(t, err)
+}
@@ -9,4 +9,6 @@ func (handler *SubscriptionHandler) RegisterRouter(router *mux.Router) {
 	router.HandleFunc("/subscriptions/{id}", handler.UpdateSubscription).Methods("PATCH")
 	router.HandleFunc("/subscriptions/{id}", handler.DeleteSubscription).Methods("DELETE")
 	router.HandleFunc("/subscriptions", handler.GetListSubscription).Methods("GET")
+	router.HandleFunc("/users/{user_id:[0-9a-fA-F-]{36}}/renewals", handler.GetRenewals).Methods("GET")
+	router.HandleFunc("/users/{user_id:[0-9a-fA-F-]{36}}/renewals.ics", handler.GetRenewalsICS).Methods("GET")
 }
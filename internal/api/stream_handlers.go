@@ -0,0 +1,174 @@
+package api
+
+import (
+	"context"
+	"effective_mobile/internal/auth"
+	"effective_mobile/internal/stream"
+	"effective_mobile/internal/tenant"
+	"effective_mobile/pkg/logger_module"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// Как часто шлем комментарий-пинг, чтобы прокси не закрывали простаивающее соединение
+const streamHeartbeat = 15 * time.Second
+
+type EventStreamHandler struct {
+	hub    *stream.Hub
+	replay stream.ReplayStore
+	logger *logger_module.Logger
+}
+
+func NewEventStreamHandler(hub *stream.Hub, replay stream.ReplayStore, logger *logger_module.Logger) *EventStreamHandler {
+	return &EventStreamHandler{hub: hub, replay: replay, logger: logger}
+}
+
+func (handler *EventStreamHandler) RegisterRouter(router *mux.Router) {
+	router.HandleFunc("/subscriptions/events", handler.StreamEvents).Methods("GET")
+}
+
+// Данная ручка отдает поток изменений подписок
+// @Summary Поток событий
+// @Description Server-Sent Events: subscription.created, subscription.updated, subscription.deleted и события планировщика. id события — номер в outbox, при переподключении с заголовком Last-Event-ID пропущенные события досылаются. Все события доступны только администратору, остальные получают события своих подписок
+// @Tags subscriptions
+// @Produce text/event-stream
+// @Param user_id query string false "Только события пользователя (UUID)" example("550e8400-e29b-41d4-a716-446655440000")
+// @Param Last-Event-ID header string false "id последнего полученного события"
+// @Success 200 {string} string "Поток text/event-stream"
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Router /api/subscriptions/events [get]
+func (handler *EventStreamHandler) StreamEvents(w http.ResponseWriter, r *http.Request) {
+	logger := requestLogger(r, handler.logger)
+	logger.Info("StreamEvents handler called", "method", r.Method, "path", r.URL.Path)
+
+	userID := uuid.Nil
+	if raw := r.URL.Query().Get("user_id"); raw != "" {
+		parsed, err := uuid.Parse(raw)
+		if err != nil {
+			logger.Error("Invalid user ID format", "error", err.Error(), "status_code", http.StatusBadRequest)
+			sendError(w, http.StatusBadRequest, "invalid user_id format")
+			return
+		}
+		userID = parsed
+	}
+
+	// Администратор может слушать все события, остальные — только свои
+	principal, ok := auth.FromContext(r.Context())
+	if !ok {
+		logger.Error("Request is not authenticated", "status_code", http.StatusUnauthorized)
+		sendError(w, http.StatusUnauthorized, "authentication required")
+		return
+	}
+	if !principal.HasRole(auth.RoleAdmin) {
+		if userID == uuid.Nil {
+			userID = principal.UserID
+		}
+		if userID == uuid.Nil || userID != principal.UserID {
+			logger.Error("Access to event stream denied", "user_id", userID, "status_code", http.StatusForbidden)
+			sendError(w, http.StatusForbidden, "forbidden")
+			return
+		}
+	}
+
+	var lastEventID int64
+	if raw := r.Header.Get("Last-Event-ID"); raw != "" {
+		parsed, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || parsed < 0 {
+			logger.Error("Invalid Last-Event-ID", "last_event_id", raw, "status_code", http.StatusBadRequest)
+			sendError(w, http.StatusBadRequest, "invalid Last-Event-ID")
+			return
+		}
+		lastEventID = parsed
+	}
+
+	// Тенант кладет middleware, запрос без него сюда не доходит
+	tenantID, _ := tenant.FromContext(r.Context())
+
+	// Подписываемся до дочитки, чтобы не потерять события между дочиткой и живым потоком
+	sub := handler.hub.Subscribe(tenantID, userID)
+	defer handler.hub.Unsubscribe(sub)
+
+	// Поток живет дольше WriteTimeout сервера, снимаем дедлайн для этого ответа
+	controller := http.NewResponseController(w)
+	controller.SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, "retry: 3000\n\n")
+	controller.Flush()
+
+	// Досылаем пропущенное из outbox, запоминая id, чтобы не отправить их повторно из живого потока
+	replayed := make(map[int64]struct{})
+	if lastEventID > 0 {
+		if err := handler.replayEvents(r.Context(), w, controller, userID, lastEventID, replayed); err != nil {
+			logger.Error("Failed replay events", "error", err, "last_event_id", lastEventID)
+			return
+		}
+	}
+
+	logger.Info("Event stream opened", "user_id", userID, "last_event_id", lastEventID)
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			logger.Info("Event stream closed by client", "user_id", userID)
+			return
+		case msg, ok := <-sub.C:
+			if !ok {
+				// Хаб отключил нас (останов сервера или медленный клиент), клиент переподключится сам
+				return
+			}
+			if _, seen := replayed[msg.ID]; seen {
+				continue
+			}
+			if err := writeEvent(w, msg); err != nil {
+				return
+			}
+			controller.Flush()
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+			controller.Flush()
+		}
+	}
+}
+
+func (handler *EventStreamHandler) replayEvents(ctx context.Context, w io.Writer, controller *http.ResponseController, userID uuid.UUID, afterID int64, replayed map[int64]struct{}) error {
+	const batch = 500
+	for {
+		messages, err := handler.replay.ListSince(ctx, afterID, userID, batch)
+		if err != nil {
+			return err
+		}
+		for _, msg := range messages {
+			if err := writeEvent(w, msg); err != nil {
+				return err
+			}
+			replayed[msg.ID] = struct{}{}
+			afterID = msg.ID
+		}
+		controller.Flush()
+		if len(messages) < batch {
+			return nil
+		}
+	}
+}
+
+// Одно событие в формате text/event-stream, данные — JSON в одну строку
+func writeEvent(w io.Writer, msg stream.Message) error {
+	_, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", msg.ID, msg.Type, msg.Data)
+	return err
+}
@@ -0,0 +1,147 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"effective_mobile/internal/auth"
+	"effective_mobile/internal/stream"
+	"effective_mobile/internal/tenant"
+	"effective_mobile/pkg/logger_module"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockReplayStore struct {
+	mock.Mock
+}
+
+func (m *MockReplayStore) ListSince(ctx context.Context, afterID int64, userID uuid.UUID, limit int) ([]stream.Message, error) {
+	args := m.Called(ctx, afterID, userID, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]stream.Message), args.Error(1)
+}
+
+func (m *MockReplayStore) GetByID(ctx context.Context, id int64) (stream.Message, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(stream.Message), args.Error(1)
+}
+
+func TestStreamEvents_ResumeAndFilter(t *testing.T) {
+	mockReplay := new(MockReplayStore)
+	logger := logger_module.Get()
+	hub := stream.NewHub("", mockReplay, logger)
+
+	handler := &EventStreamHandler{
+		hub:    hub,
+		replay: mockReplay,
+		logger: logger,
+	}
+
+	userID := uuid.New()
+	otherUserID := uuid.New()
+	data := json.RawMessage(`{"type":"subscription.created"}`)
+
+	// Клиент переподключается после события 5, в outbox есть событие 6
+	mockReplay.On("ListSince", mock.Anything, int64(5), userID, mock.Anything).
+		Return([]stream.Message{{ID: 6, Type: "subscription.created", UserID: userID, TenantID: "acme", Data: data}}, nil)
+
+	// Поток слушает сам пользователь
+	principal := &auth.Principal{UserID: userID, Roles: []auth.Role{auth.RoleUser}}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := tenant.NewContext(auth.NewContext(r.Context(), principal), "acme")
+		handler.StreamEvents(w, r.WithContext(ctx))
+	}))
+	defer server.Close()
+
+	request_test, _ := http.NewRequest("GET", server.URL+"/api/subscriptions/events?user_id="+userID.String(), nil)
+	request_test.Header.Set("Last-Event-ID", "5")
+	response, err := http.DefaultClient.Do(request_test)
+	assert.NoError(t, err)
+	defer response.Body.Close()
+
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, "text/event-stream", response.Header.Get("Content-Type"))
+
+	reader := bufio.NewReader(response.Body)
+	readID := func() string {
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return ""
+			}
+			if strings.HasPrefix(line, "id: ") {
+				return strings.TrimSpace(strings.TrimPrefix(line, "id: "))
+			}
+		}
+	}
+
+	// Сначала дочитанное из outbox
+	assert.Equal(t, "6", readID())
+
+	// Живой поток: дубль уже отправленного, чужое событие, событие того же пользователя
+	// в другой организации и новое событие пользователя
+	hub.Broadcast(stream.Message{ID: 6, Type: "subscription.created", UserID: userID, TenantID: "acme", Data: data})
+	hub.Broadcast(stream.Message{ID: 7, Type: "subscription.created", UserID: otherUserID, TenantID: "acme", Data: data})
+	hub.Broadcast(stream.Message{ID: 8, Type: "subscription.created", UserID: userID, TenantID: "globex", Data: data})
+	hub.Broadcast(stream.Message{ID: 9, Type: "subscription.deleted", UserID: userID, TenantID: "acme", Data: data})
+
+	assert.Equal(t, "9", readID())
+
+	// После остановки хаба поток закрывается
+	hub.Close()
+	assert.Equal(t, "", readID())
+	mockReplay.AssertExpectations(t)
+}
+
+func TestStreamEvents_InvalidLastEventID(t *testing.T) {
+	mockReplay := new(MockReplayStore)
+	logger := logger_module.Get()
+
+	handler := &EventStreamHandler{
+		hub:    stream.NewHub("", mockReplay, logger),
+		replay: mockReplay,
+		logger: logger,
+	}
+
+	request_test := httptest.NewRequest("GET", "/api/subscriptions/events", nil)
+	request_test = request_test.WithContext(auth.NewContext(request_test.Context(), auth.Anonymous))
+	request_test.Header.Set("Last-Event-ID", "abc")
+	w := httptest.NewRecorder()
+
+	handler.StreamEvents(w, request_test)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "invalid Last-Event-ID")
+	mockReplay.AssertNotCalled(t, "ListSince")
+}
+
+func TestStreamEvents_ForeignUserForbidden(t *testing.T) {
+	mockReplay := new(MockReplayStore)
+	logger := logger_module.Get()
+
+	handler := &EventStreamHandler{
+		hub:    stream.NewHub("", mockReplay, logger),
+		replay: mockReplay,
+		logger: logger,
+	}
+
+	// Обычный пользователь не может слушать чужие события
+	principal := &auth.Principal{UserID: uuid.New(), Roles: []auth.Role{auth.RoleUser}}
+	request_test := httptest.NewRequest("GET", "/api/subscriptions/events?user_id="+uuid.New().String(), nil)
+	request_test = request_test.WithContext(auth.NewContext(request_test.Context(), principal))
+	w := httptest.NewRecorder()
+
+	handler.StreamEvents(w, request_test)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	mockReplay.AssertNotCalled(t, "ListSince")
+}
@@ -0,0 +1,52 @@
+package api
+
+import (
+	"effective_mobile/internal/auth"
+	"effective_mobile/internal/tenant"
+	"effective_mobile/pkg/logger_module"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// Определяем организацию запроса и кладем ее в контекст, дальше репозиторий ограничивает ею все запросы.
+// Организация аутентифицированного запроса — из токена или API-ключа, а если они к организации
+// не привязаны — defaultTenant. Заголовок X-Tenant-ID выбирает организацию только при выключенной
+// аутентификации (auth.Anonymous), иначе он лишь должен совпадать с организацией учетных данных
+func NewTenantMiddleware(defaultTenant string, logger *logger_module.Logger) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			header := strings.TrimSpace(r.Header.Get(tenant.Header))
+			tenantID := defaultTenant
+			principal, ok := auth.FromContext(r.Context())
+			switch {
+			case ok && principal == auth.Anonymous:
+				if header != "" {
+					tenantID = header
+				}
+			case ok && principal.TenantID != "":
+				tenantID = principal.TenantID
+			}
+			if principal != auth.Anonymous && header != "" && header != tenantID {
+				requestLogger(r, logger).Error("Tenant header does not match credentials", "tenant_id", header, "status_code", http.StatusForbidden)
+				sendError(w, http.StatusForbidden, "tenant does not match credentials")
+				return
+			}
+
+			if tenantID == "" {
+				requestLogger(r, logger).Error("Request without tenant", "path", r.URL.Path, "status_code", http.StatusBadRequest)
+				sendError(w, http.StatusBadRequest, "tenant is required")
+				return
+			}
+			if !tenant.Valid(tenantID) {
+				requestLogger(r, logger).Error("Invalid tenant id", "tenant_id", tenantID, "status_code", http.StatusBadRequest)
+				sendError(w, http.StatusBadRequest, "invalid tenant id")
+				return
+			}
+
+			r = withLogAttrs(r, logger, "tenant_id", tenantID)
+			next.ServeHTTP(w, r.WithContext(tenant.NewContext(r.Context(), tenantID)))
+		})
+	}
+}
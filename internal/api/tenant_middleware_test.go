@@ -0,0 +1,68 @@
+package api
+
+import (
+	"effective_mobile/internal/auth"
+	"effective_mobile/internal/tenant"
+	"effective_mobile/pkg/logger_module"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTenantMiddleware(t *testing.T) {
+	whoami := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenantID, _ := tenant.FromContext(r.Context())
+		w.Write([]byte(tenantID))
+	})
+
+	user := func(tenantID string) *auth.Principal {
+		return &auth.Principal{UserID: uuid.New(), Method: auth.MethodJWT, Roles: []auth.Role{auth.RoleUser}, TenantID: tenantID}
+	}
+	admin := &auth.Principal{Subject: "ops", Method: auth.MethodJWT, Roles: []auth.Role{auth.RoleAdmin}}
+
+	cases := []struct {
+		name          string
+		defaultTenant string
+		principal     *auth.Principal
+		header        string
+		status        int
+		tenantID      string
+	}{
+		{"default tenant", tenant.Default, user(""), "", http.StatusOK, tenant.Default},
+		{"token tenant", tenant.Default, user("acme"), "", http.StatusOK, "acme"},
+		{"header matches token", tenant.Default, user("acme"), "acme", http.StatusOK, "acme"},
+		{"header differs from token", tenant.Default, user("acme"), "globex", http.StatusForbidden, ""},
+		{"header matches default tenant", tenant.Default, user(""), tenant.Default, http.StatusOK, tenant.Default},
+		// Токен без tenant_id не дает выбрать организацию заголовком, даже администратору
+		{"untenanted token with header", tenant.Default, user(""), "globex", http.StatusForbidden, ""},
+		{"untenanted admin with header", tenant.Default, admin, "globex", http.StatusForbidden, ""},
+		{"untenanted token without default", "", user(""), "globex", http.StatusForbidden, ""},
+		{"anonymous header", tenant.Default, auth.Anonymous, "acme", http.StatusOK, "acme"},
+		{"anonymous invalid header", tenant.Default, auth.Anonymous, "Acme Corp", http.StatusBadRequest, ""},
+		{"anonymous tenant required", "", auth.Anonymous, "", http.StatusBadRequest, ""},
+		{"tenant required", "", user(""), "", http.StatusBadRequest, ""},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			handler := NewTenantMiddleware(tc.defaultTenant, logger_module.Get())(whoami)
+
+			request_test := httptest.NewRequest("GET", "/api/subscriptions", nil)
+			request_test = request_test.WithContext(auth.NewContext(request_test.Context(), tc.principal))
+			if tc.header != "" {
+				request_test.Header.Set(tenant.Header, tc.header)
+			}
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, request_test)
+
+			assert.Equal(t, tc.status, w.Code)
+			if tc.status == http.StatusOK {
+				assert.Equal(t, tc.tenantID, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package api
+
+import (
+	"effective_mobile/internal/objects"
+	"effective_mobile/pkg/logger_module"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracingMiddleware_ContinuesTraceparent(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	mock_service := new(MockSubscriptionService)
+	handler := &SubscriptionHandler{service: mock_service, logger: logger_module.Get()}
+	testID := uuid.New()
+	mock_service.On("GetByID", mock.Anything, testID).Return(&objects.Subscription{ID: testID, ServiceName: "Netflix", Price: 599}, nil)
+
+	router := mux.NewRouter()
+	router.Use(NewTracingMiddleware())
+	router.HandleFunc("/api/subscriptions/{id}", handler.GetSubscription).Methods("GET")
+
+	request_test := httptest.NewRequest("GET", "/api/subscriptions/"+testID.String(), nil)
+	request_test.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, request_test)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Серверный спан и спан обработчика продолжают трейс клиента
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+	handlerSpan, serverSpan := spans[0], spans[1]
+	assert.Equal(t, "SubscriptionHandler.GetSubscription", handlerSpan.Name())
+	assert.Equal(t, "GET /api/subscriptions/{id}", serverSpan.Name())
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", serverSpan.SpanContext().TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", serverSpan.Parent().SpanID().String())
+	assert.Equal(t, serverSpan.SpanContext().SpanID(), handlerSpan.Parent().SpanID())
+
+	// В ответе traceparent серверного спана
+	assert.Contains(t, w.Header().Get("traceparent"), serverSpan.SpanContext().SpanID().String())
+}
@@ -0,0 +1,224 @@
+package api
+
+import (
+	"context"
+	"effective_mobile/internal/objects"
+	"effective_mobile/internal/service"
+	"effective_mobile/pkg/logger_module"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+type WebhookHandler struct {
+	service service.WebhookServiceI
+	logger  *logger_module.Logger
+}
+
+func NewWebhookHandler(service service.WebhookServiceI, logger *logger_module.Logger) *WebhookHandler {
+	return &WebhookHandler{service: service, logger: logger}
+}
+
+func (handler *WebhookHandler) RegisterRouter(router *mux.Router) {
+	router.HandleFunc("/webhooks", handler.CreateWebhook).Methods("POST")
+	router.HandleFunc("/webhooks", handler.GetListWebhook).Methods("GET")
+	router.HandleFunc("/webhooks/{id:[0-9a-fA-F-]{36}}", handler.GetWebhook).Methods("GET")
+	router.HandleFunc("/webhooks/{id:[0-9a-fA-F-]{36}}", handler.DeleteWebhook).Methods("DELETE")
+	router.HandleFunc("/webhooks/{id:[0-9a-fA-F-]{36}}/deliveries", handler.GetWebhookDeliveries).Methods("GET")
+}
+
+// Ошибки сервиса вебхуков в HTTP-коды
+func (handler *WebhookHandler) sendServiceError(w http.ResponseWriter, r *http.Request, err error) {
+	logger := requestLogger(r, handler.logger)
+	if sendAccessError(w, logger, err) {
+		return
+	}
+	switch {
+	case errors.Is(err, service.ErrInvalidWebhook):
+		logger.Error("Invalid webhook request", "error", err.Error(), "status_code", http.StatusBadRequest)
+		sendError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, service.ErrWebhookNotFound):
+		logger.Error("Webhook not found", "error", err.Error(), "status_code", http.StatusNotFound)
+		sendError(w, http.StatusNotFound, "webhook not found")
+	default:
+		logger.Error("Webhook service failed", "error", err.Error(), "status_code", http.StatusInternalServerError)
+		sendError(w, http.StatusInternalServerError, "internal server error")
+	}
+}
+
+// Данная ручка регистрирует вебхук
+// @Summary Зарегистрировать вебхук
+// @Description Регистрируем получателя событий по подпискам. Запросы подписываются HMAC-SHA256 в заголовке X-Webhook-Signature (t=<unix>,v1=<hex>), секрет возвращается только в ответе на создание
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Param webhook body objects.WebhookCreateRequest true "Данные вебхука"
+// @Success 201 {object} objects.Webhook
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/webhooks [post]
+func (handler *WebhookHandler) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	logger := requestLogger(r, handler.logger)
+	logger.Info("CreateWebhook handler called", "method", r.Method, "path", r.URL.Path)
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	var req objects.WebhookCreateRequest
+	logger.Debug("Decode request body")
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.Error("failed to request body", "error", err.Error(), "status_code", http.StatusBadRequest)
+		sendError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	logger.Debug("Calling service to create webhook", "url", req.URL)
+	hook, err := handler.service.Create(ctx, &req)
+	if err != nil {
+		handler.sendServiceError(w, r, err)
+		return
+	}
+	logger.Info("Webhook created successfully", "webhook_id", hook.ID)
+	renderJSON(w, http.StatusCreated, hook)
+}
+
+// Данная ручка возвращает список вебхуков
+// @Summary Получаем вебхуки
+// @Description Получаем все зарегистрированные вебхуки (без секретов)
+// @Tags webhooks
+// @Produce json
+// @Success 200 {array} objects.Webhook
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/webhooks [get]
+func (handler *WebhookHandler) GetListWebhook(w http.ResponseWriter, r *http.Request) {
+	logger := requestLogger(r, handler.logger)
+	logger.Info("GetListWebhook handler called", "method", r.Method, "path", r.URL.Path)
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	hooks, err := handler.service.List(ctx)
+	if err != nil {
+		handler.sendServiceError(w, r, err)
+		return
+	}
+	logger.Info("Successfully get list webhooks", "count", len(hooks))
+	renderJSON(w, http.StatusOK, hooks)
+}
+
+// Данная ручка возвращает вебхук по ID
+// @Summary Получить вебхук
+// @Description Получаем вебхук по id (без секрета)
+// @Tags webhooks
+// @Produce json
+// @Param id path string true "ID вебхука" format(uuid) example("550e8400-e29b-41d4-a716-446655440000")
+// @Success 200 {object} objects.Webhook
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/webhooks/{id} [get]
+func (handler *WebhookHandler) GetWebhook(w http.ResponseWriter, r *http.Request) {
+	logger := requestLogger(r, handler.logger)
+	logger.Info("GetWebhook handler called", "method", r.Method, "path", r.URL.Path)
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		logger.Error("Invalid webhook ID format", "error", err.Error(), "status_code", http.StatusBadRequest)
+		sendError(w, http.StatusBadRequest, "invalid webhook id")
+		return
+	}
+
+	hook, err := handler.service.GetByID(ctx, id)
+	if err != nil {
+		handler.sendServiceError(w, r, err)
+		return
+	}
+	renderJSON(w, http.StatusOK, hook)
+}
+
+// Данная ручка удаляет вебхук
+// @Summary Удаление вебхука
+// @Description Удаляем вебхук вместе с журналом его доставок
+// @Tags webhooks
+// @Produce json
+// @Param id path string true "ID вебхука" format(uuid) example("550e8400-e29b-41d4-a716-446655440000")
+// @Success 204 "Вебхук успешно удален"
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/webhooks/{id} [delete]
+func (handler *WebhookHandler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	logger := requestLogger(r, handler.logger)
+	logger.Info("DeleteWebhook handler called", "method", r.Method, "path", r.URL.Path)
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		logger.Error("Invalid webhook ID format", "error", err.Error(), "status_code", http.StatusBadRequest)
+		sendError(w, http.StatusBadRequest, "invalid webhook id")
+		return
+	}
+
+	if err := handler.service.Delete(ctx, id); err != nil {
+		handler.sendServiceError(w, r, err)
+		return
+	}
+	logger.Info("Successfully delete webhook", "id", id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Данная ручка возвращает журнал доставок вебхука
+// @Summary Журнал доставок
+// @Description Получаем попытки доставки событий вебхуку, новые сверху. Статусы: pending, delivered, dead
+// @Tags webhooks
+// @Produce json
+// @Param id path string true "ID вебхука" format(uuid) example("550e8400-e29b-41d4-a716-446655440000")
+// @Param status query string false "Фильтр по статусу" Enums(pending, delivered, dead)
+// @Param limit query integer false "Лимит записей (по умолчанию 10)"
+// @Param offset query integer false "Смещение (по умолчанию 0)"
+// @Success 200 {array} objects.WebhookDelivery
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/webhooks/{id}/deliveries [get]
+func (handler *WebhookHandler) GetWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	logger := requestLogger(r, handler.logger)
+	logger.Info("GetWebhookDeliveries handler called", "method", r.Method, "path", r.URL.Path)
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		logger.Error("Invalid webhook ID format", "error", err.Error(), "status_code", http.StatusBadRequest)
+		sendError(w, http.StatusBadRequest, "invalid webhook id")
+		return
+	}
+
+	params := r.URL.Query()
+	limit, _ := strconv.Atoi(params.Get("limit"))
+	offset, _ := strconv.Atoi(params.Get("offset"))
+
+	deliveries, err := handler.service.ListDeliveries(ctx, id, params.Get("status"), limit, offset)
+	if err != nil {
+		handler.sendServiceError(w, r, err)
+		return
+	}
+	logger.Info("Successfully get webhook deliveries", "webhook_id", id, "count", len(deliveries))
+	renderJSON(w, http.StatusOK, deliveries)
+}
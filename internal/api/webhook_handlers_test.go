@@ -0,0 +1,179 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"effective_mobile/internal/objects"
+	"effective_mobile/internal/service"
+	"effective_mobile/pkg/logger_module"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockWebhookService struct {
+	mock.Mock
+}
+
+func (m *MockWebhookService) Create(ctx context.Context, req *objects.WebhookCreateRequest) (*objects.Webhook, error) {
+	args := m.Called(ctx, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*objects.Webhook), args.Error(1)
+}
+
+func (m *MockWebhookService) List(ctx context.Context) ([]*objects.Webhook, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*objects.Webhook), args.Error(1)
+}
+
+func (m *MockWebhookService) GetByID(ctx context.Context, id uuid.UUID) (*objects.Webhook, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*objects.Webhook), args.Error(1)
+}
+
+func (m *MockWebhookService) Delete(ctx context.Context, id uuid.UUID) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockWebhookService) ListDeliveries(ctx context.Context, webhookID uuid.UUID, status string, limit, offset int) ([]*objects.WebhookDelivery, error) {
+	args := m.Called(ctx, webhookID, status, limit, offset)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*objects.WebhookDelivery), args.Error(1)
+}
+
+func TestCreateWebhook_Success(t *testing.T) {
+	mockService := new(MockWebhookService)
+	logger := logger_module.Get()
+
+	handler := &WebhookHandler{
+		service: mockService,
+		logger:  logger,
+	}
+
+	test_req := &objects.WebhookCreateRequest{
+		URL:        "https://billing.example.com/hooks",
+		EventTypes: []string{"subscription.created"},
+	}
+	created := &objects.Webhook{
+		ID:         uuid.New(),
+		URL:        test_req.URL,
+		Secret:     "generated-secret",
+		EventTypes: test_req.EventTypes,
+		Active:     true,
+	}
+	mockService.On("Create", mock.Anything, test_req).Return(created, nil)
+
+	test_body := `{"url": "https://billing.example.com/hooks", "event_types": ["subscription.created"]}`
+	request_test := httptest.NewRequest("POST", "/api/webhooks", bytes.NewBufferString(test_body))
+	w := httptest.NewRecorder()
+
+	handler.CreateWebhook(w, request_test)
+
+	// Проверка
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var response objects.Webhook
+	err := json.NewDecoder(w.Body).Decode(&response)
+	assert.NoError(t, err)
+	assert.Equal(t, created.ID, response.ID)
+	// Секрет отдается только при создании
+	assert.Equal(t, "generated-secret", response.Secret)
+	mockService.AssertExpectations(t)
+}
+
+func TestCreateWebhook_InvalidURL(t *testing.T) {
+	mockService := new(MockWebhookService)
+	logger := logger_module.Get()
+
+	handler := &WebhookHandler{
+		service: mockService,
+		logger:  logger,
+	}
+
+	mockService.On("Create", mock.Anything, mock.Anything).
+		Return(nil, fmt.Errorf("%w: url must be absolute http(s) url", service.ErrInvalidWebhook))
+
+	request_test := httptest.NewRequest("POST", "/api/webhooks", bytes.NewBufferString(`{"url": "ftp://example.com"}`))
+	w := httptest.NewRecorder()
+
+	handler.CreateWebhook(w, request_test)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "url must be absolute")
+	mockService.AssertExpectations(t)
+}
+
+func TestDeleteWebhook_NotFound(t *testing.T) {
+	mockService := new(MockWebhookService)
+	logger := logger_module.Get()
+
+	handler := &WebhookHandler{
+		service: mockService,
+		logger:  logger,
+	}
+
+	testID := uuid.New()
+	mockService.On("Delete", mock.Anything, testID).Return(service.ErrWebhookNotFound)
+
+	request_test := httptest.NewRequest("DELETE", "/api/webhooks/"+testID.String(), nil)
+	request_test = mux.SetURLVars(request_test, map[string]string{"id": testID.String()})
+	w := httptest.NewRecorder()
+
+	handler.DeleteWebhook(w, request_test)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Body.String(), "webhook not found")
+	mockService.AssertExpectations(t)
+}
+
+func TestGetWebhookDeliveries_Success(t *testing.T) {
+	mockService := new(MockWebhookService)
+	logger := logger_module.Get()
+
+	handler := &WebhookHandler{
+		service: mockService,
+		logger:  logger,
+	}
+
+	testID := uuid.New()
+	statusCode := 500
+	deliveries := []*objects.WebhookDelivery{
+		{ID: uuid.New(), WebhookID: testID, EventType: "subscription.deleted", Status: objects.DeliveryDead, Attempts: 8, LastStatusCode: &statusCode},
+	}
+	mockService.On("ListDeliveries", mock.Anything, testID, "dead", 5, 0).Return(deliveries, nil)
+
+	request_test := httptest.NewRequest("GET", "/api/webhooks/"+testID.String()+"/deliveries?status=dead&limit=5", nil)
+	request_test = mux.SetURLVars(request_test, map[string]string{"id": testID.String()})
+	w := httptest.NewRecorder()
+
+	handler.GetWebhookDeliveries(w, request_test)
+
+	// Проверка
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response []objects.WebhookDelivery
+	err := json.NewDecoder(w.Body).Decode(&response)
+	assert.NoError(t, err)
+	assert.Len(t, response, 1)
+	assert.Equal(t, objects.DeliveryDead, response[0].Status)
+	assert.Equal(t, 8, response[0].Attempts)
+	mockService.AssertExpectations(t)
+}
@@ -0,0 +1,52 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"effective_mobile/internal/objects"
+	"encoding/hex"
+	"strings"
+)
+
+// Префикс ключей, по нему ключ отличается от JWT в заголовке Authorization
+const APIKeyPrefix = "em_"
+
+// Хранилище ключей. Если ключа нет или он отозван — ErrInvalidCredentials
+type APIKeyStore interface {
+	FindByHash(ctx context.Context, hash string) (*objects.APIKey, error)
+}
+
+// Новый ключ: сам ключ отдается клиенту один раз, в базе хранится только его хэш
+func GenerateAPIKey() (string, error) {
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	return APIKeyPrefix + hex.EncodeToString(random), nil
+}
+
+// Ключ случайный и длинный, поэтому медленный хэш не нужен: SHA-256 достаточно
+// и позволяет искать ключ по индексу
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func IsAPIKey(credential string) bool {
+	return strings.HasPrefix(credential, APIKeyPrefix)
+}
+
+func authenticateAPIKey(ctx context.Context, store APIKeyStore, key string) (*Principal, error) {
+	apiKey, err := store.FindByHash(ctx, HashAPIKey(key))
+	if err != nil {
+		return nil, err
+	}
+	return &Principal{
+		Subject:  apiKey.ID.String(),
+		UserID:   apiKey.UserID,
+		Method:   MethodAPIKey,
+		Roles:    ParseRoles(apiKey.Roles),
+		TenantID: apiKey.TenantID,
+	}, nil
+}
@@ -0,0 +1,218 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"effective_mobile/internal/objects"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeKeyStore struct {
+	keys map[string]*objects.APIKey
+}
+
+func (s *fakeKeyStore) FindByHash(ctx context.Context, hash string) (*objects.APIKey, error) {
+	key, ok := s.keys[hash]
+	if !ok {
+		return nil, ErrInvalidCredentials
+	}
+	return key, nil
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, key interface{}, kid string, claims jwt.RegisteredClaims) string {
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	assert.NoError(t, err)
+	return signed
+}
+
+func validClaims(subject string) jwt.RegisteredClaims {
+	return jwt.RegisteredClaims{
+		Subject:   subject,
+		Issuer:    "https://id.example.com",
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}
+}
+
+func TestAuthenticate_APIKey(t *testing.T) {
+	raw, err := GenerateAPIKey()
+	assert.NoError(t, err)
+	apiKey := &objects.APIKey{ID: uuid.New(), UserID: uuid.New()}
+	authenticator := NewAuthenticator(&fakeKeyStore{keys: map[string]*objects.APIKey{HashAPIKey(raw): apiKey}}, nil, nil)
+
+	principal, err := authenticator.Authenticate(context.Background(), raw)
+	assert.NoError(t, err)
+	assert.Equal(t, apiKey.UserID, principal.UserID)
+	assert.Equal(t, MethodAPIKey, principal.Method)
+
+	_, err = authenticator.Authenticate(context.Background(), APIKeyPrefix+"unknown")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+
+	// JWT без настроенных ключей не принимаются
+	_, err = authenticator.Authenticate(context.Background(), "eyJhbGciOiJIUzI1NiJ9.e30.sig")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+
+	_, err = authenticator.Authenticate(context.Background(), "")
+	assert.ErrorIs(t, err, ErrNoCredentials)
+
+	// Без хранилища ключей (STORAGE=memory) ключи отклоняются, а не роняют запрос
+	_, err = NewAuthenticator(nil, nil, nil).Authenticate(context.Background(), raw)
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+}
+
+func TestJWTVerifier_HS256(t *testing.T) {
+	verifier, err := NewJWTVerifier(JWTConfig{HS256Secret: "test-secret", Issuer: "https://id.example.com"})
+	assert.NoError(t, err)
+
+	userID := uuid.New()
+	principal, err := verifier.Verify(sign(t, jwt.SigningMethodHS256, []byte("test-secret"), "", validClaims(userID.String())))
+	assert.NoError(t, err)
+	assert.Equal(t, userID, principal.UserID)
+	assert.Equal(t, MethodJWT, principal.Method)
+
+	// Сервисный токен: sub не UUID
+	principal, err = verifier.Verify(sign(t, jwt.SigningMethodHS256, []byte("test-secret"), "", validClaims("billing-service")))
+	assert.NoError(t, err)
+	assert.Equal(t, uuid.Nil, principal.UserID)
+
+	expired := validClaims(userID.String())
+	expired.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Hour))
+	_, err = verifier.Verify(sign(t, jwt.SigningMethodHS256, []byte("test-secret"), "", expired))
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+
+	otherIssuer := validClaims(userID.String())
+	otherIssuer.Issuer = "https://evil.example.com"
+	_, err = verifier.Verify(sign(t, jwt.SigningMethodHS256, []byte("test-secret"), "", otherIssuer))
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+
+	_, err = verifier.Verify(sign(t, jwt.SigningMethodHS256, []byte("wrong-secret"), "", validClaims(userID.String())))
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+}
+
+func TestJWTVerifier_RS256FromJWKS(t *testing.T) {
+	private, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+
+	jwks, _ := json.Marshal(map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "key-1",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(private.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(private.E)).Bytes()),
+		}},
+	})
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	assert.NoError(t, os.WriteFile(path, jwks, 0600))
+
+	verifier, err := NewJWTVerifier(JWTConfig{JWKSFile: path})
+	assert.NoError(t, err)
+
+	userID := uuid.New()
+	principal, err := verifier.Verify(sign(t, jwt.SigningMethodRS256, private, "key-1", validClaims(userID.String())))
+	assert.NoError(t, err)
+	assert.Equal(t, userID, principal.UserID)
+
+	_, err = verifier.Verify(sign(t, jwt.SigningMethodRS256, private, "key-2", validClaims(userID.String())))
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+
+	// HS256 не принимается, если секрет не настроен, даже если подписать публичным ключом
+	_, err = verifier.Verify(sign(t, jwt.SigningMethodHS256, private.N.Bytes(), "key-1", validClaims(userID.String())))
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+}
+
+func TestNewJWTVerifier_NoKeys(t *testing.T) {
+	verifier, err := NewJWTVerifier(JWTConfig{})
+	assert.NoError(t, err)
+	assert.Nil(t, verifier)
+}
+
+func TestJWTVerifier_Roles(t *testing.T) {
+	verifier, err := NewJWTVerifier(JWTConfig{HS256Secret: "test-secret"})
+	assert.NoError(t, err)
+
+	signWithRoles := func(subject string, roles []string) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims{RegisteredClaims: validClaims(subject), Roles: roles})
+		signed, err := token.SignedString([]byte("test-secret"))
+		assert.NoError(t, err)
+		return signed
+	}
+
+	// Неизвестные роли отбрасываются
+	principal, err := verifier.Verify(signWithRoles(uuid.NewString(), []string{"finance", "root"}))
+	assert.NoError(t, err)
+	assert.Equal(t, []Role{RoleFinance}, principal.Roles)
+	assert.True(t, principal.HasRole(RoleAdmin, RoleFinance))
+	assert.False(t, principal.HasRole(RoleAdmin))
+
+	// Пользователь без ролей в токене — user, сервисный токен без ролей — без прав
+	principal, err = verifier.Verify(signWithRoles(uuid.NewString(), nil))
+	assert.NoError(t, err)
+	assert.Equal(t, []Role{RoleUser}, principal.Roles)
+
+	principal, err = verifier.Verify(signWithRoles("exporter", nil))
+	assert.NoError(t, err)
+	assert.Empty(t, principal.Roles)
+}
+
+func TestJWTVerifier_Tenant(t *testing.T) {
+	verifier, err := NewJWTVerifier(JWTConfig{HS256Secret: "test-secret"})
+	assert.NoError(t, err)
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims{RegisteredClaims: validClaims(uuid.NewString()), TenantID: "acme"})
+	signed, err := token.SignedString([]byte("test-secret"))
+	assert.NoError(t, err)
+
+	principal, err := verifier.Verify(signed)
+	assert.NoError(t, err)
+	assert.Equal(t, "acme", principal.TenantID)
+}
+
+func TestFeedTokens(t *testing.T) {
+	feeds, err := NewFeedTokens("feed-secret", time.Hour)
+	assert.NoError(t, err)
+	userID := uuid.New()
+	principal := &Principal{Subject: "key-id", UserID: userID, Method: MethodAPIKey, Roles: []Role{RoleUser}, TenantID: "acme"}
+	icsPath := "/api/users/" + userID.String() + "/renewals.ics"
+
+	token, expires, err := feeds.Issue(principal, icsPath, time.Now())
+	assert.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), expires, 2*time.Second)
+
+	verified, err := feeds.Verify(token, icsPath)
+	assert.NoError(t, err)
+	assert.Equal(t, &Principal{Subject: "key-id", UserID: userID, Method: MethodFeedToken, Roles: []Role{RoleUser}, TenantID: "acme"}, verified)
+
+	// Только для своего пути и со своим ключом
+	_, err = feeds.Verify(token, "/api/subscriptions/events")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+	other, err := NewFeedTokens("other-secret", time.Hour)
+	assert.NoError(t, err)
+	_, err = other.Verify(token, icsPath)
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+
+	expired, _, err := feeds.Issue(principal, icsPath, time.Now().Add(-2*time.Hour))
+	assert.NoError(t, err)
+	_, err = feeds.Verify(expired, icsPath)
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+
+	for _, path := range []string{"/api/subscriptions", "/api/../admin/config.ics", "/swagger/x.ics"} {
+		_, _, err = feeds.Issue(principal, path, time.Now())
+		assert.ErrorIs(t, err, ErrNotFeedPath, path)
+	}
+	assert.True(t, IsFeedPath("/api/subscriptions/events"))
+}
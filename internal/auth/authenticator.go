@@ -0,0 +1,43 @@
+package auth
+
+import "context"
+
+// Проверяет учетные данные запроса: API-ключ, JWT или токен фида
+type Authenticator struct {
+	apiKeys APIKeyStore  // nil — API-ключи не принимаются (хранилище в памяти)
+	jwt     *JWTVerifier // nil — JWT не принимаются
+	feeds   *FeedTokens  // nil — токены фидов не принимаются
+}
+
+func NewAuthenticator(apiKeys APIKeyStore, jwt *JWTVerifier, feeds *FeedTokens) *Authenticator {
+	return &Authenticator{apiKeys: apiKeys, jwt: jwt, feeds: feeds}
+}
+
+// Ключ узнаем по префиксу, все остальное считаем JWT.
+// ErrInvalidCredentials — данные неверны, любая другая ошибка — сбой проверки
+func (authenticator *Authenticator) Authenticate(ctx context.Context, credential string) (*Principal, error) {
+	if credential == "" {
+		return nil, ErrNoCredentials
+	}
+	if IsAPIKey(credential) {
+		if authenticator.apiKeys == nil {
+			return nil, ErrInvalidCredentials
+		}
+		return authenticateAPIKey(ctx, authenticator.apiKeys, credential)
+	}
+	if authenticator.jwt == nil {
+		return nil, ErrInvalidCredentials
+	}
+	return authenticator.jwt.Verify(credential)
+}
+
+// Токен фида из параметра access_token, действует только для пути, для которого выпущен
+func (authenticator *Authenticator) AuthenticateFeed(token, urlPath string) (*Principal, error) {
+	if token == "" {
+		return nil, ErrNoCredentials
+	}
+	if authenticator.feeds == nil {
+		return nil, ErrInvalidCredentials
+	}
+	return authenticator.feeds.Verify(token, urlPath)
+}
@@ -0,0 +1,112 @@
+package auth
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// Запрос с токеном фида
+const MethodFeedToken = "feed_token"
+
+// aud токенов фидов: такой токен не принимается как обычный JWT
+const feedTokenAudience = "feed"
+
+var ErrNotFeedPath = errors.New("path is not a feed")
+
+// Фиды, которые клиенты читают без заголовков: поток событий и календари .ics
+func IsFeedPath(urlPath string) bool {
+	if path.Clean(urlPath) != urlPath || !strings.HasPrefix(urlPath, "/api/") {
+		return false
+	}
+	return urlPath == "/api/subscriptions/events" || strings.HasSuffix(urlPath, ".ics")
+}
+
+// Короткоживущие токены для чтения одного фида. EventSource и календари передают учетные данные
+// только в URL, а URL попадает в журналы прокси и историю браузера, поэтому вместо API-ключа или JWT
+// в параметре access_token принимается токен, выпущенный для конкретного пути и только для GET
+type FeedTokens struct {
+	secret []byte
+	ttl    time.Duration
+}
+
+// Пустой secret — случайный ключ процесса: токены действуют только на выпустившей их реплике
+func NewFeedTokens(secret string, ttl time.Duration) (*FeedTokens, error) {
+	key := []byte(secret)
+	if len(key) == 0 {
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, err
+		}
+	}
+	return &FeedTokens{secret: key, ttl: ttl}, nil
+}
+
+// Права токена — права principal на момент выпуска; отзыв ключа действует на токен по истечении ttl
+type feedClaims struct {
+	jwt.RegisteredClaims
+	Path     string    `json:"path"`
+	UserID   uuid.UUID `json:"uid"`
+	Roles    []string  `json:"roles,omitempty"`
+	TenantID string    `json:"tenant_id,omitempty"`
+}
+
+// Выпускаем токен principal для чтения urlPath, возвращаем токен и время истечения
+func (tokens *FeedTokens) Issue(principal *Principal, urlPath string, now time.Time) (string, time.Time, error) {
+	if !IsFeedPath(urlPath) {
+		return "", time.Time{}, fmt.Errorf("%w: %s", ErrNotFeedPath, urlPath)
+	}
+	expires := now.Add(tokens.ttl).Truncate(time.Second)
+	roles := make([]string, 0, len(principal.Roles))
+	for _, role := range principal.Roles {
+		roles = append(roles, string(role))
+	}
+	claims := feedClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   principal.Subject,
+			Audience:  jwt.ClaimStrings{feedTokenAudience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expires),
+		},
+		Path:     urlPath,
+		UserID:   principal.UserID,
+		Roles:    roles,
+		TenantID: principal.TenantID,
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(tokens.secret)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return token, expires, nil
+}
+
+// Проверяем подпись, срок и путь, для которого выпущен токен
+func (tokens *FeedTokens) Verify(raw, urlPath string) (*Principal, error) {
+	var claims feedClaims
+	_, err := jwt.ParseWithClaims(raw, &claims, func(*jwt.Token) (interface{}, error) { return tokens.secret, nil },
+		jwt.WithValidMethods([]string{"HS256"}),
+		jwt.WithExpirationRequired(),
+		jwt.WithAudience(feedTokenAudience),
+		jwt.WithLeeway(clockSkew),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+	}
+	if claims.Path != urlPath {
+		return nil, fmt.Errorf("%w: feed token is issued for %s", ErrInvalidCredentials, claims.Path)
+	}
+
+	return &Principal{
+		Subject:  claims.Subject,
+		UserID:   claims.UserID,
+		Method:   MethodFeedToken,
+		Roles:    ParseRoles(claims.Roles),
+		TenantID: claims.TenantID,
+	}, nil
+}
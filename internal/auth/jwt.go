@@ -0,0 +1,175 @@
+package auth
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// Допустимое расхождение часов с выпускающим токены сервисом
+const clockSkew = 30 * time.Second
+
+// Проверка JWT: HS256 с общим секретом и RS256 с публичными ключами (PEM или JWKS)
+type JWTVerifier struct {
+	secret   []byte
+	keys     map[string]*rsa.PublicKey // kid -> ключ, "" — ключ без kid
+	issuer   string
+	audience string
+}
+
+type JWTConfig struct {
+	HS256Secret  string
+	RSAPublicKey string // путь к PEM-файлу
+	JWKSFile     string // путь к локальному JWKS
+	Issuer       string
+	Audience     string
+}
+
+// Возвращает nil, если не задан ни один ключ: JWT тогда не принимаются
+func NewJWTVerifier(conf JWTConfig) (*JWTVerifier, error) {
+	verifier := &JWTVerifier{
+		secret:   []byte(conf.HS256Secret),
+		keys:     make(map[string]*rsa.PublicKey),
+		issuer:   conf.Issuer,
+		audience: conf.Audience,
+	}
+	if conf.RSAPublicKey != "" {
+		pem, err := os.ReadFile(conf.RSAPublicKey)
+		if err != nil {
+			return nil, err
+		}
+		key, err := jwt.ParseRSAPublicKeyFromPEM(pem)
+		if err != nil {
+			return nil, fmt.Errorf("parse %s: %w", conf.RSAPublicKey, err)
+		}
+		verifier.keys[""] = key
+	}
+	if conf.JWKSFile != "" {
+		keys, err := LoadJWKS(conf.JWKSFile)
+		if err != nil {
+			return nil, err
+		}
+		for kid, key := range keys {
+			verifier.keys[kid] = key
+		}
+	}
+	if len(verifier.secret) == 0 && len(verifier.keys) == 0 {
+		return nil, nil
+	}
+	return verifier, nil
+}
+
+// Проверяем подпись, срок действия, iss и aud. sub обязателен
+func (verifier *JWTVerifier) Verify(raw string) (*Principal, error) {
+	options := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{"HS256", "RS256"}),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(clockSkew),
+	}
+	if verifier.issuer != "" {
+		options = append(options, jwt.WithIssuer(verifier.issuer))
+	}
+	if verifier.audience != "" {
+		options = append(options, jwt.WithAudience(verifier.audience))
+	}
+
+	var claims claims
+	if _, err := jwt.ParseWithClaims(raw, &claims, verifier.key, options...); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+	}
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("%w: token has no subject", ErrInvalidCredentials)
+	}
+
+	// sub с UUID — пользователь, иначе сервисный токен
+	principal := &Principal{Subject: claims.Subject, Method: MethodJWT, Roles: ParseRoles(claims.Roles), TenantID: claims.TenantID}
+	if userID, err := uuid.Parse(claims.Subject); err == nil {
+		principal.UserID = userID
+		if len(claims.Roles) == 0 {
+			principal.Roles = []Role{RoleUser}
+		}
+	}
+	return principal, nil
+}
+
+// Ключ выбирается по алгоритму из заголовка: секрет никогда не используется для RS256 и наоборот
+func (verifier *JWTVerifier) key(token *jwt.Token) (interface{}, error) {
+	switch token.Method.Alg() {
+	case "HS256":
+		if len(verifier.secret) == 0 {
+			return nil, errors.New("HS256 tokens are not accepted")
+		}
+		return verifier.secret, nil
+	case "RS256":
+		kid, _ := token.Header["kid"].(string)
+		if key, ok := verifier.keys[kid]; ok {
+			return key, nil
+		}
+		// Единственный ключ подходит и для токенов без kid
+		if kid == "" && len(verifier.keys) == 1 {
+			for _, key := range verifier.keys {
+				return key, nil
+			}
+		}
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	default:
+		return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+	}
+}
+
+// Роли передаются в claim roles, организация — в tenant_id; токен пользователя без ролей получает роль user
+type claims struct {
+	jwt.RegisteredClaims
+	Roles    []string `json:"roles,omitempty"`
+	TenantID string   `json:"tenant_id,omitempty"`
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// Читаем RSA-ключи из JWKS-файла (RFC 7517), остальные типы ключей пропускаем
+func LoadJWKS(path string) (map[string]*rsa.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey)
+	for _, key := range set.Keys {
+		if key.Kty != "RSA" || (key.Use != "" && key.Use != "sig") {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(key.N)
+		if err != nil {
+			return nil, fmt.Errorf("parse %s: key %q: %w", path, key.Kid, err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(key.E)
+		if err != nil {
+			return nil, fmt.Errorf("parse %s: key %q: %w", path, key.Kid, err)
+		}
+		keys[key.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("%s has no RSA signing keys", path)
+	}
+	return keys, nil
+}
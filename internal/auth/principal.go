@@ -0,0 +1,82 @@
+package auth
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+)
+
+// Способы аутентификации
+const (
+	MethodAPIKey = "api_key"
+	MethodJWT    = "jwt"
+	MethodSystem = "system"
+)
+
+// Роли: user работает только со своими подписками, finance дополнительно видит суммы
+// по всем пользователям, admin может все
+type Role string
+
+const (
+	RoleUser    Role = "user"
+	RoleFinance Role = "finance"
+	RoleAdmin   Role = "admin"
+)
+
+func IsKnownRole(role Role) bool {
+	return role == RoleUser || role == RoleFinance || role == RoleAdmin
+}
+
+var (
+	ErrNoCredentials      = errors.New("no credentials")
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// Кто выполняет запрос. Кладется в context.Context middleware и доступен в сервисном слое
+type Principal struct {
+	Subject  string    // sub из JWT или id API-ключа
+	UserID   uuid.UUID // пользователь, от имени которого выполняется запрос; uuid.Nil для сервисных токенов
+	Method   string
+	Roles    []Role
+	TenantID string // организация из токена или ключа; пусто — DEFAULT_TENANT
+}
+
+// Фоновые задачи работают от имени системы с правами администратора
+var System = &Principal{Subject: "system", Method: MethodSystem, Roles: []Role{RoleAdmin}}
+
+// При выключенной аутентификации API открыт, как и раньше, поэтому аноним получает права администратора
+var Anonymous = &Principal{Subject: "anonymous", Method: MethodSystem, Roles: []Role{RoleAdmin}}
+
+func (principal *Principal) HasRole(roles ...Role) bool {
+	for _, have := range principal.Roles {
+		for _, want := range roles {
+			if have == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Оставляем только известные роли
+func ParseRoles(values []string) []Role {
+	roles := make([]Role, 0, len(values))
+	for _, value := range values {
+		if role := Role(value); IsKnownRole(role) {
+			roles = append(roles, role)
+		}
+	}
+	return roles
+}
+
+type principalKey struct{}
+
+func NewContext(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+func FromContext(ctx context.Context) (*Principal, bool) {
+	principal, ok := ctx.Value(principalKey{}).(*Principal)
+	return principal, ok && principal != nil
+}
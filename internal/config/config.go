@@ -2,43 +2,420 @@ package config
 
 import (
 	"effective_mobile/pkg/logger_module"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
 
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 )
 
+// Переменная окружения с путем к файлу конфига, флаг --config важнее
+const ConfigFileEnv = "CONFIG_FILE"
+
+// Где хранятся подписки: в PostgreSQL, в файле SQLite для запуска на одной машине
+// или в памяти процесса для локальной разработки
+const (
+	StoragePostgres = "postgres"
+	StorageSQLite   = "sqlite"
+	StorageMemory   = "memory"
+)
+
+// Реализация репозитория подписок в Postgres: GORM или pgxpool без ORM для горячих путей
+const (
+	RepositoryGorm = "gorm"
+	RepositoryPgx  = "pgx"
+)
+
+type StorageConfig struct {
+	Driver     string `mapstructure:"driver"`      // postgres, sqlite или memory
+	SQLitePath string `mapstructure:"sqlite_path"` // файл базы при driver=sqlite
+}
+
+type HTTPConfig struct {
+	Port               string        `mapstructure:"port"`
+	ShutdownDrainDelay time.Duration `mapstructure:"shutdown_drain_delay"` // сколько /readyz отвечает 503 после SIGTERM до остановки сервера
+}
+
+type DBConfig struct {
+	URL              string        `mapstructure:"url"` // postgres://...; если задан, host, port, user и name не нужны
+	Host             string        `mapstructure:"host"`
+	Port             string        `mapstructure:"port"`
+	User             string        `mapstructure:"user"`
+	Password         string        `mapstructure:"password"`
+	PasswordFile     string        `mapstructure:"password_file"` // файл с паролем (секреты Docker и Kubernetes)
+	Name             string        `mapstructure:"name"`
+	SSLMode          string        `mapstructure:"sslmode"`
+	SSLRootCert      string        `mapstructure:"sslrootcert"`       // CA для sslmode=verify-ca и verify-full
+	ApplicationName  string        `mapstructure:"application_name"`  // видно в pg_stat_activity
+	StatementTimeout time.Duration `mapstructure:"statement_timeout"` // 0 — без ограничения
+
+	MaxOpenConns    int           `mapstructure:"max_open_conns"`     // 0 — без ограничения
+	MaxIdleConns    int           `mapstructure:"max_idle_conns"`     // простаивающие соединения в пуле
+	ConnMaxLifetime time.Duration `mapstructure:"conn_max_lifetime"`  // соединение закрывается после этого времени, 0 — никогда
+	ConnMaxIdleTime time.Duration `mapstructure:"conn_max_idle_time"` // простаивающее соединение закрывается после этого времени, 0 — никогда
+	ConnectTimeout  time.Duration `mapstructure:"connect_timeout"`    // сколько при старте ждем, пока база станет доступна
+	NoMigrate       bool          `mapstructure:"no_migrate"`         // не применять миграции при старте, их применяет отдельная задача
+	Repository      string        `mapstructure:"repository"`         // gorm или pgx
+}
+
+type LoggingConfig struct {
+	Level      string        `mapstructure:"level"`  // debug, info, warn или error
+	Format     string        `mapstructure:"format"` // text или json
+	File       string        `mapstructure:"file"`
+	MaxSizeMB  int64         `mapstructure:"max_size_mb"` // ротация по размеру, 0 — выключена
+	MaxAge     time.Duration `mapstructure:"max_age"`     // ротация по возрасту файла, 0 — выключена
+	MaxBackups int           `mapstructure:"max_backups"` // сколько архивов хранить, 0 — все
+	Compress   bool          `mapstructure:"compress"`    // сжимать архивы gzip
+}
+
+type AuthConfig struct {
+	Enabled          bool   `mapstructure:"enabled"`
+	JWTHS256Secret   string `mapstructure:"jwt_hs256_secret"`
+	JWTPublicKeyFile string `mapstructure:"jwt_rs256_public_key_file"` // PEM с публичным ключом RS256
+	JWTJWKSFile      string `mapstructure:"jwt_jwks_file"`             // локальный JWKS с ключами RS256
+	JWTIssuer        string `mapstructure:"jwt_issuer"`
+	JWTAudience      string `mapstructure:"jwt_audience"`
+	DefaultTenant    string `mapstructure:"default_tenant"` // организация для запросов без тенанта в токене и заголовке; пусто — тенант обязателен
+	// подпись токенов фидов (.ics и поток событий); пусто — случайный ключ, токены действуют на одной реплике
+	FeedTokenSecret string        `mapstructure:"feed_token_secret"`
+	FeedTokenTTL    time.Duration `mapstructure:"feed_token_ttl"` // срок действия токена фида
+}
+
+type SchedulerConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	RunHour int  `mapstructure:"run_hour"` // час ежедневного запуска задач по UTC
+}
+
+type WebhooksConfig struct {
+	MaxAttempts  int           `mapstructure:"max_attempts"`  // после стольких неудач доставка уходит в dead
+	PollInterval time.Duration `mapstructure:"poll_interval"` // как часто проверяем outbox и повторы
+	// внутренние сети (CIDR), куда разрешена доставка; остальные внутренние адреса запрещены
+	AllowedNetworks []string `mapstructure:"allowed_networks"`
+	// сколько хранить обработанные события outbox (и досылать их потоку событий), 0 — не удалять
+	EventRetention    time.Duration `mapstructure:"event_retention"`
+	DeliveryRetention time.Duration `mapstructure:"delivery_retention"` // сколько хранить доставки delivered и dead, 0 — не удалять
+}
+
+type NotifyConfig struct {
+	Sender       string `mapstructure:"sender"`    // smtp, file или stdout
+	FilePath     string `mapstructure:"file_path"` // куда пишутся письма при sender=file
+	From         string `mapstructure:"from"`
+	SMTPHost     string `mapstructure:"smtp_host"`
+	SMTPPort     string `mapstructure:"smtp_port"`
+	SMTPUsername string `mapstructure:"smtp_username"`
+	SMTPPassword string `mapstructure:"smtp_password"`
+}
+
+type RateLimitConfig struct {
+	Enabled          bool   `mapstructure:"enabled"`
+	Default          string `mapstructure:"default"`            // общее ограничение клиента, например 20/s:40
+	IP               string `mapstructure:"ip"`                 // ограничение IP-адреса до проверки учетных данных
+	Routes           string `mapstructure:"routes"`             // ограничения роутов: "GET /api/subscriptions/total=1/s:5,..."
+	TrustForwarded   bool   `mapstructure:"trust_forwarded"`    // брать IP клиента из X-Forwarded-For
+	QuotaTiers       string `mapstructure:"quota_tiers"`        // суточные квоты тарифов: "free=1000,pro=100000"
+	QuotaDefaultTier string `mapstructure:"quota_default_tier"` // тариф организаций без записи в tenant_plans
+	// как часто реплика записывает накопленные запросы в daily_usage
+	QuotaFlushInterval time.Duration `mapstructure:"quota_flush_interval"`
+}
+
+type CORSConfig struct {
+	AllowedOrigins []string `mapstructure:"allowed_origins"` // origin браузерных клиентов, "*" — любой; пусто — CORS выключен
+}
+
+type CacheConfig struct {
+	Enabled bool          `mapstructure:"enabled"` // кэшировать суммы /api/subscriptions/total в памяти процесса
+	Size    int           `mapstructure:"size"`    // сколько сумм хранить, давно не читанные вытесняются
+	TTL     time.Duration `mapstructure:"ttl"`     // время жизни суммы; изменения с других реплик видны не позже
+}
+
+type MetricsConfig struct {
+	Enabled bool `mapstructure:"enabled"` // отдавать метрики Prometheus на /metrics
+}
+
+type TracingConfig struct {
+	Exporter    string  `mapstructure:"exporter"`     // none, stdout или otlp (адрес в OTEL_EXPORTER_OTLP_ENDPOINT)
+	ServiceName string  `mapstructure:"service_name"` // service.name в трейсах
+	SampleRatio float64 `mapstructure:"sample_ratio"` // доля записываемых трейсов от 0 до 1
+}
+
 type Config_PG struct {
-	DBHost     string `mapstructure:"DB_HOST"`
-	DBPort     string `mapstructure:"DB_PORT"`
-	DBUser     string `mapstructure:"DB_USER"`
-	DBPassword string `mapstructure:"DB_PASSWORD"`
-	DBName     string `mapstructure:"DB_NAME"`
-	DBSSLMode  string `mapstructure:"DB_SSLMODE"`
-	Http_Port  string `mapstructure:"HTTP_PORT"`
+	HTTP      HTTPConfig      `mapstructure:"http"`
+	Storage   StorageConfig   `mapstructure:"storage"`
+	DB        DBConfig        `mapstructure:"db"`
+	Logging   LoggingConfig   `mapstructure:"logging"`
+	Auth      AuthConfig      `mapstructure:"auth"`
+	Scheduler SchedulerConfig `mapstructure:"scheduler"`
+	Webhooks  WebhooksConfig  `mapstructure:"webhooks"`
+	Notify    NotifyConfig    `mapstructure:"notify"`
+	RateLimit RateLimitConfig `mapstructure:"rate_limit"`
+	CORS      CORSConfig      `mapstructure:"cors"`
+	Cache     CacheConfig     `mapstructure:"cache"`
+	Metrics   MetricsConfig   `mapstructure:"metrics"`
+	Tracing   TracingConfig   `mapstructure:"tracing"`
+
+	// Флаги функций задаются только в файле конфига (features: {name: true}) и перечитываются на лету
+	Features map[string]bool `mapstructure:"features"`
 }
 
-func Load_Config_PG(logger *logger_module.Logger) (*Config_PG, error) {
-	// viper.SetConfigFile(".env") // Указываем файл с конфигом
+// Включен ли флаг функции; незаданный флаг выключен
+func (config *Config_PG) Feature(name string) bool {
+	return config.Features[name]
+}
 
-	viper.AutomaticEnv() // читает переменные из окружения(переопределяя переменные из .env)
+// Параметр конфига: ключ в файле (он же имя флага), переменная окружения и значение
+// по умолчанию, тип которого задает тип флага
+type option struct {
+	key   string
+	env   string
+	value any
+	usage string
+}
 
-	viper.BindEnv("DB_HOST")
-	viper.BindEnv("DB_PORT")
-	viper.BindEnv("DB_USER")
-	viper.BindEnv("DB_PASSWORD")
-	viper.BindEnv("DB_NAME")
-	viper.BindEnv("DB_SSLMODE")
-	viper.BindEnv("HTTP_PORT")
+var options = []option{
+	{"http.port", "HTTP_PORT", "8080", "порт HTTP-сервера"},
+	{"http.shutdown_drain_delay", "SHUTDOWN_DRAIN_DELAY", 5 * time.Second, "сколько /readyz отвечает 503 после SIGTERM до остановки сервера"},
 
-	// Читаем и загружаем файл конфига
-	// if err := viper.ReadInConfig(); err != nil {
-	// logger.Fatal("Failed to read config", "error", err)
-	// }
+	{"storage.driver", "STORAGE", StoragePostgres, "хранилище подписок: postgres, sqlite или memory (без базы, данные теряются при перезапуске)"},
+	{"storage.sqlite_path", "SQLITE_PATH", "subscriptions.db", "файл базы SQLite при storage.driver=sqlite"},
 
-	var config Config_PG
-	// Преобразуем данные которые получили в нашу структуру(Config_PG)
-	if err := viper.Unmarshal(&config); err != nil {
-		logger.Fatal("Failed unmarshal config struct", "error", err)
+	{"db.url", "DATABASE_URL", "", "строка подключения postgres://; параметры из нее важнее остальных db.*"},
+	{"db.host", "DB_HOST", "", "хост PostgreSQL"},
+	{"db.port", "DB_PORT", "5432", "порт PostgreSQL"},
+	{"db.user", "DB_USER", "", "пользователь PostgreSQL"},
+	{"db.password", "DB_PASSWORD", "", "пароль PostgreSQL"},
+	{"db.password_file", "DB_PASSWORD_FILE", "", "файл с паролем PostgreSQL вместо db.password"},
+	{"db.name", "DB_NAME", "", "имя базы"},
+	{"db.sslmode", "DB_SSLMODE", "disable", "sslmode подключения: disable, allow, prefer, require, verify-ca или verify-full"},
+	{"db.sslrootcert", "DB_SSLROOTCERT", "", "файл с корневым сертификатом CA для TLS"},
+	{"db.application_name", "DB_APPLICATION_NAME", "effective_mobile", "application_name соединений"},
+	{"db.statement_timeout", "DB_STATEMENT_TIMEOUT", time.Duration(0), "statement_timeout соединений, 0 — без ограничения"},
+	{"db.max_open_conns", "DB_MAX_OPEN_CONNS", 25, "максимум открытых соединений, 0 — без ограничения"},
+	{"db.max_idle_conns", "DB_MAX_IDLE_CONNS", 10, "максимум простаивающих соединений в пуле"},
+	{"db.conn_max_lifetime", "DB_CONN_MAX_LIFETIME", 30 * time.Minute, "время жизни соединения, 0 — без ограничения"},
+	{"db.conn_max_idle_time", "DB_CONN_MAX_IDLE_TIME", 5 * time.Minute, "время простоя соединения до закрытия, 0 — без ограничения"},
+	{"db.connect_timeout", "DB_CONNECT_TIMEOUT", time.Minute, "сколько при старте ждать, пока база станет доступна"},
+	{"db.no_migrate", "NO_MIGRATE", false, "не применять миграции при старте (также --no-migrate)"},
+	{"db.repository", "DB_REPOSITORY", RepositoryGorm, "репозиторий подписок: gorm или pgx (pgxpool с подготовленными запросами)"},
+
+	{"logging.level", "LOG_LEVEL", "info", "уровень логов: debug, info, warn или error"},
+	{"logging.format", "LOG_FORMAT", "text", "формат логов: text или json"},
+	{"logging.file", "LOG_FILE", "app.log", "файл логов"},
+	{"logging.max_size_mb", "LOG_MAX_SIZE_MB", int64(100), "ротация файла логов по размеру в МБ, 0 — выключена"},
+	{"logging.max_age", "LOG_MAX_AGE", 24 * time.Hour, "ротация файла логов по возрасту, 0 — выключена"},
+	{"logging.max_backups", "LOG_MAX_BACKUPS", 7, "сколько архивов логов хранить, 0 — все"},
+	{"logging.compress", "LOG_COMPRESS", true, "сжимать архивы логов gzip"},
+
+	{"auth.enabled", "AUTH_ENABLED", true, "проверять API-ключи и JWT"},
+	{"auth.jwt_hs256_secret", "JWT_HS256_SECRET", "", "секрет HS256 для JWT"},
+	{"auth.jwt_rs256_public_key_file", "JWT_RS256_PUBLIC_KEY_FILE", "", "PEM с публичным ключом RS256"},
+	{"auth.jwt_jwks_file", "JWT_JWKS_FILE", "", "локальный JWKS с ключами RS256"},
+	{"auth.jwt_issuer", "JWT_ISSUER", "", "ожидаемый iss в JWT"},
+	{"auth.jwt_audience", "JWT_AUDIENCE", "", "ожидаемый aud в JWT"},
+	{"auth.default_tenant", "DEFAULT_TENANT", "default", "организация для запросов без тенанта, пусто — тенант обязателен"},
+	{"auth.feed_token_secret", "FEED_TOKEN_SECRET", "", "секрет подписи токенов фидов, пусто — случайный на каждой реплике"},
+	{"auth.feed_token_ttl", "FEED_TOKEN_TTL", time.Hour, "срок действия токенов фидов"},
+
+	{"scheduler.enabled", "SCHEDULER_ENABLED", true, "запускать фоновые задачи"},
+	{"scheduler.run_hour", "SCHEDULER_RUN_HOUR", 3, "час ежедневного запуска задач по UTC"},
+
+	{"webhooks.max_attempts", "WEBHOOK_MAX_ATTEMPTS", 8, "после стольких неудач доставка уходит в dead"},
+	{"webhooks.poll_interval", "WEBHOOK_POLL_INTERVAL", 5 * time.Second, "как часто проверять outbox и повторы"},
+	{"webhooks.event_retention", "WEBHOOK_EVENT_RETENTION", 7 * 24 * time.Hour, "сколько хранить обработанные события outbox, 0 — не удалять"},
+	{"webhooks.delivery_retention", "WEBHOOK_DELIVERY_RETENTION", 30 * 24 * time.Hour, "сколько хранить завершенные доставки, 0 — не удалять"},
+	{"webhooks.allowed_networks", "WEBHOOK_ALLOWED_NETWORKS", []string{}, "внутренние сети (CIDR) получателей вебхуков через запятую"},
+
+	{"notify.sender", "NOTIFY_SENDER", "stdout", "способ отправки писем: smtp, file или stdout"},
+	{"notify.file_path", "NOTIFY_FILE_PATH", "notifications.log", "куда пишутся письма при notify.sender=file"},
+	{"notify.from", "NOTIFY_FROM", "noreply@localhost", "адрес отправителя писем"},
+	{"notify.smtp_host", "SMTP_HOST", "", "хост SMTP"},
+	{"notify.smtp_port", "SMTP_PORT", "587", "порт SMTP"},
+	{"notify.smtp_username", "SMTP_USERNAME", "", "пользователь SMTP"},
+	{"notify.smtp_password", "SMTP_PASSWORD", "", "пароль SMTP"},
+
+	{"rate_limit.enabled", "RATE_LIMIT_ENABLED", true, "ограничивать частоту запросов"},
+	{"rate_limit.default", "RATE_LIMIT_DEFAULT", "20/s:40", "общее ограничение клиента"},
+	// Списывается до аутентификации, поэтому запросы с неверным ключом тоже ограничены.
+	// За одним IP бывает несколько клиентов, поэтому лимит больше общего
+	{"rate_limit.ip", "RATE_LIMIT_IP", "50/s:100", "ограничение IP-адреса до проверки учетных данных"},
+	// Сумма по подпискам считается агрегатом по всей таблице, ее ограничиваем отдельно
+	{"rate_limit.routes", "RATE_LIMIT_ROUTES", "GET /api/subscriptions/total=1/s:5", "ограничения отдельных роутов"},
+	{"rate_limit.trust_forwarded", "RATE_LIMIT_TRUST_FORWARDED", false, "брать IP клиента из X-Forwarded-For"},
+	{"rate_limit.quota_tiers", "RATE_QUOTA_TIERS", "", "суточные квоты тарифов, например free=1000,pro=0"},
+	{"rate_limit.quota_default_tier", "RATE_QUOTA_DEFAULT_TIER", "", "тариф организаций без записи в tenant_plans"},
+	{"rate_limit.quota_flush_interval", "RATE_QUOTA_FLUSH_INTERVAL", 5 * time.Second, "как часто записывать расход квот в базу"},
+
+	{"cors.allowed_origins", "CORS_ALLOWED_ORIGINS", []string{}, "origin браузерных клиентов через запятую, * — любой"},
+
+	{"cache.enabled", "CACHE_ENABLED", true, "кэшировать суммы /api/subscriptions/total в памяти процесса"},
+	{"cache.size", "CACHE_SIZE", 10000, "сколько сумм хранить в кэше, давно не читанные вытесняются"},
+	{"cache.ttl", "CACHE_TTL", time.Minute, "время жизни суммы в кэше; изменения с других реплик видны не позже"},
+
+	{"metrics.enabled", "METRICS_ENABLED", true, "отдавать метрики Prometheus на /metrics"},
+
+	{"tracing.exporter", "TRACING_EXPORTER", "none", "экспорт трейсов: none, stdout или otlp"},
+	{"tracing.service_name", "TRACING_SERVICE_NAME", "effective_mobile", "service.name в трейсах"},
+	{"tracing.sample_ratio", "TRACING_SAMPLE_RATIO", 1.0, "доля записываемых трейсов от 0 до 1"},
+}
+
+// Секреты не показываются в GET /admin/config
+var secretKeys = map[string]bool{
+	"db.password":            true,
+	"auth.jwt_hs256_secret":  true,
+	"auth.feed_token_secret": true,
+	"notify.smtp_password":   true,
+}
+
+// Раздел с произвольными ключами, их нет в options
+const featuresKey = "features"
+
+// Короткие имена флагов, которые принято писать без раздела
+var flagAliases = map[string]string{
+	"no-migrate": "db.no_migrate",
+}
+
+// Флаги командной строки: --config и по флагу на каждый параметр с именем ключа (--db.host)
+func newFlagSet() *pflag.FlagSet {
+	flags := pflag.NewFlagSet("effective_mobile", pflag.ContinueOnError)
+	flags.SetNormalizeFunc(func(flags *pflag.FlagSet, name string) pflag.NormalizedName {
+		if alias, ok := flagAliases[name]; ok {
+			return pflag.NormalizedName(alias)
+		}
+		return pflag.NormalizedName(name)
+	})
+	flags.String("config", "", "файл конфига YAML, TOML или JSON (также "+ConfigFileEnv+")")
+	for _, opt := range options {
+		usage := opt.usage + " (" + opt.env + ")"
+		switch value := opt.value.(type) {
+		case string:
+			flags.String(opt.key, value, usage)
+		case bool:
+			flags.Bool(opt.key, value, usage)
+		case int:
+			flags.Int(opt.key, value, usage)
+		case int64:
+			flags.Int64(opt.key, value, usage)
+		case float64:
+			flags.Float64(opt.key, value, usage)
+		case time.Duration:
+			flags.Duration(opt.key, value, usage)
+		case []string:
+			flags.StringSlice(opt.key, value, usage)
+		default:
+			panic(fmt.Sprintf("config option %s has unsupported type %T", opt.key, opt.value))
+		}
+	}
+	return flags
+}
+
+// Читаем конфиг слоями, каждый следующий важнее: значения по умолчанию, файл,
+// переменные окружения, флаги. Ошибки проверки возвращаются все сразу.
+// При --help печатает справку и возвращает pflag.ErrHelp
+func Load_Config_PG(logger *logger_module.Logger, args []string) (*Config_PG, error) {
+	_, config, err := load(logger, args)
+	return config, err
+}
+
+func load(logger *logger_module.Logger, args []string) (*viper.Viper, *Config_PG, error) {
+	flags := newFlagSet()
+	if err := flags.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	v := viper.New()
+	for _, opt := range options {
+		v.SetDefault(opt.key, opt.value)
+		v.BindEnv(opt.key, opt.env)
+		if err := v.BindPFlag(opt.key, flags.Lookup(opt.key)); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	configFile, _ := flags.GetString("config")
+	if configFile == "" {
+		configFile = os.Getenv(ConfigFileEnv)
+	}
+	if configFile != "" {
+		v.SetConfigFile(configFile)
+		if err := v.ReadInConfig(); err != nil {
+			return nil, nil, fmt.Errorf("read config file %s: %w", configFile, err)
+		}
+		logger.Info("Config file loaded", "path", configFile)
+	}
+
+	config, err := decode(v)
+	if err != nil {
+		return nil, nil, err
 	}
+	return v, config, nil
+}
 
+// Преобразуем данные которые получили в нашу структуру(Config_PG) и проверяем их
+func decode(v *viper.Viper) (*Config_PG, error) {
+	if v.ConfigFileUsed() != "" {
+		if err := checkUnknownKeys(v); err != nil {
+			return nil, fmt.Errorf("config file %s: %w", v.ConfigFileUsed(), err)
+		}
+	}
+	var config Config_PG
+	if err := v.Unmarshal(&config); err != nil {
+		return nil, fmt.Errorf("decode config: %w", err)
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	// Пароль из файла секрета; файл уже проверен в Validate
+	if config.DB.PasswordFile != "" {
+		password, err := os.ReadFile(config.DB.PasswordFile)
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", optionName("db.password_file"), err)
+		}
+		config.DB.Password = strings.TrimRight(string(password), "\r\n")
+	}
 	return &config, nil
 }
+
+// Опечатка в ключе файла иначе молча оставит значение по умолчанию
+func checkUnknownKeys(v *viper.Viper) error {
+	known := make(map[string]bool, len(options))
+	for _, opt := range options {
+		known[opt.key] = true
+	}
+	var unknown []string
+	for _, key := range v.AllKeys() {
+		if !known[key] && !strings.HasPrefix(key, featuresKey+".") {
+			unknown = append(unknown, key)
+		}
+	}
+	if len(unknown) > 0 {
+		return fmt.Errorf("unknown keys: %s", strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+// Имя параметра в сообщениях: ключ файла и переменная окружения
+func optionName(key string) string {
+	for _, opt := range options {
+		if opt.key == key {
+			return opt.key + " (" + opt.env + ")"
+		}
+	}
+	return key
+}
+
+// Все проблемы конфига, найденные при проверке
+type ValidationError struct {
+	Problems []string
+}
+
+func (err *ValidationError) Error() string {
+	return "invalid configuration:\n  - " + strings.Join(err.Problems, "\n  - ")
+}
+
+func IsValidationError(err error) (*ValidationError, bool) {
+	var validationErr *ValidationError
+	ok := errors.As(err, &validationErr)
+	return validationErr, ok
+}
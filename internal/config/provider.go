@@ -0,0 +1,206 @@
+package config
+
+import (
+	"effective_mobile/pkg/logger_module"
+	"fmt"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+)
+
+// Ключи, которые можно менять без перезапуска. Остальные изменения файла логируются
+// и применяются только при следующем старте
+var reloadableKeys = map[string]bool{
+	"logging.level":        true,
+	"rate_limit.default":   true,
+	"rate_limit.ip":        true,
+	"rate_limit.routes":    true,
+	"cors.allowed_origins": true,
+}
+
+func isReloadable(key string) bool {
+	return reloadableKeys[key] || strings.HasPrefix(key, featuresKey+".")
+}
+
+// Подписчик получает прежний и новый действующий конфиг
+type Subscriber func(previous, current *Config_PG)
+
+// Действующий конфиг с перечитыванием файла на лету. Current всегда возвращает
+// проверенный конфиг: файл с ошибками логируется и не применяется
+type Provider struct {
+	viper       *viper.Viper
+	logger      *logger_module.Logger
+	current     atomic.Pointer[Config_PG]
+	mutex       sync.Mutex
+	subscribers []Subscriber
+}
+
+func NewProvider(logger *logger_module.Logger, args []string) (*Provider, error) {
+	v, config, err := load(logger, args)
+	if err != nil {
+		return nil, err
+	}
+	provider := &Provider{viper: v, logger: logger}
+	provider.current.Store(config)
+	return provider, nil
+}
+
+func (provider *Provider) Current() *Config_PG {
+	return provider.current.Load()
+}
+
+func (provider *Provider) Subscribe(subscriber Subscriber) {
+	provider.mutex.Lock()
+	defer provider.mutex.Unlock()
+	provider.subscribers = append(provider.subscribers, subscriber)
+}
+
+// Следим за файлом конфига; без файла перечитывать нечего. Сообщения о перечитывании
+// пишутся в logger: к этому моменту он уже настроен по конфигу
+func (provider *Provider) Watch(logger *logger_module.Logger) {
+	provider.mutex.Lock()
+	provider.logger = logger
+	provider.mutex.Unlock()
+	if provider.viper.ConfigFileUsed() == "" {
+		return
+	}
+	provider.viper.OnConfigChange(func(event fsnotify.Event) {
+		provider.Reload()
+	})
+	provider.viper.WatchConfig()
+	provider.logger.Info("Watching config file", "path", provider.viper.ConfigFileUsed())
+}
+
+// Перечитываем файл и применяем изменения разрешенных ключей
+func (provider *Provider) Reload() {
+	provider.mutex.Lock()
+	defer provider.mutex.Unlock()
+
+	if err := provider.viper.ReadInConfig(); err != nil {
+		provider.logger.Error("Failed to reload config", "error", err, "path", provider.viper.ConfigFileUsed())
+		return
+	}
+	next, err := decode(provider.viper)
+	if validationErr, ok := IsValidationError(err); ok {
+		for _, problem := range validationErr.Problems {
+			provider.logger.Error("Invalid config, keeping previous", "problem", problem)
+		}
+		return
+	}
+	if err != nil {
+		provider.logger.Error("Failed to reload config", "error", err, "path", provider.viper.ConfigFileUsed())
+		return
+	}
+
+	previous := provider.Current()
+	changed := diffKeys(Flatten(previous), Flatten(next))
+	var applied, restart []string
+	for _, key := range changed {
+		if isReloadable(key) {
+			applied = append(applied, key)
+		} else {
+			restart = append(restart, key)
+		}
+	}
+	if len(restart) > 0 {
+		provider.logger.Warn("Config changes require restart", "keys", strings.Join(restart, ","))
+	}
+	if len(applied) == 0 {
+		return
+	}
+
+	current := applyReloadable(previous, next)
+	provider.current.Store(current)
+	provider.logger.Info("Config reloaded", "keys", strings.Join(applied, ","))
+	for _, subscriber := range provider.subscribers {
+		subscriber(previous, current)
+	}
+}
+
+// Копия действующего конфига, в которой заменены только разрешенные ключи
+func applyReloadable(previous, next *Config_PG) *Config_PG {
+	current := *previous
+	current.Logging.Level = next.Logging.Level
+	current.RateLimit.Default = next.RateLimit.Default
+	current.RateLimit.IP = next.RateLimit.IP
+	current.RateLimit.Routes = next.RateLimit.Routes
+	current.CORS.AllowedOrigins = next.CORS.AllowedOrigins
+	current.Features = next.Features
+	return &current
+}
+
+func diffKeys(previous, current map[string]any) []string {
+	var changed []string
+	for key, value := range current {
+		if old, ok := previous[key]; !ok || !reflect.DeepEqual(old, value) {
+			changed = append(changed, key)
+		}
+	}
+	for key := range previous {
+		if _, ok := current[key]; !ok {
+			changed = append(changed, key)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// Конфиг плоским списком "раздел.ключ" -> значение по тегам mapstructure.
+// Длительности выводятся строкой, как в файле
+func Flatten(config *Config_PG) map[string]any {
+	flat := make(map[string]any)
+	flattenStruct(reflect.ValueOf(*config), "", flat)
+	return flat
+}
+
+func flattenStruct(value reflect.Value, prefix string, flat map[string]any) {
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		key := prefix + field.Tag.Get("mapstructure")
+		fieldValue := value.Field(i)
+		switch {
+		case field.Type.Kind() == reflect.Struct:
+			flattenStruct(fieldValue, key+".", flat)
+		case field.Type.Kind() == reflect.Map:
+			iter := fieldValue.MapRange()
+			for iter.Next() {
+				flat[fmt.Sprintf("%s.%v", key, iter.Key())] = iter.Value().Interface()
+			}
+		case field.Type == reflect.TypeOf(time.Duration(0)):
+			flat[key] = fieldValue.Interface().(time.Duration).String()
+		default:
+			flat[key] = fieldValue.Interface()
+		}
+	}
+}
+
+// Действующий конфиг для GET /admin/config: заданные секреты заменены на "***"
+func (config *Config_PG) Redacted() map[string]any {
+	flat := Flatten(config)
+	for key := range secretKeys {
+		if value, ok := flat[key].(string); ok && value != "" {
+			flat[key] = "***"
+		}
+	}
+	if config.DB.URL != "" {
+		flat["db.url"] = redactURL(config.DB.URL)
+	}
+	return flat
+}
+
+// Пароль в строке подключения заменяем на "***"
+func redactURL(raw string) string {
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		return "***"
+	}
+	// url.Redacted подставляет "xxxxx", приводим к виду остальных секретов
+	return strings.Replace(parsed.Redacted(), ":xxxxx@", ":***@", 1)
+}
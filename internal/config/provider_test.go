@@ -0,0 +1,84 @@
+package config
+
+import (
+	"bytes"
+	"effective_mobile/pkg/logger_module"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProvider_Reload(t *testing.T) {
+	setRequiredEnv(t)
+	path := writeConfigFile(t, "config.yaml", `
+logging:
+  level: info
+http:
+  port: "8080"
+rate_limit:
+  default: 20/s:40
+`)
+	var out bytes.Buffer
+	logger, err := logger_module.New(&out, logger_module.Options{})
+	require.NoError(t, err)
+
+	provider, err := NewProvider(logger, []string{"--config", path})
+	require.NoError(t, err)
+	var calls []string
+	provider.Subscribe(func(previous, current *Config_PG) {
+		calls = append(calls, previous.Logging.Level+"->"+current.Logging.Level)
+	})
+	initial := provider.Current()
+
+	// Разрешенные ключи применяются, порт требует перезапуска и остается прежним
+	require.NoError(t, os.WriteFile(path, []byte(`
+logging:
+  level: debug
+http:
+  port: "9090"
+rate_limit:
+  default: 5/s
+cors:
+  allowed_origins: ["https://app.example.com"]
+features:
+  new_dashboard: true
+`), 0600))
+	provider.Reload()
+
+	current := provider.Current()
+	assert.Equal(t, "debug", current.Logging.Level)
+	assert.Equal(t, "5/s", current.RateLimit.Default)
+	assert.Equal(t, []string{"https://app.example.com"}, current.CORS.AllowedOrigins)
+	assert.True(t, current.Feature("new_dashboard"))
+	assert.False(t, current.Feature("unknown"))
+	assert.Equal(t, "8080", current.HTTP.Port)
+	assert.Equal(t, []string{"info->debug"}, calls)
+	assert.Contains(t, out.String(), `msg="Config changes require restart" keys=http.port`)
+	// Прежний конфиг не меняется: его могут читать параллельно
+	assert.Equal(t, "info", initial.Logging.Level)
+
+	// Файл с ошибкой не применяется
+	require.NoError(t, os.WriteFile(path, []byte("logging:\n  level: verbose\n"), 0600))
+	provider.Reload()
+	assert.Equal(t, "debug", provider.Current().Logging.Level)
+	assert.Len(t, calls, 1)
+	assert.Contains(t, out.String(), "Invalid config, keeping previous")
+}
+
+func TestConfig_Redacted(t *testing.T) {
+	setRequiredEnv(t)
+	t.Setenv("DB_PASSWORD", "s3cret")
+	t.Setenv("JWT_HS256_SECRET", "jwt-secret")
+
+	conf, err := Load_Config_PG(testLogger(t), nil)
+	require.NoError(t, err)
+	redacted := conf.Redacted()
+	assert.Equal(t, "***", redacted["db.password"])
+	assert.Equal(t, "***", redacted["auth.jwt_hs256_secret"])
+	// Пустой секрет виден как пустой: так понятно, что он не задан
+	assert.Equal(t, "", redacted["notify.smtp_password"])
+	assert.Equal(t, "localhost", redacted["db.host"])
+	assert.Equal(t, "5s", redacted["webhooks.poll_interval"])
+}
@@ -0,0 +1,206 @@
+package config
+
+import (
+	"effective_mobile/internal/ratelimit"
+	"effective_mobile/internal/webhooks"
+	"effective_mobile/pkg/logger_module"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+)
+
+// Накопитель проблем: проверяем все параметры, а не останавливаемся на первой ошибке
+type problems []string
+
+func (list *problems) add(key string, format string, args ...any) {
+	*list = append(*list, optionName(key)+": "+fmt.Sprintf(format, args...))
+}
+
+func (list *problems) required(key, value string) {
+	if value == "" {
+		list.add(key, "is required")
+	}
+}
+
+func (list *problems) port(key, value string) {
+	port, err := strconv.Atoi(value)
+	if err != nil || port < 1 || port > 65535 {
+		list.add(key, "must be a port number from 1 to 65535, got %q", value)
+	}
+}
+
+func (list *problems) oneOf(key, value string, allowed ...string) {
+	for _, candidate := range allowed {
+		if value == candidate {
+			return
+		}
+	}
+	list.add(key, "must be one of %v, got %q", allowed, value)
+}
+
+func (list *problems) fileExists(key, path string) {
+	if path == "" {
+		return
+	}
+	if _, err := os.Stat(path); err != nil {
+		list.add(key, "file is not readable: %v", err)
+	}
+}
+
+// Проверяем конфиг целиком и возвращаем *ValidationError со всеми найденными проблемами
+func (config *Config_PG) Validate() error {
+	var list problems
+
+	list.port("http.port", config.HTTP.Port)
+	if config.HTTP.ShutdownDrainDelay < 0 {
+		list.add("http.shutdown_drain_delay", "must not be negative, got %s", config.HTTP.ShutdownDrainDelay)
+	}
+
+	list.oneOf("storage.driver", config.Storage.Driver, StoragePostgres, StorageSQLite, StorageMemory)
+	// Параметры подключения к Postgres нужны только ему
+	switch config.Storage.Driver {
+	case StoragePostgres:
+		config.validateDB(&list)
+	case StorageSQLite:
+		list.required("storage.sqlite_path", config.Storage.SQLitePath)
+	}
+
+	if _, err := logger_module.ParseLevel(config.Logging.Level); err != nil {
+		list.add("logging.level", "must be debug, info, warn or error, got %q", config.Logging.Level)
+	}
+	list.oneOf("logging.format", config.Logging.Format, logger_module.FormatText, logger_module.FormatJSON)
+	list.required("logging.file", config.Logging.File)
+	if config.Logging.MaxSizeMB < 0 {
+		list.add("logging.max_size_mb", "must not be negative, got %d", config.Logging.MaxSizeMB)
+	}
+	if config.Logging.MaxAge < 0 {
+		list.add("logging.max_age", "must not be negative, got %s", config.Logging.MaxAge)
+	}
+	if config.Logging.MaxBackups < 0 {
+		list.add("logging.max_backups", "must not be negative, got %d", config.Logging.MaxBackups)
+	}
+
+	if config.Auth.Enabled {
+		list.fileExists("auth.jwt_rs256_public_key_file", config.Auth.JWTPublicKeyFile)
+		list.fileExists("auth.jwt_jwks_file", config.Auth.JWTJWKSFile)
+		if config.Auth.FeedTokenTTL <= 0 {
+			list.add("auth.feed_token_ttl", "must be positive, got %v", config.Auth.FeedTokenTTL)
+		}
+		// Иначе токен фида подошел бы как JWT для любых запросов
+		if config.Auth.FeedTokenSecret != "" && config.Auth.FeedTokenSecret == config.Auth.JWTHS256Secret {
+			list.add("auth.feed_token_secret", "must differ from auth.jwt_hs256_secret")
+		}
+	}
+
+	if config.Scheduler.RunHour < 0 || config.Scheduler.RunHour > 23 {
+		list.add("scheduler.run_hour", "must be an hour from 0 to 23, got %d", config.Scheduler.RunHour)
+	}
+
+	if config.Webhooks.MaxAttempts < 1 {
+		list.add("webhooks.max_attempts", "must be at least 1, got %d", config.Webhooks.MaxAttempts)
+	}
+	if config.Webhooks.EventRetention < 0 {
+		list.add("webhooks.event_retention", "must not be negative, got %v", config.Webhooks.EventRetention)
+	}
+	if config.Webhooks.DeliveryRetention < 0 {
+		list.add("webhooks.delivery_retention", "must not be negative, got %v", config.Webhooks.DeliveryRetention)
+	}
+	if _, err := webhooks.NewAddressPolicy(config.Webhooks.AllowedNetworks); err != nil {
+		list.add("webhooks.allowed_networks", "%v", err)
+	}
+	if config.Webhooks.PollInterval <= 0 {
+		list.add("webhooks.poll_interval", "must be positive, got %s", config.Webhooks.PollInterval)
+	}
+
+	list.oneOf("notify.sender", config.Notify.Sender, "smtp", "file", "stdout")
+	switch config.Notify.Sender {
+	case "smtp":
+		list.required("notify.smtp_host", config.Notify.SMTPHost)
+		list.port("notify.smtp_port", config.Notify.SMTPPort)
+	case "file":
+		list.required("notify.file_path", config.Notify.FilePath)
+	}
+
+	if config.RateLimit.Enabled {
+		if _, err := ratelimit.ParseRule(config.RateLimit.Default); err != nil {
+			list.add("rate_limit.default", "%v", err)
+		}
+		if _, err := ratelimit.ParseRule(config.RateLimit.IP); err != nil {
+			list.add("rate_limit.ip", "%v", err)
+		}
+		if _, err := ratelimit.ParseRouteRules(config.RateLimit.Routes); err != nil {
+			list.add("rate_limit.routes", "%v", err)
+		}
+		tiers, err := ratelimit.ParseTiers(config.RateLimit.QuotaTiers)
+		if err != nil {
+			list.add("rate_limit.quota_tiers", "%v", err)
+		} else if _, ok := tiers[config.RateLimit.QuotaDefaultTier]; len(tiers) > 0 && config.RateLimit.QuotaDefaultTier != "" && !ok {
+			list.add("rate_limit.quota_default_tier", "%q is not listed in rate_limit.quota_tiers", config.RateLimit.QuotaDefaultTier)
+		}
+		if len(tiers) > 0 && config.RateLimit.QuotaFlushInterval <= 0 {
+			list.add("rate_limit.quota_flush_interval", "must be positive, got %v", config.RateLimit.QuotaFlushInterval)
+		}
+	}
+
+	if config.Cache.Enabled {
+		if config.Cache.Size < 1 {
+			list.add("cache.size", "must be at least 1, got %d", config.Cache.Size)
+		}
+		if config.Cache.TTL <= 0 {
+			list.add("cache.ttl", "must be positive, got %s", config.Cache.TTL)
+		}
+	}
+
+	list.oneOf("tracing.exporter", config.Tracing.Exporter, "none", "stdout", "otlp")
+	if config.Tracing.SampleRatio < 0 || config.Tracing.SampleRatio > 1 {
+		list.add("tracing.sample_ratio", "must be between 0 and 1, got %g", config.Tracing.SampleRatio)
+	}
+
+	if len(list) > 0 {
+		return &ValidationError{Problems: list}
+	}
+	return nil
+}
+
+// Параметры подключения к PostgreSQL, проверяются только для STORAGE=postgres
+func (config *Config_PG) validateDB(list *problems) {
+	if config.DB.URL != "" {
+		if parsed, err := url.Parse(config.DB.URL); err != nil || (parsed.Scheme != "postgres" && parsed.Scheme != "postgresql") {
+			// Саму строку не выводим: в ней может быть пароль
+			list.add("db.url", "must be a postgres:// or postgresql:// URL")
+		}
+	} else {
+		list.required("db.host", config.DB.Host)
+		list.port("db.port", config.DB.Port)
+		list.required("db.user", config.DB.User)
+		list.required("db.name", config.DB.Name)
+	}
+	if config.DB.Password != "" && config.DB.PasswordFile != "" {
+		list.add("db.password_file", "set either db.password or db.password_file, not both")
+	}
+	list.fileExists("db.password_file", config.DB.PasswordFile)
+	list.oneOf("db.sslmode", config.DB.SSLMode, "disable", "allow", "prefer", "require", "verify-ca", "verify-full")
+	list.fileExists("db.sslrootcert", config.DB.SSLRootCert)
+	if config.DB.StatementTimeout < 0 {
+		list.add("db.statement_timeout", "must not be negative, got %s", config.DB.StatementTimeout)
+	}
+	if config.DB.MaxOpenConns < 0 {
+		list.add("db.max_open_conns", "must not be negative, got %d", config.DB.MaxOpenConns)
+	}
+	if config.DB.MaxIdleConns < 0 {
+		list.add("db.max_idle_conns", "must not be negative, got %d", config.DB.MaxIdleConns)
+	} else if config.DB.MaxOpenConns > 0 && config.DB.MaxIdleConns > config.DB.MaxOpenConns {
+		list.add("db.max_idle_conns", "must not exceed db.max_open_conns (%d), got %d", config.DB.MaxOpenConns, config.DB.MaxIdleConns)
+	}
+	if config.DB.ConnMaxLifetime < 0 {
+		list.add("db.conn_max_lifetime", "must not be negative, got %s", config.DB.ConnMaxLifetime)
+	}
+	if config.DB.ConnMaxIdleTime < 0 {
+		list.add("db.conn_max_idle_time", "must not be negative, got %s", config.DB.ConnMaxIdleTime)
+	}
+	if config.DB.ConnectTimeout <= 0 {
+		list.add("db.connect_timeout", "must be positive, got %s", config.DB.ConnectTimeout)
+	}
+	list.oneOf("db.repository", config.DB.Repository, RepositoryGorm, RepositoryPgx)
+}
@@ -0,0 +1,131 @@
+package events
+
+import (
+	"context"
+	"effective_mobile/internal/objects"
+	"effective_mobile/pkg/logger_module"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Тип события жизненного цикла подписки
+type Type string
+
+const (
+	SubscriptionCreated    Type = "subscription.created"
+	SubscriptionUpdated    Type = "subscription.updated"
+	SubscriptionDeleted    Type = "subscription.deleted"
+	SubscriptionExpired    Type = "subscription.expired"
+	SubscriptionRenewalDue Type = "subscription.renewal_due"
+	TrialEnding            Type = "trial.ending"
+)
+
+// Пространство имен для детерминированных id событий
+var eventNamespace = uuid.MustParse("8f1c2a6e-3d4b-4c1e-9a57-2b6f0e4d7c91")
+
+// Событие по подписке
+type Event struct {
+	ID           uuid.UUID            `json:"id"`
+	Type         Type                 `json:"type"`
+	OccurredAt   time.Time            `json:"occurred_at"`
+	DueDate      *time.Time           `json:"due_date,omitempty"` // дата, к которой относится событие (списание, окончание)
+	Subscription objects.Subscription `json:"subscription"`
+}
+
+// Все известные типы событий
+var Types = []Type{
+	SubscriptionCreated,
+	SubscriptionUpdated,
+	SubscriptionDeleted,
+	SubscriptionExpired,
+	SubscriptionRenewalDue,
+	TrialEnding,
+}
+
+func IsKnown(eventType Type) bool {
+	for _, known := range Types {
+		if known == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// Создаем событие об изменении подписки
+func New(eventType Type, sub *objects.Subscription) Event {
+	return Event{
+		ID:           uuid.New(),
+		Type:         eventType,
+		OccurredAt:   time.Now().UTC(),
+		Subscription: *sub,
+	}
+}
+
+// Создаем событие, которое относится к конкретной дате.
+// id считается из типа, подписки и даты, поэтому повторный запуск задачи даст то же событие
+// и получатели смогут отбросить дубликат
+func NewDue(eventType Type, sub *objects.Subscription, dueDate time.Time) Event {
+	key := string(eventType) + "/" + sub.ID.String() + "/" + dueDate.UTC().Format(time.RFC3339)
+	return Event{
+		ID:           uuid.NewSHA1(eventNamespace, []byte(key)),
+		Type:         eventType,
+		OccurredAt:   time.Now().UTC(),
+		DueDate:      &dueDate,
+		Subscription: *sub,
+	}
+}
+
+// Интерфейс для публикации событий
+type Publisher interface {
+	Publish(ctx context.Context, event Event) error
+}
+
+// Сигнал о том, что в outbox записаны новые события и их пора разослать
+type Notifier interface {
+	Notify()
+}
+
+// Обработчик события
+type Handler func(ctx context.Context, event Event) error
+
+// Внутрипроцессная шина: синхронно раздает событие всем подписчикам
+type Bus struct {
+	mutex    sync.RWMutex
+	handlers []Handler
+	logger   *logger_module.Logger
+}
+
+func NewBus(logger *logger_module.Logger) *Bus {
+	return &Bus{logger: logger}
+}
+
+func (bus *Bus) Subscribe(handler Handler) {
+	bus.mutex.Lock()
+	defer bus.mutex.Unlock()
+	bus.handlers = append(bus.handlers, handler)
+}
+
+// Ошибка одного подписчика не мешает остальным, все ошибки возвращаются вместе
+func (bus *Bus) Publish(ctx context.Context, event Event) error {
+	bus.mutex.RLock()
+	handlers := bus.handlers
+	bus.mutex.RUnlock()
+
+	bus.logger.Info("Publish event",
+		"event_id", event.ID,
+		"type", event.Type,
+		"subscription_id", event.Subscription.ID,
+		"user_id", event.Subscription.UserID)
+
+	var errs []error
+	for _, handler := range handlers {
+		if err := handler(ctx, event); err != nil {
+			bus.logger.Error("Event handler failed", "error", err, "event_id", event.ID, "type", event.Type)
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,108 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+)
+
+// Компонент после SIGTERM: балансировщик должен перестать слать запросы до server.Shutdown
+const shutdownComponent = "shutdown"
+
+var ErrShuttingDown = errors.New("server is shutting down")
+
+// Проверка компонента; ошибка — компонент недоступен
+type Check func(ctx context.Context) error
+
+type ComponentStatus struct {
+	Status    string `json:"status"`
+	Error     string `json:"error,omitempty"`
+	LatencyMS int64  `json:"latency_ms"`
+}
+
+type Report struct {
+	Status     string                     `json:"status"`
+	Components map[string]ComponentStatus `json:"components"`
+}
+
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+// Состояние сервиса для проб: liveness — процесс жив, readiness — все проверки прошли
+// и сервис не завершается
+type Health struct {
+	timeout      time.Duration
+	mutex        sync.RWMutex
+	checks       []namedCheck
+	shuttingDown atomic.Bool
+}
+
+func New(timeout time.Duration) *Health {
+	return &Health{timeout: timeout}
+}
+
+func (health *Health) Add(name string, check Check) {
+	health.mutex.Lock()
+	defer health.mutex.Unlock()
+	health.checks = append(health.checks, namedCheck{name: name, check: check})
+	sort.Slice(health.checks, func(i, j int) bool { return health.checks[i].name < health.checks[j].name })
+}
+
+// Вызывается при получении SIGTERM: readiness сразу начинает отвечать ошибкой
+func (health *Health) SetShuttingDown() {
+	health.shuttingDown.Store(true)
+}
+
+func (health *Health) ShuttingDown() bool {
+	return health.shuttingDown.Load()
+}
+
+// Запускаем все проверки параллельно, каждую с таймаутом
+func (health *Health) Check(ctx context.Context) Report {
+	health.mutex.RLock()
+	checks := append([]namedCheck(nil), health.checks...)
+	health.mutex.RUnlock()
+
+	ctx, cancel := context.WithTimeout(ctx, health.timeout)
+	defer cancel()
+
+	statuses := make([]ComponentStatus, len(checks))
+	var wg sync.WaitGroup
+	for i, named := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			started := time.Now()
+			err := named.check(ctx)
+			statuses[i] = ComponentStatus{Status: StatusUp, LatencyMS: time.Since(started).Milliseconds()}
+			if err != nil {
+				statuses[i].Status = StatusDown
+				statuses[i].Error = err.Error()
+			}
+		}()
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusUp, Components: make(map[string]ComponentStatus, len(checks)+1)}
+	for i, named := range checks {
+		report.Components[named.name] = statuses[i]
+		if statuses[i].Status == StatusDown {
+			report.Status = StatusDown
+		}
+	}
+	if health.ShuttingDown() {
+		report.Status = StatusDown
+		report.Components[shutdownComponent] = ComponentStatus{Status: StatusDown, Error: ErrShuttingDown.Error()}
+	}
+	return report
+}
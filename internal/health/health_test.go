@@ -0,0 +1,48 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealth_Check(t *testing.T) {
+	health := New(time.Second)
+	health.Add("database", func(ctx context.Context) error { return nil })
+	report := health.Check(context.Background())
+	assert.Equal(t, StatusUp, report.Status)
+	assert.Equal(t, StatusUp, report.Components["database"].Status)
+
+	health.Add("migrations", func(ctx context.Context) error { return errors.New("database is at version 7, expected 9") })
+	report = health.Check(context.Background())
+	assert.Equal(t, StatusDown, report.Status)
+	assert.Equal(t, StatusUp, report.Components["database"].Status)
+	assert.Equal(t, ComponentStatus{Status: StatusDown, Error: "database is at version 7, expected 9"}, report.Components["migrations"])
+}
+
+func TestHealth_CheckTimeout(t *testing.T) {
+	health := New(10 * time.Millisecond)
+	// Зависшая проверка ограничена таймаутом
+	health.Add("database", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	report := health.Check(context.Background())
+	assert.Equal(t, StatusDown, report.Status)
+	assert.Equal(t, context.DeadlineExceeded.Error(), report.Components["database"].Error)
+}
+
+func TestHealth_ShuttingDown(t *testing.T) {
+	health := New(time.Second)
+	health.Add("database", func(ctx context.Context) error { return nil })
+	health.SetShuttingDown()
+
+	report := health.Check(context.Background())
+	assert.True(t, health.ShuttingDown())
+	assert.Equal(t, StatusDown, report.Status)
+	assert.Equal(t, StatusUp, report.Components["database"].Status)
+	assert.Equal(t, ErrShuttingDown.Error(), report.Components["shutdown"].Error)
+}
@@ -0,0 +1,167 @@
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"gorm.io/gorm"
+)
+
+const namespace = "effective_mobile"
+
+// Метки только с ограниченным набором значений: шаблон роута вместо пути, имя метода репозитория
+// вместо запроса. id пользователей, подписок и тенантов в метки не попадают
+var (
+	Registry = prometheus.NewRegistry()
+
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by route template, method and status code.",
+	}, []string{"method", "route", "status"})
+
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by route template and method.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	dbDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "db_query_duration_seconds",
+		Help:      "Repository call latency by repository and method.",
+		Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"repo", "method"})
+
+	dbErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "db_query_errors_total",
+		Help:      "Failed repository calls by repository and method, not found is not an error.",
+	}, []string{"repo", "method"})
+
+	dbUp = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "db_up",
+		Help:      "Whether the last periodic database ping succeeded (1) or failed (0).",
+	})
+
+	dbConnectionLost = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "db_connection_lost_total",
+		Help:      "Times the database became unreachable after being available.",
+	})
+
+	dbConnectAttempts = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "db_connect_attempts_total",
+		Help:      "Database connection attempts at startup, including the successful one.",
+	})
+
+	jobRunsGivenUp = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "scheduler_job_runs_given_up_total",
+		Help:      "Scheduler job runs that failed on every attempt, by job name.",
+	}, []string{"job"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequests, httpDuration, dbDuration, dbErrors,
+		dbUp, dbConnectionLost, dbConnectAttempts, jobRunsGivenUp,
+	)
+}
+
+// Ручка /metrics в формате Prometheus
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
+
+// Методы, которые не обслуживает ни один роут, собираем в одну метку
+var knownMethods = map[string]bool{
+	http.MethodGet: true, http.MethodHead: true, http.MethodPost: true, http.MethodPut: true,
+	http.MethodPatch: true, http.MethodDelete: true, http.MethodOptions: true,
+}
+
+func ObserveHTTP(method, route string, status int, duration time.Duration) {
+	if !knownMethods[method] {
+		method = "OTHER"
+	}
+	httpRequests.WithLabelValues(method, route, strconv.Itoa(status)).Inc()
+	httpDuration.WithLabelValues(method, route).Observe(duration.Seconds())
+}
+
+// Учитываем вызов метода репозитория. Ненайденная запись и отмена запроса клиентом — не ошибки базы
+func ObserveDB(repo, method string, started time.Time, err error) {
+	dbDuration.WithLabelValues(repo, method).Observe(time.Since(started).Seconds())
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) && !errors.Is(err, context.Canceled) {
+		dbErrors.WithLabelValues(repo, method).Inc()
+	}
+}
+
+// Статистика пула соединений sql.DB: открытые, занятые, ожидания
+func RegisterDBStats(db *sql.DB, name string) error {
+	return Registry.Register(collectors.NewDBStatsCollector(db, name))
+}
+
+// Результат периодической проверки соединения с базой; lost — соединение только что пропало
+func SetDBUp(up, lost bool) {
+	if up {
+		dbUp.Set(1)
+	} else {
+		dbUp.Set(0)
+	}
+	if lost {
+		dbConnectionLost.Inc()
+	}
+}
+
+// Попытка подключения к базе при старте
+func IncDBConnectAttempt() {
+	dbConnectAttempts.Inc()
+}
+
+// Запуск задачи планировщика упал на всех попытках, на это стоит настроить алерт
+func IncJobGivenUp(job string) {
+	jobRunsGivenUp.WithLabelValues(job).Inc()
+}
+
+// Сколько ждем доменный показатель при сборе метрик
+const gaugeTimeout = 5 * time.Second
+
+// Доменный показатель, который считается запросом к базе при каждом сборе метрик
+type gaugeCollector struct {
+	desc  *prometheus.Desc
+	value func(ctx context.Context) (float64, error)
+}
+
+func (collector *gaugeCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- collector.desc
+}
+
+func (collector *gaugeCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), gaugeTimeout)
+	defer cancel()
+	value, err := collector.value(ctx)
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(collector.desc, err)
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(collector.desc, prometheus.GaugeValue, value)
+}
+
+func RegisterGauge(name, help string, value func(ctx context.Context) (float64, error)) error {
+	return Registry.Register(&gaugeCollector{
+		desc:  prometheus.NewDesc(prometheus.BuildFQName(namespace, "", name), help, nil, nil),
+		value: value,
+	})
+}
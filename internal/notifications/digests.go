@@ -0,0 +1,181 @@
+package notifications
+
+import (
+	"context"
+	"effective_mobile/internal/objects"
+	"effective_mobile/internal/service"
+	"effective_mobile/internal/tenant"
+	"effective_mobile/pkg/logger_module"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Виды писем, совпадают с колонками настроек в notification_preferences
+const (
+	KindMonthlySummary   = "monthly_summary"
+	KindRenewalReminders = "renewal_reminders"
+)
+
+// Получатели и журнал отправленных писем
+type Store interface {
+	// Пользователи, которые не отписались от писем kind
+	ListRecipients(ctx context.Context, kind string) ([]objects.NotificationPreferences, error)
+	// false — письмо с таким ключом уже отправлено
+	MarkSent(ctx context.Context, userID uuid.UUID, kind, dedupKey string) (bool, error)
+	UnmarkSent(ctx context.Context, kind, dedupKey string) error
+}
+
+// Откуда берем суммы и списания, подходит service.SubscriptionServiceI
+type Subscriptions interface {
+	GetTotalCost(ctx context.Context, userID uuid.UUID, serviceName string, start, end time.Time) (int, error)
+	GetRenewals(ctx context.Context, userID uuid.UUID, from, to time.Time) ([]objects.Renewal, error)
+}
+
+// Фоновые задачи рассылки писем. Как и задачи жизненного цикла, получают окно [from, to)
+// от планировщика; повторный запуск за то же окно не отправит письмо дважды благодаря журналу
+type Digests struct {
+	store  Store
+	subs   Subscriptions
+	sender Sender
+	logger *logger_module.Logger
+}
+
+func NewDigests(store Store, subs Subscriptions, sender Sender, logger *logger_module.Logger) *Digests {
+	return &Digests{store: store, subs: subs, sender: sender, logger: logger}
+}
+
+// Сводка за прошедший месяц: отправляется в запуске, окно которого содержит начало следующего месяца
+func (digests *Digests) SendMonthlySummaries(ctx context.Context, from, to time.Time) error {
+	from, to = from.UTC(), to.UTC()
+	boundary := time.Date(from.Year(), from.Month(), 1, 0, 0, 0, 0, time.UTC)
+	if boundary.Before(from) {
+		boundary = boundary.AddDate(0, 1, 0)
+	}
+
+	var errs []error
+	for ; boundary.Before(to); boundary = boundary.AddDate(0, 1, 0) {
+		errs = append(errs, digests.sendMonthlySummaries(ctx, boundary.AddDate(0, -1, 0)))
+	}
+	return errors.Join(errs...)
+}
+
+func (digests *Digests) sendMonthlySummaries(ctx context.Context, month time.Time) error {
+	digests.logger.Debug("Calling db layer for get monthly summary recipients", "month", month.Format("01-2006"))
+	recipients, err := digests.store.ListRecipients(ctx, KindMonthlySummary)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	count := 0
+	for _, recipient := range recipients {
+		// Пользователь может состоять в нескольких организациях, по каждой — отдельное письмо
+		ctx := tenant.NewContext(ctx, recipient.TenantID)
+		// Сумма за месяц считается так же, как в /subscriptions/total с start_date = end_date = месяц
+		total, err := digests.subs.GetTotalCost(ctx, recipient.UserID, "", month, month)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if total == 0 {
+			continue
+		}
+		renewals, err := digests.subs.GetRenewals(ctx, recipient.UserID, month, month.AddDate(0, 1, 0))
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		msg, err := render(monthlySummaryTemplate, recipient.Email, MonthlySummary{Month: month, Total: total, Renewals: renewals})
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		key := dedupKey(recipient, month.Format("2006-01"))
+		sent, err := digests.deliver(ctx, recipient.UserID, KindMonthlySummary, key, msg)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		if sent {
+			count++
+		}
+	}
+	digests.logger.Info("Monthly summaries sent", "month", month.Format("01-2006"), "count", count)
+	return errors.Join(errs...)
+}
+
+// Напоминания о списаниях, до которых осталось service.NoticePeriod: одно письмо на пользователя и день
+func (digests *Digests) SendRenewalReminders(ctx context.Context, from, to time.Time) error {
+	from, to = from.Add(service.NoticePeriod), to.Add(service.NoticePeriod)
+
+	digests.logger.Debug("Calling db layer for get renewal reminder recipients")
+	recipients, err := digests.store.ListRecipients(ctx, KindRenewalReminders)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	count := 0
+	for _, recipient := range recipients {
+		ctx := tenant.NewContext(ctx, recipient.TenantID)
+		renewals, err := digests.subs.GetRenewals(ctx, recipient.UserID, from, to)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		// Списания отсортированы по дате, собираем их в письма по дням
+		for start := 0; start < len(renewals); {
+			end := start
+			reminder := RenewalReminder{Date: renewals[start].Date, DaysLeft: int(service.NoticePeriod / (24 * time.Hour))}
+			for ; end < len(renewals) && renewals[end].Date.Equal(reminder.Date); end++ {
+				reminder.Total += renewals[end].Price
+			}
+			reminder.Renewals = renewals[start:end]
+			start = end
+
+			msg, err := render(renewalReminderTemplate, recipient.Email, reminder)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			key := dedupKey(recipient, reminder.Date.Format("2006-01-02"))
+			sent, err := digests.deliver(ctx, recipient.UserID, KindRenewalReminders, key, msg)
+			if err != nil {
+				errs = append(errs, err)
+			}
+			if sent {
+				count++
+			}
+		}
+	}
+	digests.logger.Info("Renewal reminders sent", "count", count)
+	return errors.Join(errs...)
+}
+
+// Ключ журнала "user:период"; для тенантов, кроме default, впереди добавляется тенант.
+// Ключи default совпадают с ключами до разделения на организации, поэтому письма не уйдут повторно
+func dedupKey(recipient objects.NotificationPreferences, period string) string {
+	key := fmt.Sprintf("%s:%s", recipient.UserID, period)
+	if recipient.TenantID != "" && recipient.TenantID != tenant.Default {
+		key = recipient.TenantID + ":" + key
+	}
+	return key
+}
+
+// Сначала пишем в журнал, потом отправляем: так два экземпляра не отправят одно письмо.
+// Если отправка не удалась, отметку снимаем и письмо уйдет при повторе задачи
+func (digests *Digests) deliver(ctx context.Context, userID uuid.UUID, kind, key string, msg Message) (bool, error) {
+	claimed, err := digests.store.MarkSent(ctx, userID, kind, key)
+	if err != nil || !claimed {
+		return false, err
+	}
+	if err := digests.sender.Send(ctx, msg); err != nil {
+		digests.logger.Error("Failed to send notification", "error", err, "kind", kind, "user_id", userID)
+		return false, errors.Join(err, digests.store.UnmarkSent(ctx, kind, key))
+	}
+	return true, nil
+}
@@ -0,0 +1,155 @@
+package notifications
+
+import (
+	"bytes"
+	"context"
+	"effective_mobile/internal/objects"
+	"effective_mobile/internal/tenant"
+	"effective_mobile/pkg/logger_module"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+// Получатели и журнал в памяти
+type fakeStore struct {
+	recipients map[string][]objects.NotificationPreferences
+	sent       map[string]bool
+}
+
+func (s *fakeStore) ListRecipients(ctx context.Context, kind string) ([]objects.NotificationPreferences, error) {
+	return s.recipients[kind], nil
+}
+
+func (s *fakeStore) MarkSent(ctx context.Context, userID uuid.UUID, kind, dedupKey string) (bool, error) {
+	if s.sent[kind+dedupKey] {
+		return false, nil
+	}
+	s.sent[kind+dedupKey] = true
+	return true, nil
+}
+
+func (s *fakeStore) UnmarkSent(ctx context.Context, kind, dedupKey string) error {
+	delete(s.sent, kind+dedupKey)
+	return nil
+}
+
+type fakeSubscriptions struct {
+	total    int
+	renewals []objects.Renewal
+	tenants  []string // тенанты, в которых считали суммы
+}
+
+func (s *fakeSubscriptions) GetTotalCost(ctx context.Context, userID uuid.UUID, serviceName string, start, end time.Time) (int, error) {
+	tenantID, _ := tenant.FromContext(ctx)
+	s.tenants = append(s.tenants, tenantID)
+	return s.total, nil
+}
+
+func (s *fakeSubscriptions) GetRenewals(ctx context.Context, userID uuid.UUID, from, to time.Time) ([]objects.Renewal, error) {
+	var renewals []objects.Renewal
+	for _, renewal := range s.renewals {
+		if !renewal.Date.Before(from) && renewal.Date.Before(to) {
+			renewals = append(renewals, renewal)
+		}
+	}
+	return renewals, nil
+}
+
+type failingSender struct{}
+
+func (failingSender) Send(ctx context.Context, msg Message) error {
+	return errors.New("smtp unavailable")
+}
+
+func newStore(kind string, prefs objects.NotificationPreferences) *fakeStore {
+	return &fakeStore{
+		recipients: map[string][]objects.NotificationPreferences{kind: {prefs}},
+		sent:       map[string]bool{},
+	}
+}
+
+func TestSendMonthlySummaries_OncePerMonth(t *testing.T) {
+	user := objects.NotificationPreferences{UserID: uuid.New(), Email: "user@example.com"}
+	store := newStore(KindMonthlySummary, user)
+	subs := &fakeSubscriptions{
+		total: 1200,
+		renewals: []objects.Renewal{
+			{ServiceName: "Yandex Plus", Price: 400, Date: time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC)},
+			{ServiceName: "Netflix", Price: 800, Date: time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC)},
+		},
+	}
+	var out bytes.Buffer
+	digests := NewDigests(store, subs, NewWriterSender(&out, "noreply@example.com"), logger_module.Get())
+
+	// Окно без начала месяца — писем нет
+	err := digests.SendMonthlySummaries(context.Background(),
+		time.Date(2025, time.March, 15, 3, 0, 0, 0, time.UTC), time.Date(2025, time.March, 16, 3, 0, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	assert.Empty(t, out.String())
+
+	// Запуск 1 апреля отправляет сводку за март, повтор того же окна ничего не шлет
+	from, to := time.Date(2025, time.March, 31, 3, 0, 0, 0, time.UTC), time.Date(2025, time.April, 1, 3, 0, 0, 0, time.UTC)
+	assert.NoError(t, digests.SendMonthlySummaries(context.Background(), from, to))
+	assert.NoError(t, digests.SendMonthlySummaries(context.Background(), from, to))
+
+	mail := out.String()
+	assert.Equal(t, 1, strings.Count(mail, "To: user@example.com"))
+	assert.Contains(t, mail, "Subject: =?utf-8?q?")
+	assert.Contains(t, mail, "за 03-2025: 1200 руб.")
+	assert.Contains(t, mail, "01.03.2025  Netflix — 800 руб.")
+}
+
+func TestSendMonthlySummaries_PerTenant(t *testing.T) {
+	// Один пользователь в двух организациях получает по сводке от каждой
+	userID := uuid.New()
+	store := &fakeStore{
+		recipients: map[string][]objects.NotificationPreferences{KindMonthlySummary: {
+			{TenantID: tenant.Default, UserID: userID, Email: "user@example.com"},
+			{TenantID: "acme", UserID: userID, Email: "user@acme.example"},
+		}},
+		sent: map[string]bool{},
+	}
+	subs := &fakeSubscriptions{total: 500}
+	var out bytes.Buffer
+	digests := NewDigests(store, subs, NewWriterSender(&out, "noreply@example.com"), logger_module.Get())
+
+	from, to := time.Date(2025, time.March, 31, 3, 0, 0, 0, time.UTC), time.Date(2025, time.April, 1, 3, 0, 0, 0, time.UTC)
+	assert.NoError(t, digests.SendMonthlySummaries(tenant.WithAllTenants(context.Background()), from, to))
+
+	mail := out.String()
+	assert.Equal(t, 1, strings.Count(mail, "To: user@example.com"))
+	assert.Equal(t, 1, strings.Count(mail, "To: user@acme.example"))
+	assert.Equal(t, []string{tenant.Default, "acme"}, subs.tenants)
+	assert.True(t, store.sent[KindMonthlySummary+userID.String()+":2025-03"])
+	assert.True(t, store.sent[KindMonthlySummary+"acme:"+userID.String()+":2025-03"])
+}
+
+func TestSendRenewalReminders_GroupsByDayAndRetriesFailures(t *testing.T) {
+	user := objects.NotificationPreferences{UserID: uuid.New(), Email: "user@example.com"}
+	store := newStore(KindRenewalReminders, user)
+	subs := &fakeSubscriptions{
+		renewals: []objects.Renewal{
+			{ServiceName: "Netflix", Price: 800, Date: time.Date(2025, time.April, 1, 0, 0, 0, 0, time.UTC)},
+			{ServiceName: "Yandex Plus", Price: 400, Date: time.Date(2025, time.April, 1, 0, 0, 0, 0, time.UTC)},
+		},
+	}
+	// Списание 1 апреля попадает в окно запуска 29 марта
+	from, to := time.Date(2025, time.March, 28, 3, 0, 0, 0, time.UTC), time.Date(2025, time.March, 29, 3, 0, 0, 0, time.UTC)
+
+	failing := NewDigests(store, subs, failingSender{}, logger_module.Get())
+	assert.Error(t, failing.SendRenewalReminders(context.Background(), from, to))
+	assert.Empty(t, store.sent)
+
+	var out bytes.Buffer
+	digests := NewDigests(store, subs, NewWriterSender(&out, "noreply@example.com"), logger_module.Get())
+	assert.NoError(t, digests.SendRenewalReminders(context.Background(), from, to))
+
+	mail := out.String()
+	assert.Equal(t, 1, strings.Count(mail, "To: user@example.com"))
+	assert.Contains(t, mail, "Итого: 1200 руб.")
+}
@@ -0,0 +1,93 @@
+package notifications
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/smtp"
+	"os"
+	"sync"
+	"time"
+)
+
+// Письмо пользователю
+type Message struct {
+	To      string
+	Subject string
+	Body    string // обычный текст
+}
+
+// Способ доставки писем
+type Sender interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// Собираем письмо в формате RFC 5322 с UTF-8 телом
+func (msg Message) bytes(from string, date time.Time) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(msg.Body)
+	return b.Bytes()
+}
+
+// Отправка через SMTP-сервер; STARTTLS включается сам, если сервер его поддерживает
+type SMTPSender struct {
+	addr string
+	auth smtp.Auth
+	from string
+}
+
+func NewSMTPSender(host, port, username, password, from string) *SMTPSender {
+	var auth smtp.Auth
+	if username != "" {
+		auth = smtp.PlainAuth("", username, password, host)
+	}
+	return &SMTPSender{addr: net.JoinHostPort(host, port), auth: auth, from: from}
+}
+
+func (sender *SMTPSender) Send(ctx context.Context, msg Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return smtp.SendMail(sender.addr, sender.auth, sender.from, []string{msg.To}, msg.bytes(sender.from, time.Now()))
+}
+
+// Для локальной разработки: письма пишутся в stdout или файл вместо отправки
+type WriterSender struct {
+	mutex sync.Mutex
+	out   io.Writer
+	from  string
+}
+
+func NewWriterSender(out io.Writer, from string) *WriterSender {
+	return &WriterSender{out: out, from: from}
+}
+
+// Письма дописываются в конец файла
+func NewFileSender(path, from string) (*WriterSender, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return NewWriterSender(file, from), nil
+}
+
+func (sender *WriterSender) Send(ctx context.Context, msg Message) error {
+	sender.mutex.Lock()
+	defer sender.mutex.Unlock()
+	if _, err := sender.out.Write(msg.bytes(sender.from, time.Now())); err != nil {
+		return err
+	}
+	_, err := io.WriteString(sender.out, "\r\n----------\r\n")
+	return err
+}
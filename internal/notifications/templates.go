@@ -0,0 +1,49 @@
+package notifications
+
+import (
+	"bytes"
+	"effective_mobile/internal/objects"
+	"embed"
+	"text/template"
+	"time"
+)
+
+//go:embed templates/*.tmpl
+var templateFS embed.FS
+
+var templateFuncs = template.FuncMap{
+	"month": func(t time.Time) string { return t.Format("01-2006") },
+	"date":  func(t time.Time) string { return t.Format("02.01.2006") },
+}
+
+// Каждый шаблон определяет блоки "subject" и "body"
+var (
+	monthlySummaryTemplate  = template.Must(template.New("").Funcs(templateFuncs).ParseFS(templateFS, "templates/monthly_summary.tmpl"))
+	renewalReminderTemplate = template.Must(template.New("").Funcs(templateFuncs).ParseFS(templateFS, "templates/renewal_reminder.tmpl"))
+)
+
+// Данные для ежемесячной сводки
+type MonthlySummary struct {
+	Month    time.Time // первое число месяца
+	Total    int
+	Renewals []objects.Renewal
+}
+
+// Данные для напоминания о списаниях в один день
+type RenewalReminder struct {
+	Date     time.Time
+	DaysLeft int
+	Total    int
+	Renewals []objects.Renewal
+}
+
+func render(tmpl *template.Template, to string, data any) (Message, error) {
+	var subject, body bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return Message{}, err
+	}
+	if err := tmpl.ExecuteTemplate(&body, "body", data); err != nil {
+		return Message{}, err
+	}
+	return Message{To: to, Subject: subject.String(), Body: body.String()}, nil
+}
@@ -0,0 +1,27 @@
+package objects
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Структура для создания API-ключа
+type APIKeyCreateRequest struct {
+	Name string `json:"name" example:"billing-export" binding:"required"`
+}
+
+// API-ключ пользователя. Сам ключ хранится только в виде хэша
+type APIKey struct {
+	ID         uuid.UUID  `gorm:"type:uuid;primaryKey" json:"id" example:"550e8400-e29b-41d4-a716-446655440000"`
+	UserID     uuid.UUID  `gorm:"type:uuid;not null" json:"user_id" example:"60601fee-2bf1-4721-ae6f-7636e79a0cba"`
+	Name       string     `gorm:"not null" json:"name" example:"billing-export"`
+	Prefix     string     `gorm:"not null" json:"prefix" example:"em_3f9a1c"` // начало ключа, чтобы отличать ключи в списке
+	KeyHash    string     `gorm:"not null;uniqueIndex" json:"-"`
+	Key        string     `gorm:"-" json:"key,omitempty" example:"em_3f9a1c..."`        // только в ответе на создание
+	Roles      []string   `gorm:"serializer:json;not null" json:"roles" example:"user"` // роли владельца на момент выпуска
+	CreatedAt  time.Time  `gorm:"not null" json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	TenantID   string     `gorm:"not null" json:"-"` // запросы с ключом выполняются в этой организации
+}
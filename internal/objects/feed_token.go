@@ -0,0 +1,15 @@
+package objects
+
+import "time"
+
+// Структура для выпуска токена фида
+type FeedTokenRequest struct {
+	Path string `json:"path" example:"/api/users/60601fee-2bf1-4721-ae6f-7636e79a0cba/renewals.ics" binding:"required"`
+}
+
+// Токен для чтения одного фида, передается в параметре access_token
+type FeedToken struct {
+	Token     string    `json:"token" example:"eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9..."`
+	Path      string    `json:"path" example:"/api/users/60601fee-2bf1-4721-ae6f-7636e79a0cba/renewals.ics"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
@@ -0,0 +1,28 @@
+package objects
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Настройки уведомлений пользователя
+type NotificationPreferences struct {
+	TenantID         string    `gorm:"primaryKey" json:"-"`
+	UserID           uuid.UUID `gorm:"type:uuid;primaryKey" json:"user_id" example:"550e8400-e29b-41d4-a716-446655440000"`
+	Email            string    `gorm:"not null" json:"email" example:"user@example.com"`
+	MonthlySummary   bool      `gorm:"not null" json:"monthly_summary" example:"true"`   // ежемесячная сводка расходов
+	RenewalReminders bool      `gorm:"not null" json:"renewal_reminders" example:"true"` // напоминания за 3 дня до списания
+	UpdatedAt        time.Time `gorm:"not null" json:"updated_at"`
+}
+
+// Структура для обновления настроек уведомлений
+type NotificationPreferencesRequest struct {
+	Email            string `json:"email" example:"user@example.com" binding:"required"`
+	MonthlySummary   *bool  `json:"monthly_summary,omitempty" example:"true"`
+	RenewalReminders *bool  `json:"renewal_reminders,omitempty" example:"false"`
+}
+
+func (NotificationPreferences) TableName() string {
+	return "notification_preferences"
+}
@@ -17,21 +17,36 @@ type SubscriptionUpdateRequest struct {
 
 // Отдельная структура для создания подписки
 type SubscriptionCreateRequest struct {
-	ServiceName string  `json:"service_name" example:"Netflix" binding:"required"`
-	Price       int     `json:"price" example:"599" binding:"required"`
-	UserID      string  `json:"user_id" example:"550e8400-e29b-41d4-a716-446655440000" binding:"required"`
-	StartDate   string  `json:"start_date" example:"09-2025" binding:"required"`
-	EndDate     *string `json:"end_date" example:"03-2025"`
+	ServiceName  string  `json:"service_name" example:"Netflix" binding:"required"`
+	Price        int     `json:"price" example:"599" binding:"required"`
+	UserID       string  `json:"user_id" example:"550e8400-e29b-41d4-a716-446655440000" binding:"required"`
+	StartDate    string  `json:"start_date" example:"09-2025" binding:"required"`
+	EndDate      *string `json:"end_date" example:"03-2025"`
+	TrialEndDate *string `json:"trial_end_date,omitempty" example:"10-2025"`
 }
 
 // Основная структура системы
 type Subscription struct {
-	ID          uuid.UUID  `gorm:"type:uuid;primaryKey"  json:"id" example:"550e8400-e29b-41d4-a716-446655440000"`   // уникальный идендификатор
-	ServiceName string     `gorm:"not null" json:"service_name" example:"Netflix"`                                   // Название сервиса
-	Price       int        `gorm:"not null;check:price > 0" json:"price" example:"599"`                              // Цена подписки
-	UserID      uuid.UUID  `gorm:"type:uuid;not null" json:"user_id" example:"550e8400-e29b-41d4-a716-446655440000"` // уникальный id пользователя
-	StartDate   time.Time  `gorm:"not null" json:"start_date" swaggertype:"string" example:"09-2025"`                // Начало активации подписки
-	EndDate     *time.Time `json:"end_date,omitempty" swaggertype:"string" example:"03-2025"`                        // Окончание подписки
+	ID           uuid.UUID  `gorm:"type:uuid;primaryKey"  json:"id" example:"550e8400-e29b-41d4-a716-446655440000"`   // уникальный идендификатор
+	ServiceName  string     `gorm:"not null" json:"service_name" example:"Netflix"`                                   // Название сервиса
+	Price        int        `gorm:"not null;check:price > 0" json:"price" example:"599"`                              // Цена подписки
+	UserID       uuid.UUID  `gorm:"type:uuid;not null" json:"user_id" example:"550e8400-e29b-41d4-a716-446655440000"` // уникальный id пользователя
+	StartDate    time.Time  `gorm:"not null" json:"start_date" swaggertype:"string" example:"09-2025"`                // Начало активации подписки
+	EndDate      *time.Time `json:"end_date,omitempty" swaggertype:"string" example:"03-2025"`                        // Окончание подписки
+	TrialEndDate *time.Time `json:"trial_end_date,omitempty" swaggertype:"string" example:"10-2025"`                  // Окончание пробного периода
+	TenantID     string     `gorm:"not null" json:"-"`                                                                // организация, заполняется репозиторием из контекста
+}
+
+// Период списания по подписке в месяцах: цена в системе указывается за месяц,
+// поэтому подписка продлевается ежемесячно начиная с даты старта
+const BillingPeriodMonths = 1
+
+// Продление подписки (одно списание) в конкретную дату
+type Renewal struct {
+	SubscriptionID uuid.UUID `json:"subscription_id" example:"550e8400-e29b-41d4-a716-446655440000"`
+	ServiceName    string    `json:"service_name" example:"Netflix"`
+	Price          int       `json:"price" example:"599"`
+	Date           time.Time `json:"date" swaggertype:"string" example:"2025-09-01T00:00:00Z"`
 }
 
 func (s *Subscription) IsActive(time_subscription time.Time) bool {
@@ -41,6 +56,27 @@ func (s *Subscription) IsActive(time_subscription time.Time) bool {
 	return time_subscription.After(s.StartDate) && time_subscription.Before(*s.EndDate)
 }
 
+// Возвращает даты списаний по подписке в полуинтервале [from, to).
+// Списания идут от StartDate с шагом BillingPeriodMonths, месяц EndDate оплачивается, как и в сумме
+// /api/subscriptions/total, где EndDate входит в период
+func (s *Subscription) RenewalDates(from, to time.Time) []time.Time {
+	var dates []time.Time
+	for i := 0; ; i++ {
+		// Считаем каждую дату от StartDate, чтобы не накапливать сдвиг из за разной длины месяцев
+		date := s.StartDate.AddDate(0, i*BillingPeriodMonths, 0)
+		if !date.Before(to) {
+			break
+		}
+		if s.EndDate != nil && date.After(*s.EndDate) {
+			break
+		}
+		if !date.Before(from) {
+			dates = append(dates, date)
+		}
+	}
+	return dates
+}
+
 // Хук перед созданием для генерации id если нету
 func (s *Subscription) BeforeCreate(tx *gorm.DB) error {
 	if s.ID == uuid.Nil {
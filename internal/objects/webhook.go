@@ -0,0 +1,62 @@
+package objects
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Статусы доставки вебхука
+const (
+	DeliveryPending   = "pending"   // ждет первой или повторной попытки
+	DeliveryDelivered = "delivered" // получатель ответил 2xx
+	DeliveryDead      = "dead"      // попытки закончились, доставка в dead-letter
+)
+
+// Структура для регистрации вебхука
+type WebhookCreateRequest struct {
+	URL        string   `json:"url" example:"https://billing.example.com/hooks/subscriptions" binding:"required"`
+	EventTypes []string `json:"event_types,omitempty" example:"subscription.created,subscription.deleted"` // пусто = все события
+	Secret     string   `json:"secret,omitempty" example:"s3cr3t"`                                         // если не указан, сгенерируем
+}
+
+// Зарегистрированный получатель событий
+type Webhook struct {
+	ID         uuid.UUID `gorm:"type:uuid;primaryKey" json:"id" example:"550e8400-e29b-41d4-a716-446655440000"`
+	URL        string    `gorm:"not null" json:"url" example:"https://billing.example.com/hooks/subscriptions"`
+	Secret     string    `gorm:"not null" json:"secret,omitempty" example:"s3cr3t"` // отдаем только при создании
+	EventTypes []string  `gorm:"serializer:json;not null" json:"event_types" example:"subscription.created"`
+	Active     bool      `gorm:"not null" json:"active" example:"true"`
+	CreatedAt  time.Time `gorm:"not null" json:"created_at"`
+	TenantID   string    `gorm:"not null" json:"-"` // получает события только своей организации
+}
+
+// Подписан ли вебхук на событие данного типа
+func (hook *Webhook) Accepts(eventType string) bool {
+	if len(hook.EventTypes) == 0 {
+		return true
+	}
+	for _, accepted := range hook.EventTypes {
+		if accepted == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// Доставка одного события одному вебхуку, заодно запись в журнале доставок
+type WebhookDelivery struct {
+	ID             uuid.UUID  `gorm:"type:uuid;primaryKey" json:"id" example:"550e8400-e29b-41d4-a716-446655440000"`
+	WebhookID      uuid.UUID  `gorm:"type:uuid;not null" json:"webhook_id" example:"550e8400-e29b-41d4-a716-446655440000"`
+	EventID        uuid.UUID  `gorm:"type:uuid;not null" json:"event_id" example:"550e8400-e29b-41d4-a716-446655440000"`
+	EventType      string     `gorm:"not null" json:"event_type" example:"subscription.created"`
+	Payload        string     `gorm:"type:jsonb;not null" json:"-"`
+	Status         string     `gorm:"not null" json:"status" example:"delivered"`
+	Attempts       int        `gorm:"not null" json:"attempts" example:"1"`
+	NextAttemptAt  time.Time  `gorm:"not null" json:"next_attempt_at"`
+	LastStatusCode *int       `json:"last_status_code,omitempty" example:"200"`
+	LastError      *string    `json:"last_error,omitempty"`
+	CreatedAt      time.Time  `gorm:"not null" json:"created_at"`
+	UpdatedAt      time.Time  `gorm:"not null" json:"updated_at"`
+	DeliveredAt    *time.Time `json:"delivered_at,omitempty"`
+}
@@ -0,0 +1,187 @@
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Ограничение token bucket: корзина вмещает Burst запросов и пополняется на Rate запросов в секунду
+type Rule struct {
+	Rate  float64
+	Burst int
+}
+
+// Через сколько опустевшая корзина заполнится целиком
+func (rule Rule) Window() time.Duration {
+	return time.Duration(float64(rule.Burst) / rule.Rate * float64(time.Second))
+}
+
+// Разбираем ограничение вида "10/s", "600/m:50" или "1000/h". Без ":burst" корзина вмещает
+// столько запросов, сколько указано до "/", т.е. "600/m" допускает всплеск в 600 запросов
+func ParseRule(spec string) (Rule, error) {
+	spec = strings.TrimSpace(spec)
+	rate, burst, hasBurst := strings.Cut(spec, ":")
+	count, unit, found := strings.Cut(rate, "/")
+	if !found {
+		return Rule{}, fmt.Errorf("invalid rate limit %q: expected <count>/<s|m|h>[:burst]", spec)
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(count))
+	if err != nil || n <= 0 {
+		return Rule{}, fmt.Errorf("invalid rate limit %q: count must be a positive integer", spec)
+	}
+
+	var per time.Duration
+	switch strings.TrimSpace(unit) {
+	case "s":
+		per = time.Second
+	case "m":
+		per = time.Minute
+	case "h":
+		per = time.Hour
+	default:
+		return Rule{}, fmt.Errorf("invalid rate limit %q: unit must be s, m or h", spec)
+	}
+
+	rule := Rule{Rate: float64(n) / per.Seconds(), Burst: n}
+	if hasBurst {
+		rule.Burst, err = strconv.Atoi(strings.TrimSpace(burst))
+		if err != nil || rule.Burst <= 0 {
+			return Rule{}, fmt.Errorf("invalid rate limit %q: burst must be a positive integer", spec)
+		}
+	}
+	return rule, nil
+}
+
+// Ограничения отдельных роутов через запятую: "GET /api/subscriptions/total=1/s:5,/api/webhooks=10/m".
+// Роут — шаблон пути gorilla/mux, метод можно не указывать
+func ParseRouteRules(spec string) (map[string]Rule, error) {
+	rules := make(map[string]Rule)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		route, rule, found := strings.Cut(entry, "=")
+		if !found || strings.TrimSpace(route) == "" {
+			return nil, fmt.Errorf("invalid route rate limit %q: expected [METHOD ]<path>=<limit>", entry)
+		}
+		parsed, err := ParseRule(rule)
+		if err != nil {
+			return nil, err
+		}
+		rules[strings.Join(strings.Fields(route), " ")] = parsed
+	}
+	return rules, nil
+}
+
+// Результат проверки ограничения
+type Decision struct {
+	Allowed    bool
+	Limit      int           // емкость корзины
+	Remaining  int           // сколько запросов можно сделать прямо сейчас
+	Reset      time.Duration // через сколько корзина заполнится целиком
+	RetryAfter time.Duration // для отклоненного запроса: когда появится следующий токен
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	rule    Rule
+}
+
+// Пополняем корзину за прошедшее время
+func (b *bucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.updated).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(float64(b.rule.Burst), b.tokens+elapsed*b.rule.Rate)
+		b.updated = now
+	}
+}
+
+// Корзина, которая успела заполниться, ничем не отличается от новой, поэтому ее можно удалить
+const sweepInterval = time.Minute
+
+// Корзины клиентов в памяти процесса. У каждой реплики свои корзины, поэтому
+// при N репликах за балансировщиком клиент получает до N-кратного лимита
+type Limiter struct {
+	defaultRule Rule
+	routes      map[string]Rule
+	mutex       sync.Mutex
+	buckets     map[string]*bucket
+	lastSweep   time.Time
+}
+
+func NewLimiter(defaultRule Rule, routes map[string]Rule) *Limiter {
+	return &Limiter{defaultRule: defaultRule, routes: routes, buckets: make(map[string]*bucket)}
+}
+
+// Новые ограничения из перечитанного конфига. Корзины сбрасываются: после смены
+// ограничения каждый клиент начинает с полной корзиной нового размера
+func (limiter *Limiter) SetRules(defaultRule Rule, routes map[string]Rule) {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+	limiter.defaultRule = defaultRule
+	limiter.routes = routes
+	limiter.buckets = make(map[string]*bucket)
+}
+
+// Ограничение роута: сначала "METHOD шаблон", затем просто шаблон, иначе общее
+func (limiter *Limiter) Rule(method, route string) (Rule, string) {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+	return limiter.rule(method, route)
+}
+
+func (limiter *Limiter) rule(method, route string) (Rule, string) {
+	if rule, ok := limiter.routes[method+" "+route]; ok {
+		return rule, method + " " + route
+	}
+	if rule, ok := limiter.routes[route]; ok {
+		return rule, route
+	}
+	return limiter.defaultRule, ""
+}
+
+// Списываем токен из корзины клиента на роуте. Роуты без собственного ограничения делят одну корзину клиента
+func (limiter *Limiter) Allow(client, method, route string, now time.Time) Decision {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+	limiter.sweep(now)
+
+	rule, key := limiter.rule(method, route)
+	key = client + "|" + key
+
+	b, ok := limiter.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(rule.Burst), updated: now, rule: rule}
+		limiter.buckets[key] = b
+	}
+	b.refill(now)
+
+	decision := Decision{Limit: rule.Burst}
+	if b.tokens >= 1 {
+		b.tokens--
+		decision.Allowed = true
+	} else {
+		decision.RetryAfter = time.Duration((1 - b.tokens) / rule.Rate * float64(time.Second))
+	}
+	decision.Remaining = int(b.tokens)
+	decision.Reset = time.Duration((float64(rule.Burst) - b.tokens) / rule.Rate * float64(time.Second))
+	return decision
+}
+
+func (limiter *Limiter) sweep(now time.Time) {
+	if now.Sub(limiter.lastSweep) < sweepInterval {
+		return
+	}
+	limiter.lastSweep = now
+	for key, b := range limiter.buckets {
+		b.refill(now)
+		if b.tokens >= float64(b.rule.Burst) {
+			delete(limiter.buckets, key)
+		}
+	}
+}
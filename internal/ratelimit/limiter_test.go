@@ -0,0 +1,189 @@
+package ratelimit
+
+import (
+	"context"
+	"effective_mobile/pkg/logger_module"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRule(t *testing.T) {
+	rule, err := ParseRule("10/s")
+	assert.NoError(t, err)
+	assert.Equal(t, Rule{Rate: 10, Burst: 10}, rule)
+
+	rule, err = ParseRule("60/m:5")
+	assert.NoError(t, err)
+	assert.Equal(t, Rule{Rate: 1, Burst: 5}, rule)
+	assert.Equal(t, 5*time.Second, rule.Window())
+
+	for _, spec := range []string{"", "10", "0/s", "10/d", "10/s:0", "x/s"} {
+		_, err := ParseRule(spec)
+		assert.Error(t, err, spec)
+	}
+
+	routes, err := ParseRouteRules("GET  /api/subscriptions/total=1/s:5, /api/webhooks=10/m")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]Rule{
+		"GET /api/subscriptions/total": {Rate: 1, Burst: 5},
+		"/api/webhooks":                {Rate: 10.0 / 60, Burst: 10},
+	}, routes)
+
+	_, err = ParseRouteRules("/api/webhooks")
+	assert.Error(t, err)
+}
+
+func TestLimiter_Allow(t *testing.T) {
+	limiter := NewLimiter(Rule{Rate: 1, Burst: 2}, map[string]Rule{"GET /api/subscriptions/total": {Rate: 0.5, Burst: 1}})
+	now := time.Date(2025, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+	// Всплеск в пределах корзины, затем отказ до пополнения
+	assert.True(t, limiter.Allow("a", "GET", "/api/subscriptions", now).Allowed)
+	decision := limiter.Allow("a", "POST", "/api/subscriptions", now)
+	assert.True(t, decision.Allowed)
+	assert.Equal(t, 0, decision.Remaining)
+	assert.Equal(t, 2*time.Second, decision.Reset)
+
+	decision = limiter.Allow("a", "GET", "/api/subscriptions", now)
+	assert.False(t, decision.Allowed)
+	assert.Equal(t, time.Second, decision.RetryAfter)
+
+	// У другого клиента своя корзина, у роута со своим ограничением тоже
+	assert.True(t, limiter.Allow("b", "GET", "/api/subscriptions", now).Allowed)
+	assert.True(t, limiter.Allow("a", "GET", "/api/subscriptions/total", now).Allowed)
+	decision = limiter.Allow("a", "GET", "/api/subscriptions/total", now)
+	assert.False(t, decision.Allowed)
+	assert.Equal(t, 2*time.Second, decision.RetryAfter)
+
+	// Через секунду появился токен
+	assert.True(t, limiter.Allow("a", "GET", "/api/subscriptions", now.Add(time.Second)).Allowed)
+
+	// Заполнившиеся корзины удаляются
+	limiter.Allow("c", "GET", "/api/subscriptions", now.Add(time.Hour))
+	assert.Len(t, limiter.buckets, 1)
+}
+
+func TestLimiter_SetRules(t *testing.T) {
+	limiter := NewLimiter(Rule{Rate: 1, Burst: 1}, nil)
+	now := time.Date(2025, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+	assert.True(t, limiter.Allow("a", "GET", "/api/subscriptions", now).Allowed)
+	assert.False(t, limiter.Allow("a", "GET", "/api/subscriptions", now).Allowed)
+
+	// Новое ограничение действует сразу, корзина клиента начинается заново
+	limiter.SetRules(Rule{Rate: 1, Burst: 3}, map[string]Rule{"/api/webhooks": {Rate: 1, Burst: 1}})
+	decision := limiter.Allow("a", "GET", "/api/subscriptions", now)
+	assert.True(t, decision.Allowed)
+	assert.Equal(t, 3, decision.Limit)
+	rule, key := limiter.Rule("POST", "/api/webhooks")
+	assert.Equal(t, Rule{Rate: 1, Burst: 1}, rule)
+	assert.Equal(t, "/api/webhooks", key)
+}
+
+type fakeQuotaStore struct {
+	tiers  map[string]string
+	counts map[string]int64
+	writes int
+	err    error
+}
+
+func (s *fakeQuotaStore) AddDaily(ctx context.Context, tenantID string, day time.Time, n int64) (int64, error) {
+	if s.err != nil {
+		return 0, s.err
+	}
+	key := tenantID + day.Format("2006-01-02")
+	s.writes++
+	s.counts[key] += n
+	return s.counts[key], nil
+}
+
+func (s *fakeQuotaStore) DailyUsage(ctx context.Context, tenantID string, day time.Time) (int64, error) {
+	return s.counts[tenantID+day.Format("2006-01-02")], nil
+}
+
+func (s *fakeQuotaStore) TenantTier(ctx context.Context, tenantID string) (string, error) {
+	return s.tiers[tenantID], nil
+}
+
+func TestQuotas_Check(t *testing.T) {
+	tiers, err := ParseTiers("free=2, enterprise=0")
+	require.NoError(t, err)
+	store := &fakeQuotaStore{tiers: map[string]string{"acme": "enterprise"}, counts: map[string]int64{}}
+	quotas := NewQuotas(store, tiers, "free", time.Second, logger_module.Get())
+	now := time.Date(2025, time.March, 1, 18, 0, 0, 0, time.UTC)
+
+	// Тариф по умолчанию: два запроса в сутки
+	decision, err := quotas.Check(context.Background(), "default", now)
+	assert.NoError(t, err)
+	assert.Equal(t, QuotaDecision{Allowed: true, Limited: true, Limit: 2, Remaining: 1, Reset: 6 * time.Hour}, decision)
+	decision, _ = quotas.Check(context.Background(), "default", now)
+	assert.True(t, decision.Allowed)
+	decision, _ = quotas.Check(context.Background(), "default", now)
+	assert.False(t, decision.Allowed)
+	assert.Equal(t, int64(0), decision.Remaining)
+
+	// Новые сутки — новый счетчик
+	decision, _ = quotas.Check(context.Background(), "default", now.Add(7*time.Hour))
+	assert.True(t, decision.Allowed)
+
+	// Тариф без лимита не считается
+	decision, _ = quotas.Check(context.Background(), "acme", now)
+	assert.Equal(t, QuotaDecision{Allowed: true}, decision)
+	quotas.Flush(context.Background(), now)
+	assert.NotContains(t, store.counts, "acme2025-03-01")
+	assert.Equal(t, int64(3), store.counts["default2025-03-01"])
+
+	_, err = ParseTiers("free")
+	assert.Error(t, err)
+}
+
+func TestQuotas_Flush(t *testing.T) {
+	store := &fakeQuotaStore{counts: map[string]int64{}}
+	tiers := map[string]int64{"free": 3}
+	first := NewQuotas(store, tiers, "free", time.Second, logger_module.Get())
+	second := NewQuotas(store, tiers, "free", time.Second, logger_module.Get())
+	now := time.Date(2025, time.March, 1, 18, 0, 0, 0, time.UTC)
+
+	// Запросы копятся в памяти и записываются одним запросом к базе
+	for range 2 {
+		decision, err := first.Check(context.Background(), "acme", now)
+		require.NoError(t, err)
+		assert.True(t, decision.Allowed)
+	}
+	assert.Zero(t, store.writes)
+	first.Flush(context.Background(), now)
+	assert.Equal(t, 1, store.writes)
+	assert.Equal(t, int64(2), store.counts["acme2025-03-01"])
+
+	// Другая реплика учитывает записанный расход
+	decision, _ := second.Check(context.Background(), "acme", now)
+	assert.True(t, decision.Allowed)
+	assert.Equal(t, int64(0), decision.Remaining)
+	decision, _ = second.Check(context.Background(), "acme", now)
+	assert.False(t, decision.Allowed)
+
+	// Если база недоступна, запросы не теряются и записываются следующей попыткой
+	store.err = errors.New("connection refused")
+	second.Flush(context.Background(), now)
+	assert.Equal(t, int64(2), store.counts["acme2025-03-01"])
+	store.err = nil
+	second.Flush(context.Background(), now)
+	assert.Equal(t, int64(4), store.counts["acme2025-03-01"])
+
+	// Расход других реплик реплика узнает, когда записывает свои запросы
+	decision, _ = first.Check(context.Background(), "acme", now)
+	assert.Equal(t, int64(3), decision.Limit)
+	assert.True(t, decision.Allowed)
+	first.Flush(context.Background(), now)
+	decision, _ = first.Check(context.Background(), "acme", now)
+	assert.False(t, decision.Allowed)
+
+	// Прошедшие сутки забываются после записи
+	first.Flush(context.Background(), now.Add(24*time.Hour))
+	first.Flush(context.Background(), now.Add(24*time.Hour))
+	assert.Empty(t, first.usage)
+}
@@ -0,0 +1,234 @@
+package ratelimit
+
+import (
+	"context"
+	"effective_mobile/pkg/logger_module"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Счетчики запросов за сутки и тарифы организаций
+type QuotaStore interface {
+	// Прибавляем к счетчику тенанта за день (UTC) n запросов и возвращаем новое значение со всех реплик
+	AddDaily(ctx context.Context, tenantID string, day time.Time, n int64) (int64, error)
+	// Текущее значение счетчика тенанта за день, 0 — запросов еще не было
+	DailyUsage(ctx context.Context, tenantID string, day time.Time) (int64, error)
+	// Тариф организации, пусто — тариф по умолчанию
+	TenantTier(ctx context.Context, tenantID string) (string, error)
+}
+
+// Тарифы с суточным лимитом запросов через запятую: "free=1000,pro=100000,enterprise=0", 0 — без лимита
+func ParseTiers(spec string) (map[string]int64, error) {
+	tiers := make(map[string]int64)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, value, found := strings.Cut(entry, "=")
+		limit, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+		if !found || strings.TrimSpace(name) == "" || err != nil || limit < 0 {
+			return nil, fmt.Errorf("invalid quota tier %q: expected <tier>=<daily requests>", entry)
+		}
+		tiers[strings.TrimSpace(name)] = limit
+	}
+	return tiers, nil
+}
+
+// Тариф тенанта меняется редко, не читаем его из базы на каждый запрос
+const tierCacheTTL = time.Minute
+
+// Результат проверки суточной квоты
+type QuotaDecision struct {
+	Allowed   bool
+	Limited   bool // false — у тарифа нет лимита, остальные поля не заполнены
+	Limit     int64
+	Remaining int64
+	Reset     time.Duration // до начала следующих суток по UTC
+}
+
+type cachedTier struct {
+	tier    string
+	expires time.Time
+}
+
+type usageKey struct {
+	tenantID string
+	day      time.Time
+}
+
+// Расход тенанта за день на этой реплике
+type dailyUsage struct {
+	flushed int64 // значение счетчика в базе после последней записи или чтения
+	pending int64 // запросы, еще не записанные в базу
+}
+
+// Суточные квоты организаций по тарифам. Счетчики хранятся в Postgres и общие для всех реплик.
+// Каждая реплика копит запросы в памяти и раз в flushInterval прибавляет их к счетчику одним запросом,
+// иначе все запросы тенанта выстраивались бы в очередь за блокировкой одной строки daily_usage.
+// Поэтому квоту можно превысить на число запросов, принятых другими репликами за интервал
+type Quotas struct {
+	store         QuotaStore
+	tiers         map[string]int64
+	defaultTier   string
+	flushInterval time.Duration
+	logger        *logger_module.Logger
+	mutex         sync.Mutex
+	cache         map[string]cachedTier
+	usage         map[usageKey]*dailyUsage
+	flushing      sync.Mutex
+	wg            sync.WaitGroup
+}
+
+func NewQuotas(store QuotaStore, tiers map[string]int64, defaultTier string, flushInterval time.Duration, logger *logger_module.Logger) *Quotas {
+	return &Quotas{
+		store:         store,
+		tiers:         tiers,
+		defaultTier:   defaultTier,
+		flushInterval: flushInterval,
+		logger:        logger,
+		cache:         make(map[string]cachedTier),
+		usage:         make(map[usageKey]*dailyUsage),
+	}
+}
+
+// Периодическая запись накопленных запросов; после отмены контекста записываем остаток
+func (quotas *Quotas) Start(ctx context.Context) {
+	quotas.wg.Add(1)
+	go func() {
+		defer quotas.wg.Done()
+		ticker := time.NewTicker(quotas.flushInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				flushCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+				quotas.Flush(flushCtx, time.Now())
+				cancel()
+				return
+			case now := <-ticker.C:
+				quotas.Flush(ctx, now)
+			}
+		}
+	}()
+}
+
+// Ждем последней записи счетчиков после отмены контекста
+func (quotas *Quotas) Wait() {
+	quotas.wg.Wait()
+}
+
+// Прибавляем накопленные запросы к счетчикам в базе и забываем прошедшие дни.
+// При ошибке запросы остаются в памяти до следующей попытки
+func (quotas *Quotas) Flush(ctx context.Context, now time.Time) {
+	// Записи не пересекаются, иначе одна могла бы удалить день, который пишет другая
+	quotas.flushing.Lock()
+	defer quotas.flushing.Unlock()
+
+	today := startOfDay(now)
+	quotas.mutex.Lock()
+	batch := make(map[usageKey]int64)
+	for key, usage := range quotas.usage {
+		if usage.pending > 0 {
+			batch[key] = usage.pending
+			usage.pending = 0
+		} else if key.day.Before(today) {
+			delete(quotas.usage, key)
+		}
+	}
+	quotas.mutex.Unlock()
+
+	for key, n := range batch {
+		total, err := quotas.store.AddDaily(ctx, key.tenantID, key.day, n)
+		quotas.mutex.Lock()
+		usage := quotas.usage[key]
+		if err != nil {
+			usage.pending += n
+		} else {
+			usage.flushed = total
+		}
+		quotas.mutex.Unlock()
+		if err != nil {
+			quotas.logger.Error("Failed to flush daily usage", "error", err, "tenant_id", key.tenantID, "requests", n)
+		}
+	}
+}
+
+// Учитываем запрос тенанта. Запросы сверх квоты тоже считаются, чтобы в счетчике было видно реальную нагрузку
+func (quotas *Quotas) Check(ctx context.Context, tenantID string, now time.Time) (QuotaDecision, error) {
+	tier, err := quotas.tier(ctx, tenantID, now)
+	if err != nil {
+		return QuotaDecision{}, err
+	}
+	limit, ok := quotas.tiers[tier]
+	if !ok || limit == 0 {
+		return QuotaDecision{Allowed: true}, nil
+	}
+
+	day := startOfDay(now)
+	used, err := quotas.count(ctx, usageKey{tenantID: tenantID, day: day})
+	if err != nil {
+		return QuotaDecision{}, err
+	}
+	return QuotaDecision{
+		Allowed:   used <= limit,
+		Limited:   true,
+		Limit:     limit,
+		Remaining: max(limit-used, 0),
+		Reset:     day.AddDate(0, 0, 1).Sub(now),
+	}, nil
+}
+
+// Учитываем запрос в памяти; расход с других реплик читаем из базы при первом запросе тенанта за день,
+// дальше он обновляется при записи счетчиков
+func (quotas *Quotas) count(ctx context.Context, key usageKey) (int64, error) {
+	quotas.mutex.Lock()
+	usage, ok := quotas.usage[key]
+	quotas.mutex.Unlock()
+	if !ok {
+		flushed, err := quotas.store.DailyUsage(ctx, key.tenantID, key.day)
+		if err != nil {
+			return 0, err
+		}
+		quotas.mutex.Lock()
+		if usage, ok = quotas.usage[key]; !ok {
+			usage = &dailyUsage{flushed: flushed}
+			quotas.usage[key] = usage
+		}
+		quotas.mutex.Unlock()
+	}
+
+	quotas.mutex.Lock()
+	defer quotas.mutex.Unlock()
+	usage.pending++
+	return usage.flushed + usage.pending, nil
+}
+
+func startOfDay(now time.Time) time.Time {
+	now = now.UTC()
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func (quotas *Quotas) tier(ctx context.Context, tenantID string, now time.Time) (string, error) {
+	quotas.mutex.Lock()
+	cached, ok := quotas.cache[tenantID]
+	quotas.mutex.Unlock()
+	if ok && now.Before(cached.expires) {
+		return cached.tier, nil
+	}
+
+	tier, err := quotas.store.TenantTier(ctx, tenantID)
+	if err != nil {
+		return "", err
+	}
+	if tier == "" {
+		tier = quotas.defaultTier
+	}
+	quotas.mutex.Lock()
+	quotas.cache[tenantID] = cachedTier{tier: tier, expires: now.Add(tierCacheTTL)}
+	quotas.mutex.Unlock()
+	return tier, nil
+}
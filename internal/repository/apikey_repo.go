@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"context"
+	"effective_mobile/internal/auth"
+	"effective_mobile/internal/objects"
+	"effective_mobile/internal/tenant"
+	"effective_mobile/pkg/logger_module"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type APIKeyRepo struct {
+	db     *gorm.DB
+	logger *logger_module.Logger
+}
+
+func NewAPIKeyRepo(db *gorm.DB, logger *logger_module.Logger) *APIKeyRepo {
+	return &APIKeyRepo{db: db, logger: logger}
+}
+
+// Сохраняем новый ключ (хэш уже посчитан сервисом)
+func (ar *APIKeyRepo) Create(ctx context.Context, key *objects.APIKey) error {
+	ar.logger.Info("Starting ORM request create api key in db")
+	if err := ar.db.WithContext(ctx).Create(key).Error; err != nil {
+		ar.logger.Error("Failed to create api key", "error", err)
+		return err
+	}
+	return nil
+}
+
+// Ключи пользователя вместе с отозванными
+// SELECT * FROM api_keys WHERE user_id = '...' ORDER BY created_at;
+func (ar *APIKeyRepo) ListByUser(ctx context.Context, userID uuid.UUID) ([]*objects.APIKey, error) {
+	ar.logger.Info("Starting ORM request get api keys in db")
+	var keys []*objects.APIKey
+	if err := ar.db.WithContext(ctx).Where("user_id = ?", userID).Order("created_at").Find(&keys).Error; err != nil {
+		ar.logger.Error("Failed to get api keys", "error", err, "user_id", userID)
+		return nil, err
+	}
+	return keys, nil
+}
+
+// Отзываем ключ пользователя, если ключа нет или он уже отозван то gorm.ErrRecordNotFound
+func (ar *APIKeyRepo) Revoke(ctx context.Context, id, userID uuid.UUID) error {
+	ar.logger.Info("Starting ORM request revoke api key in db")
+	result := ar.db.WithContext(ctx).
+		Model(&objects.APIKey{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", id, userID).
+		Update("revoked_at", time.Now().UTC())
+	if result.Error != nil {
+		ar.logger.Error("Failed to revoke api key", "error", result.Error, "id", id)
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// last_used_at обновляется не чаще раза в минуту: иначе каждый запрос с ключом был бы записью в одну строку
+const apiKeyLastUsedInterval = time.Minute
+
+// Ищем действующий ключ по хэшу
+// SELECT * FROM api_keys WHERE key_hash = '...' AND revoked_at IS NULL LIMIT 1;
+func (ar *APIKeyRepo) FindByHash(ctx context.Context, hash string) (*objects.APIKey, error) {
+	var key objects.APIKey
+	// Тенант еще не известен: он определяется по найденному ключу
+	err := ar.db.WithContext(tenant.WithAllTenants(ctx)).
+		Where("key_hash = ? AND revoked_at IS NULL", hash).
+		Take(&key).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, auth.ErrInvalidCredentials
+	}
+	if err != nil {
+		ar.logger.Error("Failed to find api key", "error", err)
+		return nil, err
+	}
+	ar.touch(ctx, &key)
+	return &key, nil
+}
+
+// Отмечаем использование ключа, если прошлая отметка старше apiKeyLastUsedInterval.
+// Условие повторяется в UPDATE, поэтому из параллельных запросов пишет только первый.
+// Ошибка отметки не мешает запросу
+// UPDATE api_keys SET last_used_at = ... WHERE id = '...' AND (last_used_at IS NULL OR last_used_at < ...);
+func (ar *APIKeyRepo) touch(ctx context.Context, key *objects.APIKey) {
+	now := time.Now().UTC()
+	stale := now.Add(-apiKeyLastUsedInterval)
+	if key.LastUsedAt != nil && key.LastUsedAt.After(stale) {
+		return
+	}
+	err := ar.db.WithContext(tenant.WithAllTenants(ctx)).
+		Model(&objects.APIKey{}).
+		Where("id = ? AND (last_used_at IS NULL OR last_used_at < ?)", key.ID, stale).
+		Update("last_used_at", now).Error
+	if err != nil {
+		ar.logger.Warn("Failed to update api key last use", "error", err, "id", key.ID)
+		return
+	}
+	key.LastUsedAt = &now
+}
@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"context"
+	"effective_mobile/internal/auth"
+	"effective_mobile/internal/objects"
+	"effective_mobile/internal/tenant"
+	"effective_mobile/pkg/logger_module"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Поиск ключа — чтение; last_used_at переписывается, только если отметка старше минуты
+func TestAPIKeyRepo_FindByHashTouchesLastUsedRarely(t *testing.T) {
+	db, _ := openTestSQLite(t)
+	allTenants := tenant.WithAllTenants(context.Background())
+	require.NoError(t, db.WithContext(allTenants).AutoMigrate(&objects.APIKey{}))
+	repo := NewAPIKeyRepo(db, logger_module.Get())
+
+	key := &objects.APIKey{ID: uuid.New(), UserID: uuid.New(), Name: "export", Prefix: "em_1", KeyHash: "hash", Roles: []string{"user"}, CreatedAt: time.Now().UTC()}
+	require.NoError(t, repo.Create(tenant.NewContext(context.Background(), "acme"), key))
+	lastUsed := func() *time.Time {
+		var stored objects.APIKey
+		require.NoError(t, db.WithContext(allTenants).Take(&stored, "id = ?", key.ID).Error)
+		return stored.LastUsedAt
+	}
+	setLastUsed := func(value time.Time) {
+		require.NoError(t, db.WithContext(allTenants).Model(&objects.APIKey{}).Where("id = ?", key.ID).Update("last_used_at", value).Error)
+	}
+
+	found, err := repo.FindByHash(context.Background(), "hash")
+	require.NoError(t, err)
+	assert.Equal(t, "acme", found.TenantID)
+	require.NotNil(t, lastUsed())
+
+	recent := time.Now().UTC().Add(-30 * time.Second).Truncate(time.Second)
+	setLastUsed(recent)
+	_, err = repo.FindByHash(context.Background(), "hash")
+	require.NoError(t, err)
+	assert.True(t, recent.Equal(*lastUsed()))
+
+	stale := time.Now().UTC().Add(-2 * time.Minute)
+	setLastUsed(stale)
+	_, err = repo.FindByHash(context.Background(), "hash")
+	require.NoError(t, err)
+	assert.True(t, lastUsed().After(stale.Add(time.Minute)))
+
+	_, err = repo.FindByHash(context.Background(), "other")
+	assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+	require.NoError(t, repo.Revoke(tenant.NewContext(context.Background(), "acme"), key.ID, key.UserID))
+	_, err = repo.FindByHash(context.Background(), "hash")
+	assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+}
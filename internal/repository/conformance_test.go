@@ -0,0 +1,362 @@
+package repository
+
+import (
+	"context"
+	"effective_mobile/internal/objects"
+	"effective_mobile/internal/tenant"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// Общий набор проверок SubsctriptionRepository: все реализации должны вести себя одинаково.
+// newRepo возвращает пустое хранилище для каждой проверки
+func runSubscriptionRepositorySuite(t *testing.T, newRepo func(t *testing.T) SubsctriptionRepository) {
+	acme := tenant.NewContext(context.Background(), "acme")
+	globex := tenant.NewContext(context.Background(), "globex")
+
+	t.Run("CreateAndGetByID", func(t *testing.T) {
+		repo := newRepo(t)
+		trialEnd := month(2025, 2)
+		subscription := newTestSubscription(uuid.New(), "Netflix", 599, month(2025, 1), nil)
+		subscription.TrialEndDate = &trialEnd
+		require.NoError(t, repo.Create(acme, subscription))
+		assert.Equal(t, "acme", subscription.TenantID)
+
+		found, err := repo.GetByID(acme, subscription.ID)
+		require.NoError(t, err)
+		assert.Equal(t, subscription.ServiceName, found.ServiceName)
+		assert.Equal(t, subscription.Price, found.Price)
+		assert.Equal(t, subscription.UserID, found.UserID)
+		assert.True(t, found.StartDate.Equal(subscription.StartDate))
+		assert.Nil(t, found.EndDate)
+		require.NotNil(t, found.TrialEndDate)
+		assert.True(t, found.TrialEndDate.Equal(trialEnd))
+
+		_, err = repo.GetByID(acme, uuid.New())
+		assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+		// id новой подписке выдает хранилище
+		generated := newTestSubscription(uuid.New(), "Spotify", 199, month(2025, 1), nil)
+		generated.ID = uuid.Nil
+		require.NoError(t, repo.Create(acme, generated))
+		assert.NotEqual(t, uuid.Nil, generated.ID)
+		_, err = repo.GetByID(acme, generated.ID)
+		assert.NoError(t, err)
+	})
+
+	t.Run("CreateRejectsInvalid", func(t *testing.T) {
+		repo := newRepo(t)
+		subscription := newTestSubscription(uuid.New(), "Netflix", 599, month(2025, 1), nil)
+		require.NoError(t, repo.Create(acme, subscription))
+		assert.Error(t, repo.Create(acme, subscription), "duplicate id")
+		assert.Error(t, repo.Create(acme, newTestSubscription(uuid.New(), "Netflix", 0, month(2025, 1), nil)), "price must be positive")
+	})
+
+	t.Run("TenantIsolation", func(t *testing.T) {
+		repo := newRepo(t)
+		subscription := newTestSubscription(uuid.New(), "Netflix", 599, month(2025, 1), nil)
+		require.NoError(t, repo.Create(acme, subscription))
+
+		_, err := repo.GetByID(globex, subscription.ID)
+		assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+		assert.ErrorIs(t, repo.Update(globex, subscription.ID, map[string]interface{}{"price": 1}), gorm.ErrRecordNotFound)
+		assert.Error(t, repo.Delete(globex, subscription.ID))
+		list, err := repo.Get_List(globex, 10, 0)
+		require.NoError(t, err)
+		assert.Empty(t, list)
+
+		// Без тенанта запрос не выполняется, фоновые задачи видят все организации
+		_, err = repo.Get_List(context.Background(), 10, 0)
+		assert.ErrorIs(t, err, ErrNoTenant)
+		count, err := repo.CountActive(tenant.WithAllTenants(context.Background()), month(2025, 6))
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), count)
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		repo := newRepo(t)
+		subscription := newTestSubscription(uuid.New(), "Netflix", 599, month(2025, 1), nil)
+		require.NoError(t, repo.Create(acme, subscription))
+
+		endDate := month(2025, 6)
+		require.NoError(t, repo.Update(acme, subscription.ID, map[string]interface{}{
+			"service_name": "Netflix Premium",
+			"price":        899,
+			"end_date":     endDate,
+		}))
+		found, err := repo.GetByID(acme, subscription.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "Netflix Premium", found.ServiceName)
+		assert.Equal(t, 899, found.Price)
+		require.NotNil(t, found.EndDate)
+		assert.True(t, found.EndDate.Equal(endDate))
+		assert.Equal(t, subscription.UserID, found.UserID)
+
+		assert.ErrorIs(t, repo.Update(acme, uuid.New(), map[string]interface{}{"price": 1}), gorm.ErrRecordNotFound)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		repo := newRepo(t)
+		subscription := newTestSubscription(uuid.New(), "Netflix", 599, month(2025, 1), nil)
+		require.NoError(t, repo.Create(acme, subscription))
+
+		require.NoError(t, repo.Delete(acme, subscription.ID))
+		_, err := repo.GetByID(acme, subscription.ID)
+		assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+		assert.ErrorIs(t, repo.Delete(acme, subscription.ID), gorm.ErrRecordNotFound)
+	})
+
+	t.Run("Pagination", func(t *testing.T) {
+		repo := newRepo(t)
+		userID, otherUserID := uuid.New(), uuid.New()
+		var userIDs []uuid.UUID
+		for i := 0; i < 5; i++ {
+			subscription := newTestSubscription(userID, "Service", 100+i, month(2025, i+1), nil)
+			require.NoError(t, repo.Create(acme, subscription))
+			userIDs = append(userIDs, subscription.ID)
+		}
+		require.NoError(t, repo.Create(acme, newTestSubscription(otherUserID, "Service", 100, month(2025, 1), nil)))
+
+		// Порядок строк без ORDER BY не гарантирован, проверяем размеры страниц и их объединение
+		var pages []uuid.UUID
+		for offset := 0; offset < 6; offset += 4 {
+			page, err := repo.Get_List(acme, 4, offset)
+			require.NoError(t, err)
+			pages = append(pages, subscriptionIDs(page)...)
+		}
+		assert.Len(t, pages, 6)
+
+		first, err := repo.Get_List_By_User(acme, userID, 2, 0)
+		require.NoError(t, err)
+		assert.Len(t, first, 2)
+		rest, err := repo.Get_List_By_User(acme, userID, 10, 2)
+		require.NoError(t, err)
+		assert.Len(t, rest, 3)
+		assert.ElementsMatch(t, userIDs, append(subscriptionIDs(first), subscriptionIDs(rest)...))
+
+		empty, err := repo.Get_List_By_User(acme, userID, 10, 10)
+		require.NoError(t, err)
+		assert.Empty(t, empty)
+	})
+
+	t.Run("GetByUserIDOrderedByStartDate", func(t *testing.T) {
+		repo := newRepo(t)
+		userID := uuid.New()
+		late := newTestSubscription(userID, "Spotify", 199, month(2025, 5), nil)
+		early := newTestSubscription(userID, "Netflix", 599, month(2025, 1), nil)
+		require.NoError(t, repo.Create(acme, late))
+		require.NoError(t, repo.Create(acme, early))
+		require.NoError(t, repo.Create(acme, newTestSubscription(uuid.New(), "Netflix", 599, month(2025, 1), nil)))
+
+		list, err := repo.GetByUserID(acme, userID)
+		require.NoError(t, err)
+		assert.Equal(t, []uuid.UUID{early.ID, late.ID}, subscriptionIDs(list))
+	})
+
+	t.Run("PeriodQueries", func(t *testing.T) {
+		repo := newRepo(t)
+		march, april := month(2025, 3), month(2025, 4)
+		expired := newTestSubscription(uuid.New(), "Expired", 100, month(2025, 1), &march)
+		active := newTestSubscription(uuid.New(), "Active", 200, month(2025, 2), nil)
+		future := newTestSubscription(uuid.New(), "Future", 300, month(2025, 5), nil)
+		trial := newTestSubscription(uuid.New(), "Trial", 400, month(2025, 1), &april)
+		trialEnd := month(2025, 3)
+		trial.TrialEndDate = &trialEnd
+		for _, subscription := range []*objects.Subscription{expired, active, future, trial} {
+			require.NoError(t, repo.Create(acme, subscription))
+		}
+
+		list, err := repo.GetExpiredBetween(acme, march, april)
+		require.NoError(t, err)
+		assert.Equal(t, []uuid.UUID{expired.ID}, subscriptionIDs(list))
+
+		// Закончившаяся ровно в начале интервала подписка в нем уже не действует
+		list, err = repo.GetActiveBetween(acme, march, april)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []uuid.UUID{active.ID, trial.ID}, subscriptionIDs(list))
+
+		list, err = repo.GetTrialsEndingBetween(acme, march, april)
+		require.NoError(t, err)
+		assert.Equal(t, []uuid.UUID{trial.ID}, subscriptionIDs(list))
+
+		count, err := repo.CountActive(acme, march)
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), count)
+	})
+
+	t.Run("GetTotalCost", func(t *testing.T) {
+		repo := newRepo(t)
+		userID := uuid.New()
+		march := month(2025, 3)
+		require.NoError(t, repo.Create(acme, newTestSubscription(userID, "Netflix", 500, month(2025, 1), &march)))
+		require.NoError(t, repo.Create(acme, newTestSubscription(userID, "Spotify", 200, month(2025, 2), nil)))
+		require.NoError(t, repo.Create(acme, newTestSubscription(uuid.New(), "Netflix", 1000, month(2025, 1), nil)))
+		require.NoError(t, repo.Create(globex, newTestSubscription(userID, "Netflix", 7000, month(2025, 1), nil)))
+
+		total, err := repo.GetTotalCost(acme, uuid.Nil, "", month(2025, 1), month(2025, 12))
+		require.NoError(t, err)
+		assert.Equal(t, 1700, total)
+
+		total, err = repo.GetTotalCost(acme, userID, "", month(2025, 1), month(2025, 12))
+		require.NoError(t, err)
+		assert.Equal(t, 700, total)
+
+		total, err = repo.GetTotalCost(acme, userID, "Netflix", month(2025, 1), month(2025, 12))
+		require.NoError(t, err)
+		assert.Equal(t, 500, total)
+
+		// Границы периода включаются: подписка, закончившаяся в марте, в март попадает
+		total, err = repo.GetTotalCost(acme, userID, "", march, month(2025, 12))
+		require.NoError(t, err)
+		assert.Equal(t, 700, total)
+
+		total, err = repo.GetTotalCost(acme, userID, "", month(2025, 4), month(2025, 12))
+		require.NoError(t, err)
+		assert.Equal(t, 200, total)
+
+		total, err = repo.GetTotalCost(acme, uuid.New(), "", month(2025, 1), month(2025, 12))
+		require.NoError(t, err)
+		assert.Equal(t, 0, total)
+	})
+
+	// Сумма по monthly_spend должна совпадать с запросом по таблице при любых изменениях подписок:
+	// даты не с первого числа, окончание раньше начала, смена цены, сервиса и дат, удаление
+	t.Run("TotalCostMatchesLiveQuery", func(t *testing.T) {
+		repo := newRepo(t)
+		random := rand.New(rand.NewSource(49))
+		users := []uuid.UUID{uuid.New(), uuid.New()}
+		services := []string{"Netflix", "Spotify"}
+		randomDate := func() time.Time {
+			date := month(2024, 1+random.Intn(24))
+			if random.Intn(2) == 0 {
+				date = date.Add(time.Duration(random.Intn(27*24)) * time.Hour)
+			}
+			return date
+		}
+		randomEnd := func(start time.Time) *time.Time {
+			if random.Intn(3) == 0 {
+				return nil
+			}
+			// Каждая десятая подписка заканчивается раньше начала
+			end := start.AddDate(0, random.Intn(15)-1, random.Intn(20))
+			return &end
+		}
+
+		var subscriptions []*objects.Subscription
+		for i := 0; i < 60; i++ {
+			start := randomDate()
+			subscription := newTestSubscription(users[i%2], services[random.Intn(2)], 1+random.Intn(1000), start, randomEnd(start))
+			require.NoError(t, repo.Create(acme, subscription))
+			subscriptions = append(subscriptions, subscription)
+		}
+		require.NoError(t, repo.Create(globex, newTestSubscription(users[0], "Netflix", 7000, month(2024, 1), nil)))
+
+		for i, subscription := range subscriptions[:20] {
+			fields := map[string]interface{}{"price": 1 + random.Intn(1000)}
+			switch i % 4 {
+			case 0:
+				fields["end_date"] = nil
+			case 1:
+				fields["end_date"] = *randomEnd(subscription.StartDate.AddDate(0, 1, 0))
+			case 2:
+				fields["service_name"] = services[random.Intn(2)]
+			case 3:
+				fields["start_date"] = randomDate()
+			}
+			require.NoError(t, repo.Update(acme, subscription.ID, fields))
+		}
+		for _, subscription := range subscriptions[50:] {
+			require.NoError(t, repo.Delete(acme, subscription.ID))
+		}
+		subscriptions = subscriptions[:50]
+		for i, subscription := range subscriptions {
+			found, err := repo.GetByID(acme, subscription.ID)
+			require.NoError(t, err)
+			subscriptions[i] = found
+		}
+
+		expected := func(userID uuid.UUID, serviceName string, start, end time.Time) int {
+			total := 0
+			for _, subscription := range subscriptions {
+				if (userID == uuid.Nil || subscription.UserID == userID) &&
+					(serviceName == "" || subscription.ServiceName == serviceName) &&
+					!subscription.StartDate.After(end) &&
+					(subscription.EndDate == nil || !subscription.EndDate.Before(start)) {
+					total += subscription.Price
+				}
+			}
+			return total
+		}
+		check := func(start, end time.Time) {
+			for _, userID := range []uuid.UUID{uuid.Nil, users[0]} {
+				for _, serviceName := range []string{"", services[1]} {
+					total, err := repo.GetTotalCost(acme, userID, serviceName, start, end)
+					require.NoError(t, err)
+					require.Equal(t, expected(userID, serviceName, start, end), total,
+						"user %s, service %q, %s — %s", userID, serviceName, start.Format("2006-01-02"), end.Format("2006-01-02"))
+				}
+			}
+		}
+		for from := 0; from < 30; from += 2 {
+			for to := from; to < 30; to += 3 {
+				check(month(2023, 12+from), month(2023, 12+to))
+			}
+		}
+		// Границы не с первого числа и начало позже конца считаются по таблице
+		check(month(2024, 3).AddDate(0, 0, 14), month(2025, 2).AddDate(0, 0, 9))
+		check(month(2025, 6), month(2024, 6))
+	})
+
+	t.Run("DatesWithOffset", func(t *testing.T) {
+		repo := newRepo(t)
+		// 1 января 02:00 по Москве — это еще 31 декабря по UTC
+		moscow := time.FixedZone("MSK", 3*60*60)
+		start := time.Date(2025, time.January, 1, 2, 0, 0, 0, moscow)
+		subscription := newTestSubscription(uuid.New(), "Netflix", 599, start, nil)
+		require.NoError(t, repo.Create(acme, subscription))
+
+		found, err := repo.GetByID(acme, subscription.ID)
+		require.NoError(t, err)
+		assert.True(t, found.StartDate.Equal(start))
+
+		total, err := repo.GetTotalCost(acme, uuid.Nil, "", time.Date(2024, time.December, 31, 0, 0, 0, 0, time.UTC), time.Date(2024, time.December, 31, 23, 30, 0, 0, time.UTC))
+		require.NoError(t, err)
+		assert.Equal(t, 599, total)
+
+		count, err := repo.CountActive(acme, time.Date(2024, time.December, 31, 22, 30, 0, 0, time.UTC))
+		require.NoError(t, err)
+		assert.Equal(t, int64(0), count)
+		count, err = repo.CountActive(acme, start.In(time.UTC))
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), count)
+	})
+}
+
+func month(year int, month int) time.Time {
+	return time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC)
+}
+
+func newTestSubscription(userID uuid.UUID, serviceName string, price int, start time.Time, end *time.Time) *objects.Subscription {
+	return &objects.Subscription{
+		ID:          uuid.New(),
+		ServiceName: serviceName,
+		Price:       price,
+		UserID:      userID,
+		StartDate:   start,
+		EndDate:     end,
+	}
+}
+
+func subscriptionIDs(subscriptions []*objects.Subscription) []uuid.UUID {
+	ids := make([]uuid.UUID, 0, len(subscriptions))
+	for _, subscription := range subscriptions {
+		ids = append(ids, subscription.ID)
+	}
+	return ids
+}
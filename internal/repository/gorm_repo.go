@@ -2,13 +2,14 @@ package repository
 
 import (
 	"context"
+	"effective_mobile/internal/events"
 	"effective_mobile/internal/objects"
 	"effective_mobile/pkg/logger_module"
-	"errors"
 	"time"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type GormRepo struct {
@@ -22,23 +23,73 @@ func NewGormRepo(db *gorm.DB, logger *logger_module.Logger) SubsctriptionReposit
 	return &GormRepo{db: db, logger: logger}
 }
 
-// Сохраняет подписку по id в БД
+// Даты пишем и сравниваем в UTC. SQLite хранит даты текстом вместе со смещением и сравнивает
+// как строки, поэтому один и тот же момент с разными смещениями сравнивался бы неверно
+func utcDate(date *time.Time) *time.Time {
+	if date == nil {
+		return nil
+	}
+	utc := date.UTC()
+	return &utc
+}
+
+func utcFields(fields map[string]interface{}) map[string]interface{} {
+	normalized := make(map[string]interface{}, len(fields))
+	for column, value := range fields {
+		switch date := value.(type) {
+		case time.Time:
+			value = date.UTC()
+		case *time.Time:
+			value = utcDate(date)
+		}
+		normalized[column] = value
+	}
+	return normalized
+}
+
+// Сохраняет подписку по id в БД вместе с событием subscription.created в outbox
 func (gr *GormRepo) Create(ctx context.Context, subscription *objects.Subscription) error {
 	gr.logger.Info("Starting ORM request create subscription in db")
-	new_subscription := gr.db.WithContext(ctx).Create(subscription) // Добавляет контекст к запросу .WithContext (позволяет отменить операцию)
-	if new_subscription.Error != nil {
-		gr.logger.Fatal("Database error", "error", new_subscription.Error)
+	subscription.StartDate = subscription.StartDate.UTC()
+	subscription.EndDate = utcDate(subscription.EndDate)
+	subscription.TrialEndDate = utcDate(subscription.TrialEndDate)
+	// Добавляет контекст к запросу .WithContext (позволяет отменить операцию)
+	err := gr.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(subscription).Error; err != nil {
+			return err
+		}
+		if err := applySpendDeltas(tx, spendChange(nil, subscription)); err != nil {
+			return err
+		}
+		return insertOutboxEvent(tx, events.New(events.SubscriptionCreated, subscription))
+	})
+	if err != nil {
+		gr.logger.Error("Database error", "error", err)
+		return err
 	}
 	return nil
 }
 
-// Удаляет подписку по id
+// Удаляет подписку по id вместе с событием subscription.deleted в outbox
 func (gr *GormRepo) Delete(ctx context.Context, id uuid.UUID) error {
 	gr.logger.Info("Starting ORM request delete subscription in db")
-	subscription_del := gr.db.WithContext(ctx).Delete(&objects.Subscription{}, "id = ?", id)
-	if subscription_del.Error != nil {
-		gr.logger.Fatal("Database error", "error", subscription_del.Error)
-
+	err := gr.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Читаем подписку до удаления, чтобы положить ее в событие
+		var subscription objects.Subscription
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&subscription, "id = ?", id).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&objects.Subscription{}, "id = ?", id).Error; err != nil {
+			return err
+		}
+		if err := applySpendDeltas(tx, spendChange(&subscription, nil)); err != nil {
+			return err
+		}
+		return insertOutboxEvent(tx, events.New(events.SubscriptionDeleted, &subscription))
+	})
+	if err != nil {
+		gr.logger.Error("Database error", "error", err, "id", id)
+		return err
 	}
 	return nil
 }
@@ -53,23 +104,105 @@ func (gr *GormRepo) Get_List(ctx context.Context, limit, offset int) ([]*objects
 		Offset(offset).
 		Find(&subscriptions)
 	if subscription_list.Error != nil {
-		gr.logger.Fatal("Failed to get subscriptions", "error", subscription_list.Error)
+		gr.logger.Error("Failed to get subscriptions", "error", subscription_list.Error)
+		return nil, subscription_list.Error
 	}
 	gr.logger.Info("Successfully request in db to get list subscriptions")
 	return subscriptions, nil
 }
 
+// Получаем список подписок пользователя с пагинацией
+// SELECT * FROM subscriptions WHERE user_id = '...' LIMIT {limit} OFFSET {offset};
+func (gr *GormRepo) Get_List_By_User(ctx context.Context, userID uuid.UUID, limit, offset int) ([]*objects.Subscription, error) {
+	gr.logger.Info("Starting ORM request get list user subscription in db")
+	var subscriptions []*objects.Subscription
+	subscription_list := gr.db.WithContext(ctx).
+		Where("user_id = ?", userID).
+		Limit(limit).
+		Offset(offset).
+		Find(&subscriptions)
+	if subscription_list.Error != nil {
+		gr.logger.Error("Failed to get user subscriptions", "error", subscription_list.Error, "user_id", userID)
+		return nil, subscription_list.Error
+	}
+	gr.logger.Info("Successfully request in db to get list user subscriptions")
+	return subscriptions, nil
+}
+
+// Получаем все подписки пользователя
+// SELECT * FROM subscriptions WHERE user_id = '...' ORDER BY start_date;
+func (gr *GormRepo) GetByUserID(ctx context.Context, userID uuid.UUID) ([]*objects.Subscription, error) {
+	gr.logger.Info("Starting ORM request get subscriptions by user id in db")
+	var subscriptions []*objects.Subscription
+	subscription_list := gr.db.WithContext(ctx).
+		Where("user_id = ?", userID).
+		Order("start_date").
+		Find(&subscriptions)
+	if subscription_list.Error != nil {
+		gr.logger.Error("Failed to get user subscriptions", "error", subscription_list.Error, "user_id", userID)
+		return nil, subscription_list.Error
+	}
+	gr.logger.Info("Successfully request in db to get subscriptions by user id")
+	return subscriptions, nil
+}
+
+// Подписки, закончившиеся в [from, to)
+// SELECT * FROM subscriptions WHERE end_date >= '...' AND end_date < '...';
+func (gr *GormRepo) GetExpiredBetween(ctx context.Context, from, to time.Time) ([]*objects.Subscription, error) {
+	return gr.findBetween(ctx, "end_date >= ? AND end_date < ?", from.UTC(), to.UTC())
+}
+
+// Подписки, действующие хотя бы часть времени в [from, to)
+// SELECT * FROM subscriptions WHERE start_date < '...' AND (end_date > '...' OR end_date IS NULL);
+func (gr *GormRepo) GetActiveBetween(ctx context.Context, from, to time.Time) ([]*objects.Subscription, error) {
+	return gr.findBetween(ctx, "start_date < ? AND (end_date > ? OR end_date IS NULL)", to.UTC(), from.UTC())
+}
+
+// Подписки, у которых пробный период заканчивается в [from, to)
+// SELECT * FROM subscriptions WHERE trial_end_date >= '...' AND trial_end_date < '...';
+func (gr *GormRepo) GetTrialsEndingBetween(ctx context.Context, from, to time.Time) ([]*objects.Subscription, error) {
+	return gr.findBetween(ctx, "trial_end_date >= ? AND trial_end_date < ?", from.UTC(), to.UTC())
+}
+
+// Число подписок, действующих в момент at (как в GetActiveBetween), для метрик
+// SELECT count(*) FROM subscriptions WHERE start_date <= '...' AND (end_date > '...' OR end_date IS NULL);
+func (gr *GormRepo) CountActive(ctx context.Context, at time.Time) (int64, error) {
+	var count int64
+	err := gr.db.WithContext(ctx).
+		Model(&objects.Subscription{}).
+		Where("start_date <= ? AND (end_date > ? OR end_date IS NULL)", at.UTC(), at.UTC()).
+		Count(&count).Error
+	if err != nil {
+		gr.logger.Error("Failed to count active subscriptions", "error", err)
+		return 0, err
+	}
+	return count, nil
+}
+
+func (gr *GormRepo) findBetween(ctx context.Context, condition string, args ...interface{}) ([]*objects.Subscription, error) {
+	gr.logger.Info("Starting ORM request get subscriptions by period in db")
+	var subscriptions []*objects.Subscription
+	subscription_list := gr.db.WithContext(ctx).
+		Where(condition, args...).
+		Order("start_date").
+		Find(&subscriptions)
+	if subscription_list.Error != nil {
+		gr.logger.Error("Failed to get subscriptions by period", "error", subscription_list.Error)
+		return nil, subscription_list.Error
+	}
+	return subscriptions, nil
+}
+
 // Получаем подписку по id
 // SELECT * FROM subscriptions WHERE id = '...' LIMIT 1;
 func (gr *GormRepo) GetByID(ctx context.Context, id uuid.UUID) (*objects.Subscription, error) {
 	gr.logger.Info("Starting ORM request get by id subscription in db")
 	var subscription objects.Subscription
 	subscription_by_id := gr.db.WithContext(ctx).First(&subscription, "id = ?", id)
-	if subscription_by_id.Error == gorm.ErrRecordNotFound {
-		gr.logger.Fatal("Failed subdcription not found", "error", gorm.ErrRecordNotFound, "id", id)
-	}
 	if subscription_by_id.Error != nil {
-		gr.logger.Fatal("Failed to get subscription", "error", subscription_by_id.Error)
+		// Сервис проверяет владельца перед изменением, поэтому отсутствие подписки — обычная ошибка, а не падение
+		gr.logger.Error("Failed to get subscription", "error", subscription_by_id.Error, "id", id)
+		return nil, subscription_by_id.Error
 	}
 
 	gr.logger.Info("Successfully request in db to get by id subscription")
@@ -77,22 +210,42 @@ func (gr *GormRepo) GetByID(ctx context.Context, id uuid.UUID) (*objects.Subscri
 	return &subscription, nil
 }
 
-// Обновляем подписку по конкретным полям
+// Обновляем подписку по конкретным полям, переносим ее цену в monthly_spend и пишем subscription.updated в outbox
 // UPDATE subscriptions
 // SET field1 = value1, field2 = value2
 // WHERE id = 'ваш-uuid';
 func (gr *GormRepo) Update(ctx context.Context, id uuid.UUID, fields map[string]interface{}) error {
 	gr.logger.Info("Starting ORM request update subscription in db")
-	update_subscription := gr.db.WithContext(ctx).
-		Model(&objects.Subscription{}).
-		Where("id = ?", id).
-		Updates(fields)
+	err := gr.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Подписка до обновления нужна, чтобы убрать ее прежнюю цену из агрегата
+		var before objects.Subscription
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&before, "id = ?", id).Error; err != nil {
+			return err
+		}
+		update_subscription := tx.
+			Model(&objects.Subscription{}).
+			Where("id = ?", id).
+			Updates(utcFields(fields))
+		if update_subscription.Error != nil {
+			return update_subscription.Error
+		}
+		if update_subscription.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
 
-	if errors.Is(update_subscription.Error, gorm.ErrRecordNotFound) {
-		gr.logger.Fatal("Failed update subscription", "error", update_subscription.Error, "id", id)
-	}
-	if update_subscription.Error != nil {
-		gr.logger.Fatal("Failed to update subscription", "error", update_subscription.Error)
+		// В событие кладем подписку уже после обновления
+		var subscription objects.Subscription
+		if err := tx.First(&subscription, "id = ?", id).Error; err != nil {
+			return err
+		}
+		if err := applySpendDeltas(tx, spendChange(&before, &subscription)); err != nil {
+			return err
+		}
+		return insertOutboxEvent(tx, events.New(events.SubscriptionUpdated, &subscription))
+	})
+	if err != nil {
+		gr.logger.Error("Failed to update subscription", "error", err, "id", id)
+		return err
 	}
 
 	gr.logger.Info("Successfully request in db to update subscription")
@@ -104,14 +257,18 @@ func (gr *GormRepo) Update(ctx context.Context, id uuid.UUID, fields map[string]
 // 	return gr.db.WithContext(ctx).AutoMigrate(&objects.Subscription{})
 // }
 
-// Реализуем функцию для просморта суммы подписок за определенный период
+// Реализуем функцию для просморта суммы подписок за определенный период: в сумму попадают
+// подписки, пересекающиеся с [start, end] включительно. Запрос без функций конкретной базы
+// (date_trunc и т.п.), поэтому одинаково работает в Postgres и SQLite
 // SELECT COALESCE(SUM(price), 0)
 // FROM subscriptions
-// WHERE user_id = '...'
+// WHERE start_date <= '2023-12-01'
 //
+//	AND (end_date >= '2023-01-01' OR end_date IS NULL)
+//	AND user_id = '...'
 //	AND service_name = '...'
-//	AND start_date >= '2023-01-01'
-//	AND (end_date <= '2023-12-31' OR end_date IS NULL)
+//
+// Если границы — первые числа месяцев, сумма берется из monthly_spend (см. MonthlySpend)
 func (gr *GormRepo) GetTotalCost(
 	ctx context.Context,
 	userID uuid.UUID,
@@ -120,25 +277,67 @@ func (gr *GormRepo) GetTotalCost(
 ) (int, error) {
 	gr.logger.Info("Starting ORM request get total cost subscriptions in db")
 
+	start, end = start.UTC(), end.UTC()
 	var total int64
+	var err error
+	if monthlySpendCovers(start, end) {
+		total, err = gr.spendTotal(ctx, userID, serviceName, start, end)
+	} else {
+		total, err = gr.liveTotal(ctx, userID, serviceName, start, end)
+	}
+	if err != nil {
+		gr.logger.Error("Failed to calculate total cost", "error", err)
+		return 0, err
+	}
 
-	query := gr.db.WithContext(ctx).
-		Model(&objects.Subscription{}).
-		Select("COALESCE(SUM(price), 0)").
-		Where("(start_date <= ? AND (end_date >= ? OR end_date IS NULL))", end, start)
+	gr.logger.Info("Successfully request in db to get total cost subscriptions")
 
+	return int(total), nil
+}
+
+func costFilters(query *gorm.DB, userID uuid.UUID, serviceName string) *gorm.DB {
 	if userID != uuid.Nil {
 		query = query.Where("user_id = ?", userID)
 	}
 	if serviceName != "" {
 		query = query.Where("service_name = ?", serviceName)
 	}
+	return query
+}
 
-	if err := query.Scan(&total).Error; err != nil {
-		gr.logger.Fatal("Failed to calculate total cost", "error", err)
-	}
+func (gr *GormRepo) liveTotal(ctx context.Context, userID uuid.UUID, serviceName string, start, end time.Time) (int64, error) {
+	var total int64
+	query := gr.db.WithContext(ctx).
+		Model(&objects.Subscription{}).
+		Select("COALESCE(SUM(price), 0)").
+		Where("(start_date <= ? AND (end_date >= ? OR end_date IS NULL))", end, start)
+	err := costFilters(query, userID, serviceName).Scan(&total).Error
+	return total, err
+}
 
-	gr.logger.Info("Successfully request in db to get total cost subscriptions")
+// SELECT COALESCE(SUM(started), 0) - COALESCE(SUM(CASE WHEN month < '2023-01-01' THEN ended ELSE 0 END), 0)
+// FROM monthly_spend
+// WHERE month <= '2023-12-01' AND user_id = '...' AND service_name = '...';
+// плюс подписки с окончанием раньше начала, которых нет в агрегате:
+// SELECT COALESCE(SUM(price), 0)
+// FROM subscriptions
+// WHERE end_date < start_date AND start_date <= '2023-12-01' AND end_date >= '2023-01-01' AND ...;
+func (gr *GormRepo) spendTotal(ctx context.Context, userID uuid.UUID, serviceName string, start, end time.Time) (int64, error) {
+	var spend, irregular int64
+	query := gr.db.WithContext(ctx).
+		Model(&MonthlySpend{}).
+		Select("COALESCE(SUM(started), 0) - COALESCE(SUM(CASE WHEN month < ? THEN ended ELSE 0 END), 0)", start).
+		Where("month <= ?", end)
+	if err := costFilters(query, userID, serviceName).Scan(&spend).Error; err != nil {
+		return 0, err
+	}
 
-	return int(total), nil
+	query = gr.db.WithContext(ctx).
+		Model(&objects.Subscription{}).
+		Select("COALESCE(SUM(price), 0)").
+		Where("end_date < start_date AND start_date <= ? AND end_date >= ?", end, start)
+	if err := costFilters(query, userID, serviceName).Scan(&irregular).Error; err != nil {
+		return 0, err
+	}
+	return spend + irregular, nil
 }
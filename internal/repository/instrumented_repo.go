@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"context"
+	"effective_mobile/internal/metrics"
+	"effective_mobile/internal/objects"
+	"effective_mobile/internal/tracing"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Репозиторий подписок с метриками и трейсингом: длительность, ошибки и спан каждого метода
+type InstrumentedRepo struct {
+	next SubsctriptionRepository
+}
+
+func NewInstrumentedRepo(next SubsctriptionRepository) SubsctriptionRepository {
+	return &InstrumentedRepo{next: next}
+}
+
+const subscriptionsRepo = "subscriptions"
+
+// Начинаем спан метода; возвращаемая функция закрывает его и учитывает вызов в метриках
+func (ir *InstrumentedRepo) start(ctx context.Context, method string) (context.Context, func(error)) {
+	started := time.Now()
+	ctx, span := tracing.Start(ctx, "GormRepo."+method)
+	return ctx, func(err error) {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			tracing.RecordError(span, err)
+		}
+		span.End()
+		metrics.ObserveDB(subscriptionsRepo, method, started, err)
+	}
+}
+
+func (ir *InstrumentedRepo) Create(ctx context.Context, subscription *objects.Subscription) (err error) {
+	ctx, done := ir.start(ctx, "Create")
+	defer func() { done(err) }()
+	return ir.next.Create(ctx, subscription)
+}
+
+func (ir *InstrumentedRepo) GetByID(ctx context.Context, id uuid.UUID) (_ *objects.Subscription, err error) {
+	ctx, done := ir.start(ctx, "GetByID")
+	defer func() { done(err) }()
+	return ir.next.GetByID(ctx, id)
+}
+
+func (ir *InstrumentedRepo) Update(ctx context.Context, id uuid.UUID, fields map[string]interface{}) (err error) {
+	ctx, done := ir.start(ctx, "Update")
+	defer func() { done(err) }()
+	return ir.next.Update(ctx, id, fields)
+}
+
+func (ir *InstrumentedRepo) Delete(ctx context.Context, id uuid.UUID) (err error) {
+	ctx, done := ir.start(ctx, "Delete")
+	defer func() { done(err) }()
+	return ir.next.Delete(ctx, id)
+}
+
+func (ir *InstrumentedRepo) Get_List(ctx context.Context, limit, offset int) (_ []*objects.Subscription, err error) {
+	ctx, done := ir.start(ctx, "Get_List")
+	defer func() { done(err) }()
+	return ir.next.Get_List(ctx, limit, offset)
+}
+
+func (ir *InstrumentedRepo) Get_List_By_User(ctx context.Context, userID uuid.UUID, limit, offset int) (_ []*objects.Subscription, err error) {
+	ctx, done := ir.start(ctx, "Get_List_By_User")
+	defer func() { done(err) }()
+	return ir.next.Get_List_By_User(ctx, userID, limit, offset)
+}
+
+func (ir *InstrumentedRepo) GetByUserID(ctx context.Context, userID uuid.UUID) (_ []*objects.Subscription, err error) {
+	ctx, done := ir.start(ctx, "GetByUserID")
+	defer func() { done(err) }()
+	return ir.next.GetByUserID(ctx, userID)
+}
+
+func (ir *InstrumentedRepo) GetExpiredBetween(ctx context.Context, from, to time.Time) (_ []*objects.Subscription, err error) {
+	ctx, done := ir.start(ctx, "GetExpiredBetween")
+	defer func() { done(err) }()
+	return ir.next.GetExpiredBetween(ctx, from, to)
+}
+
+func (ir *InstrumentedRepo) GetActiveBetween(ctx context.Context, from, to time.Time) (_ []*objects.Subscription, err error) {
+	ctx, done := ir.start(ctx, "GetActiveBetween")
+	defer func() { done(err) }()
+	return ir.next.GetActiveBetween(ctx, from, to)
+}
+
+func (ir *InstrumentedRepo) GetTrialsEndingBetween(ctx context.Context, from, to time.Time) (_ []*objects.Subscription, err error) {
+	ctx, done := ir.start(ctx, "GetTrialsEndingBetween")
+	defer func() { done(err) }()
+	return ir.next.GetTrialsEndingBetween(ctx, from, to)
+}
+
+func (ir *InstrumentedRepo) CountActive(ctx context.Context, at time.Time) (_ int64, err error) {
+	ctx, done := ir.start(ctx, "CountActive")
+	defer func() { done(err) }()
+	return ir.next.CountActive(ctx, at)
+}
+
+func (ir *InstrumentedRepo) GetTotalCost(ctx context.Context, userID uuid.UUID, serviceName string, start, end time.Time) (_ int, err error) {
+	ctx, done := ir.start(ctx, "GetTotalCost")
+	defer func() { done(err) }()
+	return ir.next.GetTotalCost(ctx, userID, serviceName, start, end)
+}
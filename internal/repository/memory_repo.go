@@ -0,0 +1,281 @@
+package repository
+
+import (
+	"context"
+	"effective_mobile/internal/objects"
+	"effective_mobile/internal/tenant"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// Хранилище подписок в памяти для локального запуска без Postgres (STORAGE=memory) и тестов.
+// Повторяет поведение GormRepo: ограничение тенантом, фильтры, пагинацию, суммы и ошибки
+// gorm.ErrRecordNotFound. Событий в outbox не пишет
+type MemoryRepo struct {
+	mutex         sync.RWMutex
+	subscriptions map[uuid.UUID]*objects.Subscription
+	order         []uuid.UUID // порядок вставки, в нем возвращает строки Get_List
+	schema        *schema.Schema
+}
+
+func NewMemoryRepo() SubsctriptionRepository {
+	// Схема модели нужна Update, чтобы менять поля по именам колонок, как GORM
+	subscriptionSchema, err := schema.Parse(&objects.Subscription{}, &sync.Map{}, schema.NamingStrategy{})
+	if err != nil {
+		panic(fmt.Sprintf("parse subscription schema: %v", err))
+	}
+	return &MemoryRepo{
+		subscriptions: make(map[uuid.UUID]*objects.Subscription),
+		schema:        subscriptionSchema,
+	}
+}
+
+// Тенант запроса по тем же правилам, что колбэки RegisterTenantScope: all — без ограничения
+func tenantScope(ctx context.Context) (id string, all bool, err error) {
+	if tenant.IsAllTenants(ctx) {
+		return "", true, nil
+	}
+	id, ok := tenant.FromContext(ctx)
+	if !ok {
+		return "", false, ErrNoTenant
+	}
+	return id, false, nil
+}
+
+// Копия записи: вызывающий код не должен менять хранилище в обход методов
+func copySubscription(subscription *objects.Subscription) *objects.Subscription {
+	copied := *subscription
+	if subscription.EndDate != nil {
+		endDate := *subscription.EndDate
+		copied.EndDate = &endDate
+	}
+	if subscription.TrialEndDate != nil {
+		trialEndDate := *subscription.TrialEndDate
+		copied.TrialEndDate = &trialEndDate
+	}
+	return &copied
+}
+
+func (mr *MemoryRepo) Create(ctx context.Context, subscription *objects.Subscription) error {
+	id, all, err := tenantScope(ctx)
+	if err != nil {
+		return err
+	}
+	// Ограничение CHECK (price > 0) из миграции
+	if subscription.Price <= 0 {
+		return fmt.Errorf("subscription price must be positive, got %d", subscription.Price)
+	}
+	// Хук модели, который GORM вызывает перед INSERT: он выдает id новой подписке
+	if err := subscription.BeforeCreate(nil); err != nil {
+		return err
+	}
+	mr.mutex.Lock()
+	defer mr.mutex.Unlock()
+	if _, ok := mr.subscriptions[subscription.ID]; ok {
+		return gorm.ErrDuplicatedKey
+	}
+	if !all {
+		subscription.TenantID = id
+	}
+	mr.subscriptions[subscription.ID] = copySubscription(subscription)
+	mr.order = append(mr.order, subscription.ID)
+	return nil
+}
+
+// Запись из хранилища, если она видна тенанту запроса. Вызывается под блокировкой
+func (mr *MemoryRepo) lookup(ctx context.Context, id uuid.UUID) (*objects.Subscription, error) {
+	tenantID, all, err := tenantScope(ctx)
+	if err != nil {
+		return nil, err
+	}
+	subscription, ok := mr.subscriptions[id]
+	if !ok || (!all && subscription.TenantID != tenantID) {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return subscription, nil
+}
+
+func (mr *MemoryRepo) GetByID(ctx context.Context, id uuid.UUID) (*objects.Subscription, error) {
+	mr.mutex.RLock()
+	defer mr.mutex.RUnlock()
+	subscription, err := mr.lookup(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return copySubscription(subscription), nil
+}
+
+// Поля задаются именами колонок, как в GormRepo.Update; неизвестная колонка — ошибка, как в Postgres.
+// Изменения применяются к копии, поэтому при ошибке запись не меняется
+func (mr *MemoryRepo) Update(ctx context.Context, id uuid.UUID, fields map[string]interface{}) error {
+	mr.mutex.Lock()
+	defer mr.mutex.Unlock()
+	subscription, err := mr.lookup(ctx, id)
+	if err != nil {
+		return err
+	}
+	updated := copySubscription(subscription)
+	value := reflect.ValueOf(updated)
+	for column, fieldValue := range fields {
+		field := mr.schema.LookUpField(column)
+		if field == nil || field.DBName == "" {
+			return fmt.Errorf("column %q of relation \"subscriptions\" does not exist", column)
+		}
+		if err := field.Set(ctx, value, fieldValue); err != nil {
+			return err
+		}
+	}
+	if updated.Price <= 0 {
+		return fmt.Errorf("subscription price must be positive, got %d", updated.Price)
+	}
+	// Первичный ключ и тенант не меняются: иначе запись потеряется в индексе
+	updated.ID, updated.TenantID = subscription.ID, subscription.TenantID
+	mr.subscriptions[id] = updated
+	return nil
+}
+
+func (mr *MemoryRepo) Delete(ctx context.Context, id uuid.UUID) error {
+	mr.mutex.Lock()
+	defer mr.mutex.Unlock()
+	if _, err := mr.lookup(ctx, id); err != nil {
+		return err
+	}
+	delete(mr.subscriptions, id)
+	for i, ordered := range mr.order {
+		if ordered == id {
+			mr.order = append(mr.order[:i], mr.order[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+// Записи тенанта в порядке вставки, прошедшие фильтр
+func (mr *MemoryRepo) filter(ctx context.Context, match func(*objects.Subscription) bool) ([]*objects.Subscription, error) {
+	tenantID, all, err := tenantScope(ctx)
+	if err != nil {
+		return nil, err
+	}
+	mr.mutex.RLock()
+	defer mr.mutex.RUnlock()
+	var subscriptions []*objects.Subscription
+	for _, id := range mr.order {
+		subscription := mr.subscriptions[id]
+		if !all && subscription.TenantID != tenantID {
+			continue
+		}
+		if match(subscription) {
+			subscriptions = append(subscriptions, copySubscription(subscription))
+		}
+	}
+	return subscriptions, nil
+}
+
+// LIMIT и OFFSET как в GORM: отрицательные значения не ограничивают выборку
+func paginate(subscriptions []*objects.Subscription, limit, offset int) []*objects.Subscription {
+	if offset > 0 {
+		if offset >= len(subscriptions) {
+			return nil
+		}
+		subscriptions = subscriptions[offset:]
+	}
+	if limit >= 0 && limit < len(subscriptions) {
+		subscriptions = subscriptions[:limit]
+	}
+	return subscriptions
+}
+
+func sortByStartDate(subscriptions []*objects.Subscription) []*objects.Subscription {
+	sort.SliceStable(subscriptions, func(i, j int) bool {
+		return subscriptions[i].StartDate.Before(subscriptions[j].StartDate)
+	})
+	return subscriptions
+}
+
+func anySubscription(*objects.Subscription) bool { return true }
+
+func (mr *MemoryRepo) Get_List(ctx context.Context, limit, offset int) ([]*objects.Subscription, error) {
+	subscriptions, err := mr.filter(ctx, anySubscription)
+	if err != nil {
+		return nil, err
+	}
+	return paginate(subscriptions, limit, offset), nil
+}
+
+func (mr *MemoryRepo) Get_List_By_User(ctx context.Context, userID uuid.UUID, limit, offset int) ([]*objects.Subscription, error) {
+	subscriptions, err := mr.filter(ctx, func(subscription *objects.Subscription) bool {
+		return subscription.UserID == userID
+	})
+	if err != nil {
+		return nil, err
+	}
+	return paginate(subscriptions, limit, offset), nil
+}
+
+func (mr *MemoryRepo) GetByUserID(ctx context.Context, userID uuid.UUID) ([]*objects.Subscription, error) {
+	subscriptions, err := mr.filter(ctx, func(subscription *objects.Subscription) bool {
+		return subscription.UserID == userID
+	})
+	return sortByStartDate(subscriptions), err
+}
+
+// Время t в полуинтервале [from, to); NULL не попадает ни в какой интервал
+func inRange(t *time.Time, from, to time.Time) bool {
+	return t != nil && !t.Before(from) && t.Before(to)
+}
+
+func (mr *MemoryRepo) GetExpiredBetween(ctx context.Context, from, to time.Time) ([]*objects.Subscription, error) {
+	subscriptions, err := mr.filter(ctx, func(subscription *objects.Subscription) bool {
+		return inRange(subscription.EndDate, from, to)
+	})
+	return sortByStartDate(subscriptions), err
+}
+
+func (mr *MemoryRepo) GetActiveBetween(ctx context.Context, from, to time.Time) ([]*objects.Subscription, error) {
+	subscriptions, err := mr.filter(ctx, func(subscription *objects.Subscription) bool {
+		return subscription.StartDate.Before(to) && (subscription.EndDate == nil || subscription.EndDate.After(from))
+	})
+	return sortByStartDate(subscriptions), err
+}
+
+func (mr *MemoryRepo) GetTrialsEndingBetween(ctx context.Context, from, to time.Time) ([]*objects.Subscription, error) {
+	subscriptions, err := mr.filter(ctx, func(subscription *objects.Subscription) bool {
+		return inRange(subscription.TrialEndDate, from, to)
+	})
+	return sortByStartDate(subscriptions), err
+}
+
+func (mr *MemoryRepo) CountActive(ctx context.Context, at time.Time) (int64, error) {
+	subscriptions, err := mr.filter(ctx, func(subscription *objects.Subscription) bool {
+		return !subscription.StartDate.After(at) && (subscription.EndDate == nil || subscription.EndDate.After(at))
+	})
+	return int64(len(subscriptions)), err
+}
+
+// Сумма цен подписок, пересекающихся с [start, end] включительно, как в GormRepo.GetTotalCost
+func (mr *MemoryRepo) GetTotalCost(ctx context.Context, userID uuid.UUID, serviceName string, start, end time.Time) (int, error) {
+	subscriptions, err := mr.filter(ctx, func(subscription *objects.Subscription) bool {
+		if userID != uuid.Nil && subscription.UserID != userID {
+			return false
+		}
+		if serviceName != "" && subscription.ServiceName != serviceName {
+			return false
+		}
+		return !subscription.StartDate.After(end) && (subscription.EndDate == nil || !subscription.EndDate.Before(start))
+	})
+	if err != nil {
+		return 0, err
+	}
+	total := 0
+	for _, subscription := range subscriptions {
+		total += subscription.Price
+	}
+	return total, nil
+}
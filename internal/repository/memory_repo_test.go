@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"context"
+	"effective_mobile/internal/tenant"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryRepo_Conformance(t *testing.T) {
+	runSubscriptionRepositorySuite(t, func(t *testing.T) SubsctriptionRepository {
+		return NewMemoryRepo()
+	})
+}
+
+// Возвращенная запись — копия: ее изменение не меняет хранилище
+func TestMemoryRepo_ReturnsCopies(t *testing.T) {
+	repo := NewMemoryRepo()
+	ctx := tenant.NewContext(context.Background(), "acme")
+	march := month(2025, 3)
+	subscription := newTestSubscription(uuid.New(), "Netflix", 599, month(2025, 1), &march)
+	require.NoError(t, repo.Create(ctx, subscription))
+	subscription.Price = 1
+
+	found, err := repo.GetByID(ctx, subscription.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 599, found.Price)
+	*found.EndDate = time.Time{}
+
+	found, err = repo.GetByID(ctx, subscription.ID)
+	require.NoError(t, err)
+	assert.True(t, found.EndDate.Equal(march))
+}
+
+func TestMemoryRepo_UnknownColumn(t *testing.T) {
+	repo := NewMemoryRepo()
+	ctx := tenant.NewContext(context.Background(), "acme")
+	subscription := newTestSubscription(uuid.New(), "Netflix", 599, month(2025, 1), nil)
+	require.NoError(t, repo.Create(ctx, subscription))
+
+	assert.Error(t, repo.Update(ctx, subscription.ID, map[string]interface{}{"price": 100, "discount": 10}))
+	found, err := repo.GetByID(ctx, subscription.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 599, found.Price)
+}
+
+// Запускается с -race: параллельные запись, чтение и изменение не должны гоняться
+func TestMemoryRepo_Concurrent(t *testing.T) {
+	repo := NewMemoryRepo()
+	ctx := tenant.NewContext(context.Background(), "acme")
+	userID := uuid.New()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			subscription := newTestSubscription(userID, fmt.Sprintf("Service %d", i), 100, month(2025, 1), nil)
+			assert.NoError(t, repo.Create(ctx, subscription))
+			assert.NoError(t, repo.Update(ctx, subscription.ID, map[string]interface{}{"price": 200}))
+			_, err := repo.Get_List_By_User(ctx, userID, 10, 0)
+			assert.NoError(t, err)
+			_, err = repo.GetTotalCost(ctx, userID, "", month(2025, 1), month(2025, 12))
+			assert.NoError(t, err)
+		}(i)
+	}
+	wg.Wait()
+
+	total, err := repo.GetTotalCost(ctx, userID, "", month(2025, 1), month(2025, 12))
+	require.NoError(t, err)
+	assert.Equal(t, 20*200, total)
+}
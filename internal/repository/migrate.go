@@ -0,0 +1,32 @@
+package repository
+
+import (
+	"effective_mobile/migrations"
+	"fmt"
+
+	"github.com/pressly/goose/v3"
+	"github.com/pressly/goose/v3/lock"
+	"gorm.io/gorm"
+)
+
+// Миграции из встроенных в бинарник файлов для диалекта подключения. Advisory-блокировка
+// Postgres не дает нескольким репликам применять миграции одновременно; SQLite открывает
+// один процесс, блокировка ему не нужна
+func NewMigrator(db *gorm.DB) (*goose.Provider, error) {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	switch db.Dialector.Name() {
+	case "postgres":
+		locker, err := lock.NewPostgresSessionLocker()
+		if err != nil {
+			return nil, err
+		}
+		return goose.NewProvider(goose.DialectPostgres, sqlDB, migrations.FS, goose.WithSessionLocker(locker))
+	case "sqlite":
+		return goose.NewProvider(goose.DialectSQLite3, sqlDB, migrations.SQLite())
+	default:
+		return nil, fmt.Errorf("no migrations for dialect %q", db.Dialector.Name())
+	}
+}
@@ -0,0 +1,182 @@
+package repository
+
+import (
+	"context"
+	"effective_mobile/internal/objects"
+	"effective_mobile/internal/tenant"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Агрегат для GetTotalCost: сумма за период считается по нему, а не по всей таблице подписок.
+// В строке хранятся изменения суммы, а не сами суммы: Started — цены подписок, которые действуют
+// с этого месяца, Ended — цены подписок, закончившихся в этом месяце. Сумма за период [S, E]
+// равна Started до E включительно минус Ended до S, расход одного месяца — то же при S = E.
+//
+// Подписка, начавшаяся не первого числа, попадает в Started следующего месяца, а закончившаяся
+// в любой день — в Ended своего месяца: так результат совпадает с запросом по таблице для любых
+// границ-месяцев. Подписки с окончанием раньше начала в агрегат не входят, их GetTotalCost
+// досчитывает по таблице (для них есть частичный индекс)
+type MonthlySpend struct {
+	TenantID    string    `gorm:"primaryKey"`
+	UserID      uuid.UUID `gorm:"type:uuid;primaryKey"`
+	ServiceName string    `gorm:"primaryKey"`
+	Month       time.Time `gorm:"primaryKey"`
+	Started     int64     `gorm:"not null"`
+	Ended       int64     `gorm:"not null"`
+}
+
+func (MonthlySpend) TableName() string {
+	return "monthly_spend"
+}
+
+// Первое число месяца момента t по UTC
+func monthStart(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// Первое число месяца не раньше t: start_date <= E для границы-месяца E равносильно monthCeil(start_date) <= E
+func monthCeil(t time.Time) time.Time {
+	start := monthStart(t)
+	if start.Equal(t) {
+		return start
+	}
+	return start.AddDate(0, 1, 0)
+}
+
+// Агрегат дает точную сумму, если границы периода — первые числа месяцев и начало не позже конца.
+// Ручка /api/subscriptions/total принимает месяцы (MM-YYYY), поэтому это обычный случай
+func monthlySpendCovers(start, end time.Time) bool {
+	return monthStart(start).Equal(start) && monthStart(end).Equal(end) && !start.After(end)
+}
+
+// Подписка с окончанием раньше начала: в агрегат не входит
+func endsBeforeStart(subscription *objects.Subscription) bool {
+	return subscription.EndDate != nil && subscription.EndDate.Before(subscription.StartDate)
+}
+
+type spendKey struct {
+	tenantID    string
+	userID      uuid.UUID
+	serviceName string
+	month       int64
+}
+
+// Изменения агрегата, накопленные по одной или нескольким подпискам
+type spendDeltas map[spendKey]*MonthlySpend
+
+// Изменения агрегата при замене подписки before на after; nil — подписки нет (создание или удаление)
+func spendChange(before, after *objects.Subscription) spendDeltas {
+	deltas := make(spendDeltas)
+	deltas.add(before, -1)
+	deltas.add(after, 1)
+	return deltas
+}
+
+// sign = 1 — подписка появилась, -1 — исчезла
+func (deltas spendDeltas) add(subscription *objects.Subscription, sign int64) {
+	if subscription == nil || endsBeforeStart(subscription) {
+		return
+	}
+	price := sign * int64(subscription.Price)
+	deltas.row(subscription, monthCeil(subscription.StartDate)).Started += price
+	if subscription.EndDate != nil {
+		deltas.row(subscription, monthStart(*subscription.EndDate)).Ended += price
+	}
+}
+
+func (deltas spendDeltas) row(subscription *objects.Subscription, month time.Time) *MonthlySpend {
+	key := spendKey{subscription.TenantID, subscription.UserID, subscription.ServiceName, month.Unix()}
+	row, ok := deltas[key]
+	if !ok {
+		row = &MonthlySpend{TenantID: subscription.TenantID, UserID: subscription.UserID, ServiceName: subscription.ServiceName, Month: month}
+		deltas[key] = row
+	}
+	return row
+}
+
+// Ненулевые изменения в порядке первичного ключа: параллельные транзакции блокируют
+// строки агрегата в одном порядке и не попадают во взаимную блокировку
+func (deltas spendDeltas) rows() []*MonthlySpend {
+	rows := make([]*MonthlySpend, 0, len(deltas))
+	for _, row := range deltas {
+		if row.Started != 0 || row.Ended != 0 {
+			rows = append(rows, row)
+		}
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		a, b := rows[i], rows[j]
+		if a.TenantID != b.TenantID {
+			return a.TenantID < b.TenantID
+		}
+		if a.UserID != b.UserID {
+			return a.UserID.String() < b.UserID.String()
+		}
+		if a.ServiceName != b.ServiceName {
+			return a.ServiceName < b.ServiceName
+		}
+		return a.Month.Before(b.Month)
+	})
+	return rows
+}
+
+// Прибавляем изменения к агрегату в транзакции изменения подписки
+// INSERT INTO monthly_spend (...) VALUES (...)
+// ON CONFLICT (tenant_id, user_id, service_name, month)
+// DO UPDATE SET started = monthly_spend.started + excluded.started, ended = monthly_spend.ended + excluded.ended;
+func applySpendDeltas(tx *gorm.DB, deltas spendDeltas) error {
+	rows := deltas.rows()
+	if len(rows) == 0 {
+		return nil
+	}
+	return tx.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "tenant_id"}, {Name: "user_id"}, {Name: "service_name"}, {Name: "month"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"started": gorm.Expr("monthly_spend.started + excluded.started"),
+			"ended":   gorm.Expr("monthly_spend.ended + excluded.ended"),
+		}),
+	}).Create(&rows).Error
+}
+
+// Пересчитываем агрегат заново по подпискам всех организаций, например после ручной правки
+// таблицы подписок. Заодно удаляются строки, в которых изменения сократились до нуля.
+// В Postgres изменения подписок ждут окончания пересчета, чтение не блокируется.
+// Возвращает число строк агрегата
+func RebuildMonthlySpend(ctx context.Context, db *gorm.DB) (int, error) {
+	var count int
+	err := db.WithContext(tenant.WithAllTenants(ctx)).Transaction(func(tx *gorm.DB) error {
+		if tx.Dialector.Name() == "postgres" {
+			if err := tx.Exec("LOCK TABLE subscriptions IN SHARE MODE").Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Exec("DELETE FROM monthly_spend").Error; err != nil {
+			return err
+		}
+
+		deltas := make(spendDeltas)
+		var batch []*objects.Subscription
+		err := tx.Model(&objects.Subscription{}).FindInBatches(&batch, 1000, func(*gorm.DB, int) error {
+			for _, subscription := range batch {
+				deltas.add(subscription, 1)
+			}
+			return nil
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		rows := deltas.rows()
+		count = len(rows)
+		if count == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(rows, 1000).Error
+	})
+	return count, err
+}
@@ -0,0 +1,165 @@
+package repository
+
+import (
+	"context"
+	"effective_mobile/internal/objects"
+	"effective_mobile/internal/tenant"
+	"effective_mobile/pkg/logger_module"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pressly/goose/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestMonthCeil(t *testing.T) {
+	assert.Equal(t, month(2025, 3), monthCeil(month(2025, 3)))
+	assert.Equal(t, month(2025, 4), monthCeil(month(2025, 3).Add(time.Second)))
+	assert.Equal(t, month(2026, 1), monthCeil(time.Date(2025, time.December, 31, 23, 0, 0, 0, time.UTC)))
+	// 1 марта 02:00 по Москве — это 28 февраля по UTC
+	moscow := time.FixedZone("MSK", 3*60*60)
+	assert.Equal(t, month(2025, 3), monthCeil(time.Date(2025, time.March, 1, 2, 0, 0, 0, moscow)))
+}
+
+func TestMonthlySpendCovers(t *testing.T) {
+	assert.True(t, monthlySpendCovers(month(2025, 1), month(2025, 12)))
+	assert.True(t, monthlySpendCovers(month(2025, 1), month(2025, 1)))
+	assert.False(t, monthlySpendCovers(month(2025, 12), month(2025, 1)))
+	assert.False(t, monthlySpendCovers(month(2025, 1).AddDate(0, 0, 1), month(2025, 12)))
+	assert.False(t, monthlySpendCovers(month(2025, 1), month(2025, 12).Add(time.Hour)))
+}
+
+func TestSpendChange(t *testing.T) {
+	userID := uuid.New()
+	march := month(2025, 3).AddDate(0, 0, 14)
+	before := newTestSubscription(userID, "Netflix", 500, month(2025, 1).AddDate(0, 0, 9), &march)
+	before.TenantID = "acme"
+
+	rows := spendChange(nil, before).rows()
+	require.Len(t, rows, 2)
+	assert.Equal(t, MonthlySpend{TenantID: "acme", UserID: userID, ServiceName: "Netflix", Month: month(2025, 2), Started: 500}, *rows[0])
+	assert.Equal(t, MonthlySpend{TenantID: "acme", UserID: userID, ServiceName: "Netflix", Month: month(2025, 3), Ended: 500}, *rows[1])
+
+	// Изменилась только цена: строки те же, в них разница цен
+	after := *before
+	after.Price = 800
+	rows = spendChange(before, &after).rows()
+	require.Len(t, rows, 2)
+	assert.Equal(t, int64(300), rows[0].Started)
+	assert.Equal(t, int64(300), rows[1].Ended)
+
+	// Ничего не изменилось — агрегат не трогаем
+	assert.Empty(t, spendChange(before, before).rows())
+
+	// Окончание раньше начала — подписки в агрегате нет
+	january := month(2025, 1)
+	after = *before
+	after.StartDate, after.EndDate = month(2025, 2), &january
+	rows = spendChange(before, &after).rows()
+	require.Len(t, rows, 2)
+	assert.Equal(t, int64(-500), rows[0].Started)
+	assert.Equal(t, int64(-500), rows[1].Ended)
+}
+
+// Пересчет дает те же строки, что ведутся при изменениях подписок
+func TestRebuildMonthlySpend(t *testing.T) {
+	db, migrator := openTestSQLite(t)
+	_, err := migrator.Up(context.Background())
+	require.NoError(t, err)
+	repo := NewGormRepo(db, logger_module.Get())
+	acme := tenant.NewContext(context.Background(), "acme")
+
+	subscriptions := seedMonthlySpendSubscriptions()
+	for _, subscription := range subscriptions {
+		require.NoError(t, repo.Create(acme, subscription))
+	}
+	require.NoError(t, repo.Update(acme, subscriptions[0].ID, map[string]interface{}{"price": 1}))
+	require.NoError(t, repo.Update(acme, subscriptions[1].ID, map[string]interface{}{"end_date": nil}))
+	require.NoError(t, repo.Delete(acme, subscriptions[2].ID))
+	maintained := monthlySpendRows(t, db)
+	require.NotEmpty(t, maintained)
+
+	// Ручная правка мимо репозитория расходится с агрегатом до пересчета
+	require.NoError(t, db.WithContext(acme).Model(&objects.Subscription{}).Where("id = ?", subscriptions[3].ID).Update("price", 12345).Error)
+	count, err := RebuildMonthlySpend(context.Background(), db)
+	require.NoError(t, err)
+	rebuilt := monthlySpendRows(t, db)
+	assert.Len(t, rebuilt, count)
+	assert.NotEqual(t, maintained, rebuilt)
+
+	require.NoError(t, repo.Update(acme, subscriptions[3].ID, map[string]interface{}{"price": subscriptions[3].Price}))
+	count, err = RebuildMonthlySpend(context.Background(), db)
+	require.NoError(t, err)
+	assert.Equal(t, maintained, monthlySpendRows(t, db))
+	assert.Len(t, maintained, count)
+}
+
+// Миграция заполняет агрегат по уже существующим подпискам так же, как RebuildMonthlySpend
+func TestMonthlySpendMigrationBackfill_SQLite(t *testing.T) {
+	db, migrator := openTestSQLite(t)
+	testMonthlySpendBackfill(t, db, migrator, 1)
+}
+
+func TestMonthlySpendMigrationBackfill_Postgres(t *testing.T) {
+	_, db := openTestPostgres(t)
+	migrator, err := NewMigrator(db)
+	require.NoError(t, err)
+	_, err = migrator.DownTo(context.Background(), 9)
+	require.NoError(t, err)
+	truncateSubscriptions(t, db)
+	testMonthlySpendBackfill(t, db, migrator, 9)
+}
+
+func testMonthlySpendBackfill(t *testing.T, db *gorm.DB, migrator *goose.Provider, before int64) {
+	ctx := context.Background()
+	_, err := migrator.UpTo(ctx, before)
+	require.NoError(t, err)
+	acme := tenant.NewContext(ctx, "acme")
+	for _, subscription := range seedMonthlySpendSubscriptions() {
+		require.NoError(t, db.WithContext(acme).Create(subscription).Error)
+	}
+
+	_, err = migrator.Up(ctx)
+	require.NoError(t, err)
+	backfilled := monthlySpendRows(t, db)
+	require.NotEmpty(t, backfilled)
+
+	_, err = RebuildMonthlySpend(ctx, db)
+	require.NoError(t, err)
+	assert.Equal(t, backfilled, monthlySpendRows(t, db))
+}
+
+// Подписки с началом и окончанием в разные дни месяца, без окончания и с окончанием раньше начала
+func seedMonthlySpendSubscriptions() []*objects.Subscription {
+	users := []uuid.UUID{uuid.New(), uuid.New()}
+	var subscriptions []*objects.Subscription
+	for i := 0; i < 24; i++ {
+		start := month(2024, 1+i%12).Add(time.Duration(i%3*36) * time.Hour)
+		var end *time.Time
+		if i%4 != 0 {
+			endDate := start.AddDate(0, i%7-1, i%5*4)
+			end = &endDate
+		}
+		subscriptions = append(subscriptions, newTestSubscription(users[i%2], fmt.Sprintf("Service %d", i%3), 100+i*10, start, end))
+	}
+	return subscriptions
+}
+
+// Ненулевые строки агрегата всех организаций в виде, не зависящем от формата дат базы
+func monthlySpendRows(t *testing.T, db *gorm.DB) map[string][2]int64 {
+	var rows []MonthlySpend
+	require.NoError(t, db.WithContext(tenant.WithAllTenants(context.Background())).Find(&rows).Error)
+	result := make(map[string][2]int64, len(rows))
+	for _, row := range rows {
+		if row.Started == 0 && row.Ended == 0 {
+			continue
+		}
+		key := fmt.Sprintf("%s/%s/%s/%s", row.TenantID, row.UserID, row.ServiceName, row.Month.UTC().Format("2006-01"))
+		result[key] = [2]int64{row.Started, row.Ended}
+	}
+	return result
+}
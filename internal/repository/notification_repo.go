@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"context"
+	"effective_mobile/internal/objects"
+	"effective_mobile/pkg/logger_module"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Запись журнала отправленных писем
+type NotificationLog struct {
+	ID       uuid.UUID `gorm:"type:uuid;primaryKey"`
+	UserID   uuid.UUID `gorm:"type:uuid;not null"`
+	Kind     string    `gorm:"not null"`
+	DedupKey string    `gorm:"not null"`
+	SentAt   time.Time `gorm:"not null"`
+}
+
+func (NotificationLog) TableName() string {
+	return "notification_log"
+}
+
+type NotificationRepo struct {
+	db     *gorm.DB
+	logger *logger_module.Logger
+}
+
+func NewNotificationRepo(db *gorm.DB, logger *logger_module.Logger) *NotificationRepo {
+	return &NotificationRepo{db: db, logger: logger}
+}
+
+// Настройки пользователя, если нет то gorm.ErrRecordNotFound
+func (nr *NotificationRepo) GetPreferences(ctx context.Context, userID uuid.UUID) (*objects.NotificationPreferences, error) {
+	nr.logger.Info("Starting ORM request get notification preferences in db")
+	var prefs objects.NotificationPreferences
+	if err := nr.db.WithContext(ctx).First(&prefs, "user_id = ?", userID).Error; err != nil {
+		nr.logger.Error("Failed to get notification preferences", "error", err, "user_id", userID)
+		return nil, err
+	}
+	return &prefs, nil
+}
+
+// Создаем или перезаписываем настройки пользователя
+// INSERT INTO notification_preferences ... ON CONFLICT (tenant_id, user_id) DO UPDATE SET ...;
+func (nr *NotificationRepo) SavePreferences(ctx context.Context, prefs *objects.NotificationPreferences) error {
+	nr.logger.Info("Starting ORM request save notification preferences in db")
+	err := nr.db.WithContext(ctx).
+		Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "tenant_id"}, {Name: "user_id"}}, UpdateAll: true}).
+		Create(prefs).Error
+	if err != nil {
+		nr.logger.Error("Failed to save notification preferences", "error", err, "user_id", prefs.UserID)
+		return err
+	}
+	return nil
+}
+
+// Пользователи, которые не отписались от писем kind (колонка настроек с тем же именем)
+// SELECT * FROM notification_preferences WHERE monthly_summary = true ORDER BY user_id;
+func (nr *NotificationRepo) ListRecipients(ctx context.Context, kind string) ([]objects.NotificationPreferences, error) {
+	var recipients []objects.NotificationPreferences
+	err := nr.db.WithContext(ctx).
+		Where(clause.Eq{Column: clause.Column{Name: kind}, Value: true}).
+		Order("user_id").
+		Find(&recipients).Error
+	if err != nil {
+		nr.logger.Error("Failed to get notification recipients", "error", err, "kind", kind)
+		return nil, err
+	}
+	return recipients, nil
+}
+
+// Отмечаем письмо как отправленное до отправки. false — письмо уже отправлено
+// этим или другим экземпляром приложения и отправлять его не нужно
+func (nr *NotificationRepo) MarkSent(ctx context.Context, userID uuid.UUID, kind, dedupKey string) (bool, error) {
+	row := &NotificationLog{
+		ID:       uuid.New(),
+		UserID:   userID,
+		Kind:     kind,
+		DedupKey: dedupKey,
+		SentAt:   time.Now().UTC(),
+	}
+	result := nr.db.WithContext(ctx).
+		Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "kind"}, {Name: "dedup_key"}}, DoNothing: true}).
+		Create(row)
+	if result.Error != nil {
+		nr.logger.Error("Failed to write notification log", "error", result.Error, "kind", kind, "dedup_key", dedupKey)
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// Снимаем отметку, если письмо отправить не удалось, чтобы следующий запуск повторил его
+func (nr *NotificationRepo) UnmarkSent(ctx context.Context, kind, dedupKey string) error {
+	err := nr.db.WithContext(ctx).
+		Where("kind = ? AND dedup_key = ?", kind, dedupKey).
+		Delete(&NotificationLog{}).Error
+	if err != nil {
+		nr.logger.Error("Failed to delete notification log", "error", err, "kind", kind, "dedup_key", dedupKey)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package repository
+
+import (
+	"context"
+	"effective_mobile/internal/events"
+	"effective_mobile/internal/stream"
+	"effective_mobile/pkg/logger_module"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Запись transactional outbox
+type OutboxEvent struct {
+	ID             int64     `gorm:"primaryKey;autoIncrement"`
+	EventID        uuid.UUID `gorm:"type:uuid;not null"`
+	EventType      string    `gorm:"not null"`
+	SubscriptionID uuid.UUID `gorm:"type:uuid;not null"`
+	UserID         uuid.UUID `gorm:"type:uuid;not null"`
+	TenantID       string    `gorm:"not null"`
+	Payload        string    `gorm:"type:jsonb;not null"`
+	CreatedAt      time.Time `gorm:"not null"`
+	ProcessedAt    *time.Time
+}
+
+// Пишем событие в outbox в рамках переданной транзакции.
+// Событие с уже записанным event_id пропускается, так повторная публикация не создаст дубль.
+// Вебхуки и поток событий работают только с Postgres, в SQLite outbox не ведется
+func insertOutboxEvent(tx *gorm.DB, event events.Event) error {
+	if tx.Dialector.Name() != "postgres" {
+		return nil
+	}
+	row, err := newOutboxEvent(event)
+	if err != nil {
+		return err
+	}
+	created := tx.Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "event_id"}}, DoNothing: true}).Create(row)
+	if created.Error != nil {
+		return created.Error
+	}
+	if created.RowsAffected == 0 {
+		return nil
+	}
+	return notifyOutboxEvent(tx, row)
+}
+
+// Запись outbox для события, id выдает база при вставке
+func newOutboxEvent(event events.Event) (*OutboxEvent, error) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+	return &OutboxEvent{
+		EventID:        event.ID,
+		EventType:      string(event.Type),
+		SubscriptionID: event.Subscription.ID,
+		UserID:         event.Subscription.UserID,
+		TenantID:       event.Subscription.TenantID,
+		Payload:        string(payload),
+		CreatedAt:      time.Now().UTC(),
+	}, nil
+}
+
+// NOTIFY внутри транзакции доставляется слушателям только после коммита,
+// поэтому поток событий никогда не увидит откатившееся изменение
+func notifyOutboxEvent(tx *gorm.DB, row *OutboxEvent) error {
+	notification, err := row.notification()
+	if err != nil {
+		return err
+	}
+	return tx.Exec("SELECT pg_notify(?, ?)", stream.Channel, notification).Error
+}
+
+// Текст NOTIFY о записи. Слишком большое уведомление уходит без данных, их дочитают из outbox
+func (row *OutboxEvent) notification() (string, error) {
+	msg := row.message()
+	notification, err := json.Marshal(msg)
+	if err != nil {
+		return "", err
+	}
+	if len(notification) > stream.MaxNotifyPayload {
+		msg.Data = nil
+		if notification, err = json.Marshal(msg); err != nil {
+			return "", err
+		}
+	}
+	return string(notification), nil
+}
+
+func (row *OutboxEvent) message() stream.Message {
+	return stream.Message{
+		ID:       row.ID,
+		Type:     row.EventType,
+		UserID:   row.UserID,
+		TenantID: row.TenantID,
+		Data:     json.RawMessage(row.Payload),
+	}
+}
+
+// Публикация событий, которые рождаются не из изменений подписки (например, из планировщика)
+type OutboxRepo struct {
+	db     *gorm.DB
+	logger *logger_module.Logger
+}
+
+func NewOutboxRepo(db *gorm.DB, logger *logger_module.Logger) *OutboxRepo {
+	return &OutboxRepo{db: db, logger: logger}
+}
+
+func (or *OutboxRepo) Publish(ctx context.Context, event events.Event) error {
+	or.logger.Debug("Write event to outbox", "event_id", event.ID, "type", event.Type)
+	err := or.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return insertOutboxEvent(tx, event)
+	})
+	if err != nil {
+		or.logger.Error("Failed to write outbox event", "error", err, "event_id", event.ID)
+		return err
+	}
+	return nil
+}
+
+// События outbox после afterID для дочитки потока, uuid.Nil = все пользователи
+// SELECT * FROM outbox_events WHERE id > ... [AND user_id = '...'] ORDER BY id LIMIT ...;
+func (or *OutboxRepo) ListSince(ctx context.Context, afterID int64, userID uuid.UUID, limit int) ([]stream.Message, error) {
+	var rows []OutboxEvent
+	query := or.db.WithContext(ctx).Where("id > ?", afterID)
+	if userID != uuid.Nil {
+		query = query.Where("user_id = ?", userID)
+	}
+	if err := query.Order("id").Limit(limit).Find(&rows).Error; err != nil {
+		or.logger.Error("Failed to read outbox events", "error", err, "after_id", afterID)
+		return nil, err
+	}
+
+	messages := make([]stream.Message, 0, len(rows))
+	for i := range rows {
+		messages = append(messages, rows[i].message())
+	}
+	return messages, nil
+}
+
+// Событие outbox по id для потока, если оно не поместилось в NOTIFY
+// SELECT * FROM outbox_events WHERE id = ... LIMIT 1;
+func (or *OutboxRepo) GetByID(ctx context.Context, id int64) (stream.Message, error) {
+	var row OutboxEvent
+	if err := or.db.WithContext(ctx).Where("id = ?", id).Take(&row).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return stream.Message{}, stream.ErrMessageNotFound
+		}
+		or.logger.Error("Failed to read outbox event", "error", err, "id", id)
+		return stream.Message{}, err
+	}
+	return row.message(), nil
+}
@@ -0,0 +1,581 @@
+package repository
+
+import (
+	"context"
+	"effective_mobile/internal/config"
+	"effective_mobile/internal/events"
+	"effective_mobile/internal/objects"
+	"effective_mobile/internal/stream"
+	"effective_mobile/pkg/logger_module"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"gorm.io/gorm"
+)
+
+// Репозиторий подписок на pgxpool без ORM (DB_REPOSITORY=pgx) для горячих путей: Get_List и
+// GetTotalCost. Запросы подготавливаются на каждом соединении пула, строки читаются без рефлексии.
+// Ведет себя как GormRepo: ограничение тенантом, события в outbox и изменения monthly_spend
+// в одной транзакции с изменением и gorm.ErrRecordNotFound, если подписки нет
+type PgxRepo struct {
+	pool   *pgxpool.Pool
+	logger *logger_module.Logger
+}
+
+func NewPgxRepo(pool *pgxpool.Pool, logger *logger_module.Logger) *PgxRepo {
+	return &PgxRepo{pool: pool, logger: logger}
+}
+
+// Пул для PgxRepo. Соединения у него свои, не из пула GORM, но ограничены теми же db.max_open_conns.
+// Запросы готовятся при открытии соединения, поэтому таблицы уже должны быть созданы миграциями.
+// Подготовленные запросы живут в соединении: между сервисом и базой не должно быть
+// PgBouncer в режиме transaction
+func NewPgxPool(logger *logger_module.Logger, config *config.Config_PG) (*pgxpool.Pool, error) {
+	poolConfig, err := pgxpool.ParseConfig(DSN(config))
+	if err != nil {
+		return nil, err
+	}
+	// Нулевые значения в конфиге значат "без ограничения", у pgxpool для них свои значения по умолчанию
+	if config.DB.MaxOpenConns > 0 {
+		poolConfig.MaxConns = int32(config.DB.MaxOpenConns)
+	}
+	if config.DB.ConnMaxLifetime > 0 {
+		poolConfig.MaxConnLifetime = config.DB.ConnMaxLifetime
+	}
+	if config.DB.ConnMaxIdleTime > 0 {
+		poolConfig.MaxConnIdleTime = config.DB.ConnMaxIdleTime
+	}
+	poolConfig.ConnConfig.Tracer = pgxTracer{}
+	poolConfig.AfterConnect = prepareSubscriptionQueries
+
+	pool, err := pgxpool.NewWithConfig(context.Background(), poolConfig)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), config.DB.ConnectTimeout)
+	defer cancel()
+	if err := waitForDB(ctx, pool.Ping, connectBackoffInitial, logger); err != nil {
+		pool.Close()
+		return nil, err
+	}
+	return pool, nil
+}
+
+// Колонки subscriptions в порядке полей objects.Subscription, в нем их читает scanSubscription
+var subscriptionColumnNames = []string{"id", "service_name", "price", "user_id", "start_date", "end_date", "trial_end_date", "tenant_id"}
+
+var subscriptionColumns = strings.Join(subscriptionColumnNames, ", ")
+
+// Место условия по тенанту в тексте запроса. Такой запрос готовится в двух вариантах:
+// с tenant_id последним параметром и без условия для фоновых задач (WithAllTenants)
+const (
+	tenantCondition  = "{tenant}"
+	allTenantsSuffix = ":all_tenants"
+)
+
+type pgxQuery struct {
+	name   string
+	sql    string
+	params int // параметров без тенанта
+}
+
+var subscriptionQueries = []pgxQuery{
+	{"subscriptions.insert", "INSERT INTO subscriptions (" + subscriptionColumns + ") VALUES ($1, $2, $3, $4, $5, $6, $7, $8)", 8},
+	{"subscriptions.get_by_id", "SELECT " + subscriptionColumns + " FROM subscriptions WHERE id = $1" + tenantCondition, 1},
+	{"subscriptions.lock", "SELECT " + subscriptionColumns + " FROM subscriptions WHERE id = $1" + tenantCondition + " FOR UPDATE", 1},
+	{"subscriptions.delete", "DELETE FROM subscriptions WHERE id = $1" + tenantCondition + " RETURNING " + subscriptionColumns, 1},
+	// LIMIT NULL — без ограничения, как Limit(-1) в GORM
+	{"subscriptions.list", "SELECT " + subscriptionColumns + " FROM subscriptions WHERE TRUE" + tenantCondition + " LIMIT $1 OFFSET $2", 2},
+	{"subscriptions.list_by_user", "SELECT " + subscriptionColumns + " FROM subscriptions WHERE user_id = $1" + tenantCondition + " LIMIT $2 OFFSET $3", 3},
+	{"subscriptions.by_user", "SELECT " + subscriptionColumns + " FROM subscriptions WHERE user_id = $1" + tenantCondition + " ORDER BY start_date", 1},
+	{"subscriptions.expired_between", "SELECT " + subscriptionColumns + " FROM subscriptions WHERE end_date >= $1 AND end_date < $2" + tenantCondition + " ORDER BY start_date", 2},
+	{"subscriptions.active_between", "SELECT " + subscriptionColumns + " FROM subscriptions WHERE start_date < $1 AND (end_date > $2 OR end_date IS NULL)" + tenantCondition + " ORDER BY start_date", 2},
+	{"subscriptions.trials_ending_between", "SELECT " + subscriptionColumns + " FROM subscriptions WHERE trial_end_date >= $1 AND trial_end_date < $2" + tenantCondition + " ORDER BY start_date", 2},
+	{"subscriptions.count_active", "SELECT count(*) FROM subscriptions WHERE start_date <= $1 AND (end_date > $1 OR end_date IS NULL)" + tenantCondition, 1},
+	totalCostQuery(false, false),
+	totalCostQuery(true, false),
+	totalCostQuery(false, true),
+	totalCostQuery(true, true),
+	spendTotalQuery(false, false),
+	spendTotalQuery(true, false),
+	spendTotalQuery(false, true),
+	spendTotalQuery(true, true),
+	{"monthly_spend.apply", "INSERT INTO monthly_spend (tenant_id, user_id, service_name, month, started, ended) VALUES ($1, $2, $3, $4, $5, $6) " +
+		"ON CONFLICT (tenant_id, user_id, service_name, month) " +
+		"DO UPDATE SET started = monthly_spend.started + excluded.started, ended = monthly_spend.ended + excluded.ended", 6},
+	{"outbox.insert", "INSERT INTO outbox_events (event_id, event_type, subscription_id, user_id, tenant_id, payload, created_at) " +
+		"VALUES ($1, $2, $3, $4, $5, $6, $7) ON CONFLICT (event_id) DO NOTHING RETURNING id", 7},
+	{"outbox.notify", "SELECT pg_notify($1, $2)", 2},
+}
+
+// Сумма для GetTotalCost. Каждое сочетание фильтров — отдельный запрос со своим планом:
+// условие вида "$3 IS NULL OR user_id = $3" в общем плане не использует индекс
+func totalCostQuery(byUser, byService bool) pgxQuery {
+	suffix, filters, params := costFilterSQL(byUser, byService)
+	return pgxQuery{
+		name:   "subscriptions.total_cost" + suffix,
+		sql:    "SELECT COALESCE(SUM(price), 0) FROM subscriptions WHERE start_date <= $1 AND (end_date >= $2 OR end_date IS NULL)" + filters + tenantCondition,
+		params: params,
+	}
+}
+
+// Та же сумма по monthly_spend для границ-месяцев (см. MonthlySpend) плюс подписки
+// с окончанием раньше начала, которых нет в агрегате. Параметры те же, что у totalCostQuery
+func spendTotalQuery(byUser, byService bool) pgxQuery {
+	suffix, filters, params := costFilterSQL(byUser, byService)
+	return pgxQuery{
+		name: "monthly_spend.total" + suffix,
+		sql: "SELECT ((SELECT COALESCE(SUM(started), 0) - COALESCE(SUM(ended) FILTER (WHERE month < $2), 0) FROM monthly_spend " +
+			"WHERE month <= $1" + filters + tenantCondition + ") + " +
+			"(SELECT COALESCE(SUM(price), 0) FROM subscriptions " +
+			"WHERE end_date < start_date AND start_date <= $1 AND end_date >= $2" + filters + tenantCondition + "))::bigint",
+		params: params,
+	}
+}
+
+// Суффикс имени, условия и число параметров для фильтров GetTotalCost после $1 = end и $2 = start
+func costFilterSQL(byUser, byService bool) (suffix, filters string, params int) {
+	params = 2
+	if byUser {
+		params++
+		suffix += "_by_user"
+		filters += fmt.Sprintf(" AND user_id = $%d", params)
+	}
+	if byService {
+		params++
+		suffix += "_by_service"
+		filters += fmt.Sprintf(" AND service_name = $%d", params)
+	}
+	return suffix, filters, params
+}
+
+// Текст запроса для подготовки: с условием по тенанту или без него. Условий может быть
+// несколько (по одному на подзапрос), тенант во всех — один и тот же параметр
+func (query pgxQuery) prepared(allTenants bool) (name, sql string) {
+	if allTenants {
+		return query.name + allTenantsSuffix, strings.ReplaceAll(query.sql, tenantCondition, "")
+	}
+	return query.name, strings.ReplaceAll(query.sql, tenantCondition, fmt.Sprintf(" AND tenant_id = $%d", query.params+1))
+}
+
+// Готовим запросы репозитория на новом соединении пула
+func prepareSubscriptionQueries(ctx context.Context, conn *pgx.Conn) error {
+	for _, query := range subscriptionQueries {
+		variants := []bool{false}
+		if strings.Contains(query.sql, tenantCondition) {
+			variants = append(variants, true)
+		}
+		for _, allTenants := range variants {
+			name, sql := query.prepared(allTenants)
+			if _, err := conn.Prepare(ctx, name, sql); err != nil {
+				return fmt.Errorf("prepare %s: %w", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Имя подготовленного запроса и аргументы с тенантом из контекста, по тем же правилам,
+// что колбэки RegisterTenantScope
+func scopedQuery(ctx context.Context, name string, args ...any) (string, []any, error) {
+	id, all, err := tenantScope(ctx)
+	if err != nil {
+		return "", nil, err
+	}
+	if all {
+		return name + allTenantsSuffix, args, nil
+	}
+	return name, append(args, id), nil
+}
+
+func scanSubscription(row pgx.Row) (*objects.Subscription, error) {
+	var subscription objects.Subscription
+	err := row.Scan(
+		&subscription.ID,
+		&subscription.ServiceName,
+		&subscription.Price,
+		&subscription.UserID,
+		&subscription.StartDate,
+		&subscription.EndDate,
+		&subscription.TrialEndDate,
+		&subscription.TenantID,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, gorm.ErrRecordNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &subscription, nil
+}
+
+// Значения колонок подписки в порядке subscriptionColumnNames
+func subscriptionValues(subscription *objects.Subscription) []any {
+	return []any{
+		subscription.ID,
+		subscription.ServiceName,
+		subscription.Price,
+		subscription.UserID,
+		subscription.StartDate,
+		subscription.EndDate,
+		subscription.TrialEndDate,
+		subscription.TenantID,
+	}
+}
+
+// Готовим подписку к вставке как GORM: id из хука модели, тенант из контекста, даты в UTC
+func prepareInsert(ctx context.Context, subscription *objects.Subscription) error {
+	id, all, err := tenantScope(ctx)
+	if err != nil {
+		return err
+	}
+	if err := subscription.BeforeCreate(nil); err != nil {
+		return err
+	}
+	if !all {
+		subscription.TenantID = id
+	}
+	subscription.StartDate = subscription.StartDate.UTC()
+	subscription.EndDate = utcDate(subscription.EndDate)
+	subscription.TrialEndDate = utcDate(subscription.TrialEndDate)
+	return nil
+}
+
+// Ставим в пакет запись события в outbox. Если событие с таким event_id уже есть, id записи остается нулевым
+func queueOutboxEvent(batch *pgx.Batch, event events.Event) (*OutboxEvent, error) {
+	row, err := newOutboxEvent(event)
+	if err != nil {
+		return nil, err
+	}
+	batch.Queue("outbox.insert", row.EventID, row.EventType, row.SubscriptionID, row.UserID, row.TenantID, row.Payload, row.CreatedAt).
+		QueryRow(func(result pgx.Row) error {
+			if err := result.Scan(&row.ID); err != nil && !errors.Is(err, pgx.ErrNoRows) {
+				return err
+			}
+			return nil
+		})
+	return row, nil
+}
+
+// Ставим в пакет изменения monthly_spend в порядке первичного ключа
+func queueSpendDeltas(batch *pgx.Batch, deltas spendDeltas) {
+	for _, row := range deltas.rows() {
+		batch.Queue("monthly_spend.apply", row.TenantID, row.UserID, row.ServiceName, row.Month, row.Started, row.Ended)
+	}
+}
+
+// NOTIFY о вставленных записях outbox одним пакетом, как notifyOutboxEvent для GORM
+func notifyOutboxEvents(ctx context.Context, tx pgx.Tx, rows ...*OutboxEvent) error {
+	batch := &pgx.Batch{}
+	for _, row := range rows {
+		if row.ID == 0 {
+			continue
+		}
+		notification, err := row.notification()
+		if err != nil {
+			return err
+		}
+		batch.Queue("outbox.notify", stream.Channel, notification)
+	}
+	if batch.Len() == 0 {
+		return nil
+	}
+	return tx.SendBatch(ctx, batch).Close()
+}
+
+// Подписка, изменения monthly_spend и событие subscription.created уходят в базу одним пакетом
+func (pr *PgxRepo) Create(ctx context.Context, subscription *objects.Subscription) error {
+	if err := prepareInsert(ctx, subscription); err != nil {
+		return err
+	}
+	err := pgx.BeginFunc(ctx, pr.pool, func(tx pgx.Tx) error {
+		batch := &pgx.Batch{}
+		batch.Queue("subscriptions.insert", subscriptionValues(subscription)...)
+		queueSpendDeltas(batch, spendChange(nil, subscription))
+		row, err := queueOutboxEvent(batch, events.New(events.SubscriptionCreated, subscription))
+		if err != nil {
+			return err
+		}
+		if err := tx.SendBatch(ctx, batch).Close(); err != nil {
+			return err
+		}
+		return notifyOutboxEvents(ctx, tx, row)
+	})
+	if err != nil {
+		pr.logger.Error("Database error", "error", err)
+		return err
+	}
+	return nil
+}
+
+// Массовая вставка через COPY вместе с событиями subscription.created в одной транзакции:
+// для импорта и заполнения базы. Каждая подписка готовится как в Create
+func (pr *PgxRepo) CreateMany(ctx context.Context, subscriptions []*objects.Subscription) error {
+	for _, subscription := range subscriptions {
+		if err := prepareInsert(ctx, subscription); err != nil {
+			return err
+		}
+	}
+	err := pgx.BeginFunc(ctx, pr.pool, func(tx pgx.Tx) error {
+		_, err := tx.CopyFrom(ctx, pgx.Identifier{"subscriptions"}, subscriptionColumnNames,
+			pgx.CopyFromSlice(len(subscriptions), func(i int) ([]any, error) {
+				return subscriptionValues(subscriptions[i]), nil
+			}))
+		if err != nil {
+			return err
+		}
+
+		// Изменения monthly_spend по всем подпискам складываются: одна строка агрегата — один запрос
+		batch := &pgx.Batch{}
+		deltas := make(spendDeltas)
+		for _, subscription := range subscriptions {
+			deltas.add(subscription, 1)
+		}
+		queueSpendDeltas(batch, deltas)
+		rows := make([]*OutboxEvent, 0, len(subscriptions))
+		for _, subscription := range subscriptions {
+			row, err := queueOutboxEvent(batch, events.New(events.SubscriptionCreated, subscription))
+			if err != nil {
+				return err
+			}
+			rows = append(rows, row)
+		}
+		if err := tx.SendBatch(ctx, batch).Close(); err != nil {
+			return err
+		}
+		return notifyOutboxEvents(ctx, tx, rows...)
+	})
+	if err != nil {
+		pr.logger.Error("Failed to copy subscriptions", "error", err, "count", len(subscriptions))
+		return err
+	}
+	return nil
+}
+
+// SELECT ... FROM subscriptions WHERE id = $1 AND tenant_id = $2;
+func (pr *PgxRepo) GetByID(ctx context.Context, id uuid.UUID) (*objects.Subscription, error) {
+	name, args, err := scopedQuery(ctx, "subscriptions.get_by_id", id)
+	if err != nil {
+		return nil, err
+	}
+	subscription, err := scanSubscription(pr.pool.QueryRow(ctx, name, args...))
+	if err != nil {
+		pr.logger.Error("Failed to get subscription", "error", err, "id", id)
+		return nil, err
+	}
+	return subscription, nil
+}
+
+// Колонки, которые можно менять через Update; id и тенант не меняются
+var updatableColumns = map[string]bool{
+	"service_name":   true,
+	"price":          true,
+	"user_id":        true,
+	"start_date":     true,
+	"end_date":       true,
+	"trial_end_date": true,
+}
+
+// UPDATE по переданным колонкам. Набор колонок меняется от запроса к запросу, такие запросы
+// pgx готовит и кэширует сам. Колонки сортируются, чтобы один набор давал один текст запроса
+func updateSubscriptionSQL(fields map[string]interface{}) (string, []any, error) {
+	if len(fields) == 0 {
+		return "", nil, errors.New("no fields to update")
+	}
+	columns := make([]string, 0, len(fields))
+	for column := range fields {
+		if !updatableColumns[column] {
+			return "", nil, fmt.Errorf("column %q of relation \"subscriptions\" cannot be updated", column)
+		}
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+
+	var sql strings.Builder
+	args := make([]any, 0, len(columns)+2)
+	sql.WriteString("UPDATE subscriptions SET ")
+	for i, column := range columns {
+		if i > 0 {
+			sql.WriteString(", ")
+		}
+		args = append(args, fields[column])
+		fmt.Fprintf(&sql, "%s = $%d", column, len(args))
+	}
+	return sql.String(), args, nil
+}
+
+// Подписка до изменения читается с блокировкой строки для monthly_spend,
+// UPDATE ... RETURNING отдает подписку после изменения для события subscription.updated
+func (pr *PgxRepo) Update(ctx context.Context, id uuid.UUID, fields map[string]interface{}) error {
+	tenantID, all, err := tenantScope(ctx)
+	if err != nil {
+		return err
+	}
+	sql, args, err := updateSubscriptionSQL(utcFields(fields))
+	if err != nil {
+		return err
+	}
+	args = append(args, id)
+	sql += fmt.Sprintf(" WHERE id = $%d", len(args))
+	if !all {
+		args = append(args, tenantID)
+		sql += fmt.Sprintf(" AND tenant_id = $%d", len(args))
+	}
+	sql += " RETURNING " + subscriptionColumns
+	lock, lockArgs, err := scopedQuery(ctx, "subscriptions.lock", id)
+	if err != nil {
+		return err
+	}
+
+	err = pgx.BeginFunc(ctx, pr.pool, func(tx pgx.Tx) error {
+		before, err := scanSubscription(tx.QueryRow(ctx, lock, lockArgs...))
+		if err != nil {
+			return err
+		}
+		subscription, err := scanSubscription(tx.QueryRow(ctx, sql, args...))
+		if err != nil {
+			return err
+		}
+		return pr.writeEvent(ctx, tx, events.New(events.SubscriptionUpdated, subscription), spendChange(before, subscription))
+	})
+	if err != nil {
+		pr.logger.Error("Failed to update subscription", "error", err, "id", id)
+		return err
+	}
+	return nil
+}
+
+// DELETE ... RETURNING отдает удаленную подписку для события subscription.deleted
+func (pr *PgxRepo) Delete(ctx context.Context, id uuid.UUID) error {
+	name, args, err := scopedQuery(ctx, "subscriptions.delete", id)
+	if err != nil {
+		return err
+	}
+	err = pgx.BeginFunc(ctx, pr.pool, func(tx pgx.Tx) error {
+		subscription, err := scanSubscription(tx.QueryRow(ctx, name, args...))
+		if err != nil {
+			return err
+		}
+		return pr.writeEvent(ctx, tx, events.New(events.SubscriptionDeleted, subscription), spendChange(subscription, nil))
+	})
+	if err != nil {
+		pr.logger.Error("Database error", "error", err, "id", id)
+		return err
+	}
+	return nil
+}
+
+// Изменения monthly_spend и событие в outbox одним пакетом
+func (pr *PgxRepo) writeEvent(ctx context.Context, tx pgx.Tx, event events.Event, deltas spendDeltas) error {
+	batch := &pgx.Batch{}
+	queueSpendDeltas(batch, deltas)
+	row, err := queueOutboxEvent(batch, event)
+	if err != nil {
+		return err
+	}
+	if err := tx.SendBatch(ctx, batch).Close(); err != nil {
+		return err
+	}
+	return notifyOutboxEvents(ctx, tx, row)
+}
+
+// Подписки по подготовленному запросу с ограничением тенантом
+func (pr *PgxRepo) list(ctx context.Context, query string, args ...any) ([]*objects.Subscription, error) {
+	name, args, err := scopedQuery(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := pr.pool.Query(ctx, name, args...)
+	if err != nil {
+		pr.logger.Error("Failed to get subscriptions", "error", err, "query", query)
+		return nil, err
+	}
+	subscriptions, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (*objects.Subscription, error) {
+		return scanSubscription(row)
+	})
+	if err != nil {
+		pr.logger.Error("Failed to read subscriptions", "error", err, "query", query)
+		return nil, err
+	}
+	return subscriptions, nil
+}
+
+// LIMIT и OFFSET как в GORM: отрицательные значения не ограничивают выборку
+func limitOffset(limit, offset int) (any, int) {
+	if limit < 0 {
+		return nil, max(offset, 0)
+	}
+	return limit, max(offset, 0)
+}
+
+func (pr *PgxRepo) Get_List(ctx context.Context, limit, offset int) ([]*objects.Subscription, error) {
+	limitValue, offsetValue := limitOffset(limit, offset)
+	return pr.list(ctx, "subscriptions.list", limitValue, offsetValue)
+}
+
+func (pr *PgxRepo) Get_List_By_User(ctx context.Context, userID uuid.UUID, limit, offset int) ([]*objects.Subscription, error) {
+	limitValue, offsetValue := limitOffset(limit, offset)
+	return pr.list(ctx, "subscriptions.list_by_user", userID, limitValue, offsetValue)
+}
+
+func (pr *PgxRepo) GetByUserID(ctx context.Context, userID uuid.UUID) ([]*objects.Subscription, error) {
+	return pr.list(ctx, "subscriptions.by_user", userID)
+}
+
+func (pr *PgxRepo) GetExpiredBetween(ctx context.Context, from, to time.Time) ([]*objects.Subscription, error) {
+	return pr.list(ctx, "subscriptions.expired_between", from.UTC(), to.UTC())
+}
+
+func (pr *PgxRepo) GetActiveBetween(ctx context.Context, from, to time.Time) ([]*objects.Subscription, error) {
+	return pr.list(ctx, "subscriptions.active_between", to.UTC(), from.UTC())
+}
+
+func (pr *PgxRepo) GetTrialsEndingBetween(ctx context.Context, from, to time.Time) ([]*objects.Subscription, error) {
+	return pr.list(ctx, "subscriptions.trials_ending_between", from.UTC(), to.UTC())
+}
+
+func (pr *PgxRepo) CountActive(ctx context.Context, at time.Time) (int64, error) {
+	name, args, err := scopedQuery(ctx, "subscriptions.count_active", at.UTC())
+	if err != nil {
+		return 0, err
+	}
+	var count int64
+	if err := pr.pool.QueryRow(ctx, name, args...).Scan(&count); err != nil {
+		pr.logger.Error("Failed to count active subscriptions", "error", err)
+		return 0, err
+	}
+	return count, nil
+}
+
+// Сумма цен подписок, пересекающихся с [start, end] включительно, как в GormRepo.GetTotalCost:
+// для границ-месяцев по monthly_spend, иначе по таблице подписок
+func (pr *PgxRepo) GetTotalCost(ctx context.Context, userID uuid.UUID, serviceName string, start, end time.Time) (int, error) {
+	start, end = start.UTC(), end.UTC()
+	query := totalCostQuery(userID != uuid.Nil, serviceName != "")
+	if monthlySpendCovers(start, end) {
+		query = spendTotalQuery(userID != uuid.Nil, serviceName != "")
+	}
+	args := []any{end, start}
+	if userID != uuid.Nil {
+		args = append(args, userID)
+	}
+	if serviceName != "" {
+		args = append(args, serviceName)
+	}
+	name, args, err := scopedQuery(ctx, query.name, args...)
+	if err != nil {
+		return 0, err
+	}
+	var total int64
+	if err := pr.pool.QueryRow(ctx, name, args...).Scan(&total); err != nil {
+		pr.logger.Error("Failed to calculate total cost", "error", err)
+		return 0, err
+	}
+	return int(total), nil
+}
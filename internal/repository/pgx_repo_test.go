@@ -0,0 +1,195 @@
+package repository
+
+import (
+	"context"
+	"effective_mobile/internal/config"
+	"effective_mobile/internal/objects"
+	"effective_mobile/internal/tenant"
+	"effective_mobile/pkg/logger_module"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdateSubscriptionSQL(t *testing.T) {
+	endDate := month(2025, 6)
+	sql, args, err := updateSubscriptionSQL(map[string]interface{}{"price": 899, "end_date": endDate, "service_name": "Netflix"})
+	require.NoError(t, err)
+	// Колонки по алфавиту: один набор полей — один текст запроса в кэше pgx
+	assert.Equal(t, "UPDATE subscriptions SET end_date = $1, price = $2, service_name = $3", sql)
+	assert.Equal(t, []any{endDate, 899, "Netflix"}, args)
+
+	_, _, err = updateSubscriptionSQL(map[string]interface{}{"tenant_id": "globex"})
+	assert.Error(t, err)
+	_, _, err = updateSubscriptionSQL(map[string]interface{}{"price; DROP TABLE subscriptions": 1})
+	assert.Error(t, err)
+	_, _, err = updateSubscriptionSQL(map[string]interface{}{})
+	assert.Error(t, err)
+}
+
+func TestPgxQuery_TenantIsLastParameter(t *testing.T) {
+	query := totalCostQuery(true, true)
+	name, sql := query.prepared(false)
+	assert.Equal(t, "subscriptions.total_cost_by_user_by_service", name)
+	assert.Equal(t, "SELECT COALESCE(SUM(price), 0) FROM subscriptions WHERE start_date <= $1 AND (end_date >= $2 OR end_date IS NULL)"+
+		" AND user_id = $3 AND service_name = $4 AND tenant_id = $5", sql)
+
+	name, sql = query.prepared(true)
+	assert.Equal(t, "subscriptions.total_cost_by_user_by_service"+allTenantsSuffix, name)
+	assert.NotContains(t, sql, "tenant_id")
+
+	// Тенант в обоих подзапросах суммы по monthly_spend — один параметр после фильтров
+	_, sql = spendTotalQuery(true, false).prepared(false)
+	assert.Equal(t, 2, strings.Count(sql, " AND user_id = $3 AND tenant_id = $4"))
+	_, sql = spendTotalQuery(true, false).prepared(true)
+	assert.NotContains(t, sql, "tenant_id")
+
+	// Имена запросов в пуле общие, совпадений быть не должно
+	names := make(map[string]bool)
+	for _, query := range subscriptionQueries {
+		assert.False(t, names[query.name], query.name)
+		names[query.name] = true
+	}
+
+	name, args, err := scopedQuery(tenant.NewContext(context.Background(), "acme"), "subscriptions.get_by_id", uuid.Nil)
+	require.NoError(t, err)
+	assert.Equal(t, "subscriptions.get_by_id", name)
+	assert.Equal(t, []any{uuid.Nil, "acme"}, args)
+	_, _, err = scopedQuery(context.Background(), "subscriptions.get_by_id", uuid.Nil)
+	assert.ErrorIs(t, err, ErrNoTenant)
+}
+
+// Тот же набор проверок, что у GormRepo, на базе из TEST_DATABASE_URL
+func TestPgxRepo_Conformance(t *testing.T) {
+	conf, db := openTestPostgres(t)
+	pool := openTestPgxPool(t, conf)
+
+	runSubscriptionRepositorySuite(t, func(t *testing.T) SubsctriptionRepository {
+		truncateSubscriptions(t, db)
+		return NewPgxRepo(pool, logger_module.Get())
+	})
+}
+
+func TestPgxRepo_CreateManyWritesOutbox(t *testing.T) {
+	conf, db := openTestPostgres(t)
+	pool := openTestPgxPool(t, conf)
+	truncateSubscriptions(t, db)
+	repo := NewPgxRepo(pool, logger_module.Get())
+	acme := tenant.NewContext(context.Background(), "acme")
+
+	userID := uuid.New()
+	subscriptions := []*objects.Subscription{
+		newTestSubscription(userID, "Netflix", 599, month(2025, 1), nil),
+		newTestSubscription(userID, "Spotify", 199, month(2025, 2), nil),
+		newTestSubscription(userID, "YouTube", 299, month(2025, 3), nil),
+	}
+	subscriptions[0].ID = uuid.Nil
+	require.NoError(t, repo.CreateMany(acme, subscriptions))
+	assert.NotEqual(t, uuid.Nil, subscriptions[0].ID)
+
+	list, err := repo.GetByUserID(acme, userID)
+	require.NoError(t, err)
+	assert.Equal(t, subscriptionIDs(subscriptions), subscriptionIDs(list))
+
+	var outbox int64
+	require.NoError(t, db.Model(&OutboxEvent{}).Where("user_id = ? AND tenant_id = ?", userID, "acme").Count(&outbox).Error)
+	assert.Equal(t, int64(3), outbox)
+}
+
+func openTestPgxPool(tb testing.TB, conf *config.Config_PG) *pgxpool.Pool {
+	pool, err := NewPgxPool(logger_module.Get(), conf)
+	require.NoError(tb, err)
+	tb.Cleanup(pool.Close)
+	return pool
+}
+
+// Сравнение с GormRepo на настоящей базе:
+// TEST_DATABASE_URL=postgres://... go test ./internal/repository -run '^$' -bench . -benchmem
+const benchmarkSubscriptions = 10000
+
+func BenchmarkRepo_Get_List(b *testing.B) {
+	benchmarkRepositories(b, func(ctx context.Context, repo SubsctriptionRepository, userID uuid.UUID) error {
+		_, err := repo.Get_List(ctx, 100, 0)
+		return err
+	})
+}
+
+func BenchmarkRepo_Get_List_By_User(b *testing.B) {
+	benchmarkRepositories(b, func(ctx context.Context, repo SubsctriptionRepository, userID uuid.UUID) error {
+		_, err := repo.Get_List_By_User(ctx, userID, 100, 0)
+		return err
+	})
+}
+
+func BenchmarkRepo_GetTotalCost(b *testing.B) {
+	benchmarkRepositories(b, func(ctx context.Context, repo SubsctriptionRepository, userID uuid.UUID) error {
+		_, err := repo.GetTotalCost(ctx, uuid.Nil, "", month(2022, 1), month(2022, 12))
+		return err
+	})
+}
+
+func BenchmarkRepo_GetTotalCost_ByUser(b *testing.B) {
+	benchmarkRepositories(b, func(ctx context.Context, repo SubsctriptionRepository, userID uuid.UUID) error {
+		_, err := repo.GetTotalCost(ctx, userID, "Service 0", month(2022, 1), month(2022, 12))
+		return err
+	})
+}
+
+func BenchmarkRepo_Create(b *testing.B) {
+	benchmarkRepositories(b, func(ctx context.Context, repo SubsctriptionRepository, userID uuid.UUID) error {
+		return repo.Create(ctx, newTestSubscription(userID, "Netflix", 599, month(2025, 1), nil))
+	})
+}
+
+// Оба репозитория на одних данных: benchmarkSubscriptions подписок организации acme,
+// вставленных через COPY, из них каждая сотая — у пользователя userID
+func benchmarkRepositories(b *testing.B, run func(ctx context.Context, repo SubsctriptionRepository, userID uuid.UUID) error) {
+	conf, db := openTestPostgres(b)
+	pool := openTestPgxPool(b, conf)
+	truncateSubscriptions(b, db)
+	// Логи запросов GormRepo на уровне info исказили бы сравнение
+	logger, err := logger_module.New(io.Discard, logger_module.Options{})
+	require.NoError(b, err)
+
+	ctx := tenant.NewContext(context.Background(), "acme")
+	userID := uuid.New()
+	subscriptions := make([]*objects.Subscription, 0, benchmarkSubscriptions)
+	for i := range benchmarkSubscriptions {
+		owner := uuid.New()
+		if i%100 == 0 {
+			owner = userID
+		}
+		var end *time.Time
+		if i%3 == 0 {
+			endDate := month(2023, 1+i%12)
+			end = &endDate
+		}
+		subscriptions = append(subscriptions, newTestSubscription(owner, fmt.Sprintf("Service %d", i%50), 100+i%900, month(2020+i%3, 1+i%12), end))
+	}
+	require.NoError(b, NewPgxRepo(pool, logger).CreateMany(ctx, subscriptions))
+
+	repos := []struct {
+		name string
+		repo SubsctriptionRepository
+	}{
+		{"gorm", NewGormRepo(db, logger)},
+		{"pgx", NewPgxRepo(pool, logger)},
+	}
+	for _, candidate := range repos {
+		b.Run(candidate.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if err := run(ctx, candidate.repo, userID); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
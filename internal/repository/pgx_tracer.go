@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"context"
+	"effective_mobile/internal/tracing"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Спаны запросов pgx, как колбэки RegisterTracing для GORM. Подготовленный запрос
+// в спане виден по имени (subscriptions.total_cost), остальные — по тексту с плейсхолдерами
+type pgxTracer struct{}
+
+func startPgxSpan(ctx context.Context, name string, attributes ...attribute.KeyValue) context.Context {
+	ctx, _ = tracing.Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(append(attributes, semconv.DBSystemPostgreSQL)...),
+	)
+	return ctx
+}
+
+func endPgxSpan(ctx context.Context, err error, attributes ...attribute.KeyValue) {
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(attributes...)
+	tracing.RecordError(span, err)
+	span.End()
+}
+
+// Имя спана: имя подготовленного запроса или первое слово SQL
+func pgxSpanName(sql string) string {
+	if operation, _, found := strings.Cut(strings.TrimSpace(sql), " "); found {
+		return operation
+	}
+	return sql
+}
+
+func (pgxTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	return startPgxSpan(ctx, pgxSpanName(data.SQL), semconv.DBQueryText(data.SQL))
+}
+
+func (pgxTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	endPgxSpan(ctx, data.Err, attribute.Int64("db.rows_affected", data.CommandTag.RowsAffected()))
+}
+
+// Пакет — один спан, запросы в нем — события спана
+func (pgxTracer) TraceBatchStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceBatchStartData) context.Context {
+	return startPgxSpan(ctx, "BATCH", attribute.Int("db.batch.size", data.Batch.Len()))
+}
+
+func (pgxTracer) TraceBatchQuery(ctx context.Context, _ *pgx.Conn, data pgx.TraceBatchQueryData) {
+	span := trace.SpanFromContext(ctx)
+	span.AddEvent(pgxSpanName(data.SQL), trace.WithAttributes(semconv.DBQueryText(data.SQL)))
+	tracing.RecordError(span, data.Err)
+}
+
+func (pgxTracer) TraceBatchEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceBatchEndData) {
+	endPgxSpan(ctx, data.Err)
+}
+
+func (pgxTracer) TraceCopyFromStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceCopyFromStartData) context.Context {
+	return startPgxSpan(ctx, "COPY "+data.TableName.Sanitize(), semconv.DBCollectionName(strings.Join(data.TableName, ".")))
+}
+
+func (pgxTracer) TraceCopyFromEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceCopyFromEndData) {
+	endPgxSpan(ctx, data.Err, attribute.Int64("db.rows_affected", data.CommandTag.RowsAffected()))
+}
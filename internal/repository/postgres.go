@@ -1,31 +1,179 @@
 package repository
 
 import (
+	"context"
 	"effective_mobile/internal/config"
+	"effective_mobile/internal/metrics"
 	"effective_mobile/pkg/logger_module"
 	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"time"
 
 	_ "github.com/jackc/pgx/v5/stdlib"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
+// Строка подключения к PostgreSQL, нужна и ORM, и отдельным соединениям (LISTEN).
+// Логин и пароль экранируются, поэтому в пароле допустимы "@", "/" и другие символы.
+// Параметры из DATABASE_URL важнее DB_SSLMODE и остальных настроек подключения
+func DSN(config *config.Config_PG) string {
+	conf := config.DB
+	dsn := &url.URL{
+		Scheme: "postgres",
+		Host:   net.JoinHostPort(conf.Host, conf.Port),
+		Path:   "/" + conf.Name,
+	}
+	if conf.URL != "" {
+		// Адрес уже проверен при загрузке конфига
+		if parsed, err := url.Parse(conf.URL); err == nil {
+			dsn = parsed
+		}
+	}
+
+	user := conf.User
+	if dsn.User != nil {
+		user = dsn.User.Username()
+	}
+	switch password, ok := dsnPassword(dsn, conf.Password); {
+	case ok:
+		dsn.User = url.UserPassword(user, password)
+	case user != "":
+		dsn.User = url.User(user)
+	}
+
+	query := dsn.Query()
+	setDefault := func(key, value string) {
+		if value != "" && !query.Has(key) {
+			query.Set(key, value)
+		}
+	}
+	setDefault("sslmode", conf.SSLMode)
+	setDefault("sslrootcert", conf.SSLRootCert)
+	setDefault("application_name", conf.ApplicationName)
+	if conf.StatementTimeout > 0 {
+		// Неизвестные pgx параметры уходят серверу как параметры сессии, значение в миллисекундах
+		setDefault("statement_timeout", strconv.FormatInt(conf.StatementTimeout.Milliseconds(), 10))
+	}
+	dsn.RawQuery = query.Encode()
+	return dsn.String()
+}
+
+// Пароль из DB_PASSWORD или файла секрета важнее пароля в DATABASE_URL: так URL без пароля
+// можно хранить в конфиге, а пароль передавать секретом
+func dsnPassword(dsn *url.URL, password string) (string, bool) {
+	if password != "" {
+		return password, true
+	}
+	if dsn.User != nil {
+		return dsn.User.Password()
+	}
+	return "", false
+}
+
+// Пауза между попытками подключения при старте растет от initial до max
+const (
+	connectBackoffInitial = 500 * time.Millisecond
+	connectBackoffMax     = 10 * time.Second
+	// Одна попытка не должна съедать весь срок ожидания, если сервер не отвечает
+	connectAttemptTimeout = 5 * time.Second
+)
+
 func NewConnectPostgresDB(logger *logger_module.Logger, config *config.Config_PG) (*gorm.DB, error) {
-	connection_db := fmt.Sprintf(
-		"postgres://%s:%s@%s:%s/%s?sslmode=%s",
-		config.DBUser,
-		config.DBPassword,
-		config.DBHost,
-		config.DBPort,
-		config.DBName,
-		config.DBSSLMode,
-	)
-
-	// Открываем подключение к базе через ORM
-	db, err := gorm.Open(postgres.Open(connection_db), &gorm.Config{})
+	connection_db := DSN(config)
+
+	// Открываем подключение к базе через ORM. Без автоматического ping Open не ходит в базу,
+	// доступность проверяем ниже с повторами: в docker-compose база может еще запускаться
+	db, err := gorm.Open(postgres.Open(connection_db), &gorm.Config{DisableAutomaticPing: true})
+	if err != nil {
+		return nil, err
+	}
+	sqlDB, err := db.DB()
 	if err != nil {
-		logger.Fatal("Failed to open DB", "error", err)
+		return nil, err
+	}
+	sqlDB.SetMaxOpenConns(config.DB.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(config.DB.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(config.DB.ConnMaxLifetime)
+	sqlDB.SetConnMaxIdleTime(config.DB.ConnMaxIdleTime)
+
+	ctx, cancel := context.WithTimeout(context.Background(), config.DB.ConnectTimeout)
+	defer cancel()
+	if err := waitForDB(ctx, sqlDB.PingContext, connectBackoffInitial, logger); err != nil {
+		sqlDB.Close()
+		return nil, err
+	}
+
+	// Каждый запрос к данным организаций ограничивается тенантом из контекста
+	if err := RegisterTenantScope(db); err != nil {
+		return nil, err
+	}
+	if err := RegisterTracing(db); err != nil {
+		return nil, err
 	}
 
 	return db, nil
 }
+
+// Ждем, пока база ответит на ping, с экспоненциальной паузой между попытками до срока ctx
+func waitForDB(ctx context.Context, ping func(ctx context.Context) error, backoff time.Duration, logger *logger_module.Logger) error {
+	for attempt := 1; ; attempt++ {
+		metrics.IncDBConnectAttempt()
+		attemptCtx, cancel := context.WithTimeout(ctx, connectAttemptTimeout)
+		err := ping(attemptCtx)
+		cancel()
+		if err == nil {
+			if attempt > 1 {
+				logger.Info("Database is available", "attempts", attempt)
+			}
+			return nil
+		}
+
+		logger.Warn("Database is not available yet", "attempt", attempt, "retry_in", backoff, "error", err)
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("database is not available after %d attempts: %w", attempt, err)
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, connectBackoffMax)
+	}
+}
+
+// Периодически проверяем соединение с базой. database/sql сам переподключается, а здесь
+// потеря и восстановление попадают в лог и метрики db_up и db_connection_lost_total
+func MonitorConnection(ctx context.Context, db *gorm.DB, interval time.Duration, logger *logger_module.Logger) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		up := true
+		metrics.SetDBUp(true, false)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			pingCtx, cancel := context.WithTimeout(ctx, connectAttemptTimeout)
+			err := sqlDB.PingContext(pingCtx)
+			cancel()
+			if ctx.Err() != nil {
+				return
+			}
+			switch {
+			case err != nil && up:
+				logger.Error("Database connection lost", "error", err)
+			case err == nil && !up:
+				logger.Info("Database connection restored")
+			}
+			metrics.SetDBUp(err == nil, err != nil && up)
+			up = err == nil
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"effective_mobile/internal/config"
+	"effective_mobile/pkg/logger_module"
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDSN_EscapesCredentials(t *testing.T) {
+	// pgx читает CA при разборе строки, подойдет любой PEM-сертификат
+	rootCert := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(rootCert, []byte(testCACert), 0600))
+
+	conf := &config.Config_PG{DB: config.DBConfig{
+		Host:             "db.internal",
+		Port:             "5432",
+		User:             "app@prod",
+		Password:         "p@ss/w:rd?#",
+		Name:             "subscriptions",
+		SSLMode:          "verify-full",
+		SSLRootCert:      rootCert,
+		ApplicationName:  "effective_mobile",
+		StatementTimeout: 30 * time.Second,
+	}}
+
+	// Строку разбирает тот же pgx, которым подключается сервис
+	parsed, err := pgx.ParseConfig(DSN(conf))
+	require.NoError(t, err)
+	assert.Equal(t, "db.internal", parsed.Host)
+	assert.Equal(t, uint16(5432), parsed.Port)
+	assert.Equal(t, "app@prod", parsed.User)
+	assert.Equal(t, "p@ss/w:rd?#", parsed.Password)
+	assert.Equal(t, "subscriptions", parsed.Database)
+	assert.Equal(t, "effective_mobile", parsed.RuntimeParams["application_name"])
+	assert.Equal(t, "30000", parsed.RuntimeParams["statement_timeout"])
+	assert.NotNil(t, parsed.TLSConfig)
+}
+
+func TestDSN_DatabaseURL(t *testing.T) {
+	conf := &config.Config_PG{DB: config.DBConfig{
+		URL:             "postgresql://reader@replica.internal:6432/analytics?sslmode=require&application_name=reports",
+		Host:            "ignored",
+		Password:        "from-secret-file",
+		SSLMode:         "disable",
+		ApplicationName: "effective_mobile",
+	}}
+
+	dsn, err := url.Parse(DSN(conf))
+	require.NoError(t, err)
+	assert.Equal(t, "replica.internal:6432", dsn.Host)
+	assert.Equal(t, "/analytics", dsn.Path)
+	assert.Equal(t, "reader", dsn.User.Username())
+	password, _ := dsn.User.Password()
+	assert.Equal(t, "from-secret-file", password)
+	// Параметры из URL важнее настроек по умолчанию
+	assert.Equal(t, "require", dsn.Query().Get("sslmode"))
+	assert.Equal(t, "reports", dsn.Query().Get("application_name"))
+}
+
+// Самоподписанный сертификат только для разбора строки подключения
+const testCACert = `-----BEGIN CERTIFICATE-----
+MIIBezCCASGgAwIBAgIUAs5QjxgSwPalpzTuQ41YXhs5anwwCgYIKoZIzj0EAwIw
+EjEQMA4GA1UEAwwHdGVzdC1jYTAgFw0yNjEwMTkwNzQwMThaGA8yMTI2MDkyNTA3
+NDAxOFowEjEQMA4GA1UEAwwHdGVzdC1jYTBZMBMGByqGSM49AgEGCCqGSM49AwEH
+A0IABAT9l02vF/keWEY8yZZhhCMrJBBw1JeNkEYL4q2RpTWW6RydJzGsBCEmEAdI
+gIhut4RdCeRpAhLPzuJ12sNYKv2jUzBRMB0GA1UdDgQWBBTGMMhSo8Vna6FG1QL/
+BOdliu41VjAfBgNVHSMEGDAWgBTGMMhSo8Vna6FG1QL/BOdliu41VjAPBgNVHRMB
+Af8EBTADAQH/MAoGCCqGSM49BAMCA0gAMEUCIQCqKyDhun4PStfjS/OGCWJYpdVJ
+zb41rY+xWK3O5sibtwIgQJaOkCWdXJBRGPcpU14TCnVaZnEdWsx7SxOwoeGL6r4=
+-----END CERTIFICATE-----
+`
+
+func TestWaitForDB_RetriesUntilAvailable(t *testing.T) {
+	var out bytes.Buffer
+	logger, err := logger_module.New(&out, logger_module.Options{})
+	require.NoError(t, err)
+
+	attempts := 0
+	ping := func(ctx context.Context) error {
+		attempts++
+		if attempts < 3 {
+			return errors.New("connection refused")
+		}
+		return nil
+	}
+	require.NoError(t, waitForDB(context.Background(), ping, time.Millisecond, logger))
+	assert.Equal(t, 3, attempts)
+	assert.Contains(t, out.String(), `msg="Database is not available yet" attempt=2 retry_in=2ms error="connection refused"`)
+	assert.Contains(t, out.String(), `msg="Database is available" attempts=3`)
+}
+
+func TestWaitForDB_Deadline(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	ping := func(ctx context.Context) error { return errors.New("connection refused") }
+	err := waitForDB(ctx, ping, 5*time.Millisecond, logger_module.Get())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "database is not available after")
+	assert.Contains(t, err.Error(), "connection refused")
+}
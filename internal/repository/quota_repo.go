@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"context"
+	"effective_mobile/pkg/logger_module"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Тариф организации, от него зависит суточная квота запросов
+type TenantPlan struct {
+	TenantID  string    `gorm:"primaryKey"`
+	Tier      string    `gorm:"not null"`
+	UpdatedAt time.Time `gorm:"not null"`
+}
+
+// Счетчики запросов к API по организациям и дням (UTC), по ним же выставляются счета
+type QuotaRepo struct {
+	db     *gorm.DB
+	logger *logger_module.Logger
+}
+
+func NewQuotaRepo(db *gorm.DB, logger *logger_module.Logger) *QuotaRepo {
+	return &QuotaRepo{db: db, logger: logger}
+}
+
+// Атомарно прибавляем запросы, накопленные репликой, так реплики не теряют запросы друг друга
+// INSERT INTO daily_usage ... ON CONFLICT (tenant_id, day) DO UPDATE SET requests = daily_usage.requests + n RETURNING requests;
+func (qr *QuotaRepo) AddDaily(ctx context.Context, tenantID string, day time.Time, n int64) (int64, error) {
+	var requests int64
+	err := qr.db.WithContext(ctx).Raw(`
+		INSERT INTO daily_usage (tenant_id, day, requests) VALUES (?, ?, ?)
+		ON CONFLICT (tenant_id, day) DO UPDATE SET requests = daily_usage.requests + EXCLUDED.requests
+		RETURNING requests`, tenantID, day.Format("2006-01-02"), n).Scan(&requests).Error
+	if err != nil {
+		qr.logger.Error("Failed to add daily usage", "error", err, "tenant_id", tenantID)
+		return 0, err
+	}
+	return requests, nil
+}
+
+// Расход тенанта за день без блокировки строки
+// SELECT requests FROM daily_usage WHERE tenant_id = '...' AND day = '...';
+func (qr *QuotaRepo) DailyUsage(ctx context.Context, tenantID string, day time.Time) (int64, error) {
+	var requests int64
+	err := qr.db.WithContext(ctx).Raw(`
+		SELECT COALESCE(SUM(requests), 0) FROM daily_usage WHERE tenant_id = ? AND day = ?`,
+		tenantID, day.Format("2006-01-02")).Scan(&requests).Error
+	if err != nil {
+		qr.logger.Error("Failed to get daily usage", "error", err, "tenant_id", tenantID)
+		return 0, err
+	}
+	return requests, nil
+}
+
+// Тариф организации, пустая строка — тариф не назначен
+// SELECT * FROM tenant_plans WHERE tenant_id = '...' LIMIT 1;
+func (qr *QuotaRepo) TenantTier(ctx context.Context, tenantID string) (string, error) {
+	var plan TenantPlan
+	err := qr.db.WithContext(ctx).First(&plan, "tenant_id = ?", tenantID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		qr.logger.Error("Failed to get tenant plan", "error", err, "tenant_id", tenantID)
+		return "", err
+	}
+	return plan.Tier, nil
+}
@@ -15,6 +15,13 @@ type SubsctriptionRepository interface {
 	Update(ctx context.Context, id uuid.UUID, fields map[string]interface{}) error
 	Delete(ctx context.Context, id uuid.UUID) error
 	Get_List(ctx context.Context, limit, offset int) ([]*objects.Subscription, error)
+	Get_List_By_User(ctx context.Context, userID uuid.UUID, limit, offset int) ([]*objects.Subscription, error)
+	GetByUserID(ctx context.Context, userID uuid.UUID) ([]*objects.Subscription, error)
+	// Выборки для фоновых задач, везде полуинтервал [from, to)
+	GetExpiredBetween(ctx context.Context, from, to time.Time) ([]*objects.Subscription, error)
+	GetActiveBetween(ctx context.Context, from, to time.Time) ([]*objects.Subscription, error)
+	GetTrialsEndingBetween(ctx context.Context, from, to time.Time) ([]*objects.Subscription, error)
+	CountActive(ctx context.Context, at time.Time) (int64, error)
 	GetTotalCost(
 		ctx context.Context,
 		userID uuid.UUID,
@@ -22,3 +29,25 @@ type SubsctriptionRepository interface {
 		start_time, end_time time.Time,
 	) (int, error)
 }
+
+// Интерфейс для работы с вебхуками и журналом их доставок
+type WebhookRepository interface {
+	Create(ctx context.Context, hook *objects.Webhook) error
+	List(ctx context.Context) ([]*objects.Webhook, error)
+	GetByID(ctx context.Context, id uuid.UUID) (*objects.Webhook, error)
+	Delete(ctx context.Context, id uuid.UUID) error
+	ListDeliveries(ctx context.Context, webhookID uuid.UUID, status string, limit, offset int) ([]*objects.WebhookDelivery, error)
+}
+
+// Интерфейс для работы с настройками уведомлений
+type NotificationRepository interface {
+	GetPreferences(ctx context.Context, userID uuid.UUID) (*objects.NotificationPreferences, error)
+	SavePreferences(ctx context.Context, prefs *objects.NotificationPreferences) error
+}
+
+// Интерфейс для работы с API-ключами
+type APIKeyRepository interface {
+	Create(ctx context.Context, key *objects.APIKey) error
+	ListByUser(ctx context.Context, userID uuid.UUID) ([]*objects.APIKey, error)
+	Revoke(ctx context.Context, id, userID uuid.UUID) error
+}
@@ -0,0 +1,188 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"effective_mobile/pkg/logger_module"
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Сколько раз повторяем упавший запуск задачи и через сколько считаем зависший запуск брошенным.
+// Повторы идут через 5, 10, 20 и 40 минут, так запуск переживает перерыв в работе базы или SMTP
+// примерно в час; после этого окно запуска обработает следующий ежедневный запуск
+const (
+	maxJobAttempts  = 5
+	staleJobRun     = time.Hour
+	jobRetryBackoff = 5 * time.Minute
+	maxJobBackoff   = time.Hour
+)
+
+// Лидерство через сессионную advisory-блокировку Postgres.
+// Блокировка живет пока живо соединение, поэтому держим для нее отдельное соединение из пула:
+// если реплика упадет или потеряет связь с БД, блокировка освободится сама
+type AdvisoryLocker struct {
+	db     *sql.DB
+	key    int64
+	conn   *sql.Conn
+	mutex  sync.Mutex
+	logger *logger_module.Logger
+}
+
+func NewAdvisoryLocker(db *gorm.DB, name string, logger *logger_module.Logger) (*AdvisoryLocker, error) {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	hash := fnv.New64a()
+	hash.Write([]byte(name))
+	return &AdvisoryLocker{db: sqlDB, key: int64(hash.Sum64()), logger: logger}, nil
+}
+
+func (locker *AdvisoryLocker) TryLock(ctx context.Context) (bool, error) {
+	locker.mutex.Lock()
+	defer locker.mutex.Unlock()
+
+	// Уже лидер: проверяем что соединение (а значит и блокировка) живо
+	if locker.conn != nil {
+		if err := locker.conn.PingContext(ctx); err == nil {
+			return true, nil
+		}
+		locker.logger.Error("Lost scheduler lock connection")
+		locker.conn.Close()
+		locker.conn = nil
+	}
+
+	conn, err := locker.db.Conn(ctx)
+	if err != nil {
+		return false, err
+	}
+	var acquired bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", locker.key).Scan(&acquired); err != nil {
+		conn.Close()
+		return false, err
+	}
+	if !acquired {
+		conn.Close()
+		return false, nil
+	}
+
+	locker.logger.Info("Acquired scheduler lock, this replica is leader")
+	locker.conn = conn
+	return true, nil
+}
+
+func (locker *AdvisoryLocker) Unlock(ctx context.Context) error {
+	locker.mutex.Lock()
+	defer locker.mutex.Unlock()
+
+	if locker.conn == nil {
+		return nil
+	}
+	_, err := locker.conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", locker.key)
+	locker.conn.Close()
+	locker.conn = nil
+	return err
+}
+
+// Запись о запуске задачи планировщика
+type JobRun struct {
+	ID          uuid.UUID `gorm:"type:uuid;primaryKey"`
+	JobName     string    `gorm:"not null"`
+	ScheduledAt time.Time `gorm:"not null"`
+	Status      string    `gorm:"not null"`
+	Attempts    int       `gorm:"not null"`
+	StartedAt   time.Time `gorm:"not null"`
+	FinishedAt  *time.Time
+	Error       *string
+	// не раньше этого времени упавший запуск можно занять снова
+	NextAttemptAt *time.Time
+}
+
+const (
+	JobRunRunning   = "running"
+	JobRunSucceeded = "succeeded"
+	JobRunFailed    = "failed"
+)
+
+type JobRunRepo struct {
+	db     *gorm.DB
+	logger *logger_module.Logger
+}
+
+func NewJobRunRepo(db *gorm.DB, logger *logger_module.Logger) *JobRunRepo {
+	return &JobRunRepo{db: db, logger: logger}
+}
+
+// Запуск занимается вставкой строки (job_name, scheduled_at): уникальный индекс не дает
+// выполнить одну задачу дважды, даже если лидерство сменилось посреди запуска.
+// Упавший запуск можно перезанять после задержки, зависший — через staleJobRun, пока не кончились попытки
+func (jr *JobRunRepo) Claim(ctx context.Context, job string, scheduledAt time.Time) (bool, time.Time, bool, error) {
+	now := time.Now().UTC()
+	var claimed []uuid.UUID
+	result := jr.db.WithContext(ctx).Raw(`
+		INSERT INTO job_runs (id, job_name, scheduled_at, status, attempts, started_at)
+		VALUES (?, ?, ?, ?, 1, ?)
+		ON CONFLICT (job_name, scheduled_at) DO UPDATE
+		SET status = EXCLUDED.status, attempts = job_runs.attempts + 1,
+			started_at = EXCLUDED.started_at, finished_at = NULL, error = NULL, next_attempt_at = NULL
+		WHERE job_runs.attempts < ?
+			AND ((job_runs.status = ? AND (job_runs.next_attempt_at IS NULL OR job_runs.next_attempt_at <= ?))
+				OR (job_runs.status = ? AND job_runs.started_at < ?))
+		RETURNING id`,
+		uuid.New(), job, scheduledAt, JobRunRunning, now,
+		maxJobAttempts, JobRunFailed, now, JobRunRunning, now.Add(-staleJobRun),
+	).Scan(&claimed)
+	if result.Error != nil {
+		return false, time.Time{}, false, result.Error
+	}
+	if len(claimed) == 0 {
+		return false, time.Time{}, false, nil
+	}
+
+	var previous []time.Time
+	result = jr.db.WithContext(ctx).
+		Model(&JobRun{}).
+		Where("job_name = ? AND status = ? AND scheduled_at < ?", job, JobRunSucceeded, scheduledAt).
+		Order("scheduled_at DESC").
+		Limit(1).
+		Pluck("scheduled_at", &previous)
+	if result.Error != nil {
+		return false, time.Time{}, false, result.Error
+	}
+	if len(previous) == 0 {
+		return true, time.Time{}, false, nil
+	}
+	return true, previous[0], true, nil
+}
+
+// Записываем результат запуска. Упавший запуск откладываем с экспоненциальной задержкой;
+// gaveUp — попытки кончились, запуск больше не повторится
+func (jr *JobRunRepo) Finish(ctx context.Context, job string, scheduledAt time.Time, runErr error) (bool, error) {
+	now := time.Now().UTC()
+	if runErr == nil {
+		return false, jr.db.WithContext(ctx).
+			Model(&JobRun{}).
+			Where("job_name = ? AND scheduled_at = ?", job, scheduledAt).
+			Updates(map[string]interface{}{"status": JobRunSucceeded, "finished_at": now, "next_attempt_at": nil}).Error
+	}
+
+	var attempts []int
+	err := jr.db.WithContext(ctx).Raw(`
+		UPDATE job_runs SET status = ?, finished_at = ?, error = ?,
+			next_attempt_at = CAST(? AS TIMESTAMP) + LEAST(? * power(2, attempts - 1), ?) * interval '1 second'
+		WHERE job_name = ? AND scheduled_at = ?
+		RETURNING attempts`,
+		JobRunFailed, now, runErr.Error(),
+		now, jobRetryBackoff.Seconds(), maxJobBackoff.Seconds(),
+		job, scheduledAt,
+	).Scan(&attempts).Error
+	if err != nil {
+		return false, err
+	}
+	return len(attempts) > 0 && attempts[0] >= maxJobAttempts, nil
+}
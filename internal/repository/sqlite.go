@@ -0,0 +1,45 @@
+package repository
+
+import (
+	"effective_mobile/internal/config"
+	"effective_mobile/pkg/logger_module"
+	"net/url"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+// Строка подключения к файлу SQLite: WAL позволяет читать во время записи,
+// busy_timeout ждет освобождения блокировки вместо ошибки SQLITE_BUSY
+func sqliteDSN(path string) string {
+	query := url.Values{}
+	query.Add("_pragma", "busy_timeout(5000)")
+	query.Add("_pragma", "journal_mode(WAL)")
+	query.Add("_pragma", "foreign_keys(1)")
+	return path + "?" + query.Encode()
+}
+
+// Подключение к SQLite для запуска без Postgres. Драйвер на чистом Go, cgo не нужен.
+// Файл создается при первом подключении
+func NewConnectSQLiteDB(logger *logger_module.Logger, config *config.Config_PG) (*gorm.DB, error) {
+	db, err := gorm.Open(sqlite.Open(sqliteDSN(config.Storage.SQLitePath)), &gorm.Config{})
+	if err != nil {
+		return nil, err
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	// Писать в SQLite может только одно соединение за раз, с одним соединением
+	// запросы ждут в пуле database/sql, а не получают SQLITE_BUSY
+	sqlDB.SetMaxOpenConns(1)
+	logger.Info("SQLite database opened", "path", config.Storage.SQLitePath)
+
+	if err := RegisterTenantScope(db); err != nil {
+		return nil, err
+	}
+	if err := RegisterTracing(db); err != nil {
+		return nil, err
+	}
+	return db, nil
+}
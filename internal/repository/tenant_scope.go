@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"effective_mobile/internal/tenant"
+	"errors"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Запрос к таблице с tenant_id без тенанта в контексте — ошибка в коде, а не повод вернуть чужие данные
+var ErrNoTenant = errors.New("tenant is not set in context")
+
+const tenantColumn = "tenant_id"
+
+// Регистрируем колбэки, которые ограничивают тенантом каждый запрос к моделям с полем tenant_id:
+// SELECT/UPDATE/DELETE получают условие tenant_id = ?, INSERT — значение tenant_id из контекста.
+// Модели без tenant_id и сырые запросы (Raw/Exec) не затрагиваются
+func RegisterTenantScope(db *gorm.DB) error {
+	callbacks := db.Callback()
+	return errors.Join(
+		callbacks.Create().Before("gorm:create").Register("tenant:create", setTenant),
+		callbacks.Query().Before("gorm:query").Register("tenant:query", scopeTenant),
+		callbacks.Row().Before("gorm:row").Register("tenant:row", scopeTenant),
+		callbacks.Update().Before("gorm:update").Register("tenant:update", scopeTenant),
+		callbacks.Delete().Before("gorm:delete").Register("tenant:delete", scopeTenant),
+	)
+}
+
+func hasTenantColumn(db *gorm.DB) bool {
+	return db.Statement.Schema != nil && db.Statement.Schema.LookUpField(tenantColumn) != nil
+}
+
+func scopeTenant(db *gorm.DB) {
+	if db.Error != nil || !hasTenantColumn(db) || tenant.IsAllTenants(db.Statement.Context) {
+		return
+	}
+	id, ok := tenant.FromContext(db.Statement.Context)
+	if !ok {
+		db.AddError(ErrNoTenant)
+		return
+	}
+	db.Statement.AddClause(clause.Where{Exprs: []clause.Expression{
+		clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: tenantColumn}, Value: id},
+	}})
+}
+
+func setTenant(db *gorm.DB) {
+	if db.Error != nil || !hasTenantColumn(db) || tenant.IsAllTenants(db.Statement.Context) {
+		return
+	}
+	id, ok := tenant.FromContext(db.Statement.Context)
+	if !ok {
+		db.AddError(ErrNoTenant)
+		return
+	}
+	// true — проставить всем записям при пакетной вставке
+	db.Statement.SetColumn(tenantColumn, id, true)
+}
@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"context"
+	"effective_mobile/internal/objects"
+	"effective_mobile/internal/tenant"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// Базы в тестах нет: DryRun только собирает SQL, не выполняя его
+func newDryRunDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{
+		DryRun:                 true,
+		DisableAutomaticPing:   true,
+		SkipDefaultTransaction: true,
+	})
+	require.NoError(t, err)
+	require.NoError(t, RegisterTenantScope(db))
+	return db
+}
+
+func TestTenantScope_Query(t *testing.T) {
+	db := newDryRunDB(t)
+	ctx := tenant.NewContext(context.Background(), "acme")
+
+	var subscriptions []objects.Subscription
+	stmt := db.WithContext(ctx).Where("user_id = ?", uuid.New()).Find(&subscriptions).Statement
+	assert.Contains(t, stmt.SQL.String(), `"subscriptions"."tenant_id" = $`)
+	assert.Contains(t, stmt.Vars, "acme")
+
+	stmt = db.WithContext(ctx).Delete(&objects.Subscription{}, "id = ?", uuid.New()).Statement
+	assert.Contains(t, stmt.SQL.String(), `"subscriptions"."tenant_id" = $`)
+
+	stmt = db.WithContext(ctx).Model(&objects.Subscription{}).Where("id = ?", uuid.New()).Update("price", 100).Statement
+	assert.Contains(t, stmt.SQL.String(), `"subscriptions"."tenant_id" = $`)
+}
+
+func TestTenantScope_Create(t *testing.T) {
+	db := newDryRunDB(t)
+	ctx := tenant.NewContext(context.Background(), "acme")
+
+	sub := &objects.Subscription{ID: uuid.New(), ServiceName: "Netflix", Price: 800, UserID: uuid.New()}
+	assert.NoError(t, db.WithContext(ctx).Create(sub).Error)
+	assert.Equal(t, "acme", sub.TenantID)
+}
+
+func TestTenantScope_MissingTenant(t *testing.T) {
+	db := newDryRunDB(t)
+
+	var subscriptions []objects.Subscription
+	assert.ErrorIs(t, db.WithContext(context.Background()).Find(&subscriptions).Error, ErrNoTenant)
+
+	// Фоновым задачам тенант не нужен, запрос не ограничивается
+	stmt := db.WithContext(tenant.WithAllTenants(context.Background())).Find(&subscriptions).Statement
+	assert.NotContains(t, stmt.SQL.String(), "tenant_id")
+
+	// Таблицы без tenant_id не затрагиваются
+	var runs []JobRun
+	assert.NoError(t, db.WithContext(context.Background()).Find(&runs).Error)
+}
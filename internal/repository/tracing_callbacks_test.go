@@ -0,0 +1,46 @@
+package repository
+
+import (
+	"context"
+	"effective_mobile/internal/objects"
+	"effective_mobile/internal/tenant"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracingCallbacks(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	db := newDryRunDB(t)
+	require.NoError(t, RegisterTracing(db))
+
+	ctx := tenant.NewContext(context.Background(), "acme")
+	ctx, parent := otel.Tracer("test").Start(ctx, "parent")
+	var subscription objects.Subscription
+	db.WithContext(ctx).First(&subscription, "id = ?", uuid.New())
+	parent.End()
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+	sqlSpan := spans[0]
+	assert.Equal(t, "SELECT subscriptions", sqlSpan.Name())
+	assert.Equal(t, parent.SpanContext().SpanID(), sqlSpan.Parent().SpanID())
+
+	var query string
+	for _, attr := range sqlSpan.Attributes() {
+		if attr.Key == attribute.Key("db.query.text") {
+			query = attr.Value.AsString()
+		}
+	}
+	// В тексте запроса плейсхолдеры, а не значения
+	assert.Contains(t, query, `"subscriptions"."tenant_id" = $`)
+	assert.NotContains(t, query, "acme")
+}
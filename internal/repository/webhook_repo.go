@@ -0,0 +1,243 @@
+package repository
+
+import (
+	"context"
+	"effective_mobile/internal/objects"
+	"effective_mobile/internal/webhooks"
+	"effective_mobile/pkg/logger_module"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type WebhookRepo struct {
+	db     *gorm.DB
+	logger *logger_module.Logger
+}
+
+func NewWebhookRepo(db *gorm.DB, logger *logger_module.Logger) *WebhookRepo {
+	return &WebhookRepo{db: db, logger: logger}
+}
+
+// Регистрируем вебхук
+func (wr *WebhookRepo) Create(ctx context.Context, hook *objects.Webhook) error {
+	wr.logger.Info("Starting ORM request create webhook in db")
+	if err := wr.db.WithContext(ctx).Create(hook).Error; err != nil {
+		wr.logger.Error("Failed to create webhook", "error", err)
+		return err
+	}
+	return nil
+}
+
+// Список вебхуков
+// SELECT * FROM webhooks ORDER BY created_at;
+func (wr *WebhookRepo) List(ctx context.Context) ([]*objects.Webhook, error) {
+	wr.logger.Info("Starting ORM request get list webhooks in db")
+	var hooks []*objects.Webhook
+	if err := wr.db.WithContext(ctx).Order("created_at").Find(&hooks).Error; err != nil {
+		wr.logger.Error("Failed to get webhooks", "error", err)
+		return nil, err
+	}
+	return hooks, nil
+}
+
+// Получаем вебхук по id, если нет то gorm.ErrRecordNotFound
+func (wr *WebhookRepo) GetByID(ctx context.Context, id uuid.UUID) (*objects.Webhook, error) {
+	wr.logger.Info("Starting ORM request get webhook by id in db")
+	var hook objects.Webhook
+	if err := wr.db.WithContext(ctx).First(&hook, "id = ?", id).Error; err != nil {
+		wr.logger.Error("Failed to get webhook", "error", err, "id", id)
+		return nil, err
+	}
+	return &hook, nil
+}
+
+// Удаляем вебхук, журнал его доставок удалится каскадно
+func (wr *WebhookRepo) Delete(ctx context.Context, id uuid.UUID) error {
+	wr.logger.Info("Starting ORM request delete webhook in db")
+	result := wr.db.WithContext(ctx).Delete(&objects.Webhook{}, "id = ?", id)
+	if result.Error != nil {
+		wr.logger.Error("Failed to delete webhook", "error", result.Error, "id", id)
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// Журнал доставок вебхука, новые сверху
+// SELECT * FROM webhook_deliveries WHERE webhook_id = '...' [AND status = '...'] ORDER BY created_at DESC LIMIT ... OFFSET ...;
+func (wr *WebhookRepo) ListDeliveries(ctx context.Context, webhookID uuid.UUID, status string, limit, offset int) ([]*objects.WebhookDelivery, error) {
+	wr.logger.Info("Starting ORM request get webhook deliveries in db")
+	var deliveries []*objects.WebhookDelivery
+	query := wr.db.WithContext(ctx).Where("webhook_id = ?", webhookID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if err := query.Order("created_at DESC").Limit(limit).Offset(offset).Find(&deliveries).Error; err != nil {
+		wr.logger.Error("Failed to get webhook deliveries", "error", err)
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+// Берем необработанные события из outbox (SKIP LOCKED, чтобы реплики не мешали друг другу),
+// создаем по доставке на каждый подписанный активный вебхук и помечаем события обработанными
+func (wr *WebhookRepo) FanOut(ctx context.Context, limit int) (int, error) {
+	count := 0
+	err := wr.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var pending []OutboxEvent
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("processed_at IS NULL").
+			Order("id").
+			Limit(limit).
+			Find(&pending).Error; err != nil {
+			return err
+		}
+		if len(pending) == 0 {
+			return nil
+		}
+
+		var hooks []objects.Webhook
+		if err := tx.Where("active = ?", true).Find(&hooks).Error; err != nil {
+			return err
+		}
+
+		now := time.Now().UTC()
+		var deliveries []objects.WebhookDelivery
+		ids := make([]int64, 0, len(pending))
+		for _, event := range pending {
+			ids = append(ids, event.ID)
+			for _, hook := range hooks {
+				if hook.TenantID != event.TenantID || !hook.Accepts(event.EventType) {
+					continue
+				}
+				deliveries = append(deliveries, objects.WebhookDelivery{
+					ID:            uuid.New(),
+					WebhookID:     hook.ID,
+					EventID:       event.EventID,
+					EventType:     event.EventType,
+					Payload:       event.Payload,
+					Status:        objects.DeliveryPending,
+					NextAttemptAt: now,
+					CreatedAt:     now,
+					UpdatedAt:     now,
+				})
+			}
+		}
+
+		if len(deliveries) > 0 {
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&deliveries).Error; err != nil {
+				return err
+			}
+		}
+		count = len(pending)
+		return tx.Model(&OutboxEvent{}).Where("id IN ?", ids).Update("processed_at", now).Error
+	})
+	if err != nil {
+		wr.logger.Error("Failed fan out outbox events", "error", err)
+		return 0, err
+	}
+	return count, nil
+}
+
+// Забираем доставки, время которых подошло, и сдвигаем им next_attempt_at на lease:
+// пока мы отправляем, другие реплики их не увидят, а если мы упадем — доставка вернется в очередь
+func (wr *WebhookRepo) ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]webhooks.Task, error) {
+	var tasks []webhooks.Task
+	err := wr.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now().UTC()
+		var due []objects.WebhookDelivery
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", objects.DeliveryPending, now).
+			Order("next_attempt_at").
+			Limit(limit).
+			Find(&due).Error; err != nil {
+			return err
+		}
+		if len(due) == 0 {
+			return nil
+		}
+
+		ids := make([]uuid.UUID, 0, len(due))
+		hookIDs := make([]uuid.UUID, 0, len(due))
+		for _, delivery := range due {
+			ids = append(ids, delivery.ID)
+			hookIDs = append(hookIDs, delivery.WebhookID)
+		}
+		if err := tx.Model(&objects.WebhookDelivery{}).
+			Where("id IN ?", ids).
+			Update("next_attempt_at", now.Add(lease)).Error; err != nil {
+			return err
+		}
+
+		var hooks []objects.Webhook
+		if err := tx.Where("id IN ?", hookIDs).Find(&hooks).Error; err != nil {
+			return err
+		}
+		byID := make(map[uuid.UUID]objects.Webhook, len(hooks))
+		for _, hook := range hooks {
+			byID[hook.ID] = hook
+		}
+
+		for _, delivery := range due {
+			hook := byID[delivery.WebhookID]
+			tasks = append(tasks, webhooks.Task{Delivery: delivery, URL: hook.URL, Secret: hook.Secret})
+		}
+		return nil
+	})
+	if err != nil {
+		wr.logger.Error("Failed claim webhook deliveries", "error", err)
+		return nil, err
+	}
+	return tasks, nil
+}
+
+// Сохраняем результат попытки доставки
+func (wr *WebhookRepo) SaveAttempt(ctx context.Context, delivery *objects.WebhookDelivery) error {
+	return wr.db.WithContext(ctx).
+		Model(&objects.WebhookDelivery{}).
+		Where("id = ?", delivery.ID).
+		Updates(map[string]interface{}{
+			"status":           delivery.Status,
+			"attempts":         delivery.Attempts,
+			"next_attempt_at":  delivery.NextAttemptAt,
+			"last_status_code": delivery.LastStatusCode,
+			"last_error":       delivery.LastError,
+			"updated_at":       delivery.UpdatedAt,
+			"delivered_at":     delivery.DeliveredAt,
+		}).Error
+}
+
+// Удаляем обработанные события outbox старше before, по limit за раз
+// DELETE FROM outbox_events WHERE id IN (SELECT id FROM outbox_events WHERE processed_at IS NOT NULL AND created_at < ... LIMIT ...);
+func (wr *WebhookRepo) DeleteProcessedEvents(ctx context.Context, before time.Time, limit int) (int64, error) {
+	result := wr.db.WithContext(ctx).Exec(`
+		DELETE FROM outbox_events WHERE id IN (
+			SELECT id FROM outbox_events
+			WHERE processed_at IS NOT NULL AND created_at < ?
+			LIMIT ?)`, before.UTC(), limit)
+	if result.Error != nil {
+		wr.logger.Error("Failed to delete old outbox events", "error", result.Error)
+		return 0, result.Error
+	}
+	return result.RowsAffected, nil
+}
+
+// Удаляем доставки в статусе delivered и dead, которые не менялись с before, по limit за раз
+// DELETE FROM webhook_deliveries WHERE id IN (SELECT id FROM webhook_deliveries WHERE status IN ('delivered', 'dead') AND updated_at < ... LIMIT ...);
+func (wr *WebhookRepo) DeleteFinishedDeliveries(ctx context.Context, before time.Time, limit int) (int64, error) {
+	result := wr.db.WithContext(ctx).Exec(`
+		DELETE FROM webhook_deliveries WHERE id IN (
+			SELECT id FROM webhook_deliveries
+			WHERE status IN (?, ?) AND updated_at < ?
+			LIMIT ?)`, objects.DeliveryDelivered, objects.DeliveryDead, before.UTC(), limit)
+	if result.Error != nil {
+		wr.logger.Error("Failed to delete old webhook deliveries", "error", result.Error)
+		return 0, result.Error
+	}
+	return result.RowsAffected, nil
+}
@@ -0,0 +1,164 @@
+package scheduler
+
+import (
+	"context"
+	"effective_mobile/internal/metrics"
+	"effective_mobile/pkg/logger_module"
+	"sync"
+	"time"
+)
+
+// Задача получает окно [from, to): от предыдущего успешного запуска до текущего.
+// Так пропущенные дни (например, сервис лежал) обрабатываются одним запуском
+type JobFunc func(ctx context.Context, from, to time.Time) error
+
+// Блокировка лидера: задачи запускает только одна реплика
+type Locker interface {
+	// Захватывает блокировку или подтверждает, что она все еще наша
+	TryLock(ctx context.Context) (bool, error)
+	Unlock(ctx context.Context) error
+}
+
+// Журнал запусков задач
+type RunStore interface {
+	// Помечает запуск задачи на scheduledAt как начатый. Возвращает false, если запуск уже
+	// выполнен или выполняется, и время предыдущего успешного запуска (ok=false если его нет)
+	Claim(ctx context.Context, job string, scheduledAt time.Time) (claimed bool, previous time.Time, ok bool, err error)
+	// Записывает результат запуска. Упавший запуск повторяется позже с задержкой,
+	// gaveUp = true — попытки кончились и запуск больше не повторится
+	Finish(ctx context.Context, job string, scheduledAt time.Time, runErr error) (gaveUp bool, err error)
+}
+
+type job struct {
+	name string
+	run  JobFunc
+}
+
+// Ежедневный планировщик задач
+type Scheduler struct {
+	locker  Locker
+	runs    RunStore
+	logger  *logger_module.Logger
+	runHour int           // час запуска по UTC
+	tick    time.Duration // как часто проверяем лидерство и наступление времени запуска
+	jobs    []job
+	now     func() time.Time
+	wg      sync.WaitGroup
+}
+
+func New(locker Locker, runs RunStore, runHour int, logger *logger_module.Logger) *Scheduler {
+	return &Scheduler{
+		locker:  locker,
+		runs:    runs,
+		logger:  logger,
+		runHour: runHour,
+		tick:    time.Minute,
+		now:     time.Now,
+	}
+}
+
+// Добавляем задачу, запускать нужно до Start
+func (s *Scheduler) Add(name string, run JobFunc) {
+	s.jobs = append(s.jobs, job{name: name, run: run})
+}
+
+// Запускаем цикл планировщика в отдельной горутине, останавливается отменой ctx
+func (s *Scheduler) Start(ctx context.Context) {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.loop(ctx)
+	}()
+}
+
+// Ждем завершения цикла после отмены контекста
+func (s *Scheduler) Wait() {
+	s.wg.Wait()
+}
+
+func (s *Scheduler) loop(ctx context.Context) {
+	s.logger.Info("Scheduler started", "run_hour_utc", s.runHour, "jobs", len(s.jobs))
+	ticker := time.NewTicker(s.tick)
+	defer ticker.Stop()
+
+	for {
+		s.runDue(ctx)
+		select {
+		case <-ctx.Done():
+			// Отпускаем лидерство, чтобы другая реплика подхватила задачи не дожидаясь разрыва соединения
+			unlockCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			if err := s.locker.Unlock(unlockCtx); err != nil {
+				s.logger.Error("Failed release scheduler lock", "error", err)
+			}
+			cancel()
+			s.logger.Info("Scheduler stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Запускаем задачи, время которых наступило, если эта реплика лидер
+func (s *Scheduler) runDue(ctx context.Context) {
+	leader, err := s.locker.TryLock(ctx)
+	if err != nil {
+		s.logger.Error("Failed acquire scheduler lock", "error", err)
+		return
+	}
+	if !leader {
+		s.logger.Debug("Scheduler is not leader, skip")
+		return
+	}
+
+	scheduledAt := s.lastScheduled(s.now().UTC())
+	for _, j := range s.jobs {
+		if ctx.Err() != nil {
+			return
+		}
+		s.runJob(ctx, j, scheduledAt)
+	}
+}
+
+// Последнее наступившее время запуска
+func (s *Scheduler) lastScheduled(now time.Time) time.Time {
+	scheduled := time.Date(now.Year(), now.Month(), now.Day(), s.runHour, 0, 0, 0, time.UTC)
+	if now.Before(scheduled) {
+		scheduled = scheduled.AddDate(0, 0, -1)
+	}
+	return scheduled
+}
+
+func (s *Scheduler) runJob(ctx context.Context, j job, scheduledAt time.Time) {
+	claimed, previous, ok, err := s.runs.Claim(ctx, j.name, scheduledAt)
+	if err != nil {
+		s.logger.Error("Failed claim job run", "error", err, "job", j.name)
+		return
+	}
+	if !claimed {
+		return
+	}
+
+	from := scheduledAt.AddDate(0, 0, -1)
+	if ok {
+		from = previous
+	}
+
+	s.logger.Info("Job started", "job", j.name, "from", from, "to", scheduledAt)
+	runErr := j.run(ctx, from, scheduledAt)
+	if runErr != nil {
+		s.logger.Error("Job failed", "error", runErr, "job", j.name)
+	} else {
+		s.logger.Info("Job finished", "job", j.name)
+	}
+
+	gaveUp, err := s.runs.Finish(ctx, j.name, scheduledAt, runErr)
+	if err != nil {
+		s.logger.Error("Failed record job run", "error", err, "job", j.name)
+	}
+	if gaveUp {
+		// Окно этого запуска начнется заново со следующего ежедневного запуска: он берет окно
+		// от последнего успешного, но события и письма задержатся на сутки
+		metrics.IncJobGivenUp(j.name)
+		s.logger.Error("Job run given up after all attempts", "error", runErr, "job", j.name, "scheduled_at", scheduledAt)
+	}
+}
@@ -0,0 +1,125 @@
+package scheduler
+
+import (
+	"bytes"
+	"context"
+	"effective_mobile/pkg/logger_module"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeLocker struct {
+	leader bool
+}
+
+func (l *fakeLocker) TryLock(ctx context.Context) (bool, error) { return l.leader, nil }
+func (l *fakeLocker) Unlock(ctx context.Context) error          { return nil }
+
+// Журнал в памяти: запуск занимается один раз, предыдущий успешный запуск запоминается.
+// Упавший запуск можно занять снова, пока не кончились maxAttempts попыток
+type fakeRunStore struct {
+	claimed     map[string]bool
+	attempts    map[string]int
+	previous    map[string]time.Time
+	maxAttempts int
+}
+
+func newFakeRunStore() *fakeRunStore {
+	return &fakeRunStore{claimed: map[string]bool{}, attempts: map[string]int{}, previous: map[string]time.Time{}, maxAttempts: 1}
+}
+
+func (s *fakeRunStore) Claim(ctx context.Context, job string, scheduledAt time.Time) (bool, time.Time, bool, error) {
+	key := job + scheduledAt.String()
+	if s.claimed[key] {
+		return false, time.Time{}, false, nil
+	}
+	s.claimed[key] = true
+	s.attempts[key]++
+	previous, ok := s.previous[job]
+	return true, previous, ok, nil
+}
+
+func (s *fakeRunStore) Finish(ctx context.Context, job string, scheduledAt time.Time, runErr error) (bool, error) {
+	key := job + scheduledAt.String()
+	if runErr == nil {
+		s.previous[job] = scheduledAt
+		return false, nil
+	}
+	if s.attempts[key] >= s.maxAttempts {
+		return true, nil
+	}
+	s.claimed[key] = false
+	return false, nil
+}
+
+func TestScheduler_RunsOncePerDayWithContinuousWindows(t *testing.T) {
+	store := newFakeRunStore()
+	sched := New(&fakeLocker{leader: true}, store, 3, logger_module.Get())
+
+	type window struct{ from, to time.Time }
+	var windows []window
+	sched.Add("test", func(ctx context.Context, from, to time.Time) error {
+		windows = append(windows, window{from, to})
+		return nil
+	})
+
+	// Два тика в один день и один тик через два дня
+	for _, now := range []time.Time{
+		time.Date(2025, time.March, 1, 4, 0, 0, 0, time.UTC),
+		time.Date(2025, time.March, 1, 5, 0, 0, 0, time.UTC),
+		time.Date(2025, time.March, 3, 3, 30, 0, 0, time.UTC),
+	} {
+		sched.now = func() time.Time { return now }
+		sched.runDue(context.Background())
+	}
+
+	day1 := time.Date(2025, time.March, 1, 3, 0, 0, 0, time.UTC)
+	day3 := time.Date(2025, time.March, 3, 3, 0, 0, 0, time.UTC)
+	assert.Equal(t, []window{
+		{day1.AddDate(0, 0, -1), day1},
+		// Пропущенный день попадает в окно следующего запуска
+		{day1, day3},
+	}, windows)
+}
+
+func TestScheduler_NotLeader(t *testing.T) {
+	sched := New(&fakeLocker{leader: false}, newFakeRunStore(), 3, logger_module.Get())
+
+	called := false
+	sched.Add("test", func(ctx context.Context, from, to time.Time) error {
+		called = true
+		return nil
+	})
+	sched.runDue(context.Background())
+
+	assert.False(t, called)
+}
+
+func TestScheduler_LogsGivenUpRun(t *testing.T) {
+	var out bytes.Buffer
+	logger, err := logger_module.New(&out, logger_module.Options{})
+	assert.NoError(t, err)
+	store := newFakeRunStore()
+	store.maxAttempts = 2
+	sched := New(&fakeLocker{leader: true}, store, 3, logger)
+	sched.now = func() time.Time { return time.Date(2025, time.March, 1, 4, 0, 0, 0, time.UTC) }
+
+	calls := 0
+	sched.Add("test", func(ctx context.Context, from, to time.Time) error {
+		calls++
+		return errors.New("smtp unavailable")
+	})
+
+	// Первая неудача — запуск повторится, вторая — попытки кончились
+	sched.runDue(context.Background())
+	assert.NotContains(t, out.String(), "given up")
+	sched.runDue(context.Background())
+	sched.runDue(context.Background())
+
+	assert.Equal(t, 2, calls)
+	assert.Equal(t, 1, strings.Count(out.String(), "Job run given up after all attempts"))
+}
@@ -0,0 +1,100 @@
+package service
+
+import (
+	"context"
+	"effective_mobile/internal/auth"
+	"effective_mobile/internal/objects"
+	"effective_mobile/internal/repository"
+	"effective_mobile/pkg/logger_module"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidAPIKey  = errors.New("invalid api key")
+	ErrAPIKeyNotFound = errors.New("api key not found")
+)
+
+// Сколько символов ключа показываем в списке
+const apiKeyVisiblePrefix = 9
+
+// Интерфейс для сервисного слоя API-ключей. Ключи всегда принадлежат вызывающему пользователю
+type APIKeyServiceI interface {
+	Create(ctx context.Context, req *objects.APIKeyCreateRequest) (*objects.APIKey, error)
+	List(ctx context.Context) ([]*objects.APIKey, error)
+	Revoke(ctx context.Context, id uuid.UUID) error
+}
+
+type APIKeyService struct {
+	rep    repository.APIKeyRepository
+	logger *logger_module.Logger
+}
+
+func NewAPIKeyService(rep repository.APIKeyRepository, logger *logger_module.Logger) APIKeyServiceI {
+	return &APIKeyService{rep: rep, logger: logger}
+}
+
+// Выпускаем ключ с ролями вызывающего; сам ключ возвращается только здесь, дальше по API он не отдается
+func (keyservice *APIKeyService) Create(ctx context.Context, req *objects.APIKeyCreateRequest) (*objects.APIKey, error) {
+	userID, err := principalUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	principal, _ := auth.FromContext(ctx)
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, fmt.Errorf("%w: name is required", ErrInvalidAPIKey)
+	}
+
+	raw, err := auth.GenerateAPIKey()
+	if err != nil {
+		return nil, err
+	}
+	key := &objects.APIKey{
+		ID:        uuid.New(),
+		UserID:    userID,
+		Name:      name,
+		Prefix:    raw[:apiKeyVisiblePrefix],
+		KeyHash:   auth.HashAPIKey(raw),
+		Roles:     make([]string, 0, len(principal.Roles)),
+		CreatedAt: time.Now().UTC(),
+	}
+	// Ключ не может дать больше прав, чем есть у выпустившего его
+	for _, role := range principal.Roles {
+		key.Roles = append(key.Roles, string(role))
+	}
+
+	keyservice.logger.Debug("Calling db layer for create api key", "user_id", userID)
+	if err := keyservice.rep.Create(ctx, key); err != nil {
+		return nil, err
+	}
+	key.Key = raw
+	return key, nil
+}
+
+func (keyservice *APIKeyService) List(ctx context.Context) ([]*objects.APIKey, error) {
+	userID, err := principalUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	keyservice.logger.Debug("Calling db layer for get api keys", "user_id", userID)
+	return keyservice.rep.ListByUser(ctx, userID)
+}
+
+func (keyservice *APIKeyService) Revoke(ctx context.Context, id uuid.UUID) error {
+	userID, err := principalUser(ctx)
+	if err != nil {
+		return err
+	}
+	keyservice.logger.Debug("Calling db layer for revoke api key", "user_id", userID)
+	err = keyservice.rep.Revoke(ctx, id, userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrAPIKeyNotFound
+	}
+	return err
+}
@@ -0,0 +1,116 @@
+package service
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// Кэш ответов сервисного слоя. Значение сохраняется с метками, по которым его сбрасывают
+// при изменении данных. Реализация по умолчанию — LRUCache в памяти процесса; общий для
+// нескольких реплик кэш (например, Redis с множеством ключей на метку) подключается
+// своей реализацией интерфейса
+type Cache interface {
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	Set(ctx context.Context, key string, value []byte, tags ...string) error
+	// Сбрасываем все значения, у которых есть хотя бы одна из меток
+	Invalidate(ctx context.Context, tags ...string) error
+}
+
+type lruEntry struct {
+	key       string
+	value     []byte
+	tags      []string
+	expiresAt time.Time
+}
+
+// Кэш в памяти процесса: не больше size значений, каждое живет ttl. При переполнении
+// вытесняется значение, которое дольше всех не читали
+type LRUCache struct {
+	mutex   sync.Mutex
+	size    int
+	ttl     time.Duration
+	now     func() time.Time
+	order   *list.List // от недавно прочитанных к давно прочитанным
+	entries map[string]*list.Element
+	tags    map[string]map[string]struct{} // метка -> ключи
+}
+
+func NewLRUCache(size int, ttl time.Duration) *LRUCache {
+	return &LRUCache{
+		size:    size,
+		ttl:     ttl,
+		now:     time.Now,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+		tags:    make(map[string]map[string]struct{}),
+	}
+}
+
+func (cache *LRUCache) Get(_ context.Context, key string) ([]byte, bool, error) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	element, ok := cache.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	entry := element.Value.(*lruEntry)
+	if !cache.now().Before(entry.expiresAt) {
+		cache.remove(element)
+		return nil, false, nil
+	}
+	cache.order.MoveToFront(element)
+	return entry.value, true, nil
+}
+
+func (cache *LRUCache) Set(_ context.Context, key string, value []byte, tags ...string) error {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	if element, ok := cache.entries[key]; ok {
+		cache.remove(element)
+	}
+	entry := &lruEntry{key: key, value: value, tags: tags, expiresAt: cache.now().Add(cache.ttl)}
+	cache.entries[key] = cache.order.PushFront(entry)
+	for _, tag := range tags {
+		keys, ok := cache.tags[tag]
+		if !ok {
+			keys = make(map[string]struct{})
+			cache.tags[tag] = keys
+		}
+		keys[key] = struct{}{}
+	}
+	for cache.order.Len() > cache.size {
+		cache.remove(cache.order.Back())
+	}
+	return nil
+}
+
+func (cache *LRUCache) Invalidate(_ context.Context, tags ...string) error {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	for _, tag := range tags {
+		for key := range cache.tags[tag] {
+			cache.remove(cache.entries[key])
+		}
+	}
+	return nil
+}
+
+func (cache *LRUCache) Len() int {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	return cache.order.Len()
+}
+
+// Удаляем значение вместе с его ключом в индексе меток
+func (cache *LRUCache) remove(element *list.Element) {
+	entry := cache.order.Remove(element).(*lruEntry)
+	delete(cache.entries, entry.key)
+	for _, tag := range entry.tags {
+		delete(cache.tags[tag], entry.key)
+		if len(cache.tags[tag]) == 0 {
+			delete(cache.tags, tag)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestLRUCache(size int, ttl time.Duration) (*LRUCache, *time.Time) {
+	now := time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)
+	cache := NewLRUCache(size, ttl)
+	cache.now = func() time.Time { return now }
+	return cache, &now
+}
+
+func TestLRUCache_EvictsLeastRecentlyRead(t *testing.T) {
+	ctx := context.Background()
+	cache, _ := newTestLRUCache(2, time.Minute)
+	require.NoError(t, cache.Set(ctx, "a", []byte("1")))
+	require.NoError(t, cache.Set(ctx, "b", []byte("2")))
+
+	// a прочитали последним, при переполнении вытесняется b
+	_, found, _ := cache.Get(ctx, "a")
+	assert.True(t, found)
+	require.NoError(t, cache.Set(ctx, "c", []byte("3")))
+	assert.Equal(t, 2, cache.Len())
+
+	_, found, _ = cache.Get(ctx, "b")
+	assert.False(t, found)
+	value, found, _ := cache.Get(ctx, "a")
+	assert.True(t, found)
+	assert.Equal(t, []byte("1"), value)
+}
+
+func TestLRUCache_ExpiresAfterTTL(t *testing.T) {
+	ctx := context.Background()
+	cache, now := newTestLRUCache(10, time.Minute)
+	require.NoError(t, cache.Set(ctx, "a", []byte("1")))
+
+	*now = now.Add(59 * time.Second)
+	_, found, _ := cache.Get(ctx, "a")
+	assert.True(t, found)
+
+	// Чтение не продлевает жизнь значения
+	*now = now.Add(time.Second)
+	_, found, _ = cache.Get(ctx, "a")
+	assert.False(t, found)
+	assert.Equal(t, 0, cache.Len())
+}
+
+func TestLRUCache_InvalidateByTag(t *testing.T) {
+	ctx := context.Background()
+	cache, _ := newTestLRUCache(10, time.Minute)
+	require.NoError(t, cache.Set(ctx, "a", []byte("1"), "user:1"))
+	require.NoError(t, cache.Set(ctx, "b", []byte("2"), "user:1", "service:netflix"))
+	require.NoError(t, cache.Set(ctx, "c", []byte("3"), "service:netflix"))
+	require.NoError(t, cache.Set(ctx, "d", []byte("4"), "user:2"))
+
+	require.NoError(t, cache.Invalidate(ctx, "user:1", "missing"))
+	for key, expected := range map[string]bool{"a": false, "b": false, "c": true, "d": true} {
+		_, found, _ := cache.Get(ctx, key)
+		assert.Equal(t, expected, found, key)
+	}
+
+	// Перезапись заменяет метки: старая метка значение больше не сбрасывает
+	require.NoError(t, cache.Set(ctx, "c", []byte("5"), "user:2"))
+	require.NoError(t, cache.Invalidate(ctx, "service:netflix"))
+	value, found, _ := cache.Get(ctx, "c")
+	assert.True(t, found)
+	assert.Equal(t, []byte("5"), value)
+
+	require.NoError(t, cache.Invalidate(ctx, "user:2"))
+	assert.Equal(t, 0, cache.Len())
+	assert.Empty(t, cache.tags)
+}
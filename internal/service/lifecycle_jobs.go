@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"effective_mobile/internal/events"
+	"effective_mobile/internal/repository"
+	"effective_mobile/pkg/logger_module"
+	"errors"
+	"time"
+)
+
+// За сколько предупреждаем о предстоящем списании и окончании пробного периода
+const NoticePeriod = 3 * 24 * time.Hour
+
+// Фоновые задачи по жизненному циклу подписок: находят наступившие события и публикуют их.
+// Каждая задача получает окно [from, to) и обрабатывает его целиком, поэтому одно событие
+// публикуется ровно в одном запуске
+type LifecycleJobs struct {
+	rep       repository.SubsctriptionRepository
+	publisher events.Publisher
+	logger    *logger_module.Logger
+}
+
+func NewLifecycleJobs(rep repository.SubsctriptionRepository, publisher events.Publisher, logger *logger_module.Logger) *LifecycleJobs {
+	return &LifecycleJobs{rep: rep, publisher: publisher, logger: logger}
+}
+
+// subscription.expired: подписки, у которых EndDate попал в окно
+func (jobs *LifecycleJobs) EmitExpired(ctx context.Context, from, to time.Time) error {
+	jobs.logger.Debug("Calling db layer for get expired subscriptions")
+	subscriptions, err := jobs.rep.GetExpiredBetween(ctx, from, to)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, sub := range subscriptions {
+		errs = append(errs, jobs.publisher.Publish(ctx, events.NewDue(events.SubscriptionExpired, sub, *sub.EndDate)))
+	}
+	jobs.logger.Info("Expired subscriptions processed", "count", len(subscriptions))
+	return errors.Join(errs...)
+}
+
+// subscription.renewal_due: списания, до которых осталось NoticePeriod
+func (jobs *LifecycleJobs) EmitRenewalDue(ctx context.Context, from, to time.Time) error {
+	from, to = from.Add(NoticePeriod), to.Add(NoticePeriod)
+
+	jobs.logger.Debug("Calling db layer for get active subscriptions")
+	subscriptions, err := jobs.rep.GetActiveBetween(ctx, from, to)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	count := 0
+	for _, sub := range subscriptions {
+		for _, date := range sub.RenewalDates(from, to) {
+			// Первое списание происходит при оформлении, о нем не предупреждаем
+			if date.Equal(sub.StartDate) {
+				continue
+			}
+			errs = append(errs, jobs.publisher.Publish(ctx, events.NewDue(events.SubscriptionRenewalDue, sub, date)))
+			count++
+		}
+	}
+	jobs.logger.Info("Renewal due subscriptions processed", "count", count)
+	return errors.Join(errs...)
+}
+
+// trial.ending: пробные периоды, до конца которых осталось NoticePeriod
+func (jobs *LifecycleJobs) EmitTrialEnding(ctx context.Context, from, to time.Time) error {
+	from, to = from.Add(NoticePeriod), to.Add(NoticePeriod)
+
+	jobs.logger.Debug("Calling db layer for get trials ending")
+	subscriptions, err := jobs.rep.GetTrialsEndingBetween(ctx, from, to)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, sub := range subscriptions {
+		errs = append(errs, jobs.publisher.Publish(ctx, events.NewDue(events.TrialEnding, sub, *sub.TrialEndDate)))
+	}
+	jobs.logger.Info("Ending trials processed", "count", len(subscriptions))
+	return errors.Join(errs...)
+}
@@ -0,0 +1,81 @@
+package service
+
+import (
+	"context"
+	"effective_mobile/internal/objects"
+	"effective_mobile/internal/repository"
+	"effective_mobile/pkg/logger_module"
+	"errors"
+	"fmt"
+	"net/mail"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidNotificationPreferences  = errors.New("invalid notification preferences")
+	ErrNotificationPreferencesNotFound = errors.New("notification preferences not found")
+)
+
+// Интерфейс для сервисного слоя настроек уведомлений
+type NotificationServiceI interface {
+	GetPreferences(ctx context.Context, userID uuid.UUID) (*objects.NotificationPreferences, error)
+	SavePreferences(ctx context.Context, userID uuid.UUID, req *objects.NotificationPreferencesRequest) (*objects.NotificationPreferences, error)
+}
+
+type NotificationService struct {
+	rep    repository.NotificationRepository
+	logger *logger_module.Logger
+}
+
+func NewNotificationService(rep repository.NotificationRepository, logger *logger_module.Logger) NotificationServiceI {
+	return &NotificationService{rep: rep, logger: logger}
+}
+
+func (notifyservice *NotificationService) GetPreferences(ctx context.Context, userID uuid.UUID) (*objects.NotificationPreferences, error) {
+	if err := authorizeUser(ctx, userID); err != nil {
+		return nil, err
+	}
+	notifyservice.logger.Debug("Calling db layer for get notification preferences")
+	prefs, err := notifyservice.rep.GetPreferences(ctx, userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotificationPreferencesNotFound
+	}
+	return prefs, err
+}
+
+// Сохраняем адрес и подписки на письма. Не переданные флаги остаются прежними,
+// для нового пользователя все письма включены
+func (notifyservice *NotificationService) SavePreferences(ctx context.Context, userID uuid.UUID, req *objects.NotificationPreferencesRequest) (*objects.NotificationPreferences, error) {
+	if err := authorizeUser(ctx, userID); err != nil {
+		return nil, err
+	}
+	address, err := mail.ParseAddress(req.Email)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid email", ErrInvalidNotificationPreferences)
+	}
+
+	prefs, err := notifyservice.GetPreferences(ctx, userID)
+	if errors.Is(err, ErrNotificationPreferencesNotFound) {
+		prefs = &objects.NotificationPreferences{UserID: userID, MonthlySummary: true, RenewalReminders: true}
+	} else if err != nil {
+		return nil, err
+	}
+
+	prefs.Email = address.Address
+	if req.MonthlySummary != nil {
+		prefs.MonthlySummary = *req.MonthlySummary
+	}
+	if req.RenewalReminders != nil {
+		prefs.RenewalReminders = *req.RenewalReminders
+	}
+	prefs.UpdatedAt = time.Now().UTC()
+
+	notifyservice.logger.Debug("Calling db layer for save notification preferences")
+	if err := notifyservice.rep.SavePreferences(ctx, prefs); err != nil {
+		return nil, err
+	}
+	return prefs, nil
+}
@@ -0,0 +1,62 @@
+package service
+
+import (
+	"context"
+	"effective_mobile/internal/auth"
+	"errors"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrUnauthenticated = errors.New("unauthenticated")
+	ErrForbidden       = errors.New("forbidden")
+)
+
+// Кто выполняет запрос (кладется в контекст middleware аутентификации или фоновыми задачами)
+func currentPrincipal(ctx context.Context) (*auth.Principal, error) {
+	principal, ok := auth.FromContext(ctx)
+	if !ok {
+		return nil, ErrUnauthenticated
+	}
+	return principal, nil
+}
+
+// Пользователь, от имени которого выполняется запрос
+func principalUser(ctx context.Context) (uuid.UUID, error) {
+	principal, err := currentPrincipal(ctx)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if principal.UserID == uuid.Nil {
+		return uuid.Nil, ErrForbidden
+	}
+	return principal.UserID, nil
+}
+
+// Вызывающий должен иметь одну из ролей
+func requireRole(ctx context.Context, roles ...auth.Role) error {
+	principal, err := currentPrincipal(ctx)
+	if err != nil {
+		return err
+	}
+	if !principal.HasRole(roles...) {
+		return ErrForbidden
+	}
+	return nil
+}
+
+// Данные пользователя userID доступны ему самому и администратору
+func authorizeUser(ctx context.Context, userID uuid.UUID) error {
+	principal, err := currentPrincipal(ctx)
+	if err != nil {
+		return err
+	}
+	if principal.HasRole(auth.RoleAdmin) {
+		return nil
+	}
+	if len(principal.Roles) > 0 && principal.UserID != uuid.Nil && principal.UserID == userID {
+		return nil
+	}
+	return ErrForbidden
+}
@@ -2,14 +2,23 @@ package service
 
 import (
 	"context"
+	"effective_mobile/internal/auth"
+	"effective_mobile/internal/events"
 	"effective_mobile/internal/objects"
 	"effective_mobile/internal/repository"
+	"effective_mobile/internal/tracing"
 	"effective_mobile/pkg/logger_module"
+	"errors"
+	"fmt"
+	"sort"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+// Неверные данные подписки, API отвечает 400
+var ErrInvalidSubscription = errors.New("invalid subscription")
+
 // Интерфейс для сервисного слоя
 type SubscriptionServiceI interface {
 	Create(ctx context.Context, sub *objects.Subscription) error
@@ -18,46 +27,109 @@ type SubscriptionServiceI interface {
 	Delete(ctx context.Context, id uuid.UUID) error
 	Get_List(ctx context.Context, limit, offset int) ([]*objects.Subscription, error)
 	GetTotalCost(ctx context.Context, userID uuid.UUID, serviceName string, start, end time.Time) (int, error)
+	GetRenewals(ctx context.Context, userID uuid.UUID, from, to time.Time) ([]objects.Renewal, error)
 }
 
 type SubscriptionService struct {
-	rep    repository.SubsctriptionRepository // принимает обьект удовлетворяющий указанному interface, тут мы используем GormRepo
-	logger *logger_module.Logger
+	rep      repository.SubsctriptionRepository // принимает обьект удовлетворяющий указанному interface, тут мы используем GormRepo
+	notifier events.Notifier                    // будит рассылку вебхуков после изменений, может быть nil
+	cache    Cache                              // суммы GetTotalCost, сбрасываются при изменениях; может быть nil
+	logger   *logger_module.Logger
+}
+
+func NewSubciptionService(rep repository.SubsctriptionRepository, notifier events.Notifier, cache Cache, logger *logger_module.Logger) SubscriptionServiceI {
+	return &SubscriptionService{rep: rep, notifier: notifier, cache: cache, logger: logger}
 }
 
-func NewSubciptionService(rep repository.SubsctriptionRepository, logger *logger_module.Logger) SubscriptionServiceI {
-	return &SubscriptionService{rep: rep, logger: logger}
+// Событие об изменении уже записано репозиторием в outbox в той же транзакции,
+// здесь только запускаем его рассылку не дожидаясь очередного опроса
+func (subservice *SubscriptionService) notify() {
+	if subservice.notifier != nil {
+		subservice.notifier.Notify()
+	}
 }
 
 func (subservice *SubscriptionService) Create(ctx context.Context, sub *objects.Subscription) error {
+	ctx, span := tracing.Start(ctx, "SubscriptionService.Create")
+	defer span.End()
+	if err := authorizeUser(ctx, sub.UserID); err != nil {
+		return err
+	}
 	if sub.Price <= 0 {
-		subservice.logger.Fatal("price must be positive")
+		return fmt.Errorf("%w: price must be positive", ErrInvalidSubscription)
 	}
 	if sub.ServiceName == "" {
-		subservice.logger.Fatal("service name is required")
+		return fmt.Errorf("%w: service name is required", ErrInvalidSubscription)
 	}
 	subservice.logger.Debug("Calling db layer for create subscription")
-	return subservice.rep.Create(ctx, sub)
+	if err := subservice.rep.Create(ctx, sub); err != nil {
+		return err
+	}
+	subservice.invalidateTotals(ctx, sub)
+	subservice.notify()
+	return nil
 }
 func (subservice *SubscriptionService) GetByID(ctx context.Context, id uuid.UUID) (*objects.Subscription, error) {
+	ctx, span := tracing.Start(ctx, "SubscriptionService.GetByID")
+	defer span.End()
 	subservice.logger.Debug("Calling db layer for get subscription by id")
-	return subservice.rep.GetByID(ctx, id)
+	sub, err := subservice.rep.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := authorizeUser(ctx, sub.UserID); err != nil {
+		return nil, err
+	}
+	return sub, nil
 }
 
 func (subservice *SubscriptionService) Update(ctx context.Context, id uuid.UUID, fields map[string]interface{}) error {
+	ctx, span := tracing.Start(ctx, "SubscriptionService.Update")
+	defer span.End()
+	// Проверяем владельца; user_id не обновляется, поэтому проверка остается верной и для самого обновления
+	before, err := subservice.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
 	if price, ok := fields["price"].(int); ok && price <= 0 {
-		subservice.logger.Fatal("price must be positive")
+		return fmt.Errorf("%w: price must be positive", ErrInvalidSubscription)
+	}
+	if serviceName, ok := fields["service_name"].(string); ok && serviceName == "" {
+		return fmt.Errorf("%w: service name is required", ErrInvalidSubscription)
 	}
 	subservice.logger.Debug("Calling db layer for update subscription by fields")
-	return subservice.rep.Update(ctx, id, fields)
+	if err := subservice.rep.Update(ctx, id, fields); err != nil {
+		return err
+	}
+	// При смене сервиса подписка переходит в суммы нового сервиса
+	after := *before
+	if serviceName, ok := fields["service_name"].(string); ok {
+		after.ServiceName = serviceName
+	}
+	subservice.invalidateTotals(ctx, before, &after)
+	subservice.notify()
+	return nil
 }
 
 func (subservice *SubscriptionService) Delete(ctx context.Context, id uuid.UUID) error {
+	ctx, span := tracing.Start(ctx, "SubscriptionService.Delete")
+	defer span.End()
+	sub, err := subservice.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
 	subservice.logger.Debug("Calling db layer for delete subscription by id")
-	return subservice.rep.Delete(ctx, id)
+	if err := subservice.rep.Delete(ctx, id); err != nil {
+		return err
+	}
+	subservice.invalidateTotals(ctx, sub)
+	subservice.notify()
+	return nil
 }
 
 func (subservice *SubscriptionService) Get_List(ctx context.Context, limit, offset int) ([]*objects.Subscription, error) {
+	ctx, span := tracing.Start(ctx, "SubscriptionService.Get_List")
+	defer span.End()
 	// Устанавливаем дефолтные значения
 	subservice.logger.Info("Install default value for limit,offset")
 
@@ -67,11 +139,73 @@ func (subservice *SubscriptionService) Get_List(ctx context.Context, limit, offs
 	if offset < 0 {
 		offset = 0
 	}
-	subservice.logger.Debug("Calling db layer for get all subscriptions")
-	return subservice.rep.Get_List(ctx, limit, offset)
+	principal, err := currentPrincipal(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if principal.HasRole(auth.RoleAdmin) {
+		subservice.logger.Debug("Calling db layer for get all subscriptions")
+		return subservice.rep.Get_List(ctx, limit, offset)
+	}
+
+	// Остальные видят только свои подписки
+	if err := authorizeUser(ctx, principal.UserID); err != nil {
+		return nil, err
+	}
+	subservice.logger.Debug("Calling db layer for get user subscriptions", "user_id", principal.UserID)
+	return subservice.rep.Get_List_By_User(ctx, principal.UserID, limit, offset)
 }
 
 func (subservice *SubscriptionService) GetTotalCost(ctx context.Context, userID uuid.UUID, serviceName string, start, end time.Time) (int, error) {
+	ctx, span := tracing.Start(ctx, "SubscriptionService.GetTotalCost")
+	defer span.End()
+	principal, err := currentPrincipal(ctx)
+	if err != nil {
+		return 0, err
+	}
+	// Суммы по всем пользователям доступны финансам и администраторам, остальным — только свои
+	if !principal.HasRole(auth.RoleAdmin, auth.RoleFinance) {
+		if userID == uuid.Nil {
+			userID = principal.UserID
+		}
+		if err := authorizeUser(ctx, userID); err != nil {
+			return 0, err
+		}
+	}
 	subservice.logger.Debug("Calling db layer for get total cost subscriptions")
-	return subservice.rep.GetTotalCost(ctx, userID, serviceName, start, end)
+	return subservice.cachedTotalCost(ctx, userID, serviceName, start, end)
+}
+
+// Собираем все списания по подпискам пользователя в полуинтервале [from, to), отсортированные по дате
+func (subservice *SubscriptionService) GetRenewals(ctx context.Context, userID uuid.UUID, from, to time.Time) ([]objects.Renewal, error) {
+	ctx, span := tracing.Start(ctx, "SubscriptionService.GetRenewals")
+	defer span.End()
+	if err := authorizeUser(ctx, userID); err != nil {
+		return nil, err
+	}
+	subservice.logger.Debug("Calling db layer for get user subscriptions", "user_id", userID)
+	subscriptions, err := subservice.rep.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	renewals := make([]objects.Renewal, 0)
+	for _, sub := range subscriptions {
+		for _, date := range sub.RenewalDates(from, to) {
+			renewals = append(renewals, objects.Renewal{
+				SubscriptionID: sub.ID,
+				ServiceName:    sub.ServiceName,
+				Price:          sub.Price,
+				Date:           date,
+			})
+		}
+	}
+
+	sort.SliceStable(renewals, func(i, j int) bool {
+		if !renewals[i].Date.Equal(renewals[j].Date) {
+			return renewals[i].Date.Before(renewals[j].Date)
+		}
+		return renewals[i].ServiceName < renewals[j].ServiceName
+	})
+	return renewals, nil
 }
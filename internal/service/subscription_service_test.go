@@ -0,0 +1,332 @@
+package service
+
+import (
+	"context"
+	"effective_mobile/internal/auth"
+	"effective_mobile/internal/objects"
+	"effective_mobile/internal/repository"
+	"effective_mobile/internal/tenant"
+	"effective_mobile/pkg/logger_module"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+type MockSubscriptionRepository struct {
+	mock.Mock
+}
+
+func (m *MockSubscriptionRepository) Create(ctx context.Context, sub *objects.Subscription) error {
+	return m.Called(ctx, sub).Error(0)
+}
+
+func (m *MockSubscriptionRepository) GetByID(ctx context.Context, id uuid.UUID) (*objects.Subscription, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*objects.Subscription), args.Error(1)
+}
+
+func (m *MockSubscriptionRepository) Update(ctx context.Context, id uuid.UUID, fields map[string]interface{}) error {
+	return m.Called(ctx, id, fields).Error(0)
+}
+
+func (m *MockSubscriptionRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	return m.Called(ctx, id).Error(0)
+}
+
+func (m *MockSubscriptionRepository) Get_List(ctx context.Context, limit, offset int) ([]*objects.Subscription, error) {
+	args := m.Called(ctx, limit, offset)
+	return args.Get(0).([]*objects.Subscription), args.Error(1)
+}
+
+func (m *MockSubscriptionRepository) Get_List_By_User(ctx context.Context, userID uuid.UUID, limit, offset int) ([]*objects.Subscription, error) {
+	args := m.Called(ctx, userID, limit, offset)
+	return args.Get(0).([]*objects.Subscription), args.Error(1)
+}
+
+func (m *MockSubscriptionRepository) GetByUserID(ctx context.Context, userID uuid.UUID) ([]*objects.Subscription, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).([]*objects.Subscription), args.Error(1)
+}
+
+func (m *MockSubscriptionRepository) GetExpiredBetween(ctx context.Context, from, to time.Time) ([]*objects.Subscription, error) {
+	args := m.Called(ctx, from, to)
+	return args.Get(0).([]*objects.Subscription), args.Error(1)
+}
+
+func (m *MockSubscriptionRepository) GetActiveBetween(ctx context.Context, from, to time.Time) ([]*objects.Subscription, error) {
+	args := m.Called(ctx, from, to)
+	return args.Get(0).([]*objects.Subscription), args.Error(1)
+}
+
+func (m *MockSubscriptionRepository) GetTrialsEndingBetween(ctx context.Context, from, to time.Time) ([]*objects.Subscription, error) {
+	args := m.Called(ctx, from, to)
+	return args.Get(0).([]*objects.Subscription), args.Error(1)
+}
+
+func (m *MockSubscriptionRepository) CountActive(ctx context.Context, at time.Time) (int64, error) {
+	args := m.Called(ctx, at)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockSubscriptionRepository) GetTotalCost(ctx context.Context, userID uuid.UUID, serviceName string, start, end time.Time) (int, error) {
+	args := m.Called(ctx, userID, serviceName, start, end)
+	return args.Int(0), args.Error(1)
+}
+
+func asRole(userID uuid.UUID, roles ...auth.Role) context.Context {
+	return auth.NewContext(context.Background(), &auth.Principal{Subject: userID.String(), UserID: userID, Roles: roles})
+}
+
+func TestSubscriptionService_UserSeesOnlyOwnSubscriptions(t *testing.T) {
+	mockRepo := new(MockSubscriptionRepository)
+	subService := NewSubciptionService(mockRepo, nil, nil, logger_module.Get())
+
+	owner, stranger := uuid.New(), uuid.New()
+	sub := &objects.Subscription{ID: uuid.New(), UserID: owner, ServiceName: "Netflix", Price: 599}
+	mockRepo.On("GetByID", mock.Anything, sub.ID).Return(sub, nil)
+	mockRepo.On("Delete", mock.Anything, sub.ID).Return(nil)
+
+	// Владелец видит подписку, чужой пользователь — нет
+	got, err := subService.GetByID(asRole(owner, auth.RoleUser), sub.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, sub.ID, got.ID)
+
+	_, err = subService.GetByID(asRole(stranger, auth.RoleUser), sub.ID)
+	assert.ErrorIs(t, err, ErrForbidden)
+
+	// Чужой пользователь не может удалить и изменить подписку, репозиторий не вызывается
+	assert.ErrorIs(t, subService.Delete(asRole(stranger, auth.RoleUser), sub.ID), ErrForbidden)
+	assert.ErrorIs(t, subService.Update(asRole(stranger, auth.RoleFinance), sub.ID, map[string]interface{}{"price": 1}), ErrForbidden)
+	mockRepo.AssertNotCalled(t, "Delete", mock.Anything, sub.ID)
+	mockRepo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything, mock.Anything)
+
+	// Администратор может все
+	assert.NoError(t, subService.Delete(asRole(uuid.New(), auth.RoleAdmin), sub.ID))
+
+	// Нельзя создать подписку от имени другого пользователя
+	err = subService.Create(asRole(stranger, auth.RoleUser), &objects.Subscription{UserID: owner, ServiceName: "Netflix", Price: 599})
+	assert.ErrorIs(t, err, ErrForbidden)
+
+	// Без аутентификации
+	_, err = subService.GetByID(context.Background(), sub.ID)
+	assert.ErrorIs(t, err, ErrUnauthenticated)
+}
+
+// Неверные данные — ошибка для ответа 400, а не остановка процесса; чужая подписка — 403 до проверки данных
+func TestSubscriptionService_InvalidSubscription(t *testing.T) {
+	mockRepo := new(MockSubscriptionRepository)
+	subService := NewSubciptionService(mockRepo, nil, nil, logger_module.Get())
+
+	owner, stranger := uuid.New(), uuid.New()
+	sub := &objects.Subscription{ID: uuid.New(), UserID: owner, ServiceName: "Netflix", Price: 599}
+	mockRepo.On("GetByID", mock.Anything, sub.ID).Return(sub, nil)
+
+	err := subService.Create(asRole(owner, auth.RoleUser), &objects.Subscription{UserID: owner, ServiceName: "Netflix"})
+	assert.ErrorIs(t, err, ErrInvalidSubscription)
+	err = subService.Create(asRole(owner, auth.RoleUser), &objects.Subscription{UserID: owner, Price: 599})
+	assert.ErrorIs(t, err, ErrInvalidSubscription)
+	err = subService.Create(asRole(stranger, auth.RoleUser), &objects.Subscription{UserID: owner, Price: 0})
+	assert.ErrorIs(t, err, ErrForbidden)
+
+	for _, fields := range []map[string]interface{}{{"price": 0}, {"price": -1}, {"service_name": ""}} {
+		assert.ErrorIs(t, subService.Update(asRole(owner, auth.RoleUser), sub.ID, fields), ErrInvalidSubscription)
+		assert.ErrorIs(t, subService.Update(asRole(stranger, auth.RoleUser), sub.ID, fields), ErrForbidden)
+	}
+	mockRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+	mockRepo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestSubscriptionService_ListScopedToUser(t *testing.T) {
+	mockRepo := new(MockSubscriptionRepository)
+	subService := NewSubciptionService(mockRepo, nil, nil, logger_module.Get())
+
+	userID := uuid.New()
+	own := []*objects.Subscription{{ID: uuid.New(), UserID: userID}}
+	all := []*objects.Subscription{own[0], {ID: uuid.New(), UserID: uuid.New()}}
+	mockRepo.On("Get_List_By_User", mock.Anything, userID, 10, 0).Return(own, nil)
+	mockRepo.On("Get_List", mock.Anything, 10, 0).Return(all, nil)
+
+	list, err := subService.Get_List(asRole(userID, auth.RoleUser), 10, 0)
+	assert.NoError(t, err)
+	assert.Len(t, list, 1)
+
+	list, err = subService.Get_List(asRole(uuid.New(), auth.RoleAdmin), 10, 0)
+	assert.NoError(t, err)
+	assert.Len(t, list, 2)
+
+	// Сервисный токен без ролей ничего не видит
+	_, err = subService.Get_List(auth.NewContext(context.Background(), &auth.Principal{Subject: "exporter"}), 10, 0)
+	assert.ErrorIs(t, err, ErrForbidden)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestSubscriptionService_TotalCostAcrossUsers(t *testing.T) {
+	mockRepo := new(MockSubscriptionRepository)
+	subService := NewSubciptionService(mockRepo, nil, nil, logger_module.Get())
+
+	userID := uuid.New()
+	start := time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2025, time.December, 1, 0, 0, 0, 0, time.UTC)
+	mockRepo.On("GetTotalCost", mock.Anything, uuid.Nil, "", start, end).Return(100000, nil)
+	mockRepo.On("GetTotalCost", mock.Anything, userID, "", start, end).Return(599, nil)
+
+	// Финансы и администратор считают по всем пользователям
+	total, err := subService.GetTotalCost(asRole(uuid.New(), auth.RoleFinance), uuid.Nil, "", start, end)
+	assert.NoError(t, err)
+	assert.Equal(t, 100000, total)
+
+	// Пользователь без user_id получает сумму по своим подпискам, по чужим — 403
+	total, err = subService.GetTotalCost(asRole(userID, auth.RoleUser), uuid.Nil, "", start, end)
+	assert.NoError(t, err)
+	assert.Equal(t, 599, total)
+
+	_, err = subService.GetTotalCost(asRole(uuid.New(), auth.RoleUser), userID, "", start, end)
+	assert.ErrorIs(t, err, ErrForbidden)
+	mockRepo.AssertExpectations(t)
+}
+
+// Сервис поверх хранилища в памяти: права, изменение и сумма проверяются на настоящих данных
+func TestSubscriptionService_WithMemoryRepo(t *testing.T) {
+	subService := NewSubciptionService(repository.NewMemoryRepo(), nil, nil, logger_module.Get())
+
+	owner := uuid.New()
+	ownerCtx := tenant.NewContext(asRole(owner, auth.RoleUser), "acme")
+	sub := &objects.Subscription{UserID: owner, ServiceName: "Netflix", Price: 599, StartDate: time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)}
+	assert.NoError(t, subService.Create(ownerCtx, sub))
+
+	assert.NoError(t, subService.Update(ownerCtx, sub.ID, map[string]interface{}{"price": 799}))
+	got, err := subService.GetByID(ownerCtx, sub.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, 799, got.Price)
+
+	strangerCtx := tenant.NewContext(asRole(uuid.New(), auth.RoleUser), "acme")
+	assert.ErrorIs(t, subService.Delete(strangerCtx, sub.ID), ErrForbidden)
+
+	total, err := subService.GetTotalCost(ownerCtx, uuid.Nil, "", sub.StartDate, sub.StartDate.AddDate(1, 0, 0))
+	assert.NoError(t, err)
+	assert.Equal(t, 799, total)
+
+	// В другой организации подписки не видно
+	_, err = subService.GetByID(tenant.NewContext(asRole(owner, auth.RoleUser), "globex"), sub.ID)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+}
+
+func TestSubscriptionService_RenewalsIncludeEndMonth(t *testing.T) {
+	subService := NewSubciptionService(repository.NewMemoryRepo(), nil, nil, logger_module.Get())
+
+	owner := uuid.New()
+	ownerCtx := tenant.NewContext(asRole(owner, auth.RoleUser), "acme")
+	january := time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)
+	june := time.Date(2025, time.June, 1, 0, 0, 0, 0, time.UTC)
+	sub := &objects.Subscription{UserID: owner, ServiceName: "Netflix", Price: 599, StartDate: january, EndDate: &june}
+	assert.NoError(t, subService.Create(ownerCtx, sub))
+
+	// Подписка до 06-2025 оплачивается в июне, как и в сумме за 06-2025 - 06-2025
+	renewals, err := subService.GetRenewals(ownerCtx, owner, june, june.AddDate(0, 1, 0))
+	assert.NoError(t, err)
+	total, err := subService.GetTotalCost(ownerCtx, owner, "", june, june)
+	assert.NoError(t, err)
+	require.Equal(t, []objects.Renewal{{SubscriptionID: sub.ID, ServiceName: "Netflix", Price: 599, Date: june}}, renewals)
+	assert.Equal(t, total, renewals[0].Price)
+
+	// Всего шесть списаний с января по июнь, после июня списаний нет
+	renewals, err = subService.GetRenewals(ownerCtx, owner, january, january.AddDate(1, 0, 0))
+	assert.NoError(t, err)
+	assert.Len(t, renewals, 6)
+}
+
+func TestSubscriptionService_CachesTotalCost(t *testing.T) {
+	mockRepo := new(MockSubscriptionRepository)
+	subService := NewSubciptionService(mockRepo, nil, NewLRUCache(100, time.Minute), logger_module.Get())
+
+	userID := uuid.New()
+	start := time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2025, time.December, 1, 0, 0, 0, 0, time.UTC)
+	mockRepo.On("GetTotalCost", mock.Anything, userID, "", start, end).Return(599, nil).Once()
+	mockRepo.On("GetTotalCost", mock.Anything, userID, "", start, end).Return(799, nil).Once()
+
+	// Без user_id и с собственным user_id — один и тот же запрос, репозиторий вызывается один раз
+	acme := tenant.NewContext(asRole(userID, auth.RoleUser), "acme")
+	for _, filter := range []uuid.UUID{uuid.Nil, userID} {
+		total, err := subService.GetTotalCost(acme, filter, "", start, end)
+		assert.NoError(t, err)
+		assert.Equal(t, 599, total)
+	}
+	// Те же даты в другом часовом поясе — тот же ключ
+	moscow := time.FixedZone("MSK", 3*60*60)
+	total, err := subService.GetTotalCost(acme, uuid.Nil, "", start.In(moscow), end.In(moscow))
+	assert.NoError(t, err)
+	assert.Equal(t, 599, total)
+
+	// Изменение подписки пользователя сбрасывает его суммы
+	sub := &objects.Subscription{ID: uuid.New(), UserID: userID, ServiceName: "Netflix", Price: 599}
+	mockRepo.On("GetByID", mock.Anything, sub.ID).Return(sub, nil)
+	mockRepo.On("Update", mock.Anything, sub.ID, mock.Anything).Return(nil)
+	assert.NoError(t, subService.Update(acme, sub.ID, map[string]interface{}{"price": 799}))
+	total, err = subService.GetTotalCost(acme, uuid.Nil, "", start, end)
+	assert.NoError(t, err)
+	assert.Equal(t, 799, total)
+	mockRepo.AssertExpectations(t)
+
+	// Без тенанта и для фоновых задач по всем организациям кэш не используется
+	mockRepo.On("GetTotalCost", mock.Anything, uuid.Nil, "", start, end).Return(100000, nil).Twice()
+	admin := asRole(uuid.New(), auth.RoleAdmin)
+	for _, ctx := range []context.Context{admin, tenant.WithAllTenants(admin)} {
+		total, err = subService.GetTotalCost(ctx, uuid.Nil, "", start, end)
+		assert.NoError(t, err)
+		assert.Equal(t, 100000, total)
+	}
+	mockRepo.AssertExpectations(t)
+}
+
+// Суммы из кэша совпадают с суммами хранилища после любых изменений через сервис
+func TestSubscriptionService_CachedTotalsInvalidatedOnMutations(t *testing.T) {
+	subService := NewSubciptionService(repository.NewMemoryRepo(), nil, NewLRUCache(100, time.Hour), logger_module.Get())
+
+	owner, other := uuid.New(), uuid.New()
+	ownerCtx := tenant.NewContext(asRole(owner, auth.RoleUser), "acme")
+	otherCtx := tenant.NewContext(asRole(other, auth.RoleUser), "acme")
+	financeCtx := tenant.NewContext(asRole(uuid.New(), auth.RoleFinance), "acme")
+	start := time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(1, 0, 0)
+
+	assertTotals := func(all, netflix, spotify, own int) {
+		t.Helper()
+		for _, check := range []struct {
+			ctx         context.Context
+			serviceName string
+			expected    int
+		}{
+			{financeCtx, "", all},
+			{financeCtx, "Netflix", netflix},
+			{financeCtx, "Spotify", spotify},
+			{ownerCtx, "", own},
+		} {
+			total, err := subService.GetTotalCost(check.ctx, uuid.Nil, check.serviceName, start, end)
+			assert.NoError(t, err)
+			assert.Equal(t, check.expected, total, check.serviceName)
+		}
+	}
+
+	sub := &objects.Subscription{UserID: owner, ServiceName: "Netflix", Price: 599, StartDate: start}
+	assert.NoError(t, subService.Create(ownerCtx, sub))
+	assertTotals(599, 599, 0, 599)
+
+	assert.NoError(t, subService.Create(otherCtx, &objects.Subscription{UserID: other, ServiceName: "Spotify", Price: 199, StartDate: start}))
+	assertTotals(798, 599, 199, 599)
+
+	// Смена сервиса сбрасывает суммы и старого, и нового сервиса
+	assert.NoError(t, subService.Update(ownerCtx, sub.ID, map[string]interface{}{"service_name": "Spotify", "price": 299}))
+	assertTotals(498, 0, 498, 299)
+
+	assert.NoError(t, subService.Delete(ownerCtx, sub.ID))
+	assertTotals(199, 0, 199, 0)
+}
@@ -0,0 +1,103 @@
+package service
+
+import (
+	"context"
+	"effective_mobile/internal/objects"
+	"effective_mobile/internal/tenant"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Метки сумм в кэше. Сумма с фильтром по пользователю сбрасывается при изменении любой его подписки,
+// сумма только по сервису — при изменении подписки на этот сервис, сумма без фильтров — при любом изменении.
+// Сумма по пользователю и другому сервису сбрасывается лишний раз, зато меток у значения всегда одна
+func totalCacheTag(tenantID string, userID uuid.UUID, serviceName string) string {
+	switch {
+	case userID != uuid.Nil:
+		return "total:" + tenantID + ":user:" + userID.String()
+	case serviceName != "":
+		return "total:" + tenantID + ":service:" + url.QueryEscape(serviceName)
+	default:
+		return "total:" + tenantID + ":all"
+	}
+}
+
+// Метки сумм, в которые попадает подписка
+func subscriptionCacheTags(tenantID string, subscription *objects.Subscription) []string {
+	return []string{
+		totalCacheTag(tenantID, subscription.UserID, ""),
+		totalCacheTag(tenantID, uuid.Nil, subscription.ServiceName),
+		totalCacheTag(tenantID, uuid.Nil, ""),
+	}
+}
+
+// Ключ суммы: параметры запроса после проверки прав (user_id уже подставлен для обычного пользователя),
+// даты в UTC, параметры по алфавиту
+func totalCacheKey(tenantID string, userID uuid.UUID, serviceName string, start, end time.Time) string {
+	params := url.Values{}
+	if userID != uuid.Nil {
+		params.Set("user_id", userID.String())
+	}
+	if serviceName != "" {
+		params.Set("service_name", serviceName)
+	}
+	params.Set("start", start.UTC().Format(time.RFC3339))
+	params.Set("end", end.UTC().Format(time.RFC3339))
+	return "total:" + tenantID + "?" + params.Encode()
+}
+
+// Кэш работает только для запросов одной организации: фоновые задачи по всем организациям
+// и запросы без тенанта идут в репозиторий
+func (subservice *SubscriptionService) cacheTenant(ctx context.Context) (string, bool) {
+	if subservice.cache == nil || tenant.IsAllTenants(ctx) {
+		return "", false
+	}
+	return tenant.FromContext(ctx)
+}
+
+// Сумма из кэша или из репозитория с сохранением в кэш. Ошибки кэша не ломают запрос
+func (subservice *SubscriptionService) cachedTotalCost(ctx context.Context, userID uuid.UUID, serviceName string, start, end time.Time) (int, error) {
+	tenantID, ok := subservice.cacheTenant(ctx)
+	if !ok {
+		return subservice.rep.GetTotalCost(ctx, userID, serviceName, start, end)
+	}
+	key := totalCacheKey(tenantID, userID, serviceName, start, end)
+	value, found, err := subservice.cache.Get(ctx, key)
+	if err != nil {
+		subservice.logger.Warn("Failed to read total cost from cache", "error", err)
+	}
+	if found {
+		if total, err := strconv.Atoi(string(value)); err == nil {
+			subservice.logger.Debug("Total cost found in cache", "key", key)
+			return total, nil
+		}
+	}
+
+	total, err := subservice.rep.GetTotalCost(ctx, userID, serviceName, start, end)
+	if err != nil {
+		return 0, err
+	}
+	if err := subservice.cache.Set(ctx, key, []byte(strconv.Itoa(total)), totalCacheTag(tenantID, userID, serviceName)); err != nil {
+		subservice.logger.Warn("Failed to save total cost to cache", "error", err)
+	}
+	return total, nil
+}
+
+// Сбрасываем суммы, в которые попадают подписки (до и после изменения)
+func (subservice *SubscriptionService) invalidateTotals(ctx context.Context, subscriptions ...*objects.Subscription) {
+	tenantID, ok := subservice.cacheTenant(ctx)
+	if !ok {
+		return
+	}
+	var tags []string
+	for _, subscription := range subscriptions {
+		tags = append(tags, subscriptionCacheTags(tenantID, subscription)...)
+	}
+	if err := subservice.cache.Invalidate(ctx, tags...); err != nil {
+		// Устаревшая сумма продержится не дольше времени жизни значения в кэше
+		subservice.logger.Error("Failed to invalidate cached totals", "error", err)
+	}
+}
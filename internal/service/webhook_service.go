@@ -0,0 +1,158 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"effective_mobile/internal/auth"
+	"effective_mobile/internal/events"
+	"effective_mobile/internal/objects"
+	"effective_mobile/internal/repository"
+	"effective_mobile/internal/webhooks"
+	"effective_mobile/pkg/logger_module"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidWebhook  = errors.New("invalid webhook")
+	ErrWebhookNotFound = errors.New("webhook not found")
+)
+
+// Интерфейс для сервисного слоя вебхуков. Вебхуки получают события всех пользователей,
+// поэтому управлять ими может только администратор
+type WebhookServiceI interface {
+	Create(ctx context.Context, req *objects.WebhookCreateRequest) (*objects.Webhook, error)
+	List(ctx context.Context) ([]*objects.Webhook, error)
+	GetByID(ctx context.Context, id uuid.UUID) (*objects.Webhook, error)
+	Delete(ctx context.Context, id uuid.UUID) error
+	ListDeliveries(ctx context.Context, webhookID uuid.UUID, status string, limit, offset int) ([]*objects.WebhookDelivery, error)
+}
+
+type WebhookService struct {
+	rep    repository.WebhookRepository
+	policy *webhooks.AddressPolicy
+	logger *logger_module.Logger
+}
+
+func NewWebhookService(rep repository.WebhookRepository, policy *webhooks.AddressPolicy, logger *logger_module.Logger) WebhookServiceI {
+	return &WebhookService{rep: rep, policy: policy, logger: logger}
+}
+
+// Проверяем адрес и типы событий, секрет генерируем если не передан.
+// Секрет возвращается только здесь, дальше по API он не отдается
+func (webservice *WebhookService) Create(ctx context.Context, req *objects.WebhookCreateRequest) (*objects.Webhook, error) {
+	if err := requireRole(ctx, auth.RoleAdmin); err != nil {
+		return nil, err
+	}
+	target, err := url.Parse(req.URL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return nil, fmt.Errorf("%w: url must be absolute http(s) url", ErrInvalidWebhook)
+	}
+	// Адрес проверяется и при каждой доставке, здесь — чтобы сразу сообщить об ошибке
+	if err := webservice.policy.CheckURL(ctx, req.URL); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidWebhook, err)
+	}
+	for _, eventType := range req.EventTypes {
+		if !events.IsKnown(events.Type(eventType)) {
+			return nil, fmt.Errorf("%w: unknown event type %q", ErrInvalidWebhook, eventType)
+		}
+	}
+
+	secret := req.Secret
+	if secret == "" {
+		random := make([]byte, 32)
+		if _, err := rand.Read(random); err != nil {
+			return nil, err
+		}
+		secret = hex.EncodeToString(random)
+	}
+
+	eventTypes := req.EventTypes
+	if eventTypes == nil {
+		eventTypes = []string{}
+	}
+	hook := &objects.Webhook{
+		ID:         uuid.New(),
+		URL:        req.URL,
+		Secret:     secret,
+		EventTypes: eventTypes,
+		Active:     true,
+		CreatedAt:  time.Now().UTC(),
+	}
+
+	webservice.logger.Debug("Calling db layer for create webhook")
+	if err := webservice.rep.Create(ctx, hook); err != nil {
+		return nil, err
+	}
+	return hook, nil
+}
+
+func (webservice *WebhookService) List(ctx context.Context) ([]*objects.Webhook, error) {
+	if err := requireRole(ctx, auth.RoleAdmin); err != nil {
+		return nil, err
+	}
+	webservice.logger.Debug("Calling db layer for get list webhooks")
+	hooks, err := webservice.rep.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, hook := range hooks {
+		hook.Secret = ""
+	}
+	return hooks, nil
+}
+
+func (webservice *WebhookService) GetByID(ctx context.Context, id uuid.UUID) (*objects.Webhook, error) {
+	if err := requireRole(ctx, auth.RoleAdmin); err != nil {
+		return nil, err
+	}
+	webservice.logger.Debug("Calling db layer for get webhook by id")
+	hook, err := webservice.rep.GetByID(ctx, id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrWebhookNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	hook.Secret = ""
+	return hook, nil
+}
+
+func (webservice *WebhookService) Delete(ctx context.Context, id uuid.UUID) error {
+	if err := requireRole(ctx, auth.RoleAdmin); err != nil {
+		return err
+	}
+	webservice.logger.Debug("Calling db layer for delete webhook")
+	err := webservice.rep.Delete(ctx, id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrWebhookNotFound
+	}
+	return err
+}
+
+func (webservice *WebhookService) ListDeliveries(ctx context.Context, webhookID uuid.UUID, status string, limit, offset int) ([]*objects.WebhookDelivery, error) {
+	switch status {
+	case "", objects.DeliveryPending, objects.DeliveryDelivered, objects.DeliveryDead:
+	default:
+		return nil, fmt.Errorf("%w: unknown delivery status %q", ErrInvalidWebhook, status)
+	}
+	// Устанавливаем дефолтные значения как и для списка подписок
+	if limit <= 0 || limit > 100 {
+		limit = 10
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	if _, err := webservice.GetByID(ctx, webhookID); err != nil {
+		return nil, err
+	}
+	webservice.logger.Debug("Calling db layer for get webhook deliveries")
+	return webservice.rep.ListDeliveries(ctx, webhookID, status, limit, offset)
+}
@@ -0,0 +1,231 @@
+package stream
+
+import (
+	"context"
+	"effective_mobile/pkg/logger_module"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// Канал Postgres, в который outbox пишет NOTIFY при каждом событии
+const Channel = "subscription_events"
+
+// NOTIFY ограничен 8000 байт; если событие больше, в уведомлении едет только id,
+// а само событие дочитывается из outbox
+const MaxNotifyPayload = 7900
+
+const (
+	subscriberBuffer  = 64
+	replayBatch       = 500
+	reconnectMinDelay = time.Second
+	reconnectMaxDelay = 30 * time.Second
+)
+
+// Событие в потоке: id совпадает с id записи outbox и используется как SSE id
+type Message struct {
+	ID       int64           `json:"id"`
+	Type     string          `json:"type"`
+	UserID   uuid.UUID       `json:"user_id"`
+	TenantID string          `json:"tenant_id"`
+	Data     json.RawMessage `json:"data,omitempty"`
+}
+
+// Откуда дочитываем события, пропущенные клиентом или самим хабом
+type ReplayStore interface {
+	// События с id > afterID по возрастанию id, uuid.Nil = все пользователи.
+	// Тенант берется из контекста, хаб дочитывает события всех тенантов
+	ListSince(ctx context.Context, afterID int64, userID uuid.UUID, limit int) ([]Message, error)
+	// Одно событие по id; ErrMessageNotFound, если его нет
+	GetByID(ctx context.Context, id int64) (Message, error)
+}
+
+var ErrMessageNotFound = errors.New("event stream message not found")
+
+// Подписчик потока. Канал закрывается, если подписчик не успевает читать или хаб останавливается
+type Subscriber struct {
+	C        chan Message
+	tenantID string
+	userID   uuid.UUID
+}
+
+// Раздает события из LISTEN/NOTIFY всем подключенным клиентам.
+// Уведомления приходят от любой реплики, потому что пишутся в той же транзакции, что и outbox
+type Hub struct {
+	dsn         string
+	replay      ReplayStore
+	logger      *logger_module.Logger
+	mutex       sync.Mutex
+	subscribers map[*Subscriber]struct{}
+	closed      bool
+	lastID      int64
+	wg          sync.WaitGroup
+}
+
+func NewHub(dsn string, replay ReplayStore, logger *logger_module.Logger) *Hub {
+	return &Hub{
+		dsn:         dsn,
+		replay:      replay,
+		logger:      logger,
+		subscribers: make(map[*Subscriber]struct{}),
+	}
+}
+
+// Подписываемся на события пользователя внутри тенанта, uuid.Nil = все события тенанта
+func (h *Hub) Subscribe(tenantID string, userID uuid.UUID) *Subscriber {
+	sub := &Subscriber{C: make(chan Message, subscriberBuffer), tenantID: tenantID, userID: userID}
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if h.closed {
+		close(sub.C)
+		return sub
+	}
+	h.subscribers[sub] = struct{}{}
+	return sub
+}
+
+func (h *Hub) Unsubscribe(sub *Subscriber) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if _, ok := h.subscribers[sub]; ok {
+		delete(h.subscribers, sub)
+		close(sub.C)
+	}
+}
+
+// Отключаем всех клиентов, чтобы server.Shutdown не ждал бесконечные потоки
+func (h *Hub) Close() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.closed = true
+	for sub := range h.subscribers {
+		delete(h.subscribers, sub)
+		close(sub.C)
+	}
+}
+
+// Раздаем событие подписчикам. Медленного подписчика отключаем: он переподключится
+// с Last-Event-ID и дочитает пропущенное из outbox
+func (h *Hub) Broadcast(msg Message) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if msg.ID > h.lastID {
+		h.lastID = msg.ID
+	}
+	for sub := range h.subscribers {
+		if sub.tenantID != msg.TenantID || (sub.userID != uuid.Nil && sub.userID != msg.UserID) {
+			continue
+		}
+		select {
+		case sub.C <- msg:
+		default:
+			h.logger.Error("Event stream subscriber is too slow, disconnect", "user_id", sub.userID)
+			delete(h.subscribers, sub)
+			close(sub.C)
+		}
+	}
+}
+
+// Запускаем прослушивание канала в отдельной горутине, останавливается отменой ctx
+func (h *Hub) Start(ctx context.Context) {
+	h.wg.Add(1)
+	go func() {
+		defer h.wg.Done()
+		delay := reconnectMinDelay
+		for ctx.Err() == nil {
+			err := h.listen(ctx)
+			if ctx.Err() != nil {
+				break
+			}
+			h.logger.Error("Event stream listener disconnected, reconnecting", "error", err, "delay", delay.String())
+			select {
+			case <-ctx.Done():
+			case <-time.After(delay):
+			}
+			delay = min(delay*2, reconnectMaxDelay)
+		}
+		h.logger.Info("Event stream listener stopped")
+	}()
+}
+
+// Ждем завершения прослушивания после отмены контекста
+func (h *Hub) Wait() {
+	h.wg.Wait()
+}
+
+func (h *Hub) listen(ctx context.Context) error {
+	conn, err := pgx.Connect(ctx, h.dsn)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+Channel); err != nil {
+		return err
+	}
+	h.logger.Info("Event stream listening", "channel", Channel)
+
+	// Пока соединения не было, уведомления терялись: дочитываем их из outbox
+	h.mutex.Lock()
+	lastID := h.lastID
+	h.mutex.Unlock()
+	if lastID > 0 {
+		if err := h.catchUp(ctx, lastID); err != nil {
+			return err
+		}
+	}
+
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		if err := h.handleNotification(ctx, notification.Payload); err != nil {
+			return err
+		}
+	}
+}
+
+// Раздаем событие из уведомления. Событие, которое не влезло в NOTIFY, берем из outbox
+// по id: остальные события после него придут своими уведомлениями
+func (h *Hub) handleNotification(ctx context.Context, payload string) error {
+	var msg Message
+	if err := json.Unmarshal([]byte(payload), &msg); err != nil {
+		h.logger.Error("Invalid event stream notification", "error", err)
+		return nil
+	}
+	if msg.Data == nil {
+		stored, err := h.replay.GetByID(ctx, msg.ID)
+		if errors.Is(err, ErrMessageNotFound) {
+			h.logger.Error("Event stream message not found in outbox", "id", msg.ID)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		msg = stored
+	}
+	h.Broadcast(msg)
+	return nil
+}
+
+// Раздаем все события после afterID из outbox
+func (h *Hub) catchUp(ctx context.Context, afterID int64) error {
+	for {
+		messages, err := h.replay.ListSince(ctx, afterID, uuid.Nil, replayBatch)
+		if err != nil {
+			return err
+		}
+		for _, msg := range messages {
+			h.Broadcast(msg)
+			afterID = msg.ID
+		}
+		if len(messages) < replayBatch {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package stream
+
+import (
+	"context"
+	"effective_mobile/pkg/logger_module"
+	"encoding/json"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Outbox в памяти: id -> событие
+type fakeReplayStore struct {
+	messages  map[int64]Message
+	listCalls int
+}
+
+func (s *fakeReplayStore) ListSince(ctx context.Context, afterID int64, userID uuid.UUID, limit int) ([]Message, error) {
+	s.listCalls++
+	var messages []Message
+	for id := afterID + 1; len(messages) < limit; id++ {
+		msg, ok := s.messages[id]
+		if !ok {
+			break
+		}
+		messages = append(messages, msg)
+	}
+	return messages, nil
+}
+
+func (s *fakeReplayStore) GetByID(ctx context.Context, id int64) (Message, error) {
+	msg, ok := s.messages[id]
+	if !ok {
+		return Message{}, ErrMessageNotFound
+	}
+	return msg, nil
+}
+
+func notificationPayload(t *testing.T, msg Message) string {
+	payload, err := json.Marshal(msg)
+	require.NoError(t, err)
+	return string(payload)
+}
+
+// Уведомление без данных раздает только свое событие: соседние уже разосланы
+// или придут своими уведомлениями
+func TestHub_NotificationWithoutDataFetchesSingleEvent(t *testing.T) {
+	userID := uuid.New()
+	event := func(id int64) Message {
+		return Message{ID: id, Type: "subscription.updated", UserID: userID, TenantID: "acme", Data: json.RawMessage(`{"id":1}`)}
+	}
+	store := &fakeReplayStore{messages: map[int64]Message{1: event(1), 2: event(2), 3: event(3)}}
+	hub := NewHub("", store, logger_module.Get())
+	sub := hub.Subscribe("acme", userID)
+	ctx := context.Background()
+
+	require.NoError(t, hub.handleNotification(ctx, notificationPayload(t, event(1))))
+	large := event(2)
+	large.Data = nil
+	require.NoError(t, hub.handleNotification(ctx, notificationPayload(t, large)))
+	require.NoError(t, hub.handleNotification(ctx, notificationPayload(t, event(3))))
+
+	var ids []int64
+	for len(sub.C) > 0 {
+		msg := <-sub.C
+		assert.NotNil(t, msg.Data)
+		ids = append(ids, msg.ID)
+	}
+	assert.Equal(t, []int64{1, 2, 3}, ids)
+	assert.Zero(t, store.listCalls)
+
+	// Событие уже удалено из outbox — пропускаем, соединение не рвем
+	missing := event(4)
+	missing.Data = nil
+	require.NoError(t, hub.handleNotification(ctx, notificationPayload(t, missing)))
+	assert.Empty(t, sub.C)
+}
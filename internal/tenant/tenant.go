@@ -0,0 +1,48 @@
+package tenant
+
+import (
+	"context"
+	"regexp"
+)
+
+// Заголовок, которым клиент без привязанного к токену тенанта выбирает организацию
+const Header = "X-Tenant-ID"
+
+// Тенант, в который попали данные, существовавшие до разделения на организации
+const Default = "default"
+
+var idPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,62}$`)
+
+// Идентификатор тенанта: строчные латинские буквы, цифры, "-" и "_", до 63 символов
+func Valid(id string) bool {
+	return idPattern.MatchString(id)
+}
+
+type tenantKey struct{}
+type allTenantsKey struct{}
+
+// Все запросы к базе с этим контекстом ограничиваются тенантом id,
+// в том числе если ctx был получен из WithAllTenants
+func NewContext(ctx context.Context, id string) context.Context {
+	if IsAllTenants(ctx) {
+		ctx = context.WithValue(ctx, allTenantsKey{}, false)
+	}
+	return context.WithValue(ctx, tenantKey{}, id)
+}
+
+func FromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(tenantKey{}).(string)
+	return id, ok && id != ""
+}
+
+// Для фоновых задач, которые обрабатывают данные всех организаций сразу
+// (планировщик, рассылка вебхуков, поток событий). Запросы с таким контекстом не ограничиваются,
+// а при вставке tenant_id должен быть заполнен явно
+func WithAllTenants(ctx context.Context) context.Context {
+	return context.WithValue(ctx, allTenantsKey{}, true)
+}
+
+func IsAllTenants(ctx context.Context) bool {
+	all, _ := ctx.Value(allTenantsKey{}).(bool)
+	return all
+}
@@ -0,0 +1,19 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetup(t *testing.T) {
+	for _, exporter := range []string{ExporterNone, ExporterStdout, ExporterOTLP} {
+		shutdown, err := Setup(context.Background(), Config{Exporter: exporter, ServiceName: "test", SampleRatio: 1})
+		assert.NoError(t, err, exporter)
+		assert.NoError(t, shutdown(context.Background()), exporter)
+	}
+
+	_, err := Setup(context.Background(), Config{Exporter: "jaeger"})
+	assert.Error(t, err)
+}
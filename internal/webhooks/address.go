@@ -0,0 +1,105 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"syscall"
+)
+
+var ErrForbiddenAddress = errors.New("webhook address is not allowed")
+
+// Диапазоны, которых нет среди проверок netip.Addr: общий адрес провайдера (CGNAT), служебные,
+// тестовые и зарезервированные сети, NAT64 — через него доступны те же IPv4-адреса
+var blockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+	netip.MustParsePrefix("64:ff9b:1::/48"),
+	netip.MustParsePrefix("fec0::/10"),
+}
+
+// Куда можно отправлять вебхуки. Адрес получателя задает администратор организации, поэтому без проверки
+// через доставки можно было бы обращаться к метаданным облака (169.254.169.254), localhost и внутренним
+// сервисам кластера (SSRF). Внутренние получатели разрешаются явно списком сетей из конфига
+type AddressPolicy struct {
+	allowed []netip.Prefix
+}
+
+// allowed — сети в нотации CIDR, в которые доставка разрешена несмотря на запрет внутренних адресов
+func NewAddressPolicy(allowed []string) (*AddressPolicy, error) {
+	policy := &AddressPolicy{}
+	for _, cidr := range allowed {
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid webhook network %q: %w", cidr, err)
+		}
+		policy.allowed = append(policy.allowed, prefix.Masked())
+	}
+	return policy, nil
+}
+
+// Публичные адреса разрешены всегда, внутренние — только из списка
+func (policy *AddressPolicy) Allowed(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range policy.allowed {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	if addr.IsLoopback() || addr.IsPrivate() || addr.IsLinkLocalUnicast() || addr.IsUnspecified() ||
+		addr.IsMulticast() || addr.IsLinkLocalMulticast() || addr.IsInterfaceLocalMulticast() {
+		return false
+	}
+	for _, prefix := range blockedPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return addr.IsValid()
+}
+
+// Проверка при регистрации: все адреса, в которые сейчас разрешается хост, должны быть разрешены
+func (policy *AddressPolicy) CheckURL(ctx context.Context, rawURL string) error {
+	target, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	host := target.Hostname()
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return policy.check(addr)
+	}
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return fmt.Errorf("resolve %s: %w", host, err)
+	}
+	for _, addr := range addrs {
+		if err := policy.check(addr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Проверка при соединении для net.Dialer.Control. DNS может вернуть другой адрес, чем при регистрации,
+// а получатель — перенаправить запрос, поэтому проверяется адрес, к которому действительно подключаемся
+func (policy *AddressPolicy) Control(network, address string, conn syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrForbiddenAddress, address)
+	}
+	return policy.check(addrPort.Addr())
+}
+
+func (policy *AddressPolicy) check(addr netip.Addr) error {
+	if !policy.Allowed(addr) {
+		return fmt.Errorf("%w: %s", ErrForbiddenAddress, addr)
+	}
+	return nil
+}
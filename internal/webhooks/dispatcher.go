@@ -0,0 +1,220 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"effective_mobile/internal/objects"
+	"effective_mobile/pkg/logger_module"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	batchSize      = 100
+	requestTimeout = 10 * time.Second
+	// На время отправки доставка откладывается, чтобы ее не взяла другая реплика
+	deliveryLease = time.Minute
+	baseBackoff   = 30 * time.Second
+	maxBackoff    = 6 * time.Hour
+)
+
+// Доставка вместе с адресом и секретом вебхука
+type Task struct {
+	Delivery objects.WebhookDelivery
+	URL      string
+	Secret   string
+}
+
+// Хранилище outbox и доставок
+type Store interface {
+	// Раскладывает новые события из outbox по доставкам подписанных вебхуков
+	FanOut(ctx context.Context, limit int) (int, error)
+	// Забирает доставки, время которых подошло, и откладывает их на lease
+	ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]Task, error)
+	// Сохраняет результат попытки доставки
+	SaveAttempt(ctx context.Context, delivery *objects.WebhookDelivery) error
+}
+
+// Рассылает события из outbox по вебхукам с повторами и экспоненциальной задержкой
+type Dispatcher struct {
+	store        Store
+	client       *http.Client
+	logger       *logger_module.Logger
+	maxAttempts  int
+	pollInterval time.Duration
+	wake         chan struct{}
+	now          func() time.Time
+	wg           sync.WaitGroup
+}
+
+func NewDispatcher(store Store, policy *AddressPolicy, maxAttempts int, pollInterval time.Duration, logger *logger_module.Logger) *Dispatcher {
+	// Адрес получателя проверяется при каждом соединении, в том числе после перенаправлений.
+	// Прокси из окружения не используется: иначе проверялся бы адрес прокси, а не получателя
+	dialer := &net.Dialer{Timeout: requestTimeout, Control: policy.Control}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &Dispatcher{
+		store:        store,
+		client:       &http.Client{Timeout: requestTimeout, Transport: transport},
+		logger:       logger,
+		maxAttempts:  maxAttempts,
+		pollInterval: pollInterval,
+		wake:         make(chan struct{}, 1),
+		now:          time.Now,
+	}
+}
+
+// Будим диспетчер, не дожидаясь очередного опроса. Не блокирует вызывающего
+func (d *Dispatcher) Notify() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Запускаем цикл рассылки в отдельной горутине, останавливается отменой ctx
+func (d *Dispatcher) Start(ctx context.Context) {
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		d.logger.Info("Webhook dispatcher started", "poll_interval", d.pollInterval.String())
+
+		ticker := time.NewTicker(d.pollInterval)
+		defer ticker.Stop()
+		for {
+			d.runOnce(ctx)
+			select {
+			case <-ctx.Done():
+				d.logger.Info("Webhook dispatcher stopped")
+				return
+			case <-ticker.C:
+			case <-d.wake:
+			}
+		}
+	}()
+}
+
+// Ждем завершения цикла после отмены контекста
+func (d *Dispatcher) Wait() {
+	d.wg.Wait()
+}
+
+// Разбираем outbox и отправляем все доставки, время которых подошло
+func (d *Dispatcher) runOnce(ctx context.Context) {
+	for ctx.Err() == nil {
+		count, err := d.store.FanOut(ctx, batchSize)
+		if err != nil {
+			d.logger.Error("Failed fan out outbox events", "error", err)
+			break
+		}
+		if count < batchSize {
+			break
+		}
+	}
+
+	for ctx.Err() == nil {
+		tasks, err := d.store.ClaimDue(ctx, batchSize, deliveryLease)
+		if err != nil {
+			d.logger.Error("Failed claim webhook deliveries", "error", err)
+			return
+		}
+		var wg sync.WaitGroup
+		for i := range tasks {
+			wg.Add(1)
+			go func(task *Task) {
+				defer wg.Done()
+				d.deliver(ctx, task)
+			}(&tasks[i])
+		}
+		wg.Wait()
+		if len(tasks) < batchSize {
+			return
+		}
+	}
+}
+
+// Одна попытка доставки и запись ее результата
+func (d *Dispatcher) deliver(ctx context.Context, task *Task) {
+	delivery := &task.Delivery
+	statusCode, err := d.send(ctx, task)
+
+	now := d.now().UTC()
+	delivery.Attempts++
+	delivery.UpdatedAt = now
+	if statusCode != 0 {
+		delivery.LastStatusCode = &statusCode
+	}
+
+	switch {
+	case err == nil:
+		delivery.Status = objects.DeliveryDelivered
+		delivery.DeliveredAt = &now
+		delivery.LastError = nil
+		d.logger.Info("Webhook delivered", "delivery_id", delivery.ID, "webhook_id", delivery.WebhookID, "event_type", delivery.EventType)
+	// Адрес запрещен политикой: повторы ничего не изменят
+	case delivery.Attempts >= d.maxAttempts || errors.Is(err, ErrForbiddenAddress):
+		message := err.Error()
+		delivery.Status = objects.DeliveryDead
+		delivery.LastError = &message
+		d.logger.Error("Webhook delivery dead-lettered", "error", err, "delivery_id", delivery.ID, "attempts", delivery.Attempts)
+	default:
+		message := err.Error()
+		delivery.Status = objects.DeliveryPending
+		delivery.LastError = &message
+		delivery.NextAttemptAt = now.Add(Backoff(delivery.Attempts))
+		d.logger.Error("Webhook delivery failed, will retry",
+			"error", err,
+			"delivery_id", delivery.ID,
+			"attempts", delivery.Attempts,
+			"next_attempt_at", delivery.NextAttemptAt)
+	}
+
+	if err := d.store.SaveAttempt(ctx, delivery); err != nil {
+		d.logger.Error("Failed save webhook delivery attempt", "error", err, "delivery_id", delivery.ID)
+	}
+}
+
+// Отправляем подписанный запрос, успехом считается любой ответ 2xx
+func (d *Dispatcher) send(ctx context.Context, task *Task) (int, error) {
+	body := []byte(task.Delivery.Payload)
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, task.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", "effective_mobile-webhooks/1.0")
+	request.Header.Set(DeliveryHeader, task.Delivery.ID.String())
+	request.Header.Set(EventHeader, task.Delivery.EventType)
+	request.Header.Set(EventIDHeader, task.Delivery.EventID.String())
+	request.Header.Set(SignatureHeader, Sign(task.Secret, d.now().Unix(), body))
+
+	response, err := d.client.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(response.Body, 64<<10))
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return response.StatusCode, fmt.Errorf("unexpected status code %d", response.StatusCode)
+	}
+	return response.StatusCode, nil
+}
+
+// Задержка перед следующей попыткой: 30s, 1m, 2m, 4m... но не больше maxBackoff
+func Backoff(attempts int) time.Duration {
+	delay := baseBackoff
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= maxBackoff {
+			return maxBackoff
+		}
+	}
+	return delay
+}
@@ -0,0 +1,184 @@
+package webhooks
+
+import (
+	"context"
+	"effective_mobile/internal/objects"
+	"effective_mobile/pkg/logger_module"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+// Хранилище в памяти: одна доставка, которую отдаем пока она в статусе pending и ее время подошло
+type fakeStore struct {
+	mutex    sync.Mutex
+	task     Task
+	saved    []objects.WebhookDelivery
+	now      func() time.Time
+	fanOuted int
+}
+
+func (s *fakeStore) FanOut(ctx context.Context, limit int) (int, error) {
+	s.fanOuted++
+	return 0, nil
+}
+
+func (s *fakeStore) ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]Task, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.task.Delivery.Status != objects.DeliveryPending || s.task.Delivery.NextAttemptAt.After(s.now()) {
+		return nil, nil
+	}
+	return []Task{s.task}, nil
+}
+
+func (s *fakeStore) SaveAttempt(ctx context.Context, delivery *objects.WebhookDelivery) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.task.Delivery = *delivery
+	s.saved = append(s.saved, *delivery)
+	return nil
+}
+
+func newTestDispatcher(url string, maxAttempts int) (*Dispatcher, *fakeStore, *time.Time) {
+	now := time.Date(2025, time.March, 1, 12, 0, 0, 0, time.UTC)
+	store := &fakeStore{
+		task: Task{
+			Delivery: objects.WebhookDelivery{
+				ID:            uuid.New(),
+				WebhookID:     uuid.New(),
+				EventID:       uuid.New(),
+				EventType:     "subscription.created",
+				Payload:       `{"type":"subscription.created"}`,
+				Status:        objects.DeliveryPending,
+				NextAttemptAt: now,
+			},
+			URL:    url,
+			Secret: "test-secret",
+		},
+		now: func() time.Time { return now },
+	}
+	// Получатели в тестах слушают на 127.0.0.1
+	policy, _ := NewAddressPolicy([]string{"127.0.0.0/8"})
+	dispatcher := NewDispatcher(store, policy, maxAttempts, time.Minute, logger_module.Get())
+	dispatcher.now = func() time.Time { return now }
+	return dispatcher, store, &now
+}
+
+func TestDispatcher_DeliversSignedRequest(t *testing.T) {
+	var received *http.Request
+	var body []byte
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer receiver.Close()
+
+	dispatcher, store, now := newTestDispatcher(receiver.URL, 3)
+	dispatcher.runOnce(context.Background())
+
+	// Проверяем запрос на стороне получателя
+	assert.NotNil(t, received)
+	assert.Equal(t, "subscription.created", received.Header.Get(EventHeader))
+	assert.Equal(t, store.task.Delivery.ID.String(), received.Header.Get(DeliveryHeader))
+	assert.NoError(t, Verify("test-secret", received.Header.Get(SignatureHeader), body, time.Minute, *now))
+	assert.ErrorIs(t, Verify("other-secret", received.Header.Get(SignatureHeader), body, time.Minute, *now), ErrInvalidSignature)
+
+	// Проверяем что доставка записана как успешная
+	assert.Equal(t, 1, store.fanOuted)
+	assert.Len(t, store.saved, 1)
+	assert.Equal(t, objects.DeliveryDelivered, store.task.Delivery.Status)
+	assert.Equal(t, 1, store.task.Delivery.Attempts)
+	assert.NotNil(t, store.task.Delivery.DeliveredAt)
+}
+
+func TestDispatcher_RetriesWithBackoffThenDeadLetters(t *testing.T) {
+	calls := 0
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer receiver.Close()
+
+	dispatcher, store, now := newTestDispatcher(receiver.URL, 3)
+
+	// Первая попытка неудачна, следующая через baseBackoff
+	dispatcher.runOnce(context.Background())
+	assert.Equal(t, objects.DeliveryPending, store.task.Delivery.Status)
+	assert.Equal(t, 503, *store.task.Delivery.LastStatusCode)
+	assert.Equal(t, now.Add(30*time.Second), store.task.Delivery.NextAttemptAt)
+
+	// Пока время не подошло, повторов нет
+	dispatcher.runOnce(context.Background())
+	assert.Equal(t, 1, calls)
+
+	// Вторая попытка: задержка удваивается
+	*now = now.Add(30 * time.Second)
+	dispatcher.runOnce(context.Background())
+	assert.Equal(t, now.Add(time.Minute), store.task.Delivery.NextAttemptAt)
+
+	// Третья попытка последняя, доставка уходит в dead
+	*now = now.Add(time.Minute)
+	dispatcher.runOnce(context.Background())
+	assert.Equal(t, 3, calls)
+	assert.Equal(t, objects.DeliveryDead, store.task.Delivery.Status)
+	assert.Equal(t, 3, store.task.Delivery.Attempts)
+	assert.NotNil(t, store.task.Delivery.LastError)
+}
+
+func TestBackoff(t *testing.T) {
+	assert.Equal(t, 30*time.Second, Backoff(1))
+	assert.Equal(t, time.Minute, Backoff(2))
+	assert.Equal(t, 4*time.Minute, Backoff(4))
+	assert.Equal(t, maxBackoff, Backoff(50))
+}
+
+func TestDispatcher_RejectsForbiddenAddress(t *testing.T) {
+	calls := 0
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer receiver.Close()
+
+	dispatcher, store, _ := newTestDispatcher(receiver.URL, 3)
+	policy, err := NewAddressPolicy(nil)
+	assert.NoError(t, err)
+	dispatcher.client.Transport.(*http.Transport).DialContext = (&net.Dialer{Control: policy.Control}).DialContext
+
+	// Соединение с localhost запрещено, доставка сразу уходит в dead
+	dispatcher.runOnce(context.Background())
+	assert.Zero(t, calls)
+	assert.Equal(t, objects.DeliveryDead, store.task.Delivery.Status)
+	assert.Contains(t, *store.task.Delivery.LastError, ErrForbiddenAddress.Error())
+}
+
+func TestAddressPolicy(t *testing.T) {
+	policy, err := NewAddressPolicy([]string{"10.20.0.0/16"})
+	assert.NoError(t, err)
+
+	for _, address := range []string{"127.0.0.1", "::1", "169.254.169.254", "10.0.0.5", "172.16.3.4", "192.168.1.1",
+		"100.64.0.1", "0.0.0.0", "fd00::1", "fe80::1", "::ffff:127.0.0.1", "64:ff9b::a9fe:a9fe"} {
+		assert.False(t, policy.Allowed(netip.MustParseAddr(address)), address)
+	}
+	for _, address := range []string{"93.184.216.34", "2606:2800:220:1::1", "10.20.5.6"} {
+		assert.True(t, policy.Allowed(netip.MustParseAddr(address)), address)
+	}
+
+	assert.ErrorIs(t, policy.CheckURL(context.Background(), "http://169.254.169.254/latest/meta-data"), ErrForbiddenAddress)
+	assert.ErrorIs(t, policy.CheckURL(context.Background(), "https://[::1]:8443/hook"), ErrForbiddenAddress)
+	assert.ErrorIs(t, policy.CheckURL(context.Background(), "http://localhost:8080/hook"), ErrForbiddenAddress)
+	assert.NoError(t, policy.CheckURL(context.Background(), "http://10.20.0.7/hook"))
+
+	_, err = NewAddressPolicy([]string{"10.0.0.0"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,61 @@
+package webhooks
+
+import (
+	"context"
+	"effective_mobile/pkg/logger_module"
+	"errors"
+	"time"
+)
+
+// Удаляем пачками, чтобы не держать долгие блокировки на больших таблицах
+const retentionBatch = 5000
+
+// Хранилище для очистки outbox и журнала доставок
+type RetentionStore interface {
+	// Удаляет до limit обработанных событий outbox, созданных раньше before
+	DeleteProcessedEvents(ctx context.Context, before time.Time, limit int) (int64, error)
+	// Удаляет до limit доставок в статусе delivered или dead, завершенных раньше before
+	DeleteFinishedDeliveries(ctx context.Context, before time.Time, limit int) (int64, error)
+}
+
+// Ежедневная очистка: обработанные события outbox нужны только для досылки пропущенного потоку событий,
+// а завершенные доставки — для журнала GET /api/webhooks/{id}/deliveries. Без очистки обе таблицы
+// растут без ограничений вместе с полными JSON событий
+type Retention struct {
+	store      RetentionStore
+	events     time.Duration // сколько хранить обработанные события, 0 — не удалять
+	deliveries time.Duration // сколько хранить завершенные доставки, 0 — не удалять
+	logger     *logger_module.Logger
+}
+
+func NewRetention(store RetentionStore, events, deliveries time.Duration, logger *logger_module.Logger) *Retention {
+	return &Retention{store: store, events: events, deliveries: deliveries, logger: logger}
+}
+
+// Задача планировщика; возраст записей отсчитывается от времени запуска to
+func (r *Retention) Run(ctx context.Context, from, to time.Time) error {
+	var errs []error
+	if r.events > 0 {
+		deleted, err := r.deleteAll(ctx, to.Add(-r.events), r.store.DeleteProcessedEvents)
+		errs = append(errs, err)
+		r.logger.Info("Old outbox events deleted", "count", deleted, "retention", r.events.String())
+	}
+	if r.deliveries > 0 {
+		deleted, err := r.deleteAll(ctx, to.Add(-r.deliveries), r.store.DeleteFinishedDeliveries)
+		errs = append(errs, err)
+		r.logger.Info("Old webhook deliveries deleted", "count", deleted, "retention", r.deliveries.String())
+	}
+	return errors.Join(errs...)
+}
+
+func (r *Retention) deleteAll(ctx context.Context, before time.Time, deleteBatch func(context.Context, time.Time, int) (int64, error)) (int64, error) {
+	var total int64
+	for ctx.Err() == nil {
+		deleted, err := deleteBatch(ctx, before, retentionBatch)
+		total += deleted
+		if err != nil || deleted < retentionBatch {
+			return total, err
+		}
+	}
+	return total, ctx.Err()
+}
@@ -0,0 +1,77 @@
+package webhooks
+
+import (
+	"context"
+	"effective_mobile/pkg/logger_module"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Записи хранятся как время создания (события) или завершения (доставки)
+type fakeRetentionStore struct {
+	events     []time.Time
+	deliveries []time.Time
+	calls      int
+	err        error
+}
+
+func deleteBefore(records []time.Time, before time.Time, limit int) ([]time.Time, int64) {
+	var kept []time.Time
+	var deleted int64
+	for _, record := range records {
+		if record.Before(before) && deleted < int64(limit) {
+			deleted++
+			continue
+		}
+		kept = append(kept, record)
+	}
+	return kept, deleted
+}
+
+func (s *fakeRetentionStore) DeleteProcessedEvents(ctx context.Context, before time.Time, limit int) (int64, error) {
+	s.calls++
+	var deleted int64
+	s.events, deleted = deleteBefore(s.events, before, limit)
+	return deleted, nil
+}
+
+func (s *fakeRetentionStore) DeleteFinishedDeliveries(ctx context.Context, before time.Time, limit int) (int64, error) {
+	if s.err != nil {
+		return 0, s.err
+	}
+	var deleted int64
+	s.deliveries, deleted = deleteBefore(s.deliveries, before, limit)
+	return deleted, nil
+}
+
+func TestRetention_DeletesOldRecordsInBatches(t *testing.T) {
+	now := time.Date(2025, time.March, 1, 3, 0, 0, 0, time.UTC)
+	store := &fakeRetentionStore{}
+	for range retentionBatch + 10 {
+		store.events = append(store.events, now.Add(-8*24*time.Hour))
+	}
+	store.events = append(store.events, now.Add(-time.Hour))
+	store.deliveries = []time.Time{now.Add(-31 * 24 * time.Hour), now.Add(-29 * 24 * time.Hour)}
+
+	retention := NewRetention(store, 7*24*time.Hour, 30*24*time.Hour, logger_module.Get())
+	assert.NoError(t, retention.Run(context.Background(), now.Add(-24*time.Hour), now))
+
+	// Старые записи удалены за несколько пачек, свежие остались
+	assert.Equal(t, []time.Time{now.Add(-time.Hour)}, store.events)
+	assert.Equal(t, 2, store.calls)
+	assert.Equal(t, []time.Time{now.Add(-29 * 24 * time.Hour)}, store.deliveries)
+}
+
+func TestRetention_DisabledAndErrors(t *testing.T) {
+	now := time.Date(2025, time.March, 1, 3, 0, 0, 0, time.UTC)
+	store := &fakeRetentionStore{events: []time.Time{now.AddDate(-1, 0, 0)}, err: errors.New("connection refused")}
+
+	// 0 — события не удаляются, ошибка очистки доставок возвращается планировщику
+	retention := NewRetention(store, 0, time.Hour, logger_module.Get())
+	assert.ErrorContains(t, retention.Run(context.Background(), now, now), "connection refused")
+	assert.Zero(t, store.calls)
+	assert.Len(t, store.events, 1)
+}
@@ -0,0 +1,21 @@
+-- +goose Up
+ALTER TABLE subscriptions ADD COLUMN trial_end_date TIMESTAMP NULL;
+
+CREATE TABLE job_runs (
+    id UUID PRIMARY KEY,
+    job_name TEXT NOT NULL,
+    scheduled_at TIMESTAMP NOT NULL,
+    status TEXT NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 1,
+    started_at TIMESTAMP NOT NULL,
+    finished_at TIMESTAMP NULL,
+    error TEXT NULL,
+    UNIQUE (job_name, scheduled_at)
+);
+
+CREATE INDEX idx_subscriptions_end_date ON subscriptions(end_date);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_subscriptions_end_date;
+DROP TABLE IF EXISTS job_runs;
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS trial_end_date;
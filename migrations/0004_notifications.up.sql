@@ -0,0 +1,23 @@
+-- +goose Up
+-- Куда и что отправлять пользователю; нет строки = нет адреса и писем не шлем
+CREATE TABLE notification_preferences (
+    user_id UUID PRIMARY KEY,
+    email TEXT NOT NULL,
+    monthly_summary BOOLEAN NOT NULL DEFAULT TRUE,
+    renewal_reminders BOOLEAN NOT NULL DEFAULT TRUE,
+    updated_at TIMESTAMP NOT NULL
+);
+
+-- Журнал отправленных писем, не дает отправить одно и то же письмо повторно при перезапуске задачи
+CREATE TABLE notification_log (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL,
+    kind TEXT NOT NULL,
+    dedup_key TEXT NOT NULL,
+    sent_at TIMESTAMP NOT NULL,
+    UNIQUE (kind, dedup_key)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS notification_log;
+DROP TABLE IF EXISTS notification_preferences;
@@ -0,0 +1,17 @@
+-- +goose Up
+-- API-ключи пользователей, хранится только SHA-256 от ключа
+CREATE TABLE api_keys (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL,
+    name TEXT NOT NULL,
+    prefix TEXT NOT NULL,
+    key_hash TEXT NOT NULL UNIQUE,
+    created_at TIMESTAMP NOT NULL,
+    last_used_at TIMESTAMP,
+    revoked_at TIMESTAMP
+);
+
+CREATE INDEX idx_api_keys_user_id ON api_keys (user_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS api_keys;
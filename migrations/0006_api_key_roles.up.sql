@@ -0,0 +1,6 @@
+-- +goose Up
+-- Роли, с которыми выпущен ключ (JSON-массив), ранее выпущенные ключи получают роль user
+ALTER TABLE api_keys ADD COLUMN roles TEXT NOT NULL DEFAULT '["user"]';
+
+-- +goose Down
+ALTER TABLE api_keys DROP COLUMN IF EXISTS roles;
@@ -0,0 +1,43 @@
+-- +goose Up
+-- Организация-владелец записи. Существующие данные переезжают в тенант default
+ALTER TABLE subscriptions ADD COLUMN tenant_id TEXT NOT NULL DEFAULT 'default' CHECK (tenant_id <> '');
+ALTER TABLE subscriptions ALTER COLUMN tenant_id DROP DEFAULT;
+DROP INDEX IF EXISTS idx_subscriptions_user_id;
+CREATE INDEX idx_subscriptions_tenant_user ON subscriptions (tenant_id, user_id);
+
+ALTER TABLE outbox_events ADD COLUMN tenant_id TEXT NOT NULL DEFAULT 'default' CHECK (tenant_id <> '');
+ALTER TABLE outbox_events ALTER COLUMN tenant_id DROP DEFAULT;
+
+ALTER TABLE webhooks ADD COLUMN tenant_id TEXT NOT NULL DEFAULT 'default' CHECK (tenant_id <> '');
+ALTER TABLE webhooks ALTER COLUMN tenant_id DROP DEFAULT;
+CREATE INDEX idx_webhooks_tenant_id ON webhooks (tenant_id);
+
+ALTER TABLE api_keys ADD COLUMN tenant_id TEXT NOT NULL DEFAULT 'default' CHECK (tenant_id <> '');
+ALTER TABLE api_keys ALTER COLUMN tenant_id DROP DEFAULT;
+DROP INDEX IF EXISTS idx_api_keys_user_id;
+CREATE INDEX idx_api_keys_tenant_user ON api_keys (tenant_id, user_id);
+
+-- Один и тот же пользователь может состоять в нескольких организациях со своими настройками писем
+ALTER TABLE notification_preferences ADD COLUMN tenant_id TEXT NOT NULL DEFAULT 'default' CHECK (tenant_id <> '');
+ALTER TABLE notification_preferences ALTER COLUMN tenant_id DROP DEFAULT;
+ALTER TABLE notification_preferences DROP CONSTRAINT notification_preferences_pkey;
+ALTER TABLE notification_preferences ADD PRIMARY KEY (tenant_id, user_id);
+
+-- +goose Down
+ALTER TABLE notification_preferences DROP CONSTRAINT notification_preferences_pkey;
+DELETE FROM notification_preferences WHERE tenant_id <> 'default';
+ALTER TABLE notification_preferences ADD PRIMARY KEY (user_id);
+ALTER TABLE notification_preferences DROP COLUMN IF EXISTS tenant_id;
+
+DROP INDEX IF EXISTS idx_api_keys_tenant_user;
+CREATE INDEX idx_api_keys_user_id ON api_keys (user_id);
+ALTER TABLE api_keys DROP COLUMN IF EXISTS tenant_id;
+
+DROP INDEX IF EXISTS idx_webhooks_tenant_id;
+ALTER TABLE webhooks DROP COLUMN IF EXISTS tenant_id;
+
+ALTER TABLE outbox_events DROP COLUMN IF EXISTS tenant_id;
+
+DROP INDEX IF EXISTS idx_subscriptions_tenant_user;
+CREATE INDEX idx_subscriptions_user_id ON subscriptions (user_id);
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS tenant_id;
@@ -0,0 +1,43 @@
+-- +goose Up
+-- Row-level security как второй рубеж изоляции организаций. Политики действуют на роли,
+-- которые не владеют таблицами (например, аналитика или отчеты с отдельным логином):
+-- такой сессии нужно выполнить SET app.tenant_id = '<тенант>', без него строк не видно.
+-- Сам сервис подключается владельцем таблиц, политики на него не действуют: запросы ограничиваются
+-- тенантом на уровне GORM. FORCE ROW LEVEL SECURITY включать нельзя: сервис не выполняет
+-- SET app.tenant_id, и ни запросы API, ни фоновые задачи по всем организациям не увидят строк
+ALTER TABLE subscriptions ENABLE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON subscriptions
+    USING (tenant_id = current_setting('app.tenant_id', true))
+    WITH CHECK (tenant_id = current_setting('app.tenant_id', true));
+
+ALTER TABLE outbox_events ENABLE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON outbox_events
+    USING (tenant_id = current_setting('app.tenant_id', true))
+    WITH CHECK (tenant_id = current_setting('app.tenant_id', true));
+
+ALTER TABLE webhooks ENABLE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON webhooks
+    USING (tenant_id = current_setting('app.tenant_id', true))
+    WITH CHECK (tenant_id = current_setting('app.tenant_id', true));
+
+ALTER TABLE api_keys ENABLE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON api_keys
+    USING (tenant_id = current_setting('app.tenant_id', true))
+    WITH CHECK (tenant_id = current_setting('app.tenant_id', true));
+
+ALTER TABLE notification_preferences ENABLE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON notification_preferences
+    USING (tenant_id = current_setting('app.tenant_id', true))
+    WITH CHECK (tenant_id = current_setting('app.tenant_id', true));
+
+-- +goose Down
+DROP POLICY IF EXISTS tenant_isolation ON notification_preferences;
+ALTER TABLE notification_preferences DISABLE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS tenant_isolation ON api_keys;
+ALTER TABLE api_keys DISABLE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS tenant_isolation ON webhooks;
+ALTER TABLE webhooks DISABLE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS tenant_isolation ON outbox_events;
+ALTER TABLE outbox_events DISABLE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS tenant_isolation ON subscriptions;
+ALTER TABLE subscriptions DISABLE ROW LEVEL SECURITY;
@@ -0,0 +1,19 @@
+-- +goose Up
+-- Тариф организации; организации без строки получают тариф RATE_QUOTA_DEFAULT_TIER
+CREATE TABLE tenant_plans (
+    tenant_id TEXT PRIMARY KEY CHECK (tenant_id <> ''),
+    tier TEXT NOT NULL,
+    updated_at TIMESTAMP NOT NULL DEFAULT now()
+);
+
+-- Число запросов к API организации за сутки (UTC)
+CREATE TABLE daily_usage (
+    tenant_id TEXT NOT NULL CHECK (tenant_id <> ''),
+    day DATE NOT NULL,
+    requests BIGINT NOT NULL,
+    PRIMARY KEY (tenant_id, day)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS daily_usage;
+DROP TABLE IF EXISTS tenant_plans;
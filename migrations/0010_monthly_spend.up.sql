@@ -0,0 +1,43 @@
+-- +goose Up
+-- Агрегат для /api/subscriptions/total (см. repository.MonthlySpend): по месяцам хранятся изменения
+-- суммы. started — цены подписок, действующих с этого месяца (подписка, начавшаяся не первого числа,
+-- считается со следующего месяца), ended — цены подписок, закончившихся в этом месяце.
+-- Ведется репозиторием в транзакции изменения подписки
+CREATE TABLE monthly_spend (
+    tenant_id TEXT NOT NULL CHECK (tenant_id <> ''),
+    user_id UUID NOT NULL,
+    service_name TEXT NOT NULL,
+    month TIMESTAMP NOT NULL,
+    started BIGINT NOT NULL DEFAULT 0,
+    ended BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (tenant_id, user_id, service_name, month)
+);
+
+-- Как и политики из 0008_tenant_rls, действует только на роли, которые не владеют таблицей
+ALTER TABLE monthly_spend ENABLE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON monthly_spend
+    USING (tenant_id = current_setting('app.tenant_id', true))
+    WITH CHECK (tenant_id = current_setting('app.tenant_id', true));
+
+-- Подписки с окончанием раньше начала в агрегат не входят, сумма по ним считается по таблице
+CREATE INDEX idx_subscriptions_ended_before_start ON subscriptions (tenant_id) WHERE end_date < start_date;
+
+INSERT INTO monthly_spend (tenant_id, user_id, service_name, month, started, ended)
+SELECT tenant_id, user_id, service_name, month, SUM(started), SUM(ended)
+FROM (
+    SELECT tenant_id, user_id, service_name,
+           CASE WHEN date_trunc('month', start_date) = start_date THEN start_date
+                ELSE date_trunc('month', start_date) + INTERVAL '1 month' END AS month,
+           price AS started, 0 AS ended
+    FROM subscriptions
+    WHERE end_date IS NULL OR end_date >= start_date
+    UNION ALL
+    SELECT tenant_id, user_id, service_name, date_trunc('month', end_date), 0, price
+    FROM subscriptions
+    WHERE end_date >= start_date
+) deltas
+GROUP BY tenant_id, user_id, service_name, month;
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_subscriptions_ended_before_start;
+DROP TABLE IF EXISTS monthly_spend;
@@ -0,0 +1,8 @@
+-- +goose Up
+-- Индексы для ежедневной очистки (webhooks.Retention): обработанные события outbox и завершенные доставки
+CREATE INDEX idx_outbox_events_processed ON outbox_events(created_at) WHERE processed_at IS NOT NULL;
+CREATE INDEX idx_webhook_deliveries_finished ON webhook_deliveries(updated_at) WHERE status IN ('delivered', 'dead');
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_webhook_deliveries_finished;
+DROP INDEX IF EXISTS idx_outbox_events_processed;
@@ -0,0 +1,6 @@
+-- +goose Up
+-- Упавший запуск задачи повторяется не раньше next_attempt_at (экспоненциальная задержка)
+ALTER TABLE job_runs ADD COLUMN next_attempt_at TIMESTAMP NULL;
+
+-- +goose Down
+ALTER TABLE job_runs DROP COLUMN IF EXISTS next_attempt_at;
@@ -0,0 +1,23 @@
+// Package migrations встраивает SQL-миграции в бинарник, поэтому сервис не зависит
+// от рабочей директории
+package migrations
+
+import (
+	"embed"
+	"io/fs"
+)
+
+// Миграции Postgres лежат в корне, миграции SQLite — в папке sqlite: схема та же,
+// но типы и ALTER TABLE у SQLite свои
+//
+//go:embed *.sql sqlite/*.sql
+var FS embed.FS
+
+// Миграции SQLite с корнем в папке sqlite
+func SQLite() fs.FS {
+	sqlite, err := fs.Sub(FS, "sqlite")
+	if err != nil {
+		panic(err)
+	}
+	return sqlite
+}
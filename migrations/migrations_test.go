@@ -0,0 +1,34 @@
+package migrations
+
+import (
+	"io/fs"
+	"testing"
+
+	"github.com/pressly/goose/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Все файлы миграций попадают в бинарник и читаются goose без базы
+func TestFS_ContainsMigrations(t *testing.T) {
+	files, err := fs.Glob(FS, "*.sql")
+	require.NoError(t, err)
+	assert.Contains(t, files, "0001_init.up.sql")
+
+	goose.SetBaseFS(FS)
+	t.Cleanup(func() { goose.SetBaseFS(nil) })
+	migrations, err := goose.CollectMigrations(".", 0, goose.MaxVersion)
+	require.NoError(t, err)
+	assert.Len(t, migrations, len(files))
+}
+
+// Миграции SQLite не попадают в набор Postgres и читаются из своей папки
+func TestSQLite_ContainsMigrations(t *testing.T) {
+	files, err := fs.Glob(SQLite(), "*.sql")
+	require.NoError(t, err)
+	assert.Contains(t, files, "0001_init.up.sql")
+
+	postgres, err := fs.Glob(FS, "*.sql")
+	require.NoError(t, err)
+	assert.NotContains(t, postgres, "sqlite/0001_init.up.sql")
+}
@@ -0,0 +1,20 @@
+-- +goose Up
+-- Схема SQLite для запуска без Postgres (STORAGE=sqlite). Повторяет итоговую схему подписок
+-- из миграций Postgres; вебхуки, письма, API-ключи и квоты в этом режиме выключены.
+-- UUID хранятся строкой, даты — строкой в UTC (формат драйвера), поэтому сравниваются как текст
+CREATE TABLE subscriptions (
+    id TEXT PRIMARY KEY,
+    service_name TEXT NOT NULL,
+    price INTEGER NOT NULL CHECK (price > 0),
+    user_id TEXT NOT NULL,
+    start_date DATETIME NOT NULL,
+    end_date DATETIME NULL,
+    trial_end_date DATETIME NULL,
+    tenant_id TEXT NOT NULL CHECK (tenant_id <> '')
+);
+
+CREATE INDEX idx_subscriptions_tenant_user ON subscriptions (tenant_id, user_id);
+CREATE INDEX idx_subscriptions_end_date ON subscriptions (end_date);
+
+-- +goose Down
+DROP TABLE IF EXISTS subscriptions;
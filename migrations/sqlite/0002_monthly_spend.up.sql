@@ -0,0 +1,34 @@
+-- +goose Up
+-- Агрегат для /api/subscriptions/total, как 0010_monthly_spend в Postgres.
+-- Месяцы записываются строкой в формате драйвера: 2025-01-01 00:00:00+00:00
+CREATE TABLE monthly_spend (
+    tenant_id TEXT NOT NULL CHECK (tenant_id <> ''),
+    user_id TEXT NOT NULL,
+    service_name TEXT NOT NULL,
+    month DATETIME NOT NULL,
+    started INTEGER NOT NULL DEFAULT 0,
+    ended INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (tenant_id, user_id, service_name, month)
+);
+
+CREATE INDEX idx_subscriptions_ended_before_start ON subscriptions (tenant_id) WHERE end_date < start_date;
+
+INSERT INTO monthly_spend (tenant_id, user_id, service_name, month, started, ended)
+SELECT tenant_id, user_id, service_name, month, SUM(started), SUM(ended)
+FROM (
+    SELECT tenant_id, user_id, service_name,
+           CASE WHEN strftime('%Y-%m-01 00:00:00+00:00', start_date) = start_date THEN start_date
+                ELSE strftime('%Y-%m-%d %H:%M:%S+00:00', start_date, 'start of month', '+1 month') END AS month,
+           price AS started, 0 AS ended
+    FROM subscriptions
+    WHERE end_date IS NULL OR end_date >= start_date
+    UNION ALL
+    SELECT tenant_id, user_id, service_name, strftime('%Y-%m-01 00:00:00+00:00', end_date), 0, price
+    FROM subscriptions
+    WHERE end_date >= start_date
+)
+GROUP BY tenant_id, user_id, service_name, month;
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_subscriptions_ended_before_start;
+DROP TABLE IF EXISTS monthly_spend;
@@ -0,0 +1,16 @@
+package logger_module
+
+import (
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// Время создания файла из statx; не все файловые системы его хранят
+func birthTime(path string) (time.Time, bool) {
+	var stat unix.Statx_t
+	if err := unix.Statx(unix.AT_FDCWD, path, 0, unix.STATX_BTIME, &stat); err != nil || stat.Mask&unix.STATX_BTIME == 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(stat.Btime.Sec, int64(stat.Btime.Nsec)), true
+}
@@ -0,0 +1,11 @@
+//go:build !linux
+
+package logger_module
+
+import "time"
+
+// Время создания файла получаем только в Linux, на остальных системах возраст лога
+// считается от последней записи
+func birthTime(path string) (time.Time, bool) {
+	return time.Time{}, false
+}
@@ -1,64 +1,164 @@
 package logger_module
 
 import (
+	"context"
+	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"os"
+	"runtime"
+	"strings"
 	"sync"
+	"time"
 )
 
+// Уровень для Fatal: выше Error, после записи процесс завершается
+const LevelFatal = slog.Level(12)
+
+// Формат вывода
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+type Options struct {
+	Format    string     // text или json
+	Level     slog.Level // записи ниже этого уровня отбрасываются
+	AddSource bool       // добавлять файл и строку вызова
+}
+
+// Логгер поверх log/slog. Аргументы после сообщения — пары ключ/значение, как в slog:
+// logger.Info("Subscription created", "id", id, "user_id", userID)
 type Logger struct {
-	*log.Logger
-	mutex sync.Mutex
+	handler slog.Handler
+	level   *slog.LevelVar
 }
 
 var (
 	instance *Logger
-	once     sync.Once
+	mutex    sync.Mutex
 )
 
-// Создаем логгер с указанными настройками (один раз из за once.DO)
-func New(out io.Writer, prefix string, flag int) *Logger {
-	once.Do(func() {
-		instance = &Logger{
-			Logger: log.New(out, prefix, flag),
-		}
-	})
-	return instance
+// Уровень из конфига: debug, info, warn или error
+func ParseLevel(value string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(strings.TrimSpace(value))); err != nil {
+		return 0, fmt.Errorf("invalid log level %q: %w", value, err)
+	}
+	return level, nil
+}
+
+// Создаем логгер. Логгер по умолчанию для Get и slog.Default не меняется, для этого есть SetDefault
+func New(out io.Writer, opts Options) (*Logger, error) {
+	level := new(slog.LevelVar)
+	level.Set(opts.Level)
+	handlerOpts := &slog.HandlerOptions{Level: level, AddSource: opts.AddSource, ReplaceAttr: replaceLevel}
+
+	var handler slog.Handler
+	switch opts.Format {
+	case FormatText, "":
+		handler = slog.NewTextHandler(out, handlerOpts)
+	case FormatJSON:
+		handler = slog.NewJSONHandler(out, handlerOpts)
+	default:
+		return nil, fmt.Errorf("unknown log format %q", opts.Format)
+	}
+
+	return &Logger{handler: handler, level: level}, nil
+}
+
+// Делаем логгер логгером по умолчанию для Get и slog.Default
+func SetDefault(logger *Logger) {
+	mutex.Lock()
+	instance = logger
+	mutex.Unlock()
+	slog.SetDefault(slog.New(logger.handler))
 }
 
+// Логгер по умолчанию; пока SetDefault не вызывался — текст в stdout с уровнем info
 func Get() *Logger {
+	mutex.Lock()
+	defer mutex.Unlock()
 	if instance == nil {
-		return New(os.Stdout, "[APP]", log.LstdFlags|log.Lshortfile)
+		level := new(slog.LevelVar)
+		instance = &Logger{
+			handler: slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: level, ReplaceAttr: replaceLevel}),
+			level:   level,
+		}
 	}
 	return instance
 }
 
-func (logger *Logger) Info(v ...interface{}) {
-	logger.mutex.Lock()
-	defer logger.mutex.Unlock()
-	logger.SetPrefix("[INFO]")
-	logger.Println(v...)
+// Подписываем уровень Fatal как FATAL, а не ERROR+4
+func replaceLevel(groups []string, attr slog.Attr) slog.Attr {
+	if attr.Key == slog.LevelKey && len(groups) == 0 {
+		if level, ok := attr.Value.Any().(slog.Level); ok && level == LevelFatal {
+			attr.Value = slog.StringValue("FATAL")
+		}
+	}
+	return attr
 }
 
-func (logger *Logger) Debug(v ...interface{}) {
-	logger.mutex.Lock()
-	defer logger.mutex.Unlock()
-	logger.SetPrefix("[DEBUG]")
-	logger.Println(v...)
+// Меняем минимальный уровень на лету, в том числе для логгеров из With
+func (logger *Logger) SetLevel(level slog.Level) {
+	logger.level.Set(level)
 }
 
-func (logger *Logger) Error(v ...interface{}) {
-	logger.mutex.Lock()
-	defer logger.mutex.Unlock()
-	logger.SetPrefix("[Error]")
-	logger.Println(v...)
+// Логгер, который добавляет ко всем записям указанные атрибуты
+func (logger *Logger) With(args ...any) *Logger {
+	return &Logger{handler: slog.New(logger.handler).With(args...).Handler(), level: logger.level}
 }
 
-func (logger *Logger) Fatal(v ...interface{}) {
-	logger.mutex.Lock()
-	defer logger.mutex.Unlock()
-	logger.SetPrefix("[FATAL]")
-	logger.Println(v...)
+// Для кода, которому нужен *slog.Logger
+func (logger *Logger) Slog() *slog.Logger {
+	return slog.New(logger.handler)
+}
+
+func (logger *Logger) Debug(msg string, args ...any) {
+	logger.log(slog.LevelDebug, msg, args)
+}
+
+func (logger *Logger) Info(msg string, args ...any) {
+	logger.log(slog.LevelInfo, msg, args)
+}
+
+func (logger *Logger) Warn(msg string, args ...any) {
+	logger.log(slog.LevelWarn, msg, args)
+}
+
+func (logger *Logger) Error(msg string, args ...any) {
+	logger.log(slog.LevelError, msg, args)
+}
+
+func (logger *Logger) Fatal(msg string, args ...any) {
+	logger.log(LevelFatal, msg, args)
 	os.Exit(1)
 }
+
+func (logger *Logger) log(level slog.Level, msg string, args []any) {
+	ctx := context.Background()
+	if !logger.handler.Enabled(ctx, level) {
+		return
+	}
+	// Пропускаем runtime.Callers, log и обертку уровня, чтобы source указывал на место вызова
+	var pcs [1]uintptr
+	runtime.Callers(3, pcs[:])
+	record := slog.NewRecord(time.Now(), level, msg, pcs[0])
+	record.Add(args...)
+	_ = logger.handler.Handle(ctx, record)
+}
+
+type contextKey struct{}
+
+// Логгер запроса с request_id, user_id и т.п. кладется в контекст middleware
+func NewContext(ctx context.Context, logger *Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, logger)
+}
+
+// Логгер из контекста или fallback, если его там нет
+func FromContext(ctx context.Context, fallback *Logger) *Logger {
+	if logger, ok := ctx.Value(contextKey{}).(*Logger); ok {
+		return logger
+	}
+	return fallback
+}
@@ -0,0 +1,72 @@
+package logger_module
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogger_JSONLevelsAndAttrs(t *testing.T) {
+	var out bytes.Buffer
+	logger, err := New(&out, Options{Format: FormatJSON, Level: slog.LevelInfo, AddSource: true})
+	require.NoError(t, err)
+
+	// Debug ниже минимального уровня и не пишется
+	logger.Debug("Debug noise", "key", "value")
+	assert.Empty(t, out.String())
+
+	logger.With("request_id", "req-1").Info("Subscription created", "price", 599)
+
+	var record map[string]any
+	require.NoError(t, json.Unmarshal(out.Bytes(), &record))
+	assert.Equal(t, "INFO", record["level"])
+	assert.Equal(t, "Subscription created", record["msg"])
+	assert.Equal(t, "req-1", record["request_id"])
+	assert.Equal(t, float64(599), record["price"])
+	// source указывает на место вызова, а не на сам логгер
+	source := record["source"].(map[string]any)
+	assert.True(t, strings.HasSuffix(source["file"].(string), "logger_test.go"))
+
+	// Уровень меняется и для логгеров, созданных через With
+	out.Reset()
+	logger.SetLevel(slog.LevelDebug)
+	logger.With("request_id", "req-2").Debug("Now visible")
+	assert.Contains(t, out.String(), `"level":"DEBUG"`)
+}
+
+func TestLogger_TextAndContext(t *testing.T) {
+	var out bytes.Buffer
+	logger, err := New(&out, Options{Format: FormatText})
+	require.NoError(t, err)
+	// New не трогает логгер по умолчанию, его меняет только SetDefault
+	previous := Get()
+	assert.NotSame(t, logger, previous)
+	SetDefault(logger)
+	defer SetDefault(previous)
+	assert.Same(t, logger, Get())
+	slog.Info("Via slog")
+	assert.Contains(t, out.String(), `msg="Via slog"`)
+	out.Reset()
+
+	fallback := logger.With("component", "fallback")
+	assert.Same(t, fallback, FromContext(context.Background(), fallback))
+
+	scoped := logger.With("request_id", "req-3")
+	FromContext(NewContext(context.Background(), scoped), fallback).Error("Failed", "status_code", 500)
+	assert.Contains(t, out.String(), `level=ERROR msg=Failed request_id=req-3 status_code=500`)
+
+	_, err = New(&out, Options{Format: "xml"})
+	assert.Error(t, err)
+
+	level, err := ParseLevel("warn")
+	assert.NoError(t, err)
+	assert.Equal(t, slog.LevelWarn, level)
+	_, err = ParseLevel("verbose")
+	assert.Error(t, err)
+}
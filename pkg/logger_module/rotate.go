@@ -0,0 +1,275 @@
+package logger_module
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Формат времени в имени архивного файла: app.log -> app.log.20250301T120000.000
+const backupTimeFormat = "20060102T150405.000"
+
+type RotateOptions struct {
+	MaxSize    int64         // байт; при превышении файл ротируется, 0 — без ограничения
+	MaxAge     time.Duration // файл старше ротируется, 0 — без ограничения
+	MaxBackups int           // сколько архивов хранить, 0 — все
+	Compress   bool          // сжимать архивы gzip
+}
+
+// Файл лога с ротацией. Текущий файл ротируется по размеру или возрасту, архивы
+// получают в имени время ротации, при необходимости сжимаются, лишние удаляются.
+// Сжатие и очистку архивов по очереди выполняет одна фоновая горутина, чтобы очистка
+// после следующей ротации не удалила архив, который еще сжимается.
+// Reopen переоткрывает файл по тому же пути — для внешнего logrotate по SIGHUP
+type RotatingFile struct {
+	path      string
+	opts      RotateOptions
+	mutex     sync.Mutex
+	file      *os.File
+	size      int64
+	openedAt  time.Time
+	pending   []string       // архивы, которые ждут сжатия и очистки
+	wg        sync.WaitGroup // архивы в очереди и в работе
+	wake      chan struct{}
+	stop      chan struct{}
+	stopped   chan struct{}
+	closeOnce sync.Once
+	now       func() time.Time
+}
+
+func NewRotatingFile(path string, opts RotateOptions) (*RotatingFile, error) {
+	rotating := &RotatingFile{
+		path:    path,
+		opts:    opts,
+		wake:    make(chan struct{}, 1),
+		stop:    make(chan struct{}),
+		stopped: make(chan struct{}),
+		now:     time.Now,
+	}
+	if err := rotating.open(); err != nil {
+		return nil, err
+	}
+	go rotating.maintain()
+	return rotating, nil
+}
+
+func (rotating *RotatingFile) open() error {
+	file, err := os.OpenFile(rotating.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	rotating.file = file
+	rotating.size = info.Size()
+	rotating.openedAt = rotating.now()
+	if info.Size() > 0 {
+		// Файл остался от прошлого запуска: возраст считаем от его создания, иначе сервис,
+		// который перезапускается чаще MaxAge, никогда не ротировал бы лог по возрасту.
+		// Если система не знает время создания, берем время последней записи
+		rotating.openedAt = info.ModTime()
+		if created, ok := birthTime(rotating.path); ok {
+			rotating.openedAt = created
+		}
+	}
+	return nil
+}
+
+func (rotating *RotatingFile) Write(p []byte) (int, error) {
+	rotating.mutex.Lock()
+	defer rotating.mutex.Unlock()
+
+	if rotating.file == nil {
+		return 0, os.ErrClosed
+	}
+	if rotating.needRotate(int64(len(p))) {
+		if err := rotating.rotate(); err != nil {
+			// Лог важнее ротации: пишем в текущий файл, если переименовать не вышло
+			fmt.Fprintf(os.Stderr, "log rotation failed: %v\n", err)
+		}
+	}
+	n, err := rotating.file.Write(p)
+	rotating.size += int64(n)
+	return n, err
+}
+
+func (rotating *RotatingFile) needRotate(next int64) bool {
+	if rotating.size == 0 {
+		return false
+	}
+	if rotating.opts.MaxSize > 0 && rotating.size+next > rotating.opts.MaxSize {
+		return true
+	}
+	return rotating.opts.MaxAge > 0 && rotating.now().Sub(rotating.openedAt) >= rotating.opts.MaxAge
+}
+
+// Принудительная ротация
+func (rotating *RotatingFile) Rotate() error {
+	rotating.mutex.Lock()
+	defer rotating.mutex.Unlock()
+	return rotating.rotate()
+}
+
+func (rotating *RotatingFile) rotate() error {
+	if err := rotating.file.Close(); err != nil {
+		return err
+	}
+	backup := rotating.path + "." + rotating.now().UTC().Format(backupTimeFormat)
+	renameErr := os.Rename(rotating.path, backup)
+	if err := rotating.open(); err != nil {
+		return err
+	}
+	if renameErr != nil {
+		// Файл остался прежним; следующую попытку по возрасту делаем не раньше чем через MaxAge
+		rotating.openedAt = rotating.now()
+		return renameErr
+	}
+
+	rotating.wg.Add(1)
+	rotating.pending = append(rotating.pending, backup)
+	select {
+	case rotating.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Фоновая горутина: сжимаем новые архивы и удаляем лишние. После Close дорабатывает очередь
+func (rotating *RotatingFile) maintain() {
+	defer close(rotating.stopped)
+	for {
+		select {
+		case <-rotating.wake:
+			rotating.processBackups()
+		case <-rotating.stop:
+			rotating.processBackups()
+			return
+		}
+	}
+}
+
+func (rotating *RotatingFile) processBackups() {
+	rotating.mutex.Lock()
+	backups := rotating.pending
+	rotating.pending = nil
+	rotating.mutex.Unlock()
+	if len(backups) == 0 {
+		return
+	}
+	defer rotating.wg.Add(-len(backups))
+
+	if rotating.opts.Compress {
+		for _, backup := range backups {
+			if err := compressFile(backup); err != nil {
+				fmt.Fprintf(os.Stderr, "log compression failed: %v\n", err)
+			}
+		}
+	}
+	if err := rotating.removeOldBackups(); err != nil {
+		fmt.Fprintf(os.Stderr, "log cleanup failed: %v\n", err)
+	}
+}
+
+// Закрываем и открываем файл заново: внешний logrotate уже переименовал старый
+func (rotating *RotatingFile) Reopen() error {
+	rotating.mutex.Lock()
+	defer rotating.mutex.Unlock()
+	if rotating.file != nil {
+		if err := rotating.file.Close(); err != nil {
+			return err
+		}
+	}
+	return rotating.open()
+}
+
+// Закрываем файл и ждем фонового сжатия архивов
+func (rotating *RotatingFile) Close() error {
+	rotating.mutex.Lock()
+	var err error
+	if rotating.file != nil {
+		err = rotating.file.Close()
+		rotating.file = nil
+	}
+	rotating.mutex.Unlock()
+	rotating.closeOnce.Do(func() { close(rotating.stop) })
+	<-rotating.stopped
+	return err
+}
+
+func compressFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(dst)
+	if _, err := io.Copy(gz, src); err != nil {
+		gz.Close()
+		dst.Close()
+		os.Remove(path + ".gz")
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		dst.Close()
+		os.Remove(path + ".gz")
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+// Оставляем MaxBackups самых свежих архивов; время ротации в имени сортируется как строка
+func (rotating *RotatingFile) removeOldBackups() error {
+	if rotating.opts.MaxBackups <= 0 {
+		return nil
+	}
+	backups, err := rotating.Backups()
+	if err != nil {
+		return err
+	}
+	for i := 0; i < len(backups)-rotating.opts.MaxBackups; i++ {
+		if err := os.Remove(backups[i]); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// Архивы текущего файла от старых к новым
+func (rotating *RotatingFile) Backups() ([]string, error) {
+	prefix := filepath.Base(rotating.path) + "."
+	entries, err := os.ReadDir(filepath.Dir(rotating.path))
+	if err != nil {
+		return nil, err
+	}
+	var backups []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		stamp := strings.TrimSuffix(strings.TrimPrefix(name, prefix), ".gz")
+		if _, err := time.Parse(backupTimeFormat, stamp); err != nil {
+			continue
+		}
+		backups = append(backups, filepath.Join(filepath.Dir(rotating.path), name))
+	}
+	sort.Strings(backups)
+	return backups, nil
+}
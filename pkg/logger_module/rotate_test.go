@@ -0,0 +1,192 @@
+package logger_module
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Часы для тестов: каждый вызов сдвигает время на секунду, чтобы имена архивов не совпадали
+func stepClock(start time.Time) func() time.Time {
+	current := start
+	return func() time.Time {
+		current = current.Add(time.Second)
+		return current
+	}
+}
+
+func newTestRotatingFile(t *testing.T, opts RotateOptions) (*RotatingFile, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "app.log")
+	rotating, err := NewRotatingFile(path, opts)
+	require.NoError(t, err)
+	rotating.now = stepClock(time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC))
+	t.Cleanup(func() { rotating.Close() })
+	return rotating, path
+}
+
+func TestRotatingFile_RotatesBySizeAndKeepsBackups(t *testing.T) {
+	rotating, path := newTestRotatingFile(t, RotateOptions{MaxSize: 10, MaxBackups: 2})
+
+	for _, line := range []string{"first-01\n", "second-2\n", "third-03\n", "fourth-4\n"} {
+		_, err := rotating.Write([]byte(line))
+		require.NoError(t, err)
+	}
+	rotating.wg.Wait()
+
+	current, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "fourth-4\n", string(current))
+
+	// Из трех архивов остались два последних
+	backups, err := rotating.Backups()
+	require.NoError(t, err)
+	require.Len(t, backups, 2)
+	second, err := os.ReadFile(backups[0])
+	require.NoError(t, err)
+	assert.Equal(t, "second-2\n", string(second))
+	third, err := os.ReadFile(backups[1])
+	require.NoError(t, err)
+	assert.Equal(t, "third-03\n", string(third))
+}
+
+func TestRotatingFile_Compress(t *testing.T) {
+	rotating, path := newTestRotatingFile(t, RotateOptions{Compress: true})
+
+	_, err := rotating.Write([]byte("before rotation\n"))
+	require.NoError(t, err)
+	require.NoError(t, rotating.Rotate())
+	rotating.wg.Wait()
+
+	backups, err := rotating.Backups()
+	require.NoError(t, err)
+	require.Len(t, backups, 1)
+	assert.True(t, strings.HasSuffix(backups[0], ".gz"))
+
+	file, err := os.Open(backups[0])
+	require.NoError(t, err)
+	defer file.Close()
+	gz, err := gzip.NewReader(file)
+	require.NoError(t, err)
+	content, err := io.ReadAll(gz)
+	require.NoError(t, err)
+	assert.Equal(t, "before rotation\n", string(content))
+
+	current, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Empty(t, current)
+}
+
+func TestRotatingFile_RotatesByAge(t *testing.T) {
+	rotating, path := newTestRotatingFile(t, RotateOptions{MaxAge: time.Hour})
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	rotating.now = func() time.Time { return now }
+	rotating.openedAt = now
+
+	_, err := rotating.Write([]byte("old\n"))
+	require.NoError(t, err)
+
+	// Час не прошел — пишем в тот же файл
+	now = now.Add(30 * time.Minute)
+	_, err = rotating.Write([]byte("still old\n"))
+	require.NoError(t, err)
+
+	now = now.Add(time.Hour)
+	_, err = rotating.Write([]byte("new\n"))
+	require.NoError(t, err)
+	rotating.wg.Wait()
+
+	backups, err := rotating.Backups()
+	require.NoError(t, err)
+	require.Len(t, backups, 1)
+	old, err := os.ReadFile(backups[0])
+	require.NoError(t, err)
+	assert.Equal(t, "old\nstill old\n", string(old))
+
+	current, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "new\n", string(current))
+}
+
+// Возраст файла, оставшегося от прошлого запуска, считается от его создания, а не от открытия
+func TestRotatingFile_AgeOfExistingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	require.NoError(t, os.WriteFile(path, []byte("previous run\n"), 0644))
+	created := time.Now()
+
+	rotating, err := NewRotatingFile(path, RotateOptions{MaxAge: time.Hour})
+	require.NoError(t, err)
+	defer rotating.Close()
+
+	// Перезапуск через 45 минут после создания файла
+	now := created.Add(45 * time.Minute)
+	rotating.now = func() time.Time { return now }
+	require.NoError(t, rotating.Reopen())
+	_, err = rotating.Write([]byte("restarted\n"))
+	require.NoError(t, err)
+	rotating.wg.Wait()
+	backups, err := rotating.Backups()
+	require.NoError(t, err)
+	assert.Empty(t, backups)
+
+	// С перезапуска прошло меньше часа, с создания файла — больше
+	now = created.Add(61 * time.Minute)
+	_, err = rotating.Write([]byte("new\n"))
+	require.NoError(t, err)
+	rotating.wg.Wait()
+	backups, err = rotating.Backups()
+	require.NoError(t, err)
+	require.Len(t, backups, 1)
+	old, err := os.ReadFile(backups[0])
+	require.NoError(t, err)
+	assert.Equal(t, "previous run\nrestarted\n", string(old))
+}
+
+// Частые ротации со сжатием: очистка не удаляет архив, который еще сжимается
+func TestRotatingFile_CompressAndCleanupDoNotRace(t *testing.T) {
+	rotating, path := newTestRotatingFile(t, RotateOptions{MaxSize: 10, MaxBackups: 3, Compress: true})
+
+	for i := 0; i < 50; i++ {
+		_, err := rotating.Write([]byte("line-0123\n"))
+		require.NoError(t, err)
+	}
+	require.NoError(t, rotating.Close())
+
+	backups, err := rotating.Backups()
+	require.NoError(t, err)
+	require.Len(t, backups, 3)
+	for _, backup := range backups {
+		assert.True(t, strings.HasSuffix(backup, ".gz"), backup)
+	}
+	entries, err := os.ReadDir(filepath.Dir(path))
+	require.NoError(t, err)
+	assert.Len(t, entries, 4) // app.log и три архива
+}
+
+func TestRotatingFile_ReopenAfterExternalRename(t *testing.T) {
+	rotating, path := newTestRotatingFile(t, RotateOptions{})
+
+	_, err := rotating.Write([]byte("before logrotate\n"))
+	require.NoError(t, err)
+
+	// Так делает logrotate: переименовывает файл и шлет SIGHUP
+	require.NoError(t, os.Rename(path, path+".1"))
+	require.NoError(t, rotating.Reopen())
+
+	_, err = rotating.Write([]byte("after logrotate\n"))
+	require.NoError(t, err)
+
+	rotated, err := os.ReadFile(path + ".1")
+	require.NoError(t, err)
+	assert.Equal(t, "before logrotate\n", string(rotated))
+	current, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "after logrotate\n", string(current))
+}